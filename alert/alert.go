@@ -0,0 +1,223 @@
+package alert
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/xixiliguo/etop/model"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	Firing   = "firing"
+	Resolved = "resolved"
+)
+
+// Rule is one condition over objects of a module, e.g
+//
+//   - name: disk_busy
+//     module: disk
+//     expr: Util > 90
+//     for: 1m
+//     severity: warning
+//     fields: [Util, ReadPerSec, WritePerSec]
+//
+// it fires once expr keeps true for the duration of For on the same object.
+type Rule struct {
+	Name     string        `yaml:"name"`
+	Module   string        `yaml:"module"`
+	Expr     string        `yaml:"expr"`
+	For      time.Duration `yaml:"for"`
+	Severity string        `yaml:"severity"`
+	Summary  string        `yaml:"summary"`
+	Fields   []string      `yaml:"fields"`
+	program  *vm.Program
+}
+
+// Config is content of rules file
+type Config struct {
+	Rules   []Rule `yaml:"rules"`
+	Webhook string `yaml:"webhook"` // url, events are posted as json
+	Exec    string `yaml:"exec"`    // command, event is passed by stdin as json
+}
+
+func LoadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err := yaml.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := cfg.compile(); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func (cfg *Config) compile() error {
+	names := make(map[string]bool)
+	for i := range cfg.Rules {
+		r := &cfg.Rules[i]
+		if r.Name == "" {
+			return fmt.Errorf("rule %d: name is required", i)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("rule %s: duplicate name", r.Name)
+		}
+		names[r.Name] = true
+		if r.Severity == "" {
+			r.Severity = "warning"
+		}
+		p, err := model.CompileFilter(r.Module, r.Expr)
+		if err != nil {
+			return fmt.Errorf("rule %s: %w", r.Name, err)
+		}
+		r.program = p
+	}
+	return nil
+}
+
+// Event is generated when rule on an object changes between firing and resolved
+type Event struct {
+	TimeStamp int64             `json:"timestamp"`
+	Time      string            `json:"time"`
+	Rule      string            `json:"rule"`
+	Module    string            `json:"module"`
+	Object    string            `json:"object,omitempty"`
+	Severity  string            `json:"severity"`
+	State     string            `json:"state"`
+	Since     int64             `json:"since"`
+	Summary   string            `json:"summary,omitempty"`
+	Values    map[string]string `json:"values,omitempty"`
+}
+
+func (e Event) String() string {
+	s := fmt.Sprintf("alert %s %s [%s]", e.Rule, e.State, e.Severity)
+	if e.Object != "" {
+		s += " on " + e.Object
+	}
+	if e.Summary != "" {
+		s += ": " + e.Summary
+	}
+	return s
+}
+
+type key struct {
+	rule   string
+	object string
+}
+
+type state struct {
+	since  int64 // timestamp of first sample that condition is true
+	firing bool
+	seen   bool
+}
+
+// Evaluator evaluate all rules on every sample and keep pending/firing
+// state per rule and object.
+type Evaluator struct {
+	rules     []Rule
+	states    map[key]*state
+	notifiers []Notifier
+	log       *slog.Logger
+}
+
+func NewEvaluator(cfg *Config, log *slog.Logger, notifiers ...Notifier) *Evaluator {
+	return &Evaluator{
+		rules:     cfg.Rules,
+		states:    make(map[key]*state),
+		notifiers: notifiers,
+		log:       log,
+	}
+}
+
+// Evaluate check rules against current sample of s and send events of
+// firing/resolved transitions to all notifiers.
+func (e *Evaluator) Evaluate(s *model.Model) []Event {
+	now := s.Curr.TimeStamp
+	events := []Event{}
+	for _, st := range e.states {
+		st.seen = false
+	}
+
+	for _, r := range e.rules {
+		for object, m := range s.IterateModule(r.Module) {
+			output, err := expr.Run(r.program, m)
+			if err != nil {
+				msg := fmt.Sprintf("evaluate rule %s on %s: %s", r.Name, object, err)
+				e.log.Warn(msg)
+				continue
+			}
+			k := key{rule: r.Name, object: object}
+			st := e.states[k]
+			if !output.(bool) {
+				if st != nil && st.firing {
+					events = append(events, newEvent(now, r, object, Resolved, st.since, m))
+				}
+				delete(e.states, k)
+				continue
+			}
+			if st == nil {
+				st = &state{since: now}
+				e.states[k] = st
+			}
+			st.seen = true
+			if !st.firing && time.Duration(now-st.since)*time.Second >= r.For {
+				st.firing = true
+				events = append(events, newEvent(now, r, object, Firing, st.since, m))
+			}
+		}
+	}
+
+	// object disappeared (e.g process exited), resolve it
+	for _, r := range e.rules {
+		for k, st := range e.states {
+			if k.rule != r.Name || st.seen {
+				continue
+			}
+			if st.firing {
+				events = append(events, newEvent(now, r, k.object, Resolved, st.since, nil))
+			}
+			delete(e.states, k)
+		}
+	}
+
+	for _, ev := range events {
+		for _, n := range e.notifiers {
+			if err := n.Notify(ev); err != nil {
+				msg := fmt.Sprintf("notify %s: %s", ev, err)
+				e.log.Warn(msg)
+			}
+		}
+	}
+	return events
+}
+
+func newEvent(now int64, r Rule, object string, st string, since int64, m model.Render) Event {
+	ev := Event{
+		TimeStamp: now,
+		Time:      time.Unix(now, 0).Format(time.RFC3339),
+		Rule:      r.Name,
+		Module:    r.Module,
+		Object:    object,
+		Severity:  r.Severity,
+		State:     st,
+		Since:     since,
+		Summary:   r.Summary,
+	}
+	if m != nil && len(r.Fields) != 0 {
+		ev.Values = make(map[string]string)
+		for _, f := range r.Fields {
+			if name := m.DefaultConfig(f).Name; name != "" {
+				ev.Values[name] = m.GetRenderValue(f, model.FieldOpt{})
+			}
+		}
+	}
+	return ev
+}
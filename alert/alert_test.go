@@ -0,0 +1,111 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/xixiliguo/etop/model"
+	"github.com/xixiliguo/etop/procfs"
+	"github.com/xixiliguo/etop/store"
+)
+
+const testRules = `
+rules:
+  - name: load_high
+    module: system
+    expr: Load1 > 2
+    for: 10s
+    severity: critical
+    fields: [Load1]
+  - name: disk_read
+    module: disk
+    expr: ReadPerSec > 5
+`
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.yaml")
+	os.WriteFile(path, []byte(testRules), 0644)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %s", err)
+	}
+	if len(cfg.Rules) != 2 || cfg.Rules[0].For != 10*time.Second || cfg.Rules[1].Severity != "warning" {
+		t.Errorf("unexpected config: %+v", cfg.Rules)
+	}
+
+	for _, bad := range []string{
+		"rules:\n  - name: x\n    module: foo\n    expr: true\n",
+		"rules:\n  - name: x\n    module: system\n    expr: NoSuchField > 1\n",
+		"rules:\n  - module: system\n    expr: Load1 > 1\n",
+		"rules:\n  - name: x\n    module: system\n    expr: Load1 > 1\n  - name: x\n    module: system\n    expr: Load1 > 1\n",
+	} {
+		os.WriteFile(path, []byte(bad), 0644)
+		if _, err := LoadConfig(path); err == nil {
+			t.Errorf("config should be invalid:\n%s", bad)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.yaml")
+	os.WriteFile(path, []byte(testRules), 0644)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %s", err)
+	}
+	out := &bytes.Buffer{}
+	e := NewEvaluator(cfg, slog.Default(), NewJSONNotifier(out))
+
+	sm, _ := model.NewSysModel(nil, slog.Default())
+	// load1 and read ios of sda for each sample, 5 seconds interval
+	loads := []float64{1, 3, 3, 3, 1}
+	reads := []uint64{0, 50, 100, 100, 100}
+	disks := []bool{true, true, true, true, false}
+	got := []string{}
+	for i := range loads {
+		s := store.NewSample()
+		s.TimeStamp = 100 + int64(i)*5
+		s.PageSize = 4096
+		s.LoadAvg = procfs.LoadAvg{Load1: loads[i]}
+		if disks[i] {
+			s.DiskStats["sda"] = procfs.DiskStatLine{DeviceName: "sda", ReadIOs: reads[i]}
+		}
+		if !sm.CollectSample(&s) {
+			continue
+		}
+		for _, ev := range e.Evaluate(sm) {
+			got = append(got, fmt.Sprintf("%s@%d", ev, ev.TimeStamp))
+		}
+	}
+	want := []string{
+		"alert disk_read firing [warning] on sda@105",
+		"alert load_high firing [critical]@115",
+		"alert disk_read resolved [warning] on sda@115",
+		"alert load_high resolved [critical]@120",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("events mismatch (-want +got):\n%s", diff)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("got %d json lines, but want %d", len(lines), len(want))
+	}
+	ev := Event{}
+	if err := json.Unmarshal([]byte(lines[1]), &ev); err != nil {
+		t.Fatalf("unmarshal %s: %s", lines[1], err)
+	}
+	if ev.Since != 105 || ev.TimeStamp != 115 || ev.Values["Load1"] != "3.00" {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+}
@@ -0,0 +1,175 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Notifier deliver event to somewhere
+type Notifier interface {
+	Notify(ev Event) error
+}
+
+// NotifierFunc adapt ordinary function to Notifier
+type NotifierFunc func(ev Event) error
+
+func (f NotifierFunc) Notify(ev Event) error {
+	return f(ev)
+}
+
+type LogNotifier struct {
+	log *slog.Logger
+}
+
+func NewLogNotifier(log *slog.Logger) *LogNotifier {
+	return &LogNotifier{log: log}
+}
+
+func (n *LogNotifier) Notify(ev Event) error {
+	if ev.State == Firing {
+		n.log.Warn(ev.String())
+	} else {
+		n.log.Info(ev.String())
+	}
+	return nil
+}
+
+// JSONNotifier write one event per line as json, e.g to stdout
+type JSONNotifier struct {
+	sync.Mutex
+	out io.Writer
+}
+
+func NewJSONNotifier(out io.Writer) *JSONNotifier {
+	return &JSONNotifier{out: out}
+}
+
+func (n *JSONNotifier) Notify(ev Event) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	n.Lock()
+	defer n.Unlock()
+	_, err = n.out.Write(b)
+	return err
+}
+
+// WebhookNotifier post event as json to url
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (n *WebhookNotifier) Notify(ev Event) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook %s: %s", n.url, resp.Status)
+	}
+	return nil
+}
+
+// ExecNotifier run command by sh for every event. event is passed by
+// stdin as json, and main fields by ETOP_ALERT_* environment variables
+type ExecNotifier struct {
+	command string
+	timeout time.Duration
+}
+
+func NewExecNotifier(command string) *ExecNotifier {
+	return &ExecNotifier{
+		command: command,
+		timeout: 30 * time.Second,
+	}
+}
+
+func (n *ExecNotifier) Notify(ev Event) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), n.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", n.command)
+	cmd.Stdin = bytes.NewReader(b)
+	cmd.Env = append(os.Environ(),
+		"ETOP_ALERT_RULE="+ev.Rule,
+		"ETOP_ALERT_STATE="+ev.State,
+		"ETOP_ALERT_SEVERITY="+ev.Severity,
+		"ETOP_ALERT_MODULE="+ev.Module,
+		"ETOP_ALERT_OBJECT="+ev.Object,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("exec %q: %w: %s", n.command, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// AsyncNotifier deliver events by n in background, so that slow
+// webhook or command does not delay collecting
+type AsyncNotifier struct {
+	n     Notifier
+	log   *slog.Logger
+	queue chan Event
+	wg    sync.WaitGroup
+}
+
+func NewAsyncNotifier(n Notifier, log *slog.Logger) *AsyncNotifier {
+	a := &AsyncNotifier{
+		n:     n,
+		log:   log,
+		queue: make(chan Event, 64),
+	}
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		for ev := range a.queue {
+			if err := a.n.Notify(ev); err != nil {
+				msg := fmt.Sprintf("notify %s: %s", ev, err)
+				a.log.Warn(msg)
+			}
+		}
+	}()
+	return a
+}
+
+func (a *AsyncNotifier) Notify(ev Event) error {
+	select {
+	case a.queue <- ev:
+		return nil
+	default:
+		return fmt.Errorf("queue is full, drop event")
+	}
+}
+
+// Close wait until all queued events are delivered
+func (a *AsyncNotifier) Close() {
+	close(a.queue)
+	a.wg.Wait()
+}
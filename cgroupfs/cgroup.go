@@ -632,58 +632,68 @@ type PSIData struct {
 	Total  uint64
 }
 
-func (c *Cgroup) PSIStats(file string) (PSIStats, error) {
-	fullPath := c.path(file)
+// NewPSIStats return PSIStats whose values are unknown, so that missing
+// line, e.g full of cpu on old kernel, can be told from zero
+func NewPSIStats() PSIStats {
+	unknown := PSIData{
+		Avg10:  math.MaxFloat64,
+		Avg60:  math.MaxFloat64,
+		Avg300: math.MaxFloat64,
+		Total:  math.MaxUint64,
+	}
+	return PSIStats{Some: unknown, Full: unknown}
+}
 
-	psi := PSIStats{
-		Some: PSIData{
-			Avg10:  math.MaxFloat64,
-			Avg60:  math.MaxFloat64,
-			Avg300: math.MaxFloat64,
-			Total:  math.MaxUint64,
-		},
-		Full: PSIData{
-			Avg10:  math.MaxFloat64,
-			Avg60:  math.MaxFloat64,
-			Avg300: math.MaxFloat64,
-			Total:  math.MaxUint64,
-		},
-	}
+// ParsePSILine parse one line of pressure file into psi, it is shared
+// by cgroup and /proc/pressure
+func ParsePSILine(line string, psi *PSIStats) error {
 
-	err := c.processFile(fullPath, func(i int, line string) error {
+	var fields [5]string
+	nFields := stringutil.FieldsN(line, fields[:])
+	if nFields < 5 {
+		return fmt.Errorf("unexpected line '%s'", line)
+	}
+	var psiData *PSIData
+	switch fields[0] {
+	case "some":
+		psiData = &psi.Some
+	case "full":
+		psiData = &psi.Full
+	}
+	if psiData == nil {
+		return fmt.Errorf("no some/full in '%s'", line)
+	}
 
-		var fields [5]string
-		nFields := stringutil.FieldsN(line, fields[:])
-		if nFields < 5 {
-			return fmt.Errorf("%s: unexpected line in %s: '%s'", fullPath, file, line)
+	var err error
+	for _, field := range fields[1:] {
+		idx := strings.Index(field, "=")
+		if idx == -1 {
+			return fmt.Errorf("unexpected field in '%s'", line)
 		}
-		var psiData *PSIData
-		switch fields[0] {
-		case "some":
-			psiData = &psi.Some
-		case "full":
-			psiData = &psi.Full
+		switch field[:idx] {
+		case "avg10":
+			psiData.Avg10, err = strconv.ParseFloat(field[idx+1:], 64)
+		case "avg60":
+			psiData.Avg60, err = strconv.ParseFloat(field[idx+1:], 64)
+		case "avg300":
+			psiData.Avg300, err = strconv.ParseFloat(field[idx+1:], 64)
+		case "total":
+			psiData.Total, err = strconv.ParseUint(field[idx+1:], 10, 64)
 		}
-		if psiData == nil {
-			return fmt.Errorf("%s: no some/full in %s: '%s'", fullPath, file, line)
+		if err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		var err error
-		for _, field := range fields[1:] {
-			idx := strings.Index(field, "=")
-			switch field[:idx] {
-			case "avg10":
-				psiData.Avg10, err = strconv.ParseFloat(field[idx+1:], 64)
-			case "avg60":
-				psiData.Avg60, err = strconv.ParseFloat(field[idx+1:], 64)
-			case "avg300":
-				psiData.Avg300, err = strconv.ParseFloat(field[idx+1:], 64)
-			case "total":
-				psiData.Total, err = strconv.ParseUint(field[idx+1:], 10, 64)
-			}
-			if err != nil {
-				return err
-			}
+func (c *Cgroup) PSIStats(file string) (PSIStats, error) {
+	fullPath := c.path(file)
+
+	psi := NewPSIStats()
+	err := c.processFile(fullPath, func(i int, line string) error {
+		if err := ParsePSILine(line, &psi); err != nil {
+			return fmt.Errorf("%s: %w", fullPath, err)
 		}
 		return nil
 	})
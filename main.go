@@ -3,12 +3,17 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/urfave/cli/v2"
+	"github.com/xixiliguo/etop/alert"
 	"github.com/xixiliguo/etop/model"
 	"github.com/xixiliguo/etop/store"
 	"github.com/xixiliguo/etop/tui"
@@ -75,7 +80,7 @@ var (
 			Name:    "output-format",
 			Aliases: []string{"O"},
 			Value:   "text",
-			Usage:   "output format, available value are text, json",
+			Usage:   "output format, available value are text, json, csv, ndjson",
 		},
 		&cli.BoolFlag{
 			Name:    "raw",
@@ -89,6 +94,22 @@ var (
 			Value:   "/var/log/etop",
 			Usage:   "dump data from `PATH`",
 		},
+		&cli.StringFlag{
+			Name:  "host",
+			Value: "",
+			Usage: "read data from etop serve at `HOST:PORT` instead of --path",
+		},
+		&cli.DurationFlag{
+			Name:    "aggregate",
+			Aliases: []string{"a"},
+			Value:   0,
+			Usage:   "e.g 5m, 1h. output stats of every `WINDOW` instead of every sample",
+		},
+		&cli.StringSliceFlag{
+			Name:  "stats",
+			Value: nil,
+			Usage: "`STAT` of --aggregate, available value are min, avg, max, sum, pN (e.g p95)",
+		},
 	}
 
 	dumpOtelFlag = []cli.Flag{
@@ -129,23 +150,112 @@ var (
 			Value:   "/var/log/etop",
 			Usage:   "dump data from `PATH`",
 		},
+		&cli.StringFlag{
+			Name:  "host",
+			Value: "",
+			Usage: "read data from etop serve at `HOST:PORT` instead of --path",
+		},
 	}
 )
 
-func dumpCommand(c *cli.Context, module string, fields []string) error {
-	path := c.String("path")
+// openStore return RemoteStore if --host is specified, otherwise LocalStore at --path
+func openStore(c *cli.Context) (store.Store, *slog.Logger, error) {
+	if host := c.String("host"); host != "" {
+		log := util.CreateLogger(os.Stderr, false)
+		remote, err := store.NewRemoteStore(host, log)
+		return remote, log, err
+	}
+	return openLocalStore(c.String("path"))
+}
+
+// openLocalStore return LocalStore at path, log is written to etop.log of path
+func openLocalStore(path string) (*store.LocalStore, *slog.Logger, error) {
 	path, _ = filepath.Abs(path)
 	logFile, err := os.OpenFile(filepath.Join(path, "etop.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
+	log := util.CreateLogger(logFile, false)
 	local, err := store.NewLocalStore(
-		store.WithPathAndLogger(path, util.CreateLogger(logFile, false)),
+		store.WithPathAndLogger(path, log),
 	)
+	return local, log, err
+}
+
+// listenPrometheus serve /metrics at listen
+func listenPrometheus(listen string, top int, log *slog.Logger) (*model.PromExporter, error) {
+	exporter := model.NewPromExporter(top)
+
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", exporter)
+	go func() {
+		if err := http.Serve(ln, mux); err != nil {
+			msg := fmt.Sprintf("serve prometheus metrics: %s", err)
+			log.Error(msg)
+		}
+	}()
+	msg := fmt.Sprintf("expose prometheus metrics at %s/metrics", ln.Addr())
+	log.Info(msg)
+	return exporter, nil
+}
+
+// isLoopback report whether host of listen address is loopback, empty
+// host means all addresses
+func isLoopback(listen string) bool {
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// newAlertEvaluator load rules from path, events are written to log and out
+// (if not nil) and extra notifiers, and delivered to webhook/exec in background
+// if configured.
+// returned func should be called to wait all events are delivered
+func newAlertEvaluator(path string, out io.Writer, log *slog.Logger, extra ...alert.Notifier) (*alert.Evaluator, func(), error) {
+	cfg, err := alert.LoadConfig(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	notifiers := append([]alert.Notifier{alert.NewLogNotifier(log)}, extra...)
+	if out != nil {
+		notifiers = append(notifiers, alert.NewJSONNotifier(out))
+	}
+	closers := []func(){}
+	if cfg.Webhook != "" {
+		a := alert.NewAsyncNotifier(alert.NewWebhookNotifier(cfg.Webhook), log)
+		notifiers = append(notifiers, a)
+		closers = append(closers, a.Close)
+	}
+	if cfg.Exec != "" {
+		a := alert.NewAsyncNotifier(alert.NewExecNotifier(cfg.Exec), log)
+		notifiers = append(notifiers, a)
+		closers = append(closers, a.Close)
+	}
+	msg := fmt.Sprintf("load %d alert rules from %s", len(cfg.Rules), path)
+	log.Info(msg)
+	return alert.NewEvaluator(cfg, log, notifiers...), func() {
+		for _, c := range closers {
+			c()
+		}
+	}, nil
+}
+
+func dumpCommand(c *cli.Context, module string, fields []string) error {
+	st, log, err := openStore(c)
 	if err != nil {
 		return err
 	}
-	sm, err := model.NewSysModel(local, util.CreateLogger(logFile, false))
+	sm, err := model.NewSysModel(st, log)
 	if err != nil {
 		return err
 	}
@@ -191,24 +301,83 @@ func dumpCommand(c *cli.Context, module string, fields []string) error {
 		DisableTitle:    c.Bool("disable-title"),
 		RepeatTitle:     c.Int("repeat-title"),
 		RawData:         c.Bool("raw"),
+		Aggregate:       c.Duration("aggregate"),
+		Stats:           c.StringSlice("stats"),
 	}
 	return sm.Dump(opt)
 }
 
-func dumpToOtel(c *cli.Context) error {
-	path := c.String("path")
-	path, _ = filepath.Abs(path)
-	logFile, err := os.OpenFile(filepath.Join(path, "etop.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+// collectWindow average samples of window (e.g "10:00+15m") from path,
+// which is data directory or snapshot file
+func collectWindow(path string, window string, modules []string) (*model.Window, error) {
+	begin, end, err := util.ParseTimeWindow(window)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	local, err := store.NewLocalStore(
-		store.WithPathAndLogger(path, util.CreateLogger(logFile, false)),
-	)
+	if fi, err := os.Stat(path); err == nil && fi.Mode().IsRegular() {
+		if path, err = util.ExtractFileFromTar(path); err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(path)
+	}
+	st, log, err := openLocalStore(path)
+	if err != nil {
+		return nil, err
+	}
+	defer st.Close()
+	sm, err := model.NewSysModel(st, log)
+	if err != nil {
+		return nil, err
+	}
+	return sm.CollectWindow(begin, end, modules)
+}
+
+func diffCommand(c *cli.Context) error {
+	modules := model.DiffModules
+	if m := c.StringSlice("modules"); len(m) != 0 {
+		modules = m
+	}
+	pathB := c.String("path-b")
+	if pathB == "" {
+		pathB = c.String("path")
+	}
+	windowB := c.String("b")
+	if windowB == "" {
+		windowB = c.String("a")
+	}
+	a, err := collectWindow(c.String("path"), c.String("a"), modules)
+	if err != nil {
+		return fmt.Errorf("window a: %w", err)
+	}
+	b, err := collectWindow(pathB, windowB, modules)
+	if err != nil {
+		return fmt.Errorf("window b: %w", err)
+	}
+
+	output := os.Stdout
+	if out := c.String("output"); out != "" {
+		if output, err = os.OpenFile(out, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+			return err
+		}
+	}
+	opt := model.DiffOption{
+		Modules:    modules,
+		Top:        c.Int("top"),
+		TopProcess: c.Int("top-process"),
+		Highlight:  c.Float64("highlight") / 100,
+		Output:     output,
+		Format:     c.String("output-format"),
+		RawData:    c.Bool("raw"),
+	}
+	return model.WriteDiff(a, b, model.Diff(a, b, opt), opt)
+}
+
+func dumpToOtel(c *cli.Context) error {
+	st, log, err := openStore(c)
 	if err != nil {
 		return err
 	}
-	sm, err := model.NewSysModel(local, util.CreateLogger(logFile, false))
+	sm, err := model.NewSysModel(st, log)
 	if err != nil {
 		return err
 	}
@@ -285,6 +454,17 @@ func main() {
 							return nil
 						},
 					},
+					&cli.BoolFlag{
+						Name:  "compress-delta",
+						Value: false,
+						Usage: "encode processes and cgroups as delta against previous sample, --compress-dict-chunk-size is length of delta chain",
+						Action: func(c *cli.Context, b bool) error {
+							if b && c.Int("compress-dict-chunk-size") == 0 {
+								return fmt.Errorf("compress-delta only is valid with --compress-dict-chunk-size")
+							}
+							return nil
+						},
+					},
 					&cli.IntFlag{
 						Name:  "retainday",
 						Value: 3,
@@ -296,6 +476,97 @@ func main() {
 						DefaultText: "20 GB",
 						Usage:       "size limit in bytes for retaining data file, detele oldest one if exceed `THRESHOLD`",
 					},
+					&cli.StringFlag{
+						Name:  "retain-tiers",
+						Value: "",
+						Usage: "downsample old data by `TIERS`, e.g 3d,30d:1m,365d:10m keep all samples for 3 days,\n" +
+							"			one per minute for 30 days and one per 10 minutes for a year. it override --retainday",
+					},
+					&cli.IntFlag{
+						Name:  "retain-top-process",
+						Value: 50,
+						Usage: "keep top `N` processes by cpu in downsampled data, 0 means all, only valid when --retain-tiers",
+					},
+					&cli.StringFlag{
+						Name:  "listen",
+						Value: "",
+						Usage: "expose latest sample in prometheus format at http://`ADDRESS`/metrics",
+					},
+					&cli.IntFlag{
+						Name:  "listen-top-process",
+						Value: 20,
+						Usage: "export top `N` processes order by cpu, only valid when --listen",
+					},
+					&cli.StringFlag{
+						Name:  "otel-endpoint",
+						Value: "",
+						Usage: "push every sample to OTLP/HTTP receiver at `ENDPOINT` (host:port or url)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "otel-header",
+						Usage: "`KEY=VALUE` header sent with every OTLP request, can be repeated",
+					},
+					&cli.StringSliceFlag{
+						Name:  "otel-resource-attr",
+						Usage: "`KEY=VALUE` resource attribute attached to all metrics, can be repeated",
+					},
+					&cli.IntFlag{
+						Name:  "otel-batch",
+						Value: 1,
+						Usage: "send `N` samples in one OTLP request",
+					},
+					&cli.BoolFlag{
+						Name:  "otel-insecure",
+						Value: false,
+						Usage: "use http instead of https when --otel-endpoint is host:port",
+					},
+					&cli.StringFlag{
+						Name:  "alert-rules",
+						Value: "",
+						Usage: "evaluate alert rules from YAML `FILE` on every sample, events are printed to stdout as json",
+					},
+					&cli.BoolFlag{
+						Name:  "threads",
+						Value: false,
+						Usage: "collect threads of processes which use most cpu",
+					},
+					&cli.IntFlag{
+						Name:  "threads-top",
+						Value: 10,
+						Usage: "collect threads of top `N` processes by cpu, only valid when --threads",
+					},
+					&cli.BoolFlag{
+						Name:  "smaps",
+						Value: false,
+						Usage: "read smaps_rollup (Pss, SwapPss) of processes which use most memory, it is expensive for processes with many mappings",
+					},
+					&cli.IntFlag{
+						Name:  "smaps-top",
+						Value: 10,
+						Usage: "read smaps_rollup of top `N` processes by rss, only valid when --smaps",
+					},
+					&cli.StringSliceFlag{
+						Name:  "modules",
+						Usage: "collect only `MODULES`, default all: " + strings.Join(store.Modules, ",") + ". stat is always collected",
+					},
+					&cli.StringSliceFlag{
+						Name:  "module-interval",
+						Usage: "collect module every `MODULE=DURATION` instead of every sample, e.g cgroup=30s, can be repeated",
+					},
+					&cli.StringSliceFlag{
+						Name:  "skip-fs-types",
+						Usage: "skip filesystems of `TYPES` in module filesystem, default: " + strings.Join(store.DefaultSkipFSTypes, ","),
+					},
+					&cli.IntFlag{
+						Name:  "sync-every",
+						Value: 0,
+						Usage: "fsync data and index file every `N` samples, 0 means leave it to kernel",
+					},
+					&cli.DurationFlag{
+						Name:  "sync-interval",
+						Value: 0,
+						Usage: "fsync data and index file if last fsync is older than `DURATION`, e.g 1m, 0 means never",
+					},
 				},
 				Action: func(c *cli.Context) error {
 					intervalFlag := c.Int("interval")
@@ -310,6 +581,9 @@ func main() {
 					if retainsizeFlag <= 0 {
 						return fmt.Errorf("retainday flag shoud great than 0, but get %d\n", retainsizeFlag)
 					}
+					if c.Int("sync-every") < 0 || c.Duration("sync-interval") < 0 {
+						return fmt.Errorf("sync-every and sync-interval flag should not be negative\n")
+					}
 					path := c.String("path")
 					path, _ = filepath.Abs(path)
 					if _, err := os.Stat(path); os.IsNotExist(err) {
@@ -334,13 +608,36 @@ func main() {
 					chunk := uint32(c.Int("compress-dict-chunk-size"))
 					if chunk != 0 {
 						mode = store.ZstdCompressWithDict
+						if c.Bool("compress-delta") {
+							mode = store.ZstdCompressWithDelta
+						}
 					}
-					local, err := store.NewLocalStore(
+					opts := []store.Option{
 						store.WithPathAndLogger(path, log),
 						store.WithWriteOnly(mode, chunk),
 						store.WithExitProcess(log),
 						store.WithCgroupNetStat(log),
-					)
+						store.WithSync(uint32(c.Int("sync-every")), c.Duration("sync-interval")),
+					}
+					intervals, err := store.ParseModuleIntervals(c.StringSlice("module-interval"))
+					if err != nil {
+						return err
+					}
+					sched, err := store.NewSchedule(c.StringSlice("modules"), intervals)
+					if err != nil {
+						return err
+					}
+					opts = append(opts, store.WithSchedule(sched))
+					if c.Bool("threads") {
+						opts = append(opts, store.WithThreads(c.Int("threads-top")))
+					}
+					if c.Bool("smaps") {
+						opts = append(opts, store.WithSmaps(c.Int("smaps-top")))
+					}
+					if types := c.StringSlice("skip-fs-types"); len(types) != 0 {
+						opts = append(opts, store.WithSkipFSTypes(types))
+					}
+					local, err := store.NewLocalStore(opts...)
 					if err != nil {
 						return err
 					}
@@ -348,7 +645,92 @@ func main() {
 						Interval:   time.Duration(intervalFlag) * time.Second,
 						RetainDay:  retaindayFlag,
 						RetainSize: retainsizeFlag,
+						TopProcess: c.Int("retain-top-process"),
 					}
+					if tiers := c.String("retain-tiers"); tiers != "" {
+						if opt.Tiers, err = store.ParseTiers(tiers); err != nil {
+							return err
+						}
+						// data older than last tier is deleted
+						last := opt.Tiers[len(opt.Tiers)-1].Age
+						opt.RetainDay = int((last + 24*time.Hour - 1) / (24 * time.Hour))
+					}
+					// consumers of computed model, which is updated by every sample
+					consumers := []func(sm *model.Model){}
+					if listen := c.String("listen"); listen != "" {
+						exporter, err := listenPrometheus(listen, c.Int("listen-top-process"), log)
+						if err != nil {
+							return err
+						}
+						consumers = append(consumers, exporter.Update)
+					}
+					if endpoint := c.String("otel-endpoint"); endpoint != "" {
+						headers, err := model.ParseKeyValues(c.StringSlice("otel-header"))
+						if err != nil {
+							return err
+						}
+						attrs, err := model.ParseKeyValues(c.StringSlice("otel-resource-attr"))
+						if err != nil {
+							return err
+						}
+						pusher, err := model.NewOtelPusher(model.OtelOption{
+							Endpoint:   endpoint,
+							Insecure:   c.Bool("otel-insecure"),
+							Headers:    headers,
+							Attributes: attrs,
+							BatchSize:  c.Int("otel-batch"),
+						}, log)
+						if err != nil {
+							return err
+						}
+						defer pusher.Shutdown()
+						consumers = append(consumers, pusher.Push)
+						msg := fmt.Sprintf("push otel metrics to %s", endpoint)
+						log.Info(msg)
+					}
+					// events are stored alongside samples
+					writeEvent := func(e store.Event) {
+						if err := local.WriteEvent(&e); err != nil {
+							msg := fmt.Sprintf("write %s event: %s", e.Kind, err)
+							log.Warn(msg)
+						}
+					}
+					consumers = append(consumers, func(sm *model.Model) {
+						for _, e := range sm.DetectEvents() {
+							writeEvent(e)
+						}
+					})
+					if rules := c.String("alert-rules"); rules != "" {
+						toStore := alert.NotifierFunc(func(ev alert.Event) error {
+							writeEvent(store.Event{
+								TimeStamp: ev.TimeStamp,
+								Kind:      store.EventAlert,
+								Severity:  ev.Severity,
+								Object:    ev.Object,
+								Message:   ev.String(),
+							})
+							return nil
+						})
+						evaluator, wait, err := newAlertEvaluator(rules, os.Stdout, log, toStore)
+						if err != nil {
+							return err
+						}
+						defer wait()
+						consumers = append(consumers, func(sm *model.Model) {
+							evaluator.Evaluate(sm)
+						})
+					}
+					sm, err := model.NewSysModel(nil, log)
+					if err != nil {
+						return err
+					}
+					opt.Hooks = append(opt.Hooks, func(s *store.Sample) {
+						if sm.CollectSample(s) {
+							for _, consume := range consumers {
+								consume(sm)
+							}
+						}
+					})
 					if err := local.WriteLoop(opt); err != nil {
 						return err
 					}
@@ -386,8 +768,29 @@ func main() {
 						Value: "",
 						Usage: "read data from snapshot `FILE`",
 					},
+					&cli.StringFlag{
+						Name:  "host",
+						Value: "",
+						Usage: "read data from etop serve at `HOST:PORT` instead of --path",
+					},
 				},
 				Action: func(c *cli.Context) error {
+					if host := c.String("host"); host != "" {
+						if c.Bool("stat") == true {
+							remote, err := store.NewRemoteStore(host, util.CreateLogger(os.Stdout, false))
+							if err != nil {
+								return err
+							}
+							result, err := remote.FileStatInfo()
+							if err != nil {
+								return err
+							}
+							fmt.Println(result)
+							return nil
+						}
+						t := tui.NewTUI()
+						return t.RunWithRemote(host, c.String("begin"))
+					}
 					path := c.String("path")
 					if snapshot := c.String("snapshot"); snapshot != "" {
 						if tempPath, err := util.ExtractFileFromTar(snapshot); err != nil {
@@ -420,6 +823,54 @@ func main() {
 					return nil
 				},
 			},
+			{
+				Name:  "serve",
+				Usage: "Serve recorded data over http, so that report/dump can read it with --host",
+				Description: "data includes full process table, e.g command lines and users of processes.\n" +
+					"there is no authentication, so listen on loopback address by default. only listen\n" +
+					"on other address in trusted network, or put it behind a proxy with authentication",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "path",
+						Aliases: []string{"p"},
+						Value:   "/var/log/etop",
+						Usage:   "serve data from `PATH`",
+					},
+					&cli.StringFlag{
+						Name:    "listen",
+						Aliases: []string{"l"},
+						Value:   "127.0.0.1:9800",
+						Usage:   "`ADDRESS` to listen on, e.g :9800 for all addresses. data is served without authentication",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					path := c.String("path")
+					path, _ = filepath.Abs(path)
+					log := util.CreateLogger(os.Stdout, false)
+					local, err := store.NewLocalStore(
+						store.WithPathAndLogger(path, log),
+					)
+					if err != nil {
+						return err
+					}
+					defer local.Close()
+					listen := c.String("listen")
+					msg := fmt.Sprintf("serve data from %s on %s", path, listen)
+					log.Info(msg)
+					if !isLoopback(listen) {
+						msg := fmt.Sprintf("%s is not loopback address, data is exposed without authentication", listen)
+						log.Warn(msg)
+					}
+					server := &http.Server{
+						Addr:              listen,
+						Handler:           store.NewServer(local, log),
+						ReadHeaderTimeout: 10 * time.Second,
+						WriteTimeout:      time.Minute,
+						IdleTimeout:       time.Minute,
+					}
+					return server.ListenAndServe()
+				},
+			},
 			{
 				Name:  "live",
 				Usage: "Live display",
@@ -430,6 +881,31 @@ func main() {
 						Value:   5,
 						Usage:   "number of seconds between samples",
 					},
+					&cli.StringFlag{
+						Name:  "alert-rules",
+						Value: "",
+						Usage: "evaluate alert rules from YAML `FILE` on every sample, events are shown in status bar",
+					},
+					&cli.BoolFlag{
+						Name:  "threads",
+						Value: false,
+						Usage: "collect threads of processes which use most cpu",
+					},
+					&cli.IntFlag{
+						Name:  "threads-top",
+						Value: 10,
+						Usage: "collect threads of top `N` processes by cpu, only valid when --threads",
+					},
+					&cli.BoolFlag{
+						Name:  "smaps",
+						Value: false,
+						Usage: "read smaps_rollup (Pss, SwapPss) of processes which use most memory, it is expensive for processes with many mappings",
+					},
+					&cli.IntFlag{
+						Name:  "smaps-top",
+						Value: 10,
+						Usage: "read smaps_rollup of top `N` processes by rss, only valid when --smaps",
+					},
 				},
 				Action: func(c *cli.Context) error {
 					internal := c.Int("interval")
@@ -438,7 +914,26 @@ func main() {
 						os.Exit(1)
 					}
 					t := tui.NewTUI()
-					if err := t.RunWithLive(time.Duration(internal) * time.Second); err != nil {
+					hooks := []func(sm *model.Model){}
+					if rules := c.String("alert-rules"); rules != "" {
+						evaluator, wait, err := newAlertEvaluator(rules, nil, t.Logger())
+						if err != nil {
+							return err
+						}
+						defer wait()
+						hooks = append(hooks, func(sm *model.Model) {
+							evaluator.Evaluate(sm)
+						})
+					}
+					threadTop := 0
+					if c.Bool("threads") {
+						threadTop = c.Int("threads-top")
+					}
+					smapsTop := 0
+					if c.Bool("smaps") {
+						smapsTop = c.Int("smaps-top")
+					}
+					if err := t.RunWithLive(time.Duration(internal)*time.Second, threadTop, smapsTop, hooks...); err != nil {
 						return err
 					}
 					return nil
@@ -526,10 +1021,13 @@ func main() {
 				Subcommands: []*cli.Command{
 					{
 						Name:  "system",
-						Usage: "Dump system stat",
+						Usage: "Dump system stat, --all include system-wide pressure",
 						Flags: dumpFlag,
 						Action: func(c *cli.Context) error {
 							fs := model.DefaultSystemFields
+							if c.Bool("all") == true {
+								fs = model.AllSystemFields
+							}
 							if f := c.StringSlice("fields"); len(f) != 0 {
 								fs = f
 							}
@@ -584,6 +1082,18 @@ func main() {
 							return dumpCommand(c, "disk", fs)
 						},
 					},
+					{
+						Name:  "filesystem",
+						Usage: "Dump capacity and inode usage of filesystems",
+						Flags: dumpFlag,
+						Action: func(c *cli.Context) error {
+							fs := model.DefaultFilesystemFields
+							if f := c.StringSlice("fields"); len(f) != 0 {
+								fs = f
+							}
+							return dumpCommand(c, "filesystem", fs)
+						},
+					},
 					{
 						Name:  "netdev",
 						Usage: "Dump netdev stat",
@@ -608,6 +1118,42 @@ func main() {
 							return dumpCommand(c, "networkprotocol", fs)
 						},
 					},
+					{
+						Name:  "snmp",
+						Usage: "Dump rate of tcp/udp/ip counters, any counter like TcpExt.TCPTimeouts can be field",
+						Flags: dumpFlag,
+						Action: func(c *cli.Context) error {
+							fs := model.DefaultNetSNMPFields
+							if f := c.StringSlice("fields"); len(f) != 0 {
+								fs = f
+							}
+							return dumpCommand(c, "snmp", fs)
+						},
+					},
+					{
+						Name:  "socket",
+						Usage: "Dump count of tcp sockets by state, udp and unix sockets",
+						Flags: dumpFlag,
+						Action: func(c *cli.Context) error {
+							fs := model.DefaultSocketFields
+							if f := c.StringSlice("fields"); len(f) != 0 {
+								fs = f
+							}
+							return dumpCommand(c, "socket", fs)
+						},
+					},
+					{
+						Name:  "connection",
+						Usage: "Dump listening sockets and top connections by queued bytes with owner",
+						Flags: dumpFlag,
+						Action: func(c *cli.Context) error {
+							fs := model.DefaultConnectionFields
+							if f := c.StringSlice("fields"); len(f) != 0 {
+								fs = f
+							}
+							return dumpCommand(c, "connection", fs)
+						},
+					},
 					{
 						Name:  "softnet",
 						Usage: "Dump softnet stat",
@@ -620,6 +1166,30 @@ func main() {
 							return dumpCommand(c, "softnet", fs)
 						},
 					},
+					{
+						Name:  "interrupt",
+						Usage: "Dump rate of interrupts, per cpu rate is field like CPU0",
+						Flags: dumpFlag,
+						Action: func(c *cli.Context) error {
+							fs := model.DefaultInterruptFields
+							if f := c.StringSlice("fields"); len(f) != 0 {
+								fs = f
+							}
+							return dumpCommand(c, "interrupt", fs)
+						},
+					},
+					{
+						Name:  "softirq",
+						Usage: "Dump rate of softirqs, per cpu rate is field like CPU0",
+						Flags: dumpFlag,
+						Action: func(c *cli.Context) error {
+							fs := model.DefaultSoftirqFields
+							if f := c.StringSlice("fields"); len(f) != 0 {
+								fs = f
+							}
+							return dumpCommand(c, "softirq", fs)
+						},
+					},
 					{
 						Name:  "process",
 						Usage: "Dump process stat",
@@ -656,6 +1226,42 @@ func main() {
 							return dumpCommand(c, "process", fs)
 						},
 					},
+					{
+						Name:  "thread",
+						Usage: "Dump thread stat, only available when etop record --threads",
+						Flags: append(dumpFlag,
+							&cli.StringFlag{
+								Name:  "sort",
+								Value: "CPU",
+								Usage: "sort `FIELD` by descending order",
+							},
+							&cli.BoolFlag{
+								Name:    "ascending-order",
+								Aliases: nil,
+								Value:   false,
+								Usage:   "sort by ascending order",
+							},
+							&cli.IntFlag{
+								Name:  "top",
+								Value: 0,
+								Usage: "show top `N` info",
+							},
+							&cli.BoolFlag{
+								Name:  "all",
+								Value: false,
+								Usage: "dump all fields",
+							}),
+						Action: func(c *cli.Context) error {
+							fs := model.DefaultThreadFields
+							if c.Bool("all") == true {
+								fs = model.AllThreadFields
+							}
+							if f := c.StringSlice("fields"); len(f) != 0 {
+								fs = f
+							}
+							return dumpCommand(c, "thread", fs)
+						},
+					},
 					{
 						Name:  "cgroup",
 						Usage: "Dump cgroup stat",
@@ -671,6 +1277,45 @@ func main() {
 							return dumpCommand(c, "cgroup", fs)
 						},
 					},
+					{
+						Name:  "etop",
+						Usage: "Dump overhead of etop record itself, e.g cpu, memory, collect time and bytes per sample",
+						Flags: dumpFlag,
+						Action: func(c *cli.Context) error {
+							fs := model.DefaultEtopFields
+							if c.Bool("all") == true {
+								fs = model.AllEtopFields
+							}
+							if f := c.StringSlice("fields"); len(f) != 0 {
+								fs = f
+							}
+							return dumpCommand(c, "etop", fs)
+						},
+					},
+					{
+						Name:  "events",
+						Usage: "Dump events, e.g oom kill, reboot and alerts",
+						Flags: dumpFlag,
+						Action: func(c *cli.Context) error {
+							fs := model.DefaultEventFields
+							if f := c.StringSlice("fields"); len(f) != 0 {
+								fs = f
+							}
+							return dumpCommand(c, "event", fs)
+						},
+					},
+					{
+						Name:  "inventory",
+						Usage: "Dump host inventory, e.g cpu, numa nodes, disks and nics",
+						Flags: dumpFlag,
+						Action: func(c *cli.Context) error {
+							fs := model.DefaultInventoryFields
+							if f := c.StringSlice("fields"); len(f) != 0 {
+								fs = f
+							}
+							return dumpCommand(c, "inventory", fs)
+						},
+					},
 					{
 						Name:  "otel",
 						Usage: "Dump and send to otel backend",
@@ -681,6 +1326,69 @@ func main() {
 					},
 				},
 			},
+			{
+				Name:  "diff",
+				Usage: "Compare two time windows, of the same or different hosts",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "a",
+						Usage:    "`WINDOW` TIME+DURATION, e.g 10:00+15m, yesterday 10:00+15m, 2006-01-02 15:04+1h",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "b",
+						Usage: "`WINDOW` compared with --a, same format with --a. default is --a, used with --path-b",
+					},
+					&cli.StringFlag{
+						Name:    "path",
+						Aliases: []string{"p"},
+						Value:   "/var/log/etop",
+						Usage:   "read window a from `PATH`, which is data directory or snapshot file",
+					},
+					&cli.StringFlag{
+						Name:  "path-b",
+						Usage: "read window b from `PATH`, e.g another host. default is --path",
+					},
+					&cli.StringSliceFlag{
+						Name:    "modules",
+						Aliases: []string{"m"},
+						Usage:   "compare `MODULES`, available are system, memory, disk, netdev, process, cgroup. default is all",
+					},
+					&cli.IntFlag{
+						Name:  "top",
+						Value: 10,
+						Usage: "show `N` fields with biggest relative change per module, 0 means all",
+					},
+					&cli.IntFlag{
+						Name:  "top-process",
+						Value: 10,
+						Usage: "only compare top `N` processes (grouped by comm) by cpu of either window, 0 means all",
+					},
+					&cli.Float64Flag{
+						Name:  "highlight",
+						Value: 50,
+						Usage: "mark fields which relative change is at least `PERCENT`",
+					},
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Value:   "",
+						Usage:   "output destination, default to stdout",
+					},
+					&cli.StringFlag{
+						Name:    "output-format",
+						Aliases: []string{"O"},
+						Value:   "text",
+						Usage:   "output format, available value are text, json, csv, ndjson",
+					},
+					&cli.BoolFlag{
+						Name:  "raw",
+						Value: false,
+						Usage: "show raw data without units or conversion",
+					},
+				},
+				Action: diffCommand,
+			},
 			{
 				Name:  "debug",
 				Usage: "Provides various debug feature",
@@ -769,6 +1477,90 @@ func main() {
 							return nil
 						},
 					},
+					{
+						Name:  "fsck",
+						Usage: "Check index and data files of store, and repair them with --repair",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "path",
+								Aliases: []string{"p"},
+								Value:   "/var/log/etop",
+								Usage:   "check files at `PATH`",
+							},
+							&cli.BoolFlag{
+								Name:  "repair",
+								Value: false,
+								Usage: "rebuild index from good frames and truncate torn tail of data file",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							path, _ := filepath.Abs(c.String("path"))
+							results, err := store.Fsck(path, c.Bool("repair"), util.CreateLogger(os.Stdout, false))
+							if err != nil {
+								return err
+							}
+							if len(results) == 0 {
+								fmt.Printf("no data at %s\n", path)
+							}
+							bad := 0
+							for _, r := range results {
+								fmt.Println(r.String())
+								if !r.OK() && !r.Repaired {
+									bad++
+								}
+							}
+							if bad != 0 {
+								return fmt.Errorf("%d of %d shards have errors, run with --repair to fix", bad, len(results))
+							}
+							return nil
+						},
+					},
+					{
+						Name:  "convert",
+						Usage: "Rewrite all files of store with another compress mode, etop record should be stopped",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "path",
+								Aliases: []string{"p"},
+								Value:   "/var/log/etop",
+								Usage:   "convert files at `PATH`",
+							},
+							&cli.StringFlag{
+								Name:  "compress-mode",
+								Value: "delta",
+								Usage: "`MODE` of new files, available value are none, zstd, dict, delta",
+							},
+							&cli.IntFlag{
+								Name:  "chunk",
+								Value: 64,
+								Usage: "number of samples which share one dict or delta chain",
+								Action: func(c *cli.Context, n int) error {
+									if n < 1 || n > store.MaxDictOffset {
+										return fmt.Errorf("chunk must be between 1 and %d", store.MaxDictOffset)
+									}
+									return nil
+								},
+							},
+						},
+						Action: func(c *cli.Context) error {
+							mode, err := store.ParseCompressMode(c.String("compress-mode"))
+							if err != nil {
+								return err
+							}
+							path, _ := filepath.Abs(c.String("path"))
+							results, err := store.Convert(path, mode, uint32(c.Int("chunk")), util.CreateLogger(os.Stdout, false))
+							for _, r := range results {
+								fmt.Println(r.String())
+							}
+							if err != nil {
+								return err
+							}
+							if len(results) == 0 {
+								fmt.Printf("no data at %s\n", path)
+							}
+							return nil
+						},
+					},
 				},
 			},
 		},
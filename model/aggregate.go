@@ -0,0 +1,367 @@
+package model
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xixiliguo/etop/store"
+)
+
+// DefaultStats is used when --aggregate is specified without --stats
+var DefaultStats = []string{"min", "avg", "max"}
+
+// ParseStats verify stats, available are min, avg, max, sum and pN (e.g p95)
+func ParseStats(stats []string) ([]string, error) {
+	res := []string{}
+	for _, s := range stats {
+		for _, stat := range strings.Split(s, ",") {
+			stat = strings.TrimSpace(stat)
+			switch stat {
+			case "min", "avg", "max", "sum":
+			default:
+				n, err := strconv.Atoi(strings.TrimPrefix(stat, "p"))
+				if !strings.HasPrefix(stat, "p") || err != nil || n <= 0 || n > 100 {
+					return nil, fmt.Errorf("no support stat: %s", stat)
+				}
+			}
+			res = append(res, stat)
+		}
+	}
+	if len(res) == 0 {
+		return DefaultStats, nil
+	}
+	return res, nil
+}
+
+// iterateDump yield objects of module which match filter in current sample,
+// key is natural identity of object, e.g cpu index, pid+starttime,
+// cgroup path. processes and threads are sorted and limited by top, set
+// opt.Top to 0 to get all of them.
+func (s *Model) iterateDump(opt DumpOption) iter.Seq2[string, Render] {
+	return func(yield func(string, Render) bool) {
+		if opt.Module == "thread" {
+			cnt := 0
+			for _, t := range s.Threads.Iterate(0, nil, opt.SortField, opt.DescendingOrder) {
+				if !isFilter(opt, t) {
+					continue
+				}
+				if !yield(fmt.Sprintf("%d-%d", t.Tid, t.StartTime), t) {
+					return
+				}
+				cnt++
+				if opt.Top > 0 && opt.Top == cnt {
+					return
+				}
+			}
+			return
+		}
+		if opt.Module != "process" {
+			for key, m := range s.IterateModule(opt.Module) {
+				if isFilter(opt, m) && !yield(key, m) {
+					return
+				}
+			}
+			return
+		}
+		cnt := 0
+		for _, p := range s.Processes.Iterate(nil, opt.SortField, opt.DescendingOrder) {
+			if !isFilter(opt, p) {
+				continue
+			}
+			if !yield(fmt.Sprintf("%d-%d", p.Pid, p.StartTime), p) {
+				return
+			}
+			cnt++
+			if opt.Top > 0 && opt.Top == cnt {
+				return
+			}
+		}
+	}
+}
+
+// aggObject keep values of one object in current window
+type aggObject struct {
+	key    string
+	m      Render      // used to get config of fields
+	values [][]float64 // numeric values per field
+	seen   []int       // number of non-empty values per field
+	last   []string    // last raw value per field, for non-numeric field
+
+	// values of opt.SortField, used to rank processes and threads
+	sortValues []float64
+	sortLast   string
+}
+
+// aggregator fold values of objects into fixed time window
+type aggregator struct {
+	opt     DumpOption
+	objs    map[string]*aggObject
+	order   []*aggObject // order of first seen in window
+	start   int64        // start time of current window
+	started bool
+}
+
+func newAggregator(opt DumpOption) *aggregator {
+	return &aggregator{
+		opt:  opt,
+		objs: make(map[string]*aggObject),
+	}
+}
+
+// windowStart return start of window which timeStamp belongs to,
+// window is aligned with local time, e.g 1h window starts at xx:00
+func windowStart(timeStamp int64, window time.Duration) int64 {
+	_, offset := time.Unix(timeStamp, 0).Zone()
+	w := int64(window / time.Second)
+	return timeStamp - (timeStamp+int64(offset))%w
+}
+
+func (a *aggregator) add(key string, m Render) {
+	obj := a.objs[key]
+	if obj == nil {
+		obj = &aggObject{
+			key:    key,
+			values: make([][]float64, len(a.opt.Fields)),
+			seen:   make([]int, len(a.opt.Fields)),
+			last:   make([]string, len(a.opt.Fields)),
+		}
+		a.objs[key] = obj
+		a.order = append(a.order, obj)
+	}
+	obj.m = m
+	if a.ranked() {
+		if raw := m.GetRenderValue(a.opt.SortField, FieldOpt{Raw: true}); raw != "-" {
+			obj.sortLast = raw
+			if v, err := strconv.ParseFloat(raw, 64); err == nil {
+				obj.sortValues = append(obj.sortValues, v)
+			}
+		}
+	}
+	for i, f := range a.opt.Fields {
+		raw := m.GetRenderValue(f, FieldOpt{Raw: true})
+		if raw == "-" {
+			continue
+		}
+		obj.seen[i]++
+		obj.last[i] = raw
+		if v, err := strconv.ParseFloat(raw, 64); err == nil {
+			obj.values[i] = append(obj.values[i], v)
+		}
+	}
+}
+
+// ranked report whether objects are sorted and limited by top in window
+func (a *aggregator) ranked() bool {
+	return (a.opt.Module == "process" || a.opt.Module == "thread") && a.opt.SortField != ""
+}
+
+// rank sort processes and threads of current window by average of sort
+// field, and keep top of them. objects without numeric value of sort
+// field are compared by last value
+func (a *aggregator) rank() []*aggObject {
+	if !a.ranked() {
+		return a.order
+	}
+	objs := slices.Clone(a.order)
+	avg := func(obj *aggObject) float64 {
+		if len(obj.sortValues) == 0 {
+			return math.Inf(-1)
+		}
+		return calcStat("avg", obj.sortValues)
+	}
+	slices.SortStableFunc(objs, func(x, y *aggObject) int {
+		var c int
+		if len(x.sortValues) != 0 || len(y.sortValues) != 0 {
+			c = cmp.Compare(avg(x), avg(y))
+		} else {
+			c = strings.Compare(x.sortLast, y.sortLast)
+		}
+		if a.opt.DescendingOrder {
+			return -c
+		}
+		return c
+	})
+	if a.opt.Top > 0 && len(objs) > a.opt.Top {
+		objs = objs[:a.opt.Top]
+	}
+	return objs
+}
+
+// rows return one row per object and stat of current window, row is
+// stat followed by value of fields. window is reset after iterating
+func (a *aggregator) rows(fixWidth bool) iter.Seq2[*aggObject, []string] {
+	return func(yield func(*aggObject, []string) bool) {
+		defer func() {
+			clear(a.objs)
+			a.order = a.order[:0]
+		}()
+		for _, obj := range a.rank() {
+			for i := range obj.values {
+				slices.Sort(obj.values[i])
+			}
+			for _, stat := range a.opt.Stats {
+				row := make([]string, 0, len(a.opt.Fields)+1)
+				row = append(row, stat)
+				for i, f := range a.opt.Fields {
+					cfg := obj.m.DefaultConfig(f)
+					cfg.ApplyOpt(FieldOpt{FixWidth: fixWidth, Raw: a.opt.RawData})
+					values := obj.values[i]
+					if obj.seen[i] == 0 {
+						row = append(row, cfg.Render("-"))
+					} else if len(values) != obj.seen[i] {
+						// non-numeric field, e.g name
+						row = append(row, cfg.Render(obj.last[i]))
+					} else {
+						row = append(row, cfg.Render(calcStat(stat, values)))
+					}
+				}
+				if !yield(obj, row) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// calcStat return stat of sorted values
+func calcStat(stat string, values []float64) float64 {
+	switch stat {
+	case "min":
+		return values[0]
+	case "max":
+		return values[len(values)-1]
+	case "sum", "avg":
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		if stat == "sum" {
+			return sum
+		}
+		return sum / float64(len(values))
+	}
+	// nearest rank percentile
+	n, _ := strconv.Atoi(strings.TrimPrefix(stat, "p"))
+	rank := int(math.Ceil(float64(n) / 100 * float64(len(values))))
+	if rank < 1 {
+		rank = 1
+	}
+	return values[rank-1]
+}
+
+// dumpAggregate output stats of every opt.Aggregate window instead of
+// every sample
+func (s *Model) dumpAggregate(opt DumpOption) error {
+
+	if err := s.CollectSampleByTime(opt.Begin); err != nil {
+		return err
+	}
+
+	fixWidth := opt.Format == "text"
+	title := fmt.Sprintf("%-25s %-5s %-20s", "TimeStamp", "Stat", "Key")
+	for _, c := range opt.Fields {
+		name, width := getNameAndWidthOfField(opt.Module, c)
+		if len(name) > width {
+			width = len(name)
+		}
+		title += fmt.Sprintf(" %-*s", width, name)
+	}
+	title += "\n"
+
+	first := true
+	cnt := 0
+	var w *recordWriter
+	if opt.Format == "csv" || opt.Format == "ndjson" {
+		columns := []string{"Timestamp", "Stat", "Key"}
+		for _, f := range opt.Fields {
+			name, _ := getNameAndWidthOfField(opt.Module, f)
+			columns = append(columns, name)
+		}
+		w = newRecordWriter(opt, columns)
+	}
+	var werr error
+	flush := func(a *aggregator) {
+		dateTime := time.Unix(a.start, 0).Format(time.RFC3339)
+		for obj, row := range a.rows(fixWidth) {
+			switch opt.Format {
+			case "text":
+				if !opt.DisableTitle && opt.RepeatTitle != 0 && cnt != 0 && cnt%opt.RepeatTitle == 0 {
+					opt.Output.WriteString(title)
+				}
+				fmt.Fprintf(opt.Output, "%-25s %-5s %-20s %s\n", dateTime, row[0], obj.key, strings.Join(row[1:], " "))
+			case "json":
+				if first {
+					first = false
+				} else {
+					opt.Output.WriteString(",\n")
+				}
+				m := map[string]string{
+					"Timestamp": dateTime,
+					"Stat":      row[0],
+					"Key":       obj.key,
+				}
+				for i, f := range opt.Fields {
+					m[obj.m.DefaultConfig(f).Name] = row[i+1]
+				}
+				b, _ := json.Marshal(m)
+				opt.Output.Write(b)
+			case "csv", "ndjson":
+				values := append([]string{dateTime, row[0], obj.key}, row[1:]...)
+				if err := w.Write(values); err != nil && werr == nil {
+					werr = err
+				}
+			}
+			cnt++
+		}
+	}
+
+	switch opt.Format {
+	case "text":
+		if !opt.DisableTitle {
+			opt.Output.WriteString(title)
+		}
+	case "json":
+		opt.Output.WriteString("[\n")
+	}
+
+	// top and sort are applied to stats of window, not to every sample
+	all := opt
+	all.Top = 0
+	a := newAggregator(opt)
+	for opt.End >= s.Curr.TimeStamp {
+		w := windowStart(s.Curr.TimeStamp, opt.Aggregate)
+		if a.started && w != a.start {
+			flush(a)
+		}
+		a.start, a.started = w, true
+		for key, m := range s.iterateDump(all) {
+			a.add(key, m)
+		}
+		if err := s.CollectNext(); err != nil {
+			if err == store.ErrOutOfRange {
+				break
+			}
+			return err
+		}
+	}
+	if a.started {
+		flush(a)
+	}
+
+	if opt.Format == "json" {
+		opt.Output.WriteString("\n]\n")
+	}
+	if w != nil {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return werr
+}
@@ -0,0 +1,187 @@
+package model
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/xixiliguo/etop/procfs"
+	"github.com/xixiliguo/etop/store"
+)
+
+// newAggregateStore write samples every 5s from 1000 to 1030,
+// Load1 is 1..7, read ios of sda/sdb increase 10/20 per sample,
+// and one process with quote and comma in cmdline
+func newAggregateStore(t *testing.T) *Model {
+	dir := t.TempDir()
+	local, err := store.NewLocalStore(
+		store.WithPathAndLogger(dir, slog.Default()),
+		store.WithWriteOnly(store.NoCompress, 0),
+	)
+	if err != nil {
+		t.Fatalf("new store: %s", err)
+	}
+	for i := 0; i < 7; i++ {
+		s := store.NewSample()
+		s.TimeStamp = 1000 + int64(i)*5
+		s.PageSize = 4096
+		s.LoadAvg = procfs.LoadAvg{Load1: float64(i + 1)}
+		s.DiskStats["sda"] = procfs.DiskStatLine{DeviceName: "sda", ReadIOs: uint64(i * 10)}
+		s.DiskStats["sdb"] = procfs.DiskStatLine{DeviceName: "sdb", ReadIOs: uint64(i * 20)}
+		s.ProcSamples[1] = store.ProcSample{
+			ProcStat: procfs.ProcStat{PID: 1, Comm: "sh"},
+			CmdLine:  `sh -c "echo a,b"`,
+		}
+		if _, err := local.WriteSample(&s); err != nil {
+			t.Fatalf("write sample: %s", err)
+		}
+	}
+	local.Close()
+
+	read, err := store.NewLocalStore(store.WithPathAndLogger(dir, slog.Default()))
+	if err != nil {
+		t.Fatalf("new store: %s", err)
+	}
+	sm, _ := NewSysModel(read, slog.Default())
+	return sm
+}
+
+func dumpToString(t *testing.T, sm *Model, opt DumpOption) string {
+	out := filepath.Join(t.TempDir(), "out")
+	f, _ := os.Create(out)
+	opt.Output = f
+	err := sm.Dump(opt)
+	f.Close()
+	if err != nil {
+		t.Fatalf("dump: %s", err)
+	}
+	b, _ := os.ReadFile(out)
+	return string(b)
+}
+
+func TestDumpAggregate(t *testing.T) {
+
+	sm := newAggregateStore(t)
+	got := dumpToString(t, sm, DumpOption{
+		Begin:     1000,
+		End:       1030,
+		Module:    "system",
+		Format:    "text",
+		Fields:    []string{"Load1"},
+		Aggregate: 10 * time.Second,
+		Stats:     []string{"avg,max", "p50"},
+	})
+	lines := strings.Split(strings.TrimSpace(got), "\n")
+	values := []string{}
+	for _, l := range lines[1:] {
+		fs := strings.Fields(l)
+		values = append(values, fs[1]+"="+fs[2])
+	}
+	// first sample 1000 is skipped, windows: [1005] [1010 1015] [1020 1025] [1030]
+	want := []string{
+		"avg=2.00", "max=2.00", "p50=2.00",
+		"avg=3.50", "max=4.00", "p50=3.00",
+		"avg=5.50", "max=6.00", "p50=5.00",
+		"avg=7.00", "max=7.00", "p50=7.00",
+	}
+	if diff := cmp.Diff(want, values); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	sm = newAggregateStore(t)
+	got = dumpToString(t, sm, DumpOption{
+		Begin:     1000,
+		End:       1030,
+		Module:    "disk",
+		Format:    "json",
+		Fields:    []string{"Disk", "ReadPerSec"},
+		Aggregate: 2 * time.Minute,
+		Stats:     []string{"sum"},
+	})
+	rows := []map[string]string{}
+	if err := json.Unmarshal([]byte(got), &rows); err != nil {
+		t.Fatalf("unmarshal %s: %s", got, err)
+	}
+	wantRows := []map[string]string{
+		{"Timestamp": time.Unix(960, 0).Format(time.RFC3339), "Stat": "sum", "Key": "sda", "Disk": "sda", "Read/s": "12/s"},
+		{"Timestamp": time.Unix(960, 0).Format(time.RFC3339), "Stat": "sum", "Key": "sdb", "Disk": "sdb", "Read/s": "24/s"},
+	}
+	if diff := cmp.Diff(wantRows, rows); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	if err := sm.Dump(DumpOption{Module: "system", Format: "text", Aggregate: time.Minute, Stats: []string{"p0"}}); err == nil {
+		t.Errorf("p0 should be invalid stat")
+	}
+}
+
+func TestDumpAggregateTop(t *testing.T) {
+
+	dir := t.TempDir()
+	local, err := store.NewLocalStore(
+		store.WithPathAndLogger(dir, slog.Default()),
+		store.WithWriteOnly(store.NoCompress, 0),
+	)
+	if err != nil {
+		t.Fatalf("new store: %s", err)
+	}
+	// pid 1 has biggest rss only in one sample, pid 2 is bigger on average
+	for i := 0; i < 7; i++ {
+		s := store.NewSample()
+		s.TimeStamp = 1000 + int64(i)*5
+		s.PageSize = 4096
+		rss := uint64(1)
+		if i == 3 {
+			rss = 30
+		}
+		s.ProcSamples[1] = store.ProcSample{ProcStat: procfs.ProcStat{PID: 1, Comm: "spike", RSS: rss}}
+		s.ProcSamples[2] = store.ProcSample{ProcStat: procfs.ProcStat{PID: 2, Comm: "steady", RSS: 10}}
+		s.ProcSamples[3] = store.ProcSample{ProcStat: procfs.ProcStat{PID: 3, Comm: "idle", RSS: 2}}
+		if _, err := local.WriteSample(&s); err != nil {
+			t.Fatalf("write sample: %s", err)
+		}
+	}
+	local.Close()
+
+	for _, tc := range []struct {
+		desc bool
+		top  int
+		want []string
+	}{
+		{true, 1, []string{"steady"}},
+		{true, 0, []string{"steady", "spike", "idle"}},
+		{false, 2, []string{"idle", "spike"}},
+	} {
+		read, err := store.NewLocalStore(store.WithPathAndLogger(dir, slog.Default()))
+		if err != nil {
+			t.Fatalf("new store: %s", err)
+		}
+		sm, _ := NewSysModel(read, slog.Default())
+		got := dumpToString(t, sm, DumpOption{
+			Begin:           1000,
+			End:             1030,
+			Module:          "process",
+			Format:          "text",
+			Fields:          []string{"Comm"},
+			SortField:       "RSS",
+			DescendingOrder: tc.desc,
+			Top:             tc.top,
+			Aggregate:       2 * time.Minute,
+			Stats:           []string{"avg"},
+		})
+		comms := []string{}
+		for _, l := range strings.Split(strings.TrimSpace(got), "\n")[1:] {
+			fs := strings.Fields(l)
+			comms = append(comms, fs[len(fs)-1])
+		}
+		if diff := cmp.Diff(tc.want, comms); diff != "" {
+			t.Errorf("desc %v top %d mismatch (-want +got):\n%s", tc.desc, tc.top, diff)
+		}
+		read.Close()
+	}
+}
@@ -4,12 +4,16 @@ import (
 	"math"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/expr-lang/expr"
 	"github.com/expr-lang/expr/vm"
 	"github.com/mattn/go-runewidth"
 	"github.com/xixiliguo/etop/cgroupfs"
 	"github.com/xixiliguo/etop/store"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 )
 
 var DefaultCgroupFields = []string{"Name", "NrDescendants", "NrDyingDescendants", "UsagePercent", "Controllers"}
@@ -1061,3 +1065,122 @@ func (c *Cgroup) sortChild(sortField string, descOrder bool) []*Cgroup {
 	}
 	return childs
 }
+
+// Walk call fn for c and all descendants, parent first
+func (c *Cgroup) Walk(fn func(c *Cgroup)) {
+	fn(c)
+	for _, child := range c.sortChild("Name", false) {
+		child.Walk(fn)
+	}
+}
+
+func (c *Cgroup) GetPromMetric(w *PromWriter) {
+	if c.FullPath == "" {
+		return
+	}
+	cgs := []*Cgroup{}
+	c.Walk(func(c *Cgroup) {
+		cgs = append(cgs, c)
+	})
+	w.Metric("cgroup_cpu_usage_percent", "gauge", "CPU usage of cgroup from cpu.stat.")
+	for _, cg := range cgs {
+		w.Sample("cgroup_cpu_usage_percent", cg.UserPercent, "path", cg.FullPath, "mode", "user")
+		w.Sample("cgroup_cpu_usage_percent", cg.SystemPercent, "path", cg.FullPath, "mode", "system")
+	}
+	w.Metric("cgroup_cpu_throttled_percent", "gauge", "Percentage of time cgroup was throttled.")
+	for _, cg := range cgs {
+		w.Sample("cgroup_cpu_throttled_percent", cg.ThrottledPercent, "path", cg.FullPath)
+	}
+	w.Metric("cgroup_memory_current_bytes", "gauge", "Memory usage of cgroup from memory.current.")
+	for _, cg := range cgs {
+		w.Sample("cgroup_memory_current_bytes", float64(cg.MemoryCurrent), "path", cg.FullPath)
+	}
+	w.Metric("cgroup_memory_oom_kill_per_second", "gauge", "Processes killed by OOM killer per second.")
+	for _, cg := range cgs {
+		w.Sample("cgroup_memory_oom_kill_per_second", cg.EventOomKillPerSec, "path", cg.FullPath)
+	}
+	w.Metric("cgroup_io_bytes_per_second", "gauge", "Bytes read/written per second from io.stat.")
+	for _, cg := range cgs {
+		w.Sample("cgroup_io_bytes_per_second", cg.RbytePerSec, "path", cg.FullPath, "action", "read")
+		w.Sample("cgroup_io_bytes_per_second", cg.WbytePerSec, "path", cg.FullPath, "action", "write")
+	}
+	w.Metric("cgroup_pressure_avg60", "gauge", "Pressure stall information averaged over 60 seconds.")
+	for _, cg := range cgs {
+		w.Sample("cgroup_pressure_avg60", cg.CPUSomePressure, "path", cg.FullPath, "resource", "cpu", "type", "some")
+		w.Sample("cgroup_pressure_avg60", cg.CPUFullPressure, "path", cg.FullPath, "resource", "cpu", "type", "full")
+		w.Sample("cgroup_pressure_avg60", cg.MemorySomePressure, "path", cg.FullPath, "resource", "memory", "type", "some")
+		w.Sample("cgroup_pressure_avg60", cg.MemoryFullPressure, "path", cg.FullPath, "resource", "memory", "type", "full")
+		w.Sample("cgroup_pressure_avg60", cg.IOSomePressure, "path", cg.FullPath, "resource", "io", "type", "some")
+		w.Sample("cgroup_pressure_avg60", cg.IOFullPressure, "path", cg.FullPath, "resource", "io", "type", "full")
+	}
+}
+
+func (c *Cgroup) GetOtelMetric(timeStamp int64, sm *metricdata.ScopeMetrics) {
+
+	sm.Scope = instrumentation.Scope{Name: "cgroup", Version: "0.0.1"}
+	if c.FullPath == "" {
+		return
+	}
+	cpuUsage := metricdata.Metrics{
+		Name: "cgroup.cpu.usage",
+	}
+	cpuUsageData := metricdata.Gauge[float64]{}
+	memUsage := metricdata.Metrics{
+		Name: "cgroup.memory.usage",
+	}
+	memUsageData := metricdata.Gauge[int64]{}
+	oomKill := metricdata.Metrics{
+		Name: "cgroup.memory.oom.kill",
+	}
+	oomKillData := metricdata.Gauge[float64]{}
+	ioByte := metricdata.Metrics{
+		Name: "cgroup.io.byte",
+	}
+	ioByteData := metricdata.Gauge[float64]{}
+	pressure := metricdata.Metrics{
+		Name: "cgroup.pressure",
+	}
+	pressureData := metricdata.Gauge[float64]{}
+
+	// missing value should not be sent
+	appendFloat := func(data *metricdata.Gauge[float64], value float64, attrs ...attribute.KeyValue) {
+		if value == math.MaxFloat64 {
+			return
+		}
+		data.DataPoints = append(data.DataPoints, metricdata.DataPoint[float64]{
+			Attributes: attribute.NewSet(attrs...),
+			Time:       time.Unix(timeStamp, 0),
+			Value:      value,
+		})
+	}
+
+	c.Walk(func(cg *Cgroup) {
+		path := attribute.String("path", cg.FullPath)
+		appendFloat(&cpuUsageData, cg.UserPercent, path, attribute.String("mode", "user"))
+		appendFloat(&cpuUsageData, cg.SystemPercent, path, attribute.String("mode", "system"))
+		appendFloat(&cpuUsageData, cg.ThrottledPercent, path, attribute.String("mode", "throttled"))
+		if cg.MemoryCurrent != math.MaxUint64 {
+			memUsageData.DataPoints = append(memUsageData.DataPoints, metricdata.DataPoint[int64]{
+				Attributes: attribute.NewSet(path),
+				Time:       time.Unix(timeStamp, 0),
+				Value:      int64(cg.MemoryCurrent),
+			})
+		}
+		appendFloat(&oomKillData, cg.EventOomKillPerSec, path)
+		appendFloat(&ioByteData, cg.RbytePerSec, path, attribute.String("action", "read"))
+		appendFloat(&ioByteData, cg.WbytePerSec, path, attribute.String("action", "write"))
+		appendFloat(&pressureData, cg.CPUSomePressure, path, attribute.String("resource", "cpu"), attribute.String("type", "some"))
+		appendFloat(&pressureData, cg.CPUFullPressure, path, attribute.String("resource", "cpu"), attribute.String("type", "full"))
+		appendFloat(&pressureData, cg.MemorySomePressure, path, attribute.String("resource", "memory"), attribute.String("type", "some"))
+		appendFloat(&pressureData, cg.MemoryFullPressure, path, attribute.String("resource", "memory"), attribute.String("type", "full"))
+		appendFloat(&pressureData, cg.IOSomePressure, path, attribute.String("resource", "io"), attribute.String("type", "some"))
+		appendFloat(&pressureData, cg.IOFullPressure, path, attribute.String("resource", "io"), attribute.String("type", "full"))
+	})
+
+	cpuUsage.Data = cpuUsageData
+	memUsage.Data = memUsageData
+	oomKill.Data = oomKillData
+	ioByte.Data = ioByteData
+	pressure.Data = pressureData
+	sm.Metrics = append(sm.Metrics, cpuUsage, memUsage, oomKill, ioByte, pressure)
+}
@@ -217,3 +217,19 @@ func calcCpuUsage(prev, curr procfs.CPUStat) CPU {
 
 	return c
 }
+
+func (cpus *CPUSlice) GetPromMetric(w *PromWriter) {
+	w.Metric("cpu_usage_percent", "gauge", "Percentage of CPU time spent in each mode, cpu=\"total\" for all CPUs.")
+	for _, c := range *cpus {
+		w.Sample("cpu_usage_percent", c.User, "cpu", c.Index, "mode", "user")
+		w.Sample("cpu_usage_percent", c.Nice, "cpu", c.Index, "mode", "nice")
+		w.Sample("cpu_usage_percent", c.System, "cpu", c.Index, "mode", "system")
+		w.Sample("cpu_usage_percent", c.Idle, "cpu", c.Index, "mode", "idle")
+		w.Sample("cpu_usage_percent", c.Iowait, "cpu", c.Index, "mode", "iowait")
+		w.Sample("cpu_usage_percent", c.IRQ, "cpu", c.Index, "mode", "irq")
+		w.Sample("cpu_usage_percent", c.SoftIRQ, "cpu", c.Index, "mode", "soft_irq")
+		w.Sample("cpu_usage_percent", c.Steal, "cpu", c.Index, "mode", "steal")
+		w.Sample("cpu_usage_percent", c.Guest, "cpu", c.Index, "mode", "guest")
+		w.Sample("cpu_usage_percent", c.GuestNice, "cpu", c.Index, "mode", "guest_nice")
+	}
+}
@@ -0,0 +1,383 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xixiliguo/etop/store"
+)
+
+// DiffModules is compared by etop diff by default
+var DiffModules = []string{"system", "memory", "disk", "netdev", "process", "cgroup"}
+
+// DiffFields is fields compared per module, non-numeric fields are ignored
+var DiffFields = map[string][]string{
+	"system":  DefaultSystemFields,
+	"memory":  DefaultMEMFields,
+	"disk":    DefaultDiskFields,
+	"netdev":  DefaultNetDevFields,
+	"process": {"NumThreads", "CPU", "Mem", "RSS", "MajFlt", "ReadBytePerSec", "WriteBytePerSec"},
+	"cgroup": {"UsagePercent", "ThrottledPercent", "MemoryCurrent", "Anon", "File",
+		"PgmajfaultPerSec", "RbytePerSec", "WbytePerSec",
+		"CPUSomePressure", "MemorySomePressure", "IOSomePressure"},
+}
+
+// Window is average value of fields per object during [Begin, End],
+// processes are grouped by comm and cgroups are keyed by path
+type Window struct {
+	Begin   int64
+	End     int64
+	Samples int
+	values  map[string]map[string][]float64 // module -> key -> sum per field
+	counts  map[string]map[string]int       // module -> key -> number of samples
+}
+
+// CollectWindow read samples during [begin, end] and average fields of
+// modules over them
+func (s *Model) CollectWindow(begin, end int64, modules []string) (*Window, error) {
+
+	w := &Window{
+		Begin:  begin,
+		End:    end,
+		values: make(map[string]map[string][]float64),
+		counts: make(map[string]map[string]int),
+	}
+	for _, module := range modules {
+		if _, ok := DiffFields[module]; !ok {
+			return nil, fmt.Errorf("no support module %s for diff", module)
+		}
+		w.values[module] = make(map[string][]float64)
+		w.counts[module] = make(map[string]int)
+	}
+
+	if err := s.CollectSampleByTime(begin); err != nil {
+		return nil, err
+	}
+	for end >= s.Curr.TimeStamp {
+		// jump may stop at nearest sample before begin
+		if s.Curr.TimeStamp >= begin {
+			w.add(s, modules)
+		}
+		if err := s.CollectNext(); err != nil {
+			if err == store.ErrOutOfRange {
+				break
+			}
+			return nil, err
+		}
+	}
+	if w.Samples == 0 {
+		return nil, fmt.Errorf("no sample between %s and %s",
+			time.Unix(begin, 0).Format(time.DateTime), time.Unix(end, 0).Format(time.DateTime))
+	}
+	for module, objs := range w.values {
+		for key, sums := range objs {
+			for i := range sums {
+				sums[i] /= float64(w.counts[module][key])
+			}
+		}
+	}
+	return w, nil
+}
+
+// add fold current sample into window, values of processes with same
+// comm are summed in one sample before averaged
+func (w *Window) add(s *Model, modules []string) {
+	w.Samples++
+	for _, module := range modules {
+		fields := DiffFields[module]
+		seen := map[string]bool{}
+		for key, m := range s.IterateModule(module) {
+			if p, ok := m.(*Process); ok {
+				key = p.Comm
+			}
+			sums := w.values[module][key]
+			if sums == nil {
+				sums = make([]float64, len(fields))
+				for i := range sums {
+					sums[i] = math.NaN()
+				}
+				w.values[module][key] = sums
+			}
+			if !seen[key] {
+				seen[key] = true
+				w.counts[module][key]++
+			}
+			for i, f := range fields {
+				v, err := strconv.ParseFloat(m.GetRenderValue(f, FieldOpt{Raw: true}), 64)
+				if err != nil {
+					continue
+				}
+				if math.IsNaN(sums[i]) {
+					sums[i] = 0
+				}
+				sums[i] += v
+			}
+		}
+	}
+}
+
+// DiffRow is change of one field of one object between window a and b,
+// A or B is NaN if object or field does not exist in the window
+type DiffRow struct {
+	Module string
+	Key    string
+	Field  string
+	A      float64
+	B      float64
+}
+
+// Delta return B - A
+func (r DiffRow) Delta() float64 {
+	return r.B - r.A
+}
+
+// Change return relative change of B against A, it is +Inf if A is zero
+// or missing and B is not
+func (r DiffRow) Change() float64 {
+	a, b := r.A, r.B
+	if math.IsNaN(a) {
+		a = 0
+	}
+	if math.IsNaN(b) {
+		b = 0
+	}
+	if a == b {
+		return 0
+	}
+	if a == 0 {
+		return math.Inf(1)
+	}
+	return (b - a) / math.Abs(a)
+}
+
+// DiffOption control how two windows are compared
+type DiffOption struct {
+	Modules    []string
+	Top        int     // number of rows per module, 0 means no limit
+	TopProcess int     // only compare top N comms by CPU of either window, 0 means no limit
+	Highlight  float64 // mark rows which relative change is at least Highlight (0.5 means 50%)
+	Output     *os.File
+	Format     string
+	RawData    bool
+}
+
+// Diff compare every field of objects in a and b, rows are sorted by
+// absolute relative change per module, unchanged rows are dropped
+func Diff(a, b *Window, opt DiffOption) []DiffRow {
+	rows := []DiffRow{}
+	for _, module := range opt.Modules {
+		keys := []string{}
+		for key := range a.values[module] {
+			keys = append(keys, key)
+		}
+		for key := range b.values[module] {
+			if _, ok := a.values[module][key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		if module == "process" && opt.TopProcess > 0 {
+			keys = topComms(a, b, keys, opt.TopProcess)
+		}
+		slices.Sort(keys)
+
+		moduleRows := []DiffRow{}
+		for _, key := range keys {
+			for i, f := range DiffFields[module] {
+				r := DiffRow{
+					Module: module,
+					Key:    key,
+					Field:  f,
+					A:      a.value(module, key, i),
+					B:      b.value(module, key, i),
+				}
+				if math.IsNaN(r.A) && math.IsNaN(r.B) || r.Change() == 0 {
+					continue
+				}
+				moduleRows = append(moduleRows, r)
+			}
+		}
+		slices.SortStableFunc(moduleRows, func(x, y DiffRow) int {
+			cx, cy := math.Abs(x.Change()), math.Abs(y.Change())
+			if cx != cy {
+				if cx > cy {
+					return -1
+				}
+				return 1
+			}
+			dx, dy := math.Abs(x.Delta()), math.Abs(y.Delta())
+			if dx > dy {
+				return -1
+			} else if dx < dy {
+				return 1
+			}
+			return 0
+		})
+		if opt.Top > 0 && len(moduleRows) > opt.Top {
+			moduleRows = moduleRows[:opt.Top]
+		}
+		rows = append(rows, moduleRows...)
+	}
+	return rows
+}
+
+func (w *Window) value(module, key string, i int) float64 {
+	sums, ok := w.values[module][key]
+	if !ok {
+		return math.NaN()
+	}
+	return sums[i]
+}
+
+// topComms return comms which is in top n by CPU of window a or b
+func topComms(a, b *Window, keys []string, n int) []string {
+	cpu := slices.Index(DiffFields["process"], "CPU")
+	keep := map[string]bool{}
+	for _, w := range []*Window{a, b} {
+		sorted := slices.Clone(keys)
+		slices.SortFunc(sorted, func(x, y string) int {
+			vx, vy := w.value("process", x, cpu), w.value("process", y, cpu)
+			if math.IsNaN(vx) {
+				vx = -1
+			}
+			if math.IsNaN(vy) {
+				vy = -1
+			}
+			if vx > vy {
+				return -1
+			} else if vx < vy {
+				return 1
+			}
+			return strings.Compare(x, y)
+		})
+		for _, key := range sorted[:min(n, len(sorted))] {
+			keep[key] = true
+		}
+	}
+	return slices.DeleteFunc(keys, func(key string) bool {
+		return !keep[key]
+	})
+}
+
+// renderDiff return rendered value of A, B, delta and change of row
+func renderDiff(r DiffRow, raw bool) (string, string, string, string) {
+	_, cfg := moduleRenderConfig(r.Module, r.Field)
+	cfg.ApplyOpt(FieldOpt{Raw: raw})
+	value := func(v float64) string {
+		if math.IsNaN(v) {
+			return "-"
+		}
+		return cfg.Render(v)
+	}
+	delta := r.Delta()
+	deltaStr := "-"
+	if !math.IsNaN(delta) {
+		sign := "+"
+		if delta < 0 {
+			sign = "-"
+		}
+		deltaStr = sign + cfg.Render(math.Abs(delta))
+	}
+	change := ""
+	switch {
+	case math.IsNaN(r.A):
+		change = "new"
+	case math.IsNaN(r.B):
+		change = "gone"
+	case math.IsInf(r.Change(), 1):
+		change = "+inf%"
+	default:
+		change = fmt.Sprintf("%+.1f%%", r.Change()*100)
+	}
+	return value(r.A), value(r.B), deltaStr, change
+}
+
+// moduleRenderConfig return name and render config of field in module
+func moduleRenderConfig(module, field string) (string, Field) {
+	cfg := defaultConfigOfField(module, field)
+	// values are float64 after averaged
+	if cfg.Format != HumanReadableSize && cfg.Precision == 0 {
+		cfg.Precision = 1
+	}
+	return cfg.Name, cfg
+}
+
+// WriteDiff output rows in opt.Format, rows which relative change is
+// at least opt.Highlight are marked with "*" in text format
+func WriteDiff(a, b *Window, rows []DiffRow, opt DiffOption) error {
+
+	window := func(w *Window) string {
+		return fmt.Sprintf("%s - %s (%d samples)",
+			time.Unix(w.Begin, 0).Format(time.DateTime),
+			time.Unix(w.End, 0).Format(time.DateTime),
+			w.Samples)
+	}
+	columns := []string{"Module", "Key", "Field", "A", "B", "Delta", "Change"}
+
+	switch opt.Format {
+	case "text":
+		fmt.Fprintf(opt.Output, "A: %s\nB: %s\n", window(a), window(b))
+		keyWidth := 20
+		for _, r := range rows {
+			keyWidth = max(keyWidth, len(r.Key))
+		}
+		module := ""
+		for _, r := range rows {
+			if r.Module != module {
+				module = r.Module
+				fmt.Fprintf(opt.Output, "\n%s\n  %-*s %-20s %12s %12s %12s %10s\n",
+					module, keyWidth, "Key", "Field", "A", "B", "Delta", "Change")
+			}
+			name, _ := moduleRenderConfig(r.Module, r.Field)
+			va, vb, delta, change := renderDiff(r, opt.RawData)
+			mark := " "
+			if opt.Highlight > 0 && math.Abs(r.Change()) >= opt.Highlight {
+				mark = "*"
+			}
+			key := r.Key
+			if key == "" {
+				key = "-"
+			}
+			fmt.Fprintf(opt.Output, "%s %-*s %-20s %12s %12s %12s %10s\n",
+				mark, keyWidth, key, name, va, vb, delta, change)
+		}
+		if len(rows) == 0 {
+			fmt.Fprintf(opt.Output, "\nno difference\n")
+		}
+	case "json":
+		result := []map[string]string{}
+		for _, r := range rows {
+			name, _ := moduleRenderConfig(r.Module, r.Field)
+			va, vb, delta, change := renderDiff(r, opt.RawData)
+			result = append(result, map[string]string{
+				"Module": r.Module, "Key": r.Key, "Field": name,
+				"A": va, "B": vb, "Delta": delta, "Change": change,
+			})
+		}
+		b, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+		b = append(b, '\n')
+		_, err = opt.Output.Write(b)
+		return err
+	case "csv", "ndjson":
+		w := newRecordWriter(DumpOption{Format: opt.Format, Output: opt.Output}, columns)
+		for _, r := range rows {
+			name, _ := moduleRenderConfig(r.Module, r.Field)
+			va, vb, delta, change := renderDiff(r, opt.RawData)
+			if err := w.Write([]string{r.Module, r.Key, name, va, vb, delta, change}); err != nil {
+				return err
+			}
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("no support output format %s", opt.Format)
+	}
+	return nil
+}
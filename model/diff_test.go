@@ -0,0 +1,75 @@
+package model
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiff(t *testing.T) {
+
+	sm := newAggregateStore(t)
+	// first sample 1000 is skipped, a has Load1 2,3 and b has 5,6,7
+	a, err := sm.CollectWindow(1000, 1010, DiffModules)
+	if err != nil {
+		t.Fatalf("collect window a: %s", err)
+	}
+	b, err := sm.CollectWindow(1020, 1030, DiffModules)
+	if err != nil {
+		t.Fatalf("collect window b: %s", err)
+	}
+	if a.Samples != 2 || b.Samples != 3 {
+		t.Fatalf("got %d %d samples, but want 2 3", a.Samples, b.Samples)
+	}
+	if _, err := sm.CollectWindow(2000, 2010, DiffModules); err == nil {
+		t.Errorf("window without sample should fail")
+	}
+
+	opt := DiffOption{Modules: []string{"system", "disk", "process"}, Highlight: 0.5}
+	rows := Diff(a, b, opt)
+	// read of disks and cpu of process are not changed
+	want := []DiffRow{
+		{Module: "system", Key: "", Field: "Load1", A: 2.5, B: 6},
+	}
+	if diff := cmp.Diff(want, rows); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	if c := rows[0].Change(); math.Abs(c-1.4) > 1e-9 {
+		t.Errorf("got change %f, but want 1.4", c)
+	}
+	if c := (DiffRow{A: math.NaN(), B: 1}).Change(); !math.IsInf(c, 1) {
+		t.Errorf("got change %f of new object, but want +Inf", c)
+	}
+
+	out := filepath.Join(t.TempDir(), "out")
+	f, _ := os.Create(out)
+	opt.Output = f
+	opt.Format = "csv"
+	err = WriteDiff(a, b, rows, opt)
+	f.Close()
+	if err != nil {
+		t.Fatalf("write diff: %s", err)
+	}
+	got, _ := os.ReadFile(out)
+	wantCSV := "Module,Key,Field,A,B,Delta,Change\nsystem,,Load1,2.50,6.00,+3.50,+140.0%\n"
+	if diff := cmp.Diff(wantCSV, string(got)); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	f, _ = os.Create(out)
+	opt.Output = f
+	opt.Format = "text"
+	err = WriteDiff(a, b, rows, opt)
+	f.Close()
+	if err != nil {
+		t.Fatalf("write diff: %s", err)
+	}
+	got, _ = os.ReadFile(out)
+	if !strings.Contains(string(got), "* -") {
+		t.Errorf("Load1 should be highlighted:\n%s", got)
+	}
+}
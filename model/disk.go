@@ -380,3 +380,34 @@ func (diskMap DiskMap) GetOtelMetric(timeStamp int64, sm *metricdata.ScopeMetric
 	diskIOTime.Data = diskIOTimeData
 	sm.Metrics = append(sm.Metrics, diskIO, diskByte, diskUsage, diskIOSize, diskQueueLen, diskFlight, diskIOWait, diskIOTime)
 }
+
+func (diskMap DiskMap) GetPromMetric(w *PromWriter) {
+	disks := diskMap.Iterate()
+	w.Metric("disk_util_percent", "gauge", "Percentage of time the disk was busy.")
+	for _, d := range disks {
+		w.Sample("disk_util_percent", d.Util, "disk", d.DeviceName)
+	}
+	w.Metric("disk_io_per_second", "gauge", "Completed I/O requests per second.")
+	for _, d := range disks {
+		w.Sample("disk_io_per_second", d.ReadPerSec, "disk", d.DeviceName, "action", "read")
+		w.Sample("disk_io_per_second", d.WritePerSec, "disk", d.DeviceName, "action", "write")
+		w.Sample("disk_io_per_second", d.DiscardPerSec, "disk", d.DeviceName, "action", "discard")
+	}
+	w.Metric("disk_bytes_per_second", "gauge", "Bytes transferred per second.")
+	for _, d := range disks {
+		w.Sample("disk_bytes_per_second", d.ReadBytePerSec, "disk", d.DeviceName, "action", "read")
+		w.Sample("disk_bytes_per_second", d.WriteBytePerSec, "disk", d.DeviceName, "action", "write")
+	}
+	w.Metric("disk_io_wait_milliseconds", "gauge", "Average time of I/O requests including queue time.")
+	for _, d := range disks {
+		w.Sample("disk_io_wait_milliseconds", d.AvgIOWait, "disk", d.DeviceName)
+	}
+	w.Metric("disk_queue_length", "gauge", "Average queue length of I/O requests.")
+	for _, d := range disks {
+		w.Sample("disk_queue_length", d.AvgQueueLength, "disk", d.DeviceName)
+	}
+	w.Metric("disk_in_flight", "gauge", "Number of I/O requests in progress.")
+	for _, d := range disks {
+		w.Sample("disk_in_flight", float64(d.IOsInProgress), "disk", d.DeviceName)
+	}
+}
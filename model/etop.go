@@ -0,0 +1,127 @@
+package model
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/xixiliguo/etop/store"
+)
+
+var DefaultEtopFields = []string{"CPU", "RSS", "CollectTime", "WriteTime",
+	"RawBytes", "StoredBytes", "LostExits", "SmapsErrors"}
+
+// AllEtopFields also include collect time of every module, e.g ProcessTime
+var AllEtopFields = func() []string {
+	fs := slices.Clone(DefaultEtopFields)
+	for _, m := range store.Modules {
+		fs = append(fs, strings.ToUpper(m[:1])+m[1:]+"Time")
+	}
+	return fs
+}()
+
+// Etop is overhead of etop record itself. WriteTime, RawBytes and
+// StoredBytes are of the previous sample
+type Etop struct {
+	CPU         float64 // percent of one cpu
+	RSS         uint64
+	CollectTime float64 // ms
+	WriteTime   float64 // ms
+	RawBytes    int64
+	StoredBytes int64
+	LostExits   uint64             // exited processes lost during interval
+	SmapsErrors uint64             // processes whose smaps_rollup can not be read
+	ModuleTimes map[string]float64 // ms, key is store.Modules
+}
+
+// moduleTimeField return module of field like ProcessTime
+func moduleTimeField(field string) (string, bool) {
+	m, ok := strings.CutSuffix(field, "Time")
+	if !ok || m == "" {
+		return "", false
+	}
+	m = strings.ToLower(m)
+	return m, slices.Contains(store.Modules, m)
+}
+
+func (e *Etop) DefaultConfig(field string) Field {
+	cfg := Field{}
+	switch field {
+	case "CPU":
+		cfg = Field{"CPU", Raw, 1, "%", 10, false}
+	case "RSS":
+		cfg = Field{"RSS", HumanReadableSize, 0, "", 10, false}
+	case "CollectTime":
+		cfg = Field{"Collect", Raw, 1, " ms", 10, false}
+	case "WriteTime":
+		cfg = Field{"Write", Raw, 1, " ms", 10, false}
+	case "RawBytes":
+		cfg = Field{"RawBytes", HumanReadableSize, 0, "", 10, false}
+	case "StoredBytes":
+		cfg = Field{"StoredBytes", HumanReadableSize, 0, "", 11, false}
+	case "LostExits":
+		cfg = Field{"LostExits", Raw, 0, "", 10, false}
+	case "SmapsErrors":
+		cfg = Field{"SmapsErrors", Raw, 0, "", 11, false}
+	default:
+		if _, ok := moduleTimeField(field); ok {
+			cfg = Field{field, Raw, 1, " ms", 10, false}
+		}
+	}
+	return cfg
+}
+
+func (e *Etop) GetRenderValue(field string, opt FieldOpt) string {
+	cfg := e.DefaultConfig(field)
+	cfg.ApplyOpt(opt)
+	s := ""
+	switch field {
+	case "CPU":
+		s = cfg.Render(e.CPU)
+	case "RSS":
+		s = cfg.Render(e.RSS)
+	case "CollectTime":
+		s = cfg.Render(e.CollectTime)
+	case "WriteTime":
+		s = cfg.Render(e.WriteTime)
+	case "RawBytes":
+		s = cfg.Render(float64(e.RawBytes))
+	case "StoredBytes":
+		s = cfg.Render(float64(e.StoredBytes))
+	case "LostExits":
+		s = cfg.Render(e.LostExits)
+	case "SmapsErrors":
+		s = cfg.Render(e.SmapsErrors)
+	default:
+		if m, ok := moduleTimeField(field); ok {
+			s = cfg.Render(e.ModuleTimes[m])
+		} else {
+			s = "no " + field + " for etop stat"
+		}
+	}
+	return s
+}
+
+func (e *Etop) Collect(prev, curr *store.Sample) {
+
+	p, c := &prev.Etop, &curr.Etop
+	interval := float64(curr.TimeStamp - prev.TimeStamp)
+	e.CPU = 0
+	e.LostExits = 0
+	// etop was restarted if cpu time decrease
+	if ticks := c.UTime + c.STime; ticks >= p.UTime+p.STime {
+		e.CPU = float64(ticks-p.UTime-p.STime) * 100 / userHZ / interval
+		if c.LostExits >= p.LostExits {
+			e.LostExits = c.LostExits - p.LostExits
+		}
+	}
+	e.RSS = c.RSS
+	e.CollectTime = float64(c.CollectTime) / 1000
+	e.WriteTime = float64(c.WriteTime) / 1000
+	e.RawBytes = c.RawBytes
+	e.StoredBytes = c.StoredBytes
+	e.SmapsErrors = c.SmapsErrors
+	e.ModuleTimes = make(map[string]float64, len(c.ModuleTimes))
+	for m, t := range c.ModuleTimes {
+		e.ModuleTimes[m] = float64(t) / 1000
+	}
+}
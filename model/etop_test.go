@@ -0,0 +1,53 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/xixiliguo/etop/store"
+)
+
+func TestEtop(t *testing.T) {
+	prev := store.NewSample()
+	prev.TimeStamp = 100
+	prev.Etop = store.EtopSample{UTime: 10, STime: 5, LostExits: 2}
+	curr := store.NewSample()
+	curr.TimeStamp = 110
+	curr.Etop = store.EtopSample{
+		ModuleTimes: map[string]int64{store.ModuleProcess: 12500},
+		CollectTime: 20000,
+		WriteTime:   1500,
+		RawBytes:    100 << 10,
+		StoredBytes: 10 << 10,
+		RSS:         30 << 20,
+		UTime:       20,
+		STime:       10,
+		LostExits:   5,
+	}
+
+	e := Etop{}
+	e.Collect(&prev, &curr)
+	for field, want := range map[string]string{
+		"CPU":         "1.5%",
+		"RSS":         "30.0 MB",
+		"CollectTime": "20.0 ms",
+		"WriteTime":   "1.5 ms",
+		"StoredBytes": "10.0 KB",
+		"LostExits":   "3",
+		"ProcessTime": "12.5 ms",
+		"CgroupTime":  "0.0 ms",
+	} {
+		if got := e.GetRenderValue(field, FieldOpt{}); got != want {
+			t.Errorf("%s: got %s, but want %s", field, got, want)
+		}
+	}
+	if name, _ := getNameAndWidthOfField("etop", "FooTime"); name != "" {
+		t.Errorf("FooTime should not be a field")
+	}
+
+	// etop was restarted
+	curr.Etop.UTime, curr.Etop.STime, curr.Etop.LostExits = 1, 1, 0
+	e.Collect(&prev, &curr)
+	if e.CPU != 0 || e.LostExits != 0 {
+		t.Errorf("got %+v, but want zero cpu and lost exits", e)
+	}
+}
@@ -0,0 +1,203 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/xixiliguo/etop/store"
+	"golang.org/x/sys/unix"
+)
+
+var DefaultEventFields = []string{"Kind", "Severity", "Object", "Message"}
+
+type Event struct {
+	store.Event
+}
+
+func (e *Event) DefaultConfig(field string) Field {
+	cfg := Field{}
+	switch field {
+	case "Kind":
+		cfg = Field{"Kind", Raw, 0, "", 10, false}
+	case "Severity":
+		cfg = Field{"Severity", Raw, 0, "", 8, false}
+	case "Object":
+		cfg = Field{"Object", Raw, 0, "", 30, false}
+	case "Message":
+		cfg = Field{"Message", Raw, 0, "", 10, false}
+	}
+	return cfg
+}
+
+func (e *Event) GetRenderValue(field string, opt FieldOpt) string {
+	cfg := e.DefaultConfig(field)
+	cfg.ApplyOpt(opt)
+	s := ""
+	switch field {
+	case "Kind":
+		s = cfg.Render(e.Kind)
+	case "Severity":
+		s = cfg.Render(e.Severity)
+	case "Object":
+		object := e.Object
+		if object == "" {
+			object = "-"
+		}
+		s = cfg.Render(object)
+	case "Message":
+		s = cfg.Render(e.Message)
+	}
+	return s
+}
+
+// DetectEvents return notable events between previous and current sample,
+// which are stored alongside samples by etop record
+func (s *Model) DetectEvents() []store.Event {
+	events := []store.Event{}
+
+	if s.Vm.OOMKill != 0 && s.Vm.OOMKill != math.MaxUint64 {
+		msg := fmt.Sprintf("%d processes were killed by OOM killer", s.Vm.OOMKill)
+		victims := []string{}
+		for _, p := range s.Processes.Iterate(nil, "Pid", false) {
+			status := unix.WaitStatus(p.ExitCode)
+			if p.EndTime != 0 && status.Signaled() && status.Signal() == unix.SIGKILL {
+				victims = append(victims, fmt.Sprintf("%d(%s)", p.Pid, p.Comm))
+			}
+		}
+		if len(victims) != 0 {
+			msg += ", exited by SIGKILL: " + strings.Join(victims, " ")
+		}
+		events = append(events, store.Event{
+			TimeStamp: s.Curr.TimeStamp,
+			Kind:      store.EventOOMKill,
+			Severity:  "critical",
+			Message:   msg,
+		})
+	}
+
+	// memory.events is hierarchical, only report the deepest cgroups
+	interval := float64(s.Curr.TimeStamp - s.Prev.TimeStamp)
+	isOOMKill := func(c *Cgroup) bool {
+		return c.EventOomKillPerSec != 0 && c.EventOomKillPerSec != math.MaxFloat64
+	}
+	if s.Cgroup.FullPath != "" {
+		s.Cgroup.Walk(func(c *Cgroup) {
+			if !isOOMKill(c) {
+				return
+			}
+			for _, child := range c.Child {
+				if isOOMKill(child) {
+					return
+				}
+			}
+			events = append(events, store.Event{
+				TimeStamp: s.Curr.TimeStamp,
+				Kind:      store.EventOOMKill,
+				Severity:  "critical",
+				Object:    c.FullPath,
+				Message:   fmt.Sprintf("%.0f processes were killed by OOM killer", c.EventOomKillPerSec*interval),
+			})
+		})
+	}
+	return events
+}
+
+// Events return events between begin and end, or nothing if store
+// does not support events
+func (s *Model) Events(begin, end int64) ([]Event, error) {
+	es, ok := s.Store.(store.EventStore)
+	if !ok {
+		return nil, nil
+	}
+	events, err := es.Events(begin, end)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Event, 0, len(events))
+	for _, e := range events {
+		res = append(res, Event{e})
+	}
+	return res, nil
+}
+
+// CurrEvents return events happened between previous and current sample
+func (s *Model) CurrEvents() ([]Event, error) {
+	return s.Events(s.Prev.TimeStamp+1, s.Curr.TimeStamp)
+}
+
+func (s *Model) dumpEvents(opt DumpOption) error {
+
+	events, err := s.Events(opt.Begin, opt.End)
+	if err != nil {
+		return err
+	}
+	items := make([]Render, 0, len(events))
+	for i := range events {
+		items = append(items, &events[i])
+	}
+	return dumpList(opt, items, func(i int) int64 {
+		return events[i].TimeStamp
+	})
+}
+
+// dumpList dump items which are not computed from samples, e.g events.
+// timeStamp return time of the i-th item
+func dumpList(opt DumpOption, items []Render, timeStamp func(i int) int64) error {
+
+	switch opt.Format {
+	case "text":
+		title := fmt.Sprintf("%-25s", "TimeStamp")
+		for _, c := range opt.Fields {
+			name, width := getNameAndWidthOfField(opt.Module, c)
+			if len(name) > width {
+				width = len(name)
+			}
+			title += fmt.Sprintf(" %-*s", width, name)
+		}
+		title += "\n"
+		if !opt.DisableTitle {
+			opt.Output.WriteString(title)
+		}
+		for i, item := range items {
+			if !opt.DisableTitle && opt.RepeatTitle != 0 && i != 0 && i%opt.RepeatTitle == 0 {
+				opt.Output.WriteString(title)
+			}
+			dumpText(timeStamp(i), opt, item)
+		}
+	case "json":
+		opt.Output.WriteString("[\n")
+		first := true
+		for i, item := range items {
+			if !isFilter(opt, item) {
+				continue
+			}
+			if first {
+				first = false
+			} else {
+				opt.Output.WriteString(",\n")
+			}
+			dumpJson(timeStamp(i), opt, item)
+		}
+		opt.Output.WriteString("\n]\n")
+	case "csv", "ndjson":
+		w := newRecordWriter(opt, recordColumns(opt))
+		for i, item := range items {
+			if !isFilter(opt, item) {
+				continue
+			}
+			values := []string{time.Unix(timeStamp(i), 0).Format(time.RFC3339)}
+			for _, f := range opt.Fields {
+				values = append(values, item.GetRenderValue(f, FieldOpt{Raw: opt.RawData}))
+			}
+			if err := w.Write(values); err != nil {
+				return err
+			}
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("no support output format: %s", opt.Format)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xixiliguo/etop/procfs"
+	"github.com/xixiliguo/etop/store"
+	"golang.org/x/sys/unix"
+)
+
+func TestDetectEvents(t *testing.T) {
+
+	prev := store.NewSample()
+	prev.TimeStamp = 100
+	prev.PageSize = 4096
+	prev.VmStat = procfs.VmStat{OOMKill: 1}
+	prev.ProcSamples[10] = store.ProcSample{ProcStat: procfs.ProcStat{PID: 10, Comm: "java"}}
+
+	curr := store.NewSample()
+	curr.TimeStamp = 105
+	curr.PageSize = 4096
+	curr.VmStat = procfs.VmStat{OOMKill: 3}
+	curr.ProcSamples[10] = store.ProcSample{
+		ProcStat: procfs.ProcStat{PID: 10, Comm: "java"},
+		EndTime:  1000,
+		ExitCode: uint64(unix.SIGKILL),
+	}
+
+	sm, _ := NewSysModel(nil, slog.Default())
+	sm.CollectSample(&prev)
+	if !sm.CollectSample(&curr) {
+		t.Fatalf("second sample should be collected")
+	}
+	events := sm.DetectEvents()
+	if len(events) != 1 {
+		t.Fatalf("got %d events, but want 1: %+v", len(events), events)
+	}
+	e := events[0]
+	want := "2 processes were killed by OOM killer, exited by SIGKILL: 10(java)"
+	if e.Kind != store.EventOOMKill || e.TimeStamp != 105 || e.Message != want {
+		t.Errorf("got %+v, but want message %q", e, want)
+	}
+}
+
+func TestDumpEvents(t *testing.T) {
+	dir := t.TempDir()
+	local, err := store.NewLocalStore(
+		store.WithPathAndLogger(dir, slog.Default()),
+		store.WithWriteOnly(store.NoCompress, 0),
+	)
+	if err != nil {
+		t.Fatalf("new store: %s", err)
+	}
+	for _, e := range []store.Event{
+		{TimeStamp: 100, Kind: store.EventStart, Severity: "info", Message: "started"},
+		{TimeStamp: 200, Kind: store.EventOOMKill, Severity: "critical", Object: "/a", Message: "killed"},
+	} {
+		if err := local.WriteEvent(&e); err != nil {
+			t.Fatalf("write event: %s", err)
+		}
+	}
+	local.Close()
+
+	read, err := store.NewLocalStore(store.WithPathAndLogger(dir, slog.Default()))
+	if err != nil {
+		t.Fatalf("new store: %s", err)
+	}
+	sm, _ := NewSysModel(read, slog.Default())
+
+	out := filepath.Join(dir, "out")
+	f, _ := os.Create(out)
+	err = sm.Dump(DumpOption{
+		Begin:      0,
+		End:        300,
+		Module:     "event",
+		Output:     f,
+		Format:     "text",
+		Fields:     DefaultEventFields,
+		FilterText: `Kind == "oom_kill"`,
+	})
+	f.Close()
+	if err != nil {
+		t.Fatalf("dump events: %s", err)
+	}
+	b, _ := os.ReadFile(out)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], "oom_kill") || !strings.Contains(lines[1], "/a") {
+		t.Errorf("unexpected output:\n%s", b)
+	}
+}
@@ -28,6 +28,10 @@ type Field struct {
 
 func appendReadableSize(dst []byte, fsize float64) []byte {
 	unitMap := []string{" B", " KB", " MB", " GB", " TB", " PB"}
+	if fsize < 0 {
+		dst = append(dst, '-')
+		fsize = -fsize
+	}
 	i := 0
 	unitsLimit := len(unitMap) - 1
 	for fsize >= 1024 && i < unitsLimit {
@@ -0,0 +1,209 @@
+package model
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/xixiliguo/etop/store"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+var DefaultFilesystemFields = []string{"MountPoint", "Device", "FSType", "Size",
+	"Used", "Avail", "UsedPercent", "GrowthPerSec", "InodesPercent"}
+
+// Filesystem is capacity and inode usage of one mounted filesystem
+type Filesystem struct {
+	MountPoint    string
+	Source        string
+	FSType        string
+	Device        string // DeviceName of Disk, empty if not a block device
+	Size          uint64
+	Used          uint64
+	Avail         uint64
+	UsedPercent   float64 // like df, percent of used in used+avail
+	GrowthPerSec  float64 // bytes per second of used, negative if it shrink
+	Inodes        uint64
+	InodesUsed    uint64
+	InodesPercent float64
+}
+
+func (f *Filesystem) DefaultConfig(field string) Field {
+	cfg := Field{}
+	switch field {
+	case "MountPoint":
+		cfg = Field{"MountPoint", Raw, 0, "", 20, false}
+	case "Source":
+		cfg = Field{"Source", Raw, 0, "", 20, false}
+	case "FSType":
+		cfg = Field{"FSType", Raw, 0, "", 8, false}
+	case "Device":
+		cfg = Field{"Device", Raw, 0, "", 10, false}
+	case "Size":
+		cfg = Field{"Size", HumanReadableSize, 1, "", 10, false}
+	case "Used":
+		cfg = Field{"Used", HumanReadableSize, 1, "", 10, false}
+	case "Avail":
+		cfg = Field{"Avail", HumanReadableSize, 1, "", 10, false}
+	case "UsedPercent":
+		cfg = Field{"Use%", Raw, 1, "%", 8, false}
+	case "GrowthPerSec":
+		cfg = Field{"Growth/s", HumanReadableSize, 1, "/s", 10, false}
+	case "Inodes":
+		cfg = Field{"Inodes", Raw, 0, "", 10, false}
+	case "InodesUsed":
+		cfg = Field{"IUsed", Raw, 0, "", 10, false}
+	case "InodesPercent":
+		cfg = Field{"IUse%", Raw, 1, "%", 8, false}
+	}
+	return cfg
+}
+
+func (f *Filesystem) GetRenderValue(field string, opt FieldOpt) string {
+	cfg := f.DefaultConfig(field)
+	cfg.ApplyOpt(opt)
+	s := ""
+	switch field {
+	case "MountPoint":
+		s = cfg.Render(f.MountPoint)
+	case "Source":
+		s = cfg.Render(f.Source)
+	case "FSType":
+		s = cfg.Render(f.FSType)
+	case "Device":
+		device := f.Device
+		if device == "" {
+			device = "-"
+		}
+		s = cfg.Render(device)
+	case "Size":
+		s = cfg.Render(f.Size)
+	case "Used":
+		s = cfg.Render(f.Used)
+	case "Avail":
+		s = cfg.Render(f.Avail)
+	case "UsedPercent":
+		s = cfg.Render(f.UsedPercent)
+	case "GrowthPerSec":
+		s = cfg.Render(f.GrowthPerSec)
+	case "Inodes":
+		s = cfg.Render(f.Inodes)
+	case "InodesUsed":
+		s = cfg.Render(f.InodesUsed)
+	case "InodesPercent":
+		s = cfg.Render(f.InodesPercent)
+	default:
+		s = "no " + field + " for filesystem stat"
+	}
+	return s
+}
+
+// FilesystemSlice is filesystems order by mount point
+type FilesystemSlice []Filesystem
+
+func (fs *FilesystemSlice) Collect(prev, curr *store.Sample) {
+
+	prevUsed := make(map[string]uint64, len(prev.Filesystems))
+	for _, p := range prev.Filesystems {
+		prevUsed[p.MountPoint] = p.Used
+	}
+	interval := float64(curr.TimeStamp - prev.TimeStamp)
+
+	*fs = (*fs)[:0]
+	for _, c := range curr.Filesystems {
+		f := Filesystem{
+			MountPoint:    c.MountPoint,
+			Source:        c.Source,
+			FSType:        c.FSType,
+			Device:        c.Device,
+			Size:          c.Size,
+			Used:          c.Used,
+			Avail:         c.Avail,
+			GrowthPerSec:  math.MaxFloat64,
+			Inodes:        c.Inodes,
+			InodesUsed:    c.Inodes - c.InodesFree,
+			InodesPercent: math.MaxFloat64,
+		}
+		if c.Used+c.Avail != 0 {
+			f.UsedPercent = float64(c.Used) * 100 / float64(c.Used+c.Avail)
+		}
+		// filesystem which is mounted during interval has no growth
+		if old, ok := prevUsed[c.MountPoint]; ok && interval > 0 {
+			f.GrowthPerSec = (float64(c.Used) - float64(old)) / interval
+		}
+		if c.Inodes != 0 {
+			f.InodesPercent = float64(f.InodesUsed) * 100 / float64(c.Inodes)
+		}
+		*fs = append(*fs, f)
+	}
+	sort.Slice(*fs, func(i, j int) bool {
+		return (*fs)[i].MountPoint < (*fs)[j].MountPoint
+	})
+}
+
+func (fs FilesystemSlice) GetOtelMetric(timeStamp int64, sm *metricdata.ScopeMetrics) {
+
+	sm.Scope = instrumentation.Scope{Name: "filesystem", Version: "0.0.1"}
+	usage := metricdata.Metrics{
+		Name: "filesystem.usage",
+	}
+	usageData := metricdata.Gauge[int64]{}
+	utilization := metricdata.Metrics{
+		Name: "filesystem.utilization",
+	}
+	utilizationData := metricdata.Gauge[float64]{}
+	growth := metricdata.Metrics{
+		Name: "filesystem.growth",
+	}
+	growthData := metricdata.Gauge[float64]{}
+	inodes := metricdata.Metrics{
+		Name: "filesystem.inodes.utilization",
+	}
+	inodesData := metricdata.Gauge[float64]{}
+
+	for _, f := range fs {
+		attrs := []attribute.KeyValue{
+			attribute.String("mountpoint", f.MountPoint),
+			attribute.String("device", f.Device),
+			attribute.String("type", f.FSType),
+		}
+		usageData.DataPoints = append(usageData.DataPoints, []metricdata.DataPoint[int64]{
+			{
+				Attributes: attribute.NewSet(append(attrs, attribute.String("state", "used"))...),
+				Time:       time.Unix(timeStamp, 0),
+				Value:      int64(f.Used),
+			},
+			{
+				Attributes: attribute.NewSet(append(attrs, attribute.String("state", "free"))...),
+				Time:       time.Unix(timeStamp, 0),
+				Value:      int64(f.Avail),
+			},
+		}...)
+		utilizationData.DataPoints = append(utilizationData.DataPoints, metricdata.DataPoint[float64]{
+			Attributes: attribute.NewSet(attrs...),
+			Time:       time.Unix(timeStamp, 0),
+			Value:      f.UsedPercent,
+		})
+		if f.GrowthPerSec != math.MaxFloat64 {
+			growthData.DataPoints = append(growthData.DataPoints, metricdata.DataPoint[float64]{
+				Attributes: attribute.NewSet(attrs...),
+				Time:       time.Unix(timeStamp, 0),
+				Value:      f.GrowthPerSec,
+			})
+		}
+		if f.InodesPercent != math.MaxFloat64 {
+			inodesData.DataPoints = append(inodesData.DataPoints, metricdata.DataPoint[float64]{
+				Attributes: attribute.NewSet(attrs...),
+				Time:       time.Unix(timeStamp, 0),
+				Value:      f.InodesPercent,
+			})
+		}
+	}
+	usage.Data = usageData
+	utilization.Data = utilizationData
+	growth.Data = growthData
+	inodes.Data = inodesData
+	sm.Metrics = append(sm.Metrics, usage, utilization, growth, inodes)
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/xixiliguo/etop/store"
+)
+
+func TestFilesystem(t *testing.T) {
+	prev := store.NewSample()
+	prev.TimeStamp = 100
+	prev.Filesystems = []store.Filesystem{
+		{MountPoint: "/", Device: "vda1", FSType: "ext4", Size: 100 << 30, Used: 60 << 30, Avail: 35 << 30},
+		{MountPoint: "/data", Device: "dm-0", FSType: "xfs", Size: 10 << 30, Used: 5 << 30, Avail: 5 << 30},
+	}
+	curr := store.NewSample()
+	curr.TimeStamp = 110
+	curr.Filesystems = []store.Filesystem{
+		{MountPoint: "/data", Device: "dm-0", FSType: "xfs", Size: 10 << 30, Used: 5<<30 - 10<<20, Avail: 5<<30 + 10<<20,
+			Inodes: 1000, InodesFree: 250},
+		{MountPoint: "/", Device: "vda1", FSType: "ext4", Size: 100 << 30, Used: 60<<30 + 100<<20, Avail: 35<<30 - 100<<20},
+		{MountPoint: "/mnt", FSType: "btrfs", Size: 1 << 30, Used: 0, Avail: 1 << 30},
+	}
+
+	fs := FilesystemSlice{}
+	fs.Collect(&prev, &curr)
+	if len(fs) != 3 || fs[0].MountPoint != "/" || fs[1].MountPoint != "/data" || fs[2].MountPoint != "/mnt" {
+		t.Fatalf("got %+v, but want order by mount point", fs)
+	}
+	for i, want := range []map[string]string{
+		{"UsedPercent": "63.3%", "GrowthPerSec": "10.0 MB/s", "InodesPercent": "-"},
+		{"Device": "dm-0", "UsedPercent": "49.9%", "GrowthPerSec": "-1.0 MB/s", "InodesPercent": "75.0%"},
+		{"Device": "-", "UsedPercent": "0.0%", "GrowthPerSec": "-", "Avail": "1.0 GB"},
+	} {
+		for field, w := range want {
+			if got := fs[i].GetRenderValue(field, FieldOpt{}); got != w {
+				t.Errorf("%s of %s: got %s, but want %s", field, fs[i].MountPoint, got, w)
+			}
+		}
+	}
+	for _, f := range DefaultFilesystemFields {
+		if name, _ := getNameAndWidthOfField("filesystem", f); name == "" {
+			t.Errorf("%s should be a field of filesystem", f)
+		}
+	}
+}
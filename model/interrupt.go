@@ -0,0 +1,185 @@
+package model
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xixiliguo/etop/procfs"
+	"github.com/xixiliguo/etop/store"
+)
+
+var DefaultInterruptFields = []string{"Name", "PerSec", "MaxCPU", "MaxShare", "Chip", "Device"}
+
+var DefaultSoftirqFields = []string{"Name", "PerSec", "MaxCPU", "MaxShare"}
+
+// Interrupt is rate of one line of /proc/interrupts or /proc/softirqs.
+// rate of every cpu is field like CPU0
+type Interrupt struct {
+	Name     string // irq number, arch-specific name like NMI, or softirq like NET_RX
+	Chip     string
+	Device   string
+	PerSec   float64 // of all cpus
+	MaxCPU   int     // cpu which handle the most, -1 if unknown
+	MaxShare float64 // percent of MaxCPU in all cpus, 100 means no balance at all
+	CPUs     []int
+	PerCPU   []float64 // rate of every cpu in CPUs
+}
+
+// cpuField return cpu of field like CPU0
+func cpuField(field string) (int, bool) {
+	c, ok := strings.CutPrefix(field, "CPU")
+	if !ok {
+		return 0, false
+	}
+	cpu, err := strconv.Atoi(c)
+	return cpu, err == nil && cpu >= 0
+}
+
+func (irq *Interrupt) DefaultConfig(field string) Field {
+	cfg := Field{}
+	switch field {
+	case "Name":
+		cfg = Field{"Name", Raw, 0, "", 10, false}
+	case "Chip":
+		cfg = Field{"Chip", Raw, 0, "", 20, false}
+	case "Device":
+		cfg = Field{"Device", Raw, 0, "", 20, false}
+	case "PerSec":
+		cfg = Field{"PerSec", Raw, 1, "/s", 12, false}
+	case "MaxCPU":
+		cfg = Field{"MaxCPU", Raw, 0, "", 6, false}
+	case "MaxShare":
+		cfg = Field{"MaxShare", Raw, 1, "%", 8, false}
+	default:
+		if _, ok := cpuField(field); ok {
+			cfg = Field{field, Raw, 1, "/s", 10, false}
+		}
+	}
+	return cfg
+}
+
+func (irq *Interrupt) GetRenderValue(field string, opt FieldOpt) string {
+	cfg := irq.DefaultConfig(field)
+	cfg.ApplyOpt(opt)
+	s := ""
+	switch field {
+	case "Name":
+		s = cfg.Render(irq.Name)
+	case "Chip":
+		s = cfg.Render(irq.Chip)
+	case "Device":
+		s = cfg.Render(irq.Device)
+	case "PerSec":
+		s = cfg.Render(irq.PerSec)
+	case "MaxCPU":
+		if irq.MaxCPU < 0 {
+			s = cfg.Render("-")
+		} else {
+			s = cfg.Render(irq.MaxCPU)
+		}
+	case "MaxShare":
+		s = cfg.Render(irq.MaxShare)
+	default:
+		if cpu, ok := cpuField(field); ok {
+			// unknown if cpu is offline or not counted per cpu
+			r := math.MaxFloat64
+			for i, c := range irq.CPUs {
+				if c == cpu && i < len(irq.PerCPU) {
+					r = irq.PerCPU[i]
+				}
+			}
+			s = cfg.Render(r)
+		} else {
+			s = "no " + field + " for interrupt stat"
+		}
+	}
+	return s
+}
+
+// InterruptSlice is interrupts or softirqs in order of file
+type InterruptSlice []Interrupt
+
+func (irqs *InterruptSlice) collect(prev, curr *procfs.Interrupts, interval int64) {
+
+	*irqs = (*irqs)[:0]
+
+	// count of cpu in prev, cpu may be offline or online between samples
+	type key struct {
+		name string
+		cpu  int
+	}
+	old := make(map[key]uint64)
+	for _, l := range prev.Lines {
+		if len(l.Counts) == 0 {
+			continue
+		} else if len(l.Counts) != len(prev.CPUs) {
+			old[key{l.Name, -1}] = l.Counts[0]
+			continue
+		}
+		for i, cnt := range l.Counts {
+			old[key{l.Name, prev.CPUs[i]}] = cnt
+		}
+	}
+	delta := func(name string, cpu int, cnt uint64) float64 {
+		o, ok := old[key{name, cpu}]
+		if !ok || cnt < o {
+			return 0
+		}
+		return float64(cnt-o) / float64(interval)
+	}
+
+	for _, l := range curr.Lines {
+		irq := Interrupt{
+			Name:   l.Name,
+			Chip:   l.Chip,
+			Device: l.Device,
+			MaxCPU: -1,
+		}
+		if len(l.Counts) != len(curr.CPUs) {
+			// e.g ERR, which is not counted per cpu
+			if len(l.Counts) != 0 {
+				irq.PerSec = delta(l.Name, -1, l.Counts[0])
+			}
+			*irqs = append(*irqs, irq)
+			continue
+		}
+		irq.CPUs = curr.CPUs
+		irq.PerCPU = make([]float64, len(l.Counts))
+		maxRate := 0.0
+		for i, cnt := range l.Counts {
+			r := delta(l.Name, curr.CPUs[i], cnt)
+			irq.PerCPU[i] = r
+			irq.PerSec += r
+			if r > maxRate {
+				maxRate = r
+				irq.MaxCPU = curr.CPUs[i]
+			}
+		}
+		if irq.PerSec > 0 {
+			irq.MaxShare = maxRate * 100 / irq.PerSec
+		}
+		*irqs = append(*irqs, irq)
+	}
+}
+
+func (irqs *InterruptSlice) CollectInterrupts(prev, curr *store.Sample) {
+	irqs.collect(&prev.Interrupts, &curr.Interrupts, curr.TimeStamp-prev.TimeStamp)
+}
+
+func (irqs *InterruptSlice) CollectSoftirqs(prev, curr *store.Sample) {
+	irqs.collect(&prev.Softirqs, &curr.Softirqs, curr.TimeStamp-prev.TimeStamp)
+}
+
+// Iterate return interrupts order by rate of all cpus
+func (irqs InterruptSlice) Iterate() []*Interrupt {
+	res := make([]*Interrupt, 0, len(irqs))
+	for i := range irqs {
+		res = append(res, &irqs[i])
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].PerSec > res[j].PerSec
+	})
+	return res
+}
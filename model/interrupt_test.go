@@ -0,0 +1,70 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/xixiliguo/etop/procfs"
+	"github.com/xixiliguo/etop/store"
+)
+
+func TestInterrupt(t *testing.T) {
+	prev := store.NewSample()
+	prev.TimeStamp = 100
+	prev.Interrupts = procfs.Interrupts{
+		CPUs: []int{0, 1, 2},
+		Lines: []procfs.InterruptLine{
+			{Name: "24", Chip: "PCI-MSIX 0-edge", Device: "eth0-rx-0", Counts: []uint64{100, 10, 10}},
+			{Name: "ERR", Counts: []uint64{5}},
+		},
+	}
+	// cpu 1 is offline, and irq 25 is new
+	curr := store.NewSample()
+	curr.TimeStamp = 110
+	curr.Interrupts = procfs.Interrupts{
+		CPUs: []int{0, 2},
+		Lines: []procfs.InterruptLine{
+			{Name: "24", Chip: "PCI-MSIX 0-edge", Device: "eth0-rx-0", Counts: []uint64{1100, 20}},
+			{Name: "25", Chip: "PCI-MSIX 1-edge", Device: "eth0-rx-1", Counts: []uint64{50, 60}},
+			{Name: "ERR", Counts: []uint64{25}},
+		},
+	}
+
+	irqs := InterruptSlice{}
+	irqs.CollectInterrupts(&prev, &curr)
+	if len(irqs) != 3 {
+		t.Fatalf("got %+v", irqs)
+	}
+	for _, c := range []struct {
+		idx   int
+		field string
+		want  string
+	}{
+		{0, "PerSec", "101.0/s"},
+		{0, "MaxCPU", "0"},
+		{0, "MaxShare", "99.0%"},
+		{0, "CPU0", "100.0/s"},
+		{0, "CPU2", "1.0/s"},
+		{0, "CPU1", "-"},
+		{0, "Device", "eth0-rx-0"},
+		{1, "PerSec", "0.0/s"},
+		{1, "MaxCPU", "-"},
+		{2, "Name", "ERR"},
+		{2, "PerSec", "2.0/s"},
+		{2, "CPU0", "-"},
+	} {
+		if got := irqs[c.idx].GetRenderValue(c.field, FieldOpt{}); got != c.want {
+			t.Errorf("%s of %s: got %s, but want %s", c.field, irqs[c.idx].Name, got, c.want)
+		}
+	}
+	if top := irqs.Iterate(); top[0].Name != "24" || top[1].Name != "ERR" {
+		t.Errorf("got %s %s, but want order by rate", top[0].Name, top[1].Name)
+	}
+	for _, f := range append(DefaultInterruptFields, "CPU12") {
+		if name, _ := getNameAndWidthOfField("interrupt", f); name == "" {
+			t.Errorf("%s should be a field of interrupt", f)
+		}
+	}
+	if name, _ := getNameAndWidthOfField("softirq", "CPUx"); name != "" {
+		t.Errorf("CPUx should not be a field")
+	}
+}
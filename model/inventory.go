@@ -0,0 +1,80 @@
+package model
+
+import (
+	"github.com/xixiliguo/etop/store"
+)
+
+var DefaultInventoryFields = []string{"Kind", "Name", "Detail"}
+
+// Inventory is one part of host inventory, e.g one disk
+type Inventory struct {
+	TimeStamp int64 // when inventory was recorded
+	store.InventoryItem
+}
+
+func (i *Inventory) DefaultConfig(field string) Field {
+	cfg := Field{}
+	switch field {
+	case "Kind":
+		cfg = Field{"Kind", Raw, 0, "", 5, false}
+	case "Name":
+		cfg = Field{"Name", Raw, 0, "", 20, false}
+	case "Detail":
+		cfg = Field{"Detail", Raw, 0, "", 10, false}
+	}
+	return cfg
+}
+
+func (i *Inventory) GetRenderValue(field string, opt FieldOpt) string {
+	cfg := i.DefaultConfig(field)
+	cfg.ApplyOpt(opt)
+	s := ""
+	switch field {
+	case "Kind":
+		s = cfg.Render(i.Kind)
+	case "Name":
+		name := i.Name
+		if name == "" {
+			name = "-"
+		}
+		s = cfg.Render(name)
+	case "Detail":
+		s = cfg.Render(i.Detail)
+	}
+	return s
+}
+
+// Inventories return inventory in effect at begin and inventories
+// recorded until end, or nothing if store does not support inventory
+func (s *Model) Inventories(begin, end int64) ([]Inventory, error) {
+	is, ok := s.Store.(store.InventoryStore)
+	if !ok {
+		return nil, nil
+	}
+	invs, err := is.Inventories(begin, end)
+	if err != nil {
+		return nil, err
+	}
+	res := []Inventory{}
+	for _, inv := range invs {
+		for _, item := range inv.Items() {
+			res = append(res, Inventory{inv.TimeStamp, item})
+		}
+	}
+	return res, nil
+}
+
+func (s *Model) dumpInventory(opt DumpOption) error {
+
+	invs, err := s.Inventories(opt.Begin, opt.End)
+	if err != nil {
+		return err
+	}
+	items := make([]Render, 0, len(invs))
+	for i := range invs {
+		items = append(items, &invs[i])
+	}
+	return dumpList(opt, items, func(i int) int64 {
+		return invs[i].TimeStamp
+	})
+}
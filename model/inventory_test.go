@@ -0,0 +1,61 @@
+package model
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xixiliguo/etop/procfs"
+	"github.com/xixiliguo/etop/store"
+)
+
+func TestDumpInventory(t *testing.T) {
+	dir := t.TempDir()
+	local, err := store.NewLocalStore(
+		store.WithPathAndLogger(dir, slog.Default()),
+		store.WithWriteOnly(store.NoCompress, 0),
+	)
+	if err != nil {
+		t.Fatalf("new store: %s", err)
+	}
+	for _, inv := range []store.Inventory{
+		{TimeStamp: 100, HostName: "a", Disks: []procfs.BlockDevice{{Name: "sda", Rotational: true}}},
+		{TimeStamp: 200, HostName: "a", Disks: []procfs.BlockDevice{{Name: "sda"}, {Name: "nvme0n1"}}},
+	} {
+		if err := local.WriteInventory(&inv); err != nil {
+			t.Fatalf("write inventory: %s", err)
+		}
+	}
+	local.Close()
+
+	read, err := store.NewLocalStore(store.WithPathAndLogger(dir, slog.Default()))
+	if err != nil {
+		t.Fatalf("new store: %s", err)
+	}
+	sm, _ := NewSysModel(read, slog.Default())
+
+	out := filepath.Join(dir, "out")
+	f, _ := os.Create(out)
+	// inventory at 100 is still in effect at 150
+	err = sm.Dump(DumpOption{
+		Begin:      150,
+		End:        300,
+		Module:     "inventory",
+		Output:     f,
+		Format:     "csv",
+		Fields:     DefaultInventoryFields,
+		FilterText: `Kind == "disk"`,
+	})
+	f.Close()
+	if err != nil {
+		t.Fatalf("dump inventory: %s", err)
+	}
+	b, _ := os.ReadFile(out)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 4 || !strings.Contains(lines[1], "sda,\"unknown model, hdd") ||
+		!strings.Contains(lines[3], "nvme0n1") {
+		t.Errorf("unexpected output:\n%s", b)
+	}
+}
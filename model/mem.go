@@ -428,3 +428,38 @@ func (m *MEM) GetOtelMetric(timeStamp int64, sm *metricdata.ScopeMetrics) {
 	md.Data = data
 	sm.Metrics = append(sm.Metrics, md)
 }
+
+func (m *MEM) GetPromMetric(w *PromWriter) {
+	w.Metric("memory_bytes", "gauge", "Memory usage from /proc/meminfo.")
+	for _, v := range []struct {
+		state string
+		value uint64
+	}{
+		{"total", m.MemTotal},
+		{"free", m.MemFree},
+		{"avail", m.MemAvailable},
+		{"buffer", m.Buffers},
+		{"cache", m.Cached},
+		{"shmem", m.Shmem},
+		{"slab", m.Slab},
+		{"slab_reclaimable", m.SReclaimable},
+		{"slab_unreclaimable", m.SUnreclaim},
+		{"active_anon", m.ActiveAnon},
+		{"inactive_anon", m.InactiveAnon},
+		{"active_file", m.ActiveFile},
+		{"inactive_file", m.InactiveFile},
+		{"dirty", m.Dirty},
+		{"writeback", m.Writeback},
+		{"anon", m.AnonPages},
+		{"mapped", m.Mapped},
+		{"kernel_stack", m.KernelStack},
+		{"page_tables", m.PageTables},
+		{"swap_total", m.SwapTotal},
+		{"swap_free", m.SwapFree},
+		{"swap_cached", m.SwapCached},
+		{"committed_as", m.CommittedAS},
+		{"anon_huge_pages", m.AnonHugePages},
+	} {
+		w.Sample("memory_bytes", float64(v.value)*1024, "state", v.state)
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"iter"
 	"log/slog"
 	"os"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/expr-lang/expr"
@@ -15,8 +18,6 @@ import (
 	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
 	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/metric/metricdata"
-	"go.opentelemetry.io/otel/sdk/resource"
-	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
 )
 
 type Model struct {
@@ -30,14 +31,25 @@ type Model struct {
 	MEM
 	Vm
 	Disks        DiskMap
+	Filesystems  FilesystemSlice
 	Nets         NetDevMap
 	NetProtocols NetProtocolMap
+	NetSNMP      NetSNMP
+	Socket       Socket
+	Connections  ConnectionSlice
 	Softnets     SoftnetSlice
+	Interrupts   InterruptSlice
+	Softirqs     InterruptSlice
 	Processes    ProcessMap
+	Threads      ThreadMap
 	Cgroup
+	Etop Etop
+	// last sample which has module, it is used as previous sample of
+	// module which was not collected in Prev
+	lastSeen map[string]*store.Sample
 }
 
-func NewSysModel(s *store.LocalStore, log *slog.Logger) (*Model, error) {
+func NewSysModel(s store.Store, log *slog.Logger) (*Model, error) {
 	p := &Model{
 		Mode:         "report",
 		Store:        s,
@@ -49,20 +61,29 @@ func NewSysModel(s *store.LocalStore, log *slog.Logger) (*Model, error) {
 		MEM:          MEM{},
 		Vm:           Vm{},
 		Disks:        make(DiskMap),
+		Filesystems:  []Filesystem{},
 		Nets:         make(NetDevMap),
 		NetProtocols: make(NetProtocolMap),
+		NetSNMP:      NetSNMP{},
+		Socket:       Socket{},
+		Connections:  []Connection{},
 		Softnets:     []Softnet{},
+		Interrupts:   []Interrupt{},
+		Softirqs:     []Interrupt{},
 		Processes:    make(ProcessMap),
+		Threads:      make(ThreadMap),
 		Cgroup:       Cgroup{},
+		Etop:         Etop{},
+		lastSeen:     make(map[string]*store.Sample),
 	}
 	return p, nil
 }
 
-func (s *Model) CollectLiveSample(exit *store.ExitProcess, c *store.CgroupNetStat) error {
+func (s *Model) CollectLiveSample(cs *store.Collectors) error {
 
 	s.Prev = s.Curr
 	s.Curr = store.NewSample()
-	if err := store.CollectSampleFromSys(&s.Curr, exit, c, s.log); err != nil {
+	if err := store.CollectSampleFromSys(&s.Curr, cs); err != nil {
 		return err
 	}
 	s.CollectField()
@@ -87,6 +108,7 @@ func (s *Model) CollectNext() error {
 }
 
 func (s *Model) CollectPrev() error {
+	clear(s.lastSeen)
 	n := store.NewSample()
 	if err := s.Store.NextSample(-2, &n); err != nil {
 		return err
@@ -106,6 +128,7 @@ func (s *Model) CollectPrev() error {
 }
 
 func (s *Model) CollectSampleByTime(timeStamp int64) error {
+	clear(s.lastSeen)
 	s.Curr = store.NewSample()
 	if err := s.Store.JumpSampleByTimeStamp(timeStamp, &s.Curr); err != nil {
 		return err
@@ -128,18 +151,104 @@ func (s *Model) CollectSampleByTime(timeStamp int64) error {
 	return nil
 }
 
+// CollectSample take n as current sample and compute all fields, it is used
+// when samples are generated by caller (e.g etop record) instead of Store.
+// return false if no previous sample can compare with n.
+func (s *Model) CollectSample(n *store.Sample) bool {
+	s.Prev = s.Curr
+	s.Curr = *n
+	if s.Prev.TimeStamp == 0 || s.Prev.TimeStamp >= s.Curr.TimeStamp {
+		s.remember()
+		return false
+	}
+	if s.Curr.BootTime != s.Prev.BootTime {
+		//system ever reboot, skip one sample
+		s.log.Info("skip one sample since system reboot")
+		return false
+	}
+	s.CollectField()
+	return true
+}
+
+// CollectField compute all modules from Prev and Curr. module which was
+// not collected in Curr (see store.Sample.Missing) keep values computed
+// before, and module which was not collected in Prev is computed with
+// the last sample which has it. if there is no such sample, e.g after
+// jumping to other time, module is reset as missing.
 func (s *Model) CollectField() {
 
-	s.Sys.Collect(&s.Prev, &s.Curr)
-	s.CPUs.Collect(&s.Prev, &s.Curr)
-	s.MEM.Collect(&s.Prev, &s.Curr)
-	s.Vm.Collect(&s.Prev, &s.Curr)
-	s.Disks.Collect(&s.Prev, &s.Curr)
-	s.Nets.Collect(&s.Prev, &s.Curr)
-	s.NetProtocols.Collect(&s.Prev, &s.Curr)
-	s.Softnets.Collect(&s.Prev, &s.Curr)
-	s.Sys.Processes, s.Sys.Threads = s.Processes.Collect(&s.Prev, &s.Curr)
-	s.Cgroup.Collect(&s.Prev.CgroupSample, &s.Curr.CgroupSample, s.Curr.TimeStamp-s.Prev.TimeStamp)
+	collectors := []struct {
+		module  string
+		collect func(prev, curr *store.Sample)
+		reset   func()
+	}{
+		{store.ModuleStat, func(prev, curr *store.Sample) {
+			s.Sys.Collect(prev, curr)
+			s.CPUs.Collect(prev, curr)
+		}, func() {
+			s.Sys = System{}
+			s.CPUs = s.CPUs[:0]
+		}},
+		{store.ModuleMeminfo, s.MEM.Collect, func() { s.MEM = MEM{} }},
+		{store.ModuleVmstat, s.Vm.Collect, func() { s.Vm = Vm{} }},
+		{store.ModuleDiskstats, s.Disks.Collect, func() { clear(s.Disks) }},
+		{store.ModuleFilesystem, s.Filesystems.Collect, func() { s.Filesystems = s.Filesystems[:0] }},
+		{store.ModuleNetDev, s.Nets.Collect, func() { clear(s.Nets) }},
+		{store.ModuleProtocols, s.NetProtocols.Collect, func() { clear(s.NetProtocols) }},
+		{store.ModuleSNMP, s.NetSNMP.Collect, func() { s.NetSNMP = NetSNMP{} }},
+		{store.ModuleSockets, func(prev, curr *store.Sample) {
+			s.Socket.Collect(prev, curr)
+			s.Connections.Collect(prev, curr)
+		}, func() {
+			s.Socket = Socket{}
+			s.Connections = s.Connections[:0]
+		}},
+		{store.ModuleSoftnet, s.Softnets.Collect, func() { s.Softnets = s.Softnets[:0] }},
+		{store.ModulePressure, s.Sys.CollectPressure, s.Sys.resetPressure},
+		{store.ModuleInterrupts, s.Interrupts.CollectInterrupts, func() { s.Interrupts = s.Interrupts[:0] }},
+		{store.ModuleSoftirqs, s.Softirqs.CollectSoftirqs, func() { s.Softirqs = s.Softirqs[:0] }},
+		{store.ModuleProcess, func(prev, curr *store.Sample) {
+			s.Sys.Processes, s.Sys.Threads = s.Processes.Collect(prev, curr)
+			s.Threads.Collect(prev, curr)
+		}, func() {
+			clear(s.Processes)
+			clear(s.Threads)
+		}},
+		{store.ModuleCgroup, func(prev, curr *store.Sample) {
+			s.Cgroup.Collect(&prev.CgroupSample, &curr.CgroupSample, curr.TimeStamp-prev.TimeStamp)
+		}, func() { s.Cgroup = Cgroup{} }},
+		// etop is always collected
+		{"etop", s.Etop.Collect, func() { s.Etop = Etop{} }},
+	}
+
+	for _, c := range collectors {
+		if !s.Curr.Has(c.module) {
+			continue
+		}
+		prev := &s.Prev
+		if !prev.Has(c.module) {
+			prev = s.lastSeen[c.module]
+		}
+		if prev == nil || prev.TimeStamp >= s.Curr.TimeStamp || prev.BootTime != s.Curr.BootTime {
+			c.reset()
+		} else {
+			c.collect(prev, &s.Curr)
+		}
+	}
+	s.remember()
+}
+
+// remember Curr as the last sample of modules which it has
+func (s *Model) remember() {
+	if s.lastSeen == nil {
+		s.lastSeen = make(map[string]*store.Sample)
+	}
+	curr := s.Curr
+	for _, m := range store.Modules {
+		if curr.Has(m) {
+			s.lastSeen[m] = &curr
+		}
+	}
 }
 
 type DumpOption struct {
@@ -157,6 +266,8 @@ type DumpOption struct {
 	DisableTitle    bool
 	RepeatTitle     int
 	RawData         bool
+	Aggregate       time.Duration // output stats of every window instead of every sample
+	Stats           []string      // e.g min, avg, max, p95, only valid with Aggregate
 }
 
 func (s *Model) Dump(opt DumpOption) error {
@@ -170,12 +281,39 @@ func (s *Model) Dump(opt DumpOption) error {
 	if err := verifyFilterText(&opt); err != nil {
 		return err
 	}
+	if opt.Module == "event" || opt.Module == "inventory" {
+		if opt.Aggregate > 0 {
+			return fmt.Errorf("no support aggregate for module %s", opt.Module)
+		}
+		if opt.Module == "inventory" {
+			return s.dumpInventory(opt)
+		}
+		return s.dumpEvents(opt)
+	}
+	if opt.Aggregate > 0 {
+		if opt.Aggregate < time.Second {
+			return fmt.Errorf("aggregate window should be at least 1s, but get %s", opt.Aggregate)
+		}
+		stats, err := ParseStats(opt.Stats)
+		if err != nil {
+			return err
+		}
+		opt.Stats = stats
+		switch opt.Format {
+		case "text", "json", "csv", "ndjson":
+		default:
+			return fmt.Errorf("no support output format: %s", opt.Format)
+		}
+		return s.dumpAggregate(opt)
+	}
 
 	switch opt.Format {
 	case "text":
 		return s.dumpText(opt)
 	case "json":
 		return s.dumpJson(opt)
+	case "csv", "ndjson":
+		return s.dumpRecord(opt)
 	default:
 		return fmt.Errorf("no support output format: %s", opt.Format)
 	}
@@ -212,20 +350,11 @@ func (s *Model) DumpToOtel(opt DumpOtelOption) (err error) {
 
 	cnt := 0
 	rm := metricdata.ResourceMetrics{
-		Resource: resource.NewSchemaless(
-			semconv.ServiceName("etop"),
-			semconv.ServiceInstanceID(s.Curr.HostName),
-		),
+		Resource: otelResource(s.Curr.HostName, nil),
 	}
 
 	for opt.End >= s.Curr.TimeStamp {
-		var sm metricdata.ScopeMetrics
-		s.CPUs.GetOtelMetric(s.Curr.TimeStamp, &sm)
-		s.MEM.GetOtelMetric(s.Curr.TimeStamp, &sm)
-		s.Disks.GetOtelMetric(s.Curr.TimeStamp, &sm)
-		s.Nets.GetOtelMetric(s.Curr.TimeStamp, &sm)
-		s.Processes.GetOtelMetric(s.Curr.TimeStamp, &sm)
-		rm.ScopeMetrics = append(rm.ScopeMetrics, sm)
+		rm.ScopeMetrics = append(rm.ScopeMetrics, s.GetOtelMetrics()...)
 		if err := s.CollectNext(); err != nil {
 			if err == store.ErrOutOfRange {
 				break
@@ -254,8 +383,14 @@ func verifyFilterText(opt *DumpOption) (err error) {
 	if opt.FilterText == "" {
 		return nil
 	}
+	opt.FilterProgram, err = CompileFilter(opt.Module, opt.FilterText)
+	return err
+}
+
+// CompileFilter compile text as bool expression against one object of module
+func CompileFilter(module string, text string) (*vm.Program, error) {
 	var s any
-	switch opt.Module {
+	switch module {
 	case "system":
 		s = &System{}
 	case "cpu":
@@ -264,21 +399,152 @@ func verifyFilterText(opt *DumpOption) (err error) {
 		s = &MEM{}
 	case "vm":
 		s = &Vm{}
+	case "etop":
+		s = &Etop{}
 	case "disk":
 		s = &Disk{}
+	case "filesystem":
+		s = &Filesystem{}
 	case "netdev":
 		s = &NetDev{}
 	case "networkprotocol":
 		s = &NetProtocol{}
+	case "snmp":
+		s = &NetSNMP{}
+	case "socket":
+		s = &Socket{}
+	case "connection":
+		s = &Connection{}
 	case "softnet":
 		s = &Softnet{}
+	case "interrupt", "softirq":
+		s = &Interrupt{}
 	case "process":
 		s = &Process{}
+	case "thread":
+		s = &Thread{}
 	case "cgroup":
 		s = &Cgroup{}
+	case "event":
+		s = &Event{}
+	case "inventory":
+		s = &Inventory{}
+	default:
+		return nil, fmt.Errorf("no support module: %s", module)
+	}
+	return expr.Compile(text, expr.Env(s), expr.AsBool())
+}
+
+// Modules are modules which can be iterated by IterateModule
+var Modules = []string{"system", "cpu", "memory", "vm", "disk", "filesystem", "netdev",
+	"networkprotocol", "snmp", "socket", "connection", "softnet", "interrupt", "softirq", "process", "thread",
+	"cgroup", "etop"}
+
+// IterateModule yield all objects of module in current sample, key is
+// name of object (e.g disk name, pid) and empty for single object module
+func (s *Model) IterateModule(module string) iter.Seq2[string, Render] {
+	return func(yield func(string, Render) bool) {
+		switch module {
+		case "system":
+			yield("", &s.Sys)
+		case "cpu":
+			for i := range s.CPUs {
+				if !yield(s.CPUs[i].Index, &s.CPUs[i]) {
+					return
+				}
+			}
+		case "memory":
+			yield("", &s.MEM)
+		case "vm":
+			yield("", &s.Vm)
+		case "etop":
+			yield("", &s.Etop)
+		case "snmp":
+			yield("", &s.NetSNMP)
+		case "socket":
+			yield("", &s.Socket)
+		case "connection":
+			for i := range s.Connections {
+				c := &s.Connections[i]
+				if !yield(c.Proto+" "+c.Local+" "+c.Remote, c) {
+					return
+				}
+			}
+		case "disk":
+			for _, disk := range s.Disks.Iterate() {
+				if !yield(disk.DeviceName, disk) {
+					return
+				}
+			}
+		case "filesystem":
+			for i := range s.Filesystems {
+				f := &s.Filesystems[i]
+				if !yield(f.MountPoint, f) {
+					return
+				}
+			}
+		case "netdev":
+			for _, dev := range s.Nets.GetKeys() {
+				n := s.Nets[dev]
+				if !yield(dev, &n) {
+					return
+				}
+			}
+		case "networkprotocol":
+			names := make([]string, 0, len(s.NetProtocols))
+			for name := range s.NetProtocols {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				n := s.NetProtocols[name]
+				if !yield(name, &n) {
+					return
+				}
+			}
+		case "softnet":
+			for i := range s.Softnets {
+				if !yield(strconv.FormatUint(s.Softnets[i].CPU, 10), &s.Softnets[i]) {
+					return
+				}
+			}
+		case "interrupt", "softirq":
+			irqs := s.Interrupts
+			if module == "softirq" {
+				irqs = s.Softirqs
+			}
+			for i := range irqs {
+				if !yield(irqs[i].Name, &irqs[i]) {
+					return
+				}
+			}
+		case "process":
+			for _, p := range s.Processes.Iterate(nil, "Pid", false) {
+				if !yield(fmt.Sprintf("%d(%s)", p.Pid, p.Comm), p) {
+					return
+				}
+			}
+		case "thread":
+			for _, t := range s.Threads.Iterate(0, nil, "Tid", false) {
+				if !yield(fmt.Sprintf("%d(%s)", t.Tid, t.Comm), t) {
+					return
+				}
+			}
+		case "cgroup":
+			if s.Cgroup.FullPath == "" {
+				return
+			}
+			cgs := []*Cgroup{}
+			s.Cgroup.Walk(func(c *Cgroup) {
+				cgs = append(cgs, c)
+			})
+			for _, c := range cgs {
+				if !yield(c.FullPath, c) {
+					return
+				}
+			}
+		}
 	}
-	opt.FilterProgram, err = expr.Compile(opt.FilterText, expr.Env(s), expr.AsBool())
-	return err
 }
 
 func isFilter(opt DumpOption, m Render) bool {
@@ -290,6 +556,11 @@ func isFilter(opt DumpOption, m Render) bool {
 }
 
 func getNameAndWidthOfField(module string, f string) (string, int) {
+	cfg := defaultConfigOfField(module, f)
+	return cfg.Name, cfg.Width
+}
+
+func defaultConfigOfField(module string, f string) Field {
 	var s Render
 	switch module {
 	case "system":
@@ -300,21 +571,38 @@ func getNameAndWidthOfField(module string, f string) (string, int) {
 		s = &MEM{}
 	case "vm":
 		s = &Vm{}
+	case "etop":
+		s = &Etop{}
 	case "disk":
 		s = &Disk{}
+	case "filesystem":
+		s = &Filesystem{}
 	case "netdev":
 		s = &NetDev{}
 	case "networkprotocol":
 		s = &NetProtocol{}
+	case "snmp":
+		s = &NetSNMP{}
+	case "socket":
+		s = &Socket{}
+	case "connection":
+		s = &Connection{}
 	case "softnet":
 		s = &Softnet{}
+	case "interrupt", "softirq":
+		s = &Interrupt{}
 	case "process":
 		s = &Process{}
+	case "thread":
+		s = &Thread{}
 	case "cgroup":
 		s = &Cgroup{}
+	case "event":
+		s = &Event{}
+	case "inventory":
+		s = &Inventory{}
 	}
-	cfg := s.DefaultConfig(f)
-	return cfg.Name, cfg.Width
+	return s.DefaultConfig(f)
 }
 
 func (s *Model) dumpText(opt DumpOption) error {
@@ -351,10 +639,24 @@ func (s *Model) dumpText(opt DumpOption) error {
 			dumpText(s.Curr.TimeStamp, opt, &s.MEM)
 		case "vm":
 			dumpText(s.Curr.TimeStamp, opt, &s.Vm)
+		case "etop":
+			dumpText(s.Curr.TimeStamp, opt, &s.Etop)
+		case "snmp":
+			dumpText(s.Curr.TimeStamp, opt, &s.NetSNMP)
+		case "socket":
+			dumpText(s.Curr.TimeStamp, opt, &s.Socket)
+		case "connection":
+			for _, c := range s.Connections {
+				dumpText(s.Curr.TimeStamp, opt, &c)
+			}
 		case "disk":
 			for _, disk := range s.Disks.Iterate() {
 				dumpText(s.Curr.TimeStamp, opt, disk)
 			}
+		case "filesystem":
+			for _, f := range s.Filesystems {
+				dumpText(s.Curr.TimeStamp, opt, &f)
+			}
 		case "netdev":
 			for _, dev := range s.Nets.GetKeys() {
 				n := s.Nets[dev]
@@ -368,6 +670,14 @@ func (s *Model) dumpText(opt DumpOption) error {
 			for _, soft := range s.Softnets {
 				dumpText(s.Curr.TimeStamp, opt, &soft)
 			}
+		case "interrupt":
+			for _, irq := range s.Interrupts {
+				dumpText(s.Curr.TimeStamp, opt, &irq)
+			}
+		case "softirq":
+			for _, irq := range s.Softirqs {
+				dumpText(s.Curr.TimeStamp, opt, &irq)
+			}
 		case "process":
 			processList := s.Processes.Iterate(nil, opt.SortField, opt.DescendingOrder)
 			cnt := 0
@@ -378,6 +688,16 @@ func (s *Model) dumpText(opt DumpOption) error {
 					break
 				}
 			}
+		case "thread":
+			threadList := s.Threads.Iterate(0, nil, opt.SortField, opt.DescendingOrder)
+			cnt := 0
+			for _, t := range threadList {
+				dumpText(s.Curr.TimeStamp, opt, t)
+				cnt++
+				if opt.Top > 0 && opt.Top == cnt {
+					break
+				}
+			}
 		case "cgroup":
 			dumpTextForCgroup(s.Curr.TimeStamp, opt, s.Cgroup)
 		}
@@ -434,6 +754,32 @@ func (s *Model) dumpJson(opt DumpOption) error {
 			if isFilter(opt, &s.Vm) {
 				dumpJson(s.Curr.TimeStamp, opt, &s.Vm)
 			}
+		case "etop":
+			if isFilter(opt, &s.Etop) {
+				dumpJson(s.Curr.TimeStamp, opt, &s.Etop)
+			}
+		case "snmp":
+			if isFilter(opt, &s.NetSNMP) {
+				dumpJson(s.Curr.TimeStamp, opt, &s.NetSNMP)
+			}
+		case "socket":
+			if isFilter(opt, &s.Socket) {
+				dumpJson(s.Curr.TimeStamp, opt, &s.Socket)
+			}
+		case "connection":
+			opt.Output.WriteString("[")
+			first := true
+			for _, c := range s.Connections {
+				if isFilter(opt, &c) {
+					if first {
+						first = false
+					} else {
+						opt.Output.WriteString(",\n")
+					}
+					dumpJson(s.Curr.TimeStamp, opt, &c)
+				}
+			}
+			opt.Output.WriteString("]")
 		case "disk":
 			opt.Output.WriteString("[")
 			first := true
@@ -448,6 +794,20 @@ func (s *Model) dumpJson(opt DumpOption) error {
 				}
 			}
 			opt.Output.WriteString("]")
+		case "filesystem":
+			opt.Output.WriteString("[")
+			first := true
+			for _, f := range s.Filesystems {
+				if isFilter(opt, &f) {
+					if first {
+						first = false
+					} else {
+						opt.Output.WriteString(",\n")
+					}
+					dumpJson(s.Curr.TimeStamp, opt, &f)
+				}
+			}
+			opt.Output.WriteString("]")
 		case "netdev":
 			opt.Output.WriteString("[")
 			first := true
@@ -491,6 +851,24 @@ func (s *Model) dumpJson(opt DumpOption) error {
 				}
 			}
 			opt.Output.WriteString("]")
+		case "interrupt", "softirq":
+			irqs := s.Interrupts
+			if opt.Module == "softirq" {
+				irqs = s.Softirqs
+			}
+			opt.Output.WriteString("[")
+			first := true
+			for _, irq := range irqs {
+				if isFilter(opt, &irq) {
+					if first {
+						first = false
+					} else {
+						opt.Output.WriteString(",\n")
+					}
+					dumpJson(s.Curr.TimeStamp, opt, &irq)
+				}
+			}
+			opt.Output.WriteString("]")
 		case "process":
 			processList := s.Processes.Iterate(nil, opt.SortField, opt.DescendingOrder)
 			cnt := 0
@@ -511,6 +889,26 @@ func (s *Model) dumpJson(opt DumpOption) error {
 				}
 			}
 			opt.Output.WriteString("]")
+		case "thread":
+			threadList := s.Threads.Iterate(0, nil, opt.SortField, opt.DescendingOrder)
+			cnt := 0
+			opt.Output.WriteString("[")
+			first := true
+			for _, t := range threadList {
+				if isFilter(opt, t) {
+					if first {
+						first = false
+					} else {
+						opt.Output.WriteString(",\n")
+					}
+					dumpJson(s.Curr.TimeStamp, opt, t)
+					cnt++
+					if opt.Top > 0 && opt.Top == cnt {
+						break
+					}
+				}
+			}
+			opt.Output.WriteString("]")
 		case "cgroup":
 			re := dumpJsonForCgroup(s.Curr.TimeStamp, opt, s.Cgroup)
 			b, _ := json.Marshal(re)
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/xixiliguo/etop/procfs"
+	"github.com/xixiliguo/etop/store"
+)
+
+func TestCollectFieldWithMissingModules(t *testing.T) {
+
+	newSample := func(ts int64, utime uint64, load float64, missing ...string) *store.Sample {
+		s := store.NewSample()
+		s.TimeStamp = ts
+		s.BootTime = 1
+		s.PageSize = 4096
+		s.Load1 = load
+		s.Missing = missing
+		if s.Has(store.ModuleProcess) {
+			s.ProcSamples[1] = store.ProcSample{
+				ProcStat: procfs.ProcStat{PID: 1, Comm: "init", UTime: utime},
+			}
+		}
+		return &s
+	}
+
+	sm, _ := NewSysModel(nil, slog.Default())
+	sm.CollectSample(newSample(100, 0, 1))
+	// processes and load are collected every 10s
+	sm.CollectSample(newSample(105, 0, 0, store.ModuleProcess, store.ModuleLoad))
+	if len(sm.Processes) != 0 || sm.Sys.Load1 != 0 {
+		t.Errorf("nothing should be carried forward without previous value")
+	}
+
+	sm.CollectSample(newSample(110, 500, 2))
+	p, ok := sm.Processes[1]
+	if !ok {
+		t.Fatalf("process should be computed with sample at 100")
+	}
+	// 500 ticks in 10 seconds
+	if p.User != 50 || sm.Sys.Load1 != 2 {
+		t.Errorf("got user %f load %f, but want 50 2", p.User, sm.Sys.Load1)
+	}
+
+	sm.CollectSample(newSample(115, 0, 0, store.ModuleProcess, store.ModuleLoad))
+	if p, ok := sm.Processes[1]; !ok || p.User != 50 || sm.Sys.Load1 != 2 {
+		t.Errorf("process and load should be carried forward")
+	}
+}
@@ -261,3 +261,31 @@ func (netMap NetDevMap) GetOtelMetric(timeStamp int64, sm *metricdata.ScopeMetri
 
 	sm.Metrics = append(sm.Metrics, netDevPacket, netDevByte)
 }
+
+func (netMap NetDevMap) GetPromMetric(w *PromWriter) {
+	keys := netMap.GetKeys()
+	w.Metric("netdev_bytes_per_second", "gauge", "Bytes received/transmitted per second.")
+	for _, k := range keys {
+		n := netMap[k]
+		w.Sample("netdev_bytes_per_second", n.RxBytePerSec, "netdev", k, "direction", "rx")
+		w.Sample("netdev_bytes_per_second", n.TxBytePerSec, "netdev", k, "direction", "tx")
+	}
+	w.Metric("netdev_packets_per_second", "gauge", "Packets received/transmitted per second.")
+	for _, k := range keys {
+		n := netMap[k]
+		w.Sample("netdev_packets_per_second", n.RxPacketPerSec, "netdev", k, "direction", "rx")
+		w.Sample("netdev_packets_per_second", n.TxPacketPerSec, "netdev", k, "direction", "tx")
+	}
+	w.Metric("netdev_errors", "gauge", "Errors during the last sample interval.")
+	for _, k := range keys {
+		n := netMap[k]
+		w.Sample("netdev_errors", float64(n.RxErrors), "netdev", k, "direction", "rx")
+		w.Sample("netdev_errors", float64(n.TxErrors), "netdev", k, "direction", "tx")
+	}
+	w.Metric("netdev_dropped", "gauge", "Dropped packets during the last sample interval.")
+	for _, k := range keys {
+		n := netMap[k]
+		w.Sample("netdev_dropped", float64(n.RxDropped), "netdev", k, "direction", "rx")
+		w.Sample("netdev_dropped", float64(n.TxDropped), "netdev", k, "direction", "tx")
+	}
+}
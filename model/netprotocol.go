@@ -1,7 +1,12 @@
 package model
 
 import (
+	"time"
+
 	"github.com/xixiliguo/etop/store"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 )
 
 var DefaultNetProtocolFields = []string{"Name", "Sockets", "Memory", "Pressure"}
@@ -68,3 +73,36 @@ func (netProtocolMap NetProtocolMap) Collect(prev, curr *store.Sample) {
 		}
 	}
 }
+
+func (netProtocolMap NetProtocolMap) GetOtelMetric(timeStamp int64, sm *metricdata.ScopeMetrics) {
+
+	sm.Scope = instrumentation.Scope{Name: "netprotocol", Version: "0.0.1"}
+	sockets := metricdata.Metrics{
+		Name: "netprotocol.sockets",
+	}
+	socketsData := metricdata.Gauge[int64]{}
+	memory := metricdata.Metrics{
+		Name: "netprotocol.memory",
+	}
+	memoryData := metricdata.Gauge[int64]{}
+
+	for _, n := range netProtocolMap {
+		name := attribute.String("protocol", n.Name)
+		socketsData.DataPoints = append(socketsData.DataPoints, metricdata.DataPoint[int64]{
+			Attributes: attribute.NewSet(name),
+			Time:       time.Unix(timeStamp, 0),
+			Value:      int64(n.Sockets),
+		})
+		// -1 means protocol does not track memory
+		if n.Memory >= 0 {
+			memoryData.DataPoints = append(memoryData.DataPoints, metricdata.DataPoint[int64]{
+				Attributes: attribute.NewSet(name),
+				Time:       time.Unix(timeStamp, 0),
+				Value:      int64(n.Memory),
+			})
+		}
+	}
+	sockets.Data = socketsData
+	memory.Data = memoryData
+	sm.Metrics = append(sm.Metrics, sockets, memory)
+}
@@ -0,0 +1,128 @@
+package model
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/xixiliguo/etop/store"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+var DefaultNetSNMPFields = []string{"Tcp.ActiveOpens", "Tcp.PassiveOpens",
+	"Tcp.CurrEstab", "Tcp.RetransSegs", "RetransPercent", "Tcp.InErrs",
+	"TcpExt.ListenOverflows", "TcpExt.ListenDrops", "TcpExt.TCPTimeouts",
+	"Udp.InErrors", "Udp.RcvbufErrors", "Ip.ReasmFails"}
+
+// snmpGauges are not counters, they are shown as is instead of rate
+var snmpGauges = map[string]bool{
+	"Ip.Forwarding":    true,
+	"Ip.DefaultTTL":    true,
+	"Ip6.Forwarding":   true,
+	"Tcp.RtoAlgorithm": true,
+	"Tcp.RtoMin":       true,
+	"Tcp.RtoMax":       true,
+	"Tcp.CurrEstab":    true,
+}
+
+// NetSNMP is rate of counters in /proc/net/snmp, /proc/net/netstat and
+// /proc/net/snmp6. field is name of counter like TcpExt.ListenOverflows,
+// see procfs.NetSNMP
+type NetSNMP struct {
+	RetransPercent float64            // percent of Tcp.RetransSegs in Tcp.OutSegs
+	Rates          map[string]float64 // per second of counters
+	Gauges         map[string]uint64
+}
+
+// isSNMPField return true if field is like Tcp.RetransSegs, counter
+// which is not known by this kernel is rendered as unknown
+func isSNMPField(field string) bool {
+	proto, name, ok := strings.Cut(field, ".")
+	return ok && proto != "" && name != ""
+}
+
+func (n *NetSNMP) DefaultConfig(field string) Field {
+	cfg := Field{}
+	switch {
+	case field == "RetransPercent":
+		cfg = Field{"RetransPercent", Raw, 2, "%", 14, false}
+	case snmpGauges[field]:
+		cfg = Field{field, Raw, 0, "", len(field), false}
+	case isSNMPField(field):
+		cfg = Field{field, Raw, 1, "/s", len(field), false}
+	}
+	return cfg
+}
+
+func (n *NetSNMP) GetRenderValue(field string, opt FieldOpt) string {
+	cfg := n.DefaultConfig(field)
+	cfg.ApplyOpt(opt)
+	s := ""
+	switch {
+	case field == "RetransPercent":
+		s = cfg.Render(n.RetransPercent)
+	case snmpGauges[field]:
+		v, ok := n.Gauges[field]
+		if !ok {
+			v = math.MaxUint64
+		}
+		s = cfg.Render(v)
+	case isSNMPField(field):
+		v, ok := n.Rates[field]
+		if !ok {
+			v = math.MaxFloat64
+		}
+		s = cfg.Render(v)
+	default:
+		s = "no " + field + " for snmp stat"
+	}
+	return s
+}
+
+func (n *NetSNMP) Collect(prev, curr *store.Sample) {
+
+	interval := float64(curr.TimeStamp - prev.TimeStamp)
+	n.Rates = make(map[string]float64, len(curr.NetSNMP))
+	n.Gauges = make(map[string]uint64)
+	for k, v := range curr.NetSNMP {
+		if snmpGauges[k] {
+			n.Gauges[k] = v
+			continue
+		}
+		// counter which is new or wrapped is unknown
+		if old, ok := prev.NetSNMP[k]; ok && v >= old {
+			n.Rates[k] = float64(v-old) / interval
+		}
+	}
+	n.RetransPercent = 0
+	if out := n.Rates["Tcp.OutSegs"]; out > 0 {
+		n.RetransPercent = n.Rates["Tcp.RetransSegs"] * 100 / out
+	}
+}
+
+func (n *NetSNMP) GetOtelMetric(timeStamp int64, sm *metricdata.ScopeMetrics) {
+
+	sm.Scope = instrumentation.Scope{Name: "snmp", Version: "0.0.1"}
+	md := metricdata.Metrics{
+		Name: "snmp.rate",
+	}
+	data := metricdata.Gauge[float64]{}
+
+	// only counters of default fields, there are hundreds of them
+	for _, f := range DefaultNetSNMPFields {
+		v, ok := n.Rates[f]
+		if !ok {
+			continue
+		}
+		proto, name, _ := strings.Cut(f, ".")
+		data.DataPoints = append(data.DataPoints, metricdata.DataPoint[float64]{
+			Attributes: attribute.NewSet(attribute.String("protocol", proto), attribute.String("counter", name)),
+			Time:       time.Unix(timeStamp, 0),
+			Value:      v,
+		})
+	}
+	md.Data = data
+	sm.Metrics = append(sm.Metrics, md)
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/xixiliguo/etop/procfs"
+	"github.com/xixiliguo/etop/store"
+)
+
+func TestNetSNMP(t *testing.T) {
+	prev := store.NewSample()
+	prev.TimeStamp = 100
+	prev.NetSNMP = procfs.NetSNMP{
+		"Tcp.OutSegs":            1000,
+		"Tcp.RetransSegs":        10,
+		"Tcp.CurrEstab":          5,
+		"TcpExt.ListenOverflows": 7,
+	}
+	curr := store.NewSample()
+	curr.TimeStamp = 110
+	curr.NetSNMP = procfs.NetSNMP{
+		"Tcp.OutSegs":            3000,
+		"Tcp.RetransSegs":        60,
+		"Tcp.CurrEstab":          8,
+		"TcpExt.ListenOverflows": 2, // counter is reset
+		"Udp.InErrors":           3, // new counter
+	}
+
+	n := NetSNMP{}
+	n.Collect(&prev, &curr)
+	for field, want := range map[string]string{
+		"Tcp.OutSegs":            "200.0/s",
+		"Tcp.RetransSegs":        "5.0/s",
+		"RetransPercent":         "2.50%",
+		"Tcp.CurrEstab":          "8",
+		"TcpExt.ListenOverflows": "-",
+		"Udp.InErrors":           "-",
+		"Ip.ReasmFails":          "-",
+	} {
+		if got := n.GetRenderValue(field, FieldOpt{}); got != want {
+			t.Errorf("%s: got %s, but want %s", field, got, want)
+		}
+	}
+	for _, f := range DefaultNetSNMPFields {
+		if name, _ := getNameAndWidthOfField("snmp", f); name == "" {
+			t.Errorf("%s should be a field of snmp", f)
+		}
+	}
+	if name, _ := getNameAndWidthOfField("snmp", "Tcp."); name != "" {
+		t.Errorf("Tcp. should not be a field")
+	}
+}
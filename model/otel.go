@@ -0,0 +1,170 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+// GetOtelMetrics return metrics of current sample, one scope per module
+func (s *Model) GetOtelMetrics() []metricdata.ScopeMetrics {
+	sms := make([]metricdata.ScopeMetrics, 12)
+	s.CPUs.GetOtelMetric(s.Curr.TimeStamp, &sms[0])
+	s.MEM.GetOtelMetric(s.Curr.TimeStamp, &sms[1])
+	s.Vm.GetOtelMetric(s.Curr.TimeStamp, &sms[2])
+	s.Disks.GetOtelMetric(s.Curr.TimeStamp, &sms[3])
+	s.Nets.GetOtelMetric(s.Curr.TimeStamp, &sms[4])
+	s.NetProtocols.GetOtelMetric(s.Curr.TimeStamp, &sms[5])
+	s.Softnets.GetOtelMetric(s.Curr.TimeStamp, &sms[6])
+	s.Cgroup.GetOtelMetric(s.Curr.TimeStamp, &sms[7])
+	s.Processes.GetOtelMetric(s.Curr.TimeStamp, &sms[8])
+	s.Sys.GetOtelMetric(s.Curr.TimeStamp, &sms[9])
+	s.NetSNMP.GetOtelMetric(s.Curr.TimeStamp, &sms[10])
+	s.Filesystems.GetOtelMetric(s.Curr.TimeStamp, &sms[11])
+	return sms
+}
+
+func otelResource(hostName string, attrs map[string]string) *resource.Resource {
+	kvs := []attribute.KeyValue{
+		semconv.ServiceName("etop"),
+		semconv.ServiceInstanceID(hostName),
+	}
+	for k, v := range attrs {
+		kvs = append(kvs, attribute.String(k, v))
+	}
+	return resource.NewSchemaless(kvs...)
+}
+
+// ParseKeyValues parse k1=v1 pairs, e.g from command line flags
+func ParseKeyValues(pairs []string) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, pair := range pairs {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid %q: should be key=value", pair)
+		}
+		m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return m, nil
+}
+
+type OtelOption struct {
+	Endpoint   string            // host:port or full url of OTLP/HTTP receiver
+	Insecure   bool              // use http instead of https, only for host:port endpoint
+	Headers    map[string]string // e.g authorization header
+	Attributes map[string]string // extra resource attributes
+	BatchSize  int               // number of samples sent in one request
+	Timeout    time.Duration
+}
+
+// OtelPusher push metrics of every sample to OTLP/HTTP receiver.
+// samples are batched and exported in background, so that slow
+// receiver does not delay collecting.
+type OtelPusher struct {
+	opt     OtelOption
+	log     *slog.Logger
+	export  metric.Exporter
+	pending []metricdata.ScopeMetrics
+	cnt     int
+	res     *resource.Resource
+	queue   chan *metricdata.ResourceMetrics
+	wg      sync.WaitGroup
+}
+
+func NewOtelPusher(opt OtelOption, log *slog.Logger) (*OtelPusher, error) {
+	if opt.BatchSize <= 0 {
+		opt.BatchSize = 1
+	}
+	if opt.Timeout <= 0 {
+		opt.Timeout = 10 * time.Second
+	}
+	opts := []otlpmetrichttp.Option{
+		otlpmetrichttp.WithTimeout(opt.Timeout),
+	}
+	if strings.Contains(opt.Endpoint, "://") {
+		opts = append(opts, otlpmetrichttp.WithEndpointURL(opt.Endpoint))
+	} else if opt.Endpoint != "" {
+		opts = append(opts, otlpmetrichttp.WithEndpoint(opt.Endpoint))
+		if opt.Insecure {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		}
+	}
+	if len(opt.Headers) != 0 {
+		opts = append(opts, otlpmetrichttp.WithHeaders(opt.Headers))
+	}
+	export, err := otlpmetrichttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &OtelPusher{
+		opt:    opt,
+		log:    log,
+		export: export,
+		queue:  make(chan *metricdata.ResourceMetrics, 16),
+	}
+	p.wg.Add(1)
+	go p.run()
+	return p, nil
+}
+
+func (p *OtelPusher) run() {
+	defer p.wg.Done()
+	for rm := range p.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), p.opt.Timeout)
+		if err := p.export.Export(ctx, rm); err != nil {
+			msg := fmt.Sprintf("push otel metrics: %s", err)
+			p.log.Warn(msg)
+		}
+		cancel()
+	}
+}
+
+// Push add metrics of current sample into batch, and send the batch
+// if it is full.
+func (p *OtelPusher) Push(s *Model) {
+	if p.res == nil {
+		p.res = otelResource(s.Curr.HostName, p.opt.Attributes)
+	}
+	p.pending = append(p.pending, s.GetOtelMetrics()...)
+	p.cnt++
+	if p.cnt >= p.opt.BatchSize {
+		p.flush()
+	}
+}
+
+func (p *OtelPusher) flush() {
+	if p.cnt == 0 {
+		return
+	}
+	rm := &metricdata.ResourceMetrics{
+		Resource:     p.res,
+		ScopeMetrics: p.pending,
+	}
+	select {
+	case p.queue <- rm:
+	default:
+		msg := fmt.Sprintf("otel queue is full, drop %d samples", p.cnt)
+		p.log.Warn(msg)
+	}
+	p.pending = nil
+	p.cnt = 0
+}
+
+// Shutdown send remaining samples and wait until all are exported
+func (p *OtelPusher) Shutdown() error {
+	p.flush()
+	close(p.queue)
+	p.wg.Wait()
+	return p.export.Shutdown(context.Background())
+}
@@ -0,0 +1,109 @@
+package model
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/xixiliguo/etop/procfs"
+	"github.com/xixiliguo/etop/store"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestOtelPusher(t *testing.T) {
+
+	var (
+		mu       sync.Mutex
+		requests []*colmetricpb.ExportMetricsServiceRequest
+		headers  []string
+	)
+	// stand-in of OTLP/HTTP receiver
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/metrics" {
+			http.NotFound(w, r)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		req := &colmetricpb.ExportMetricsServiceRequest{}
+		if err := proto.Unmarshal(b, req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		requests = append(requests, req)
+		headers = append(headers, r.Header.Get("X-Token"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		resp, _ := proto.Marshal(&colmetricpb.ExportMetricsServiceResponse{})
+		w.Write(resp)
+	}))
+	defer srv.Close()
+
+	pusher, err := NewOtelPusher(OtelOption{
+		Endpoint:   srv.URL,
+		Headers:    map[string]string{"X-Token": "secret"},
+		Attributes: map[string]string{"env": "test"},
+		BatchSize:  2,
+	}, slog.Default())
+	if err != nil {
+		t.Fatalf("NewOtelPusher: %s", err)
+	}
+
+	sm, _ := NewSysModel(nil, slog.Default())
+	for i := int64(0); i < 4; i++ {
+		s := store.NewSample()
+		s.TimeStamp = 100 + i*5
+		s.HostName = "host1"
+		s.PageSize = 4096
+		s.PageIn = uint64(i * 10)
+		s.SoftNetStats = []procfs.SoftnetStat{{Processed: uint64(i * 100)}}
+		s.NetProtocolStats["TCP"] = procfs.NetProtocolStatLine{Name: "TCP", Sockets: 3, Memory: 1}
+		s.NetSNMP["Tcp.RetransSegs"] = uint64(i * 2)
+		if sm.CollectSample(&s) {
+			pusher.Push(sm)
+		}
+	}
+	if err := pusher.Shutdown(); err != nil {
+		t.Fatalf("Shutdown: %s", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// 3 samples with batch 2, the last one is sent by Shutdown
+	if len(requests) != 2 {
+		t.Fatalf("got %d requests, but want 2", len(requests))
+	}
+	for _, h := range headers {
+		if h != "secret" {
+			t.Errorf("got header %q, but want %q", h, "secret")
+		}
+	}
+
+	attrs := map[string]string{}
+	for _, kv := range requests[0].ResourceMetrics[0].Resource.Attributes {
+		attrs[kv.Key] = kv.Value.GetStringValue()
+	}
+	if attrs["env"] != "test" || attrs["service.instance.id"] != "host1" {
+		t.Errorf("unexpected resource attributes: %v", attrs)
+	}
+
+	names := map[string]int{}
+	for _, req := range requests {
+		for _, rm := range req.ResourceMetrics {
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					names[m.Name] += len(m.GetGauge().GetDataPoints())
+				}
+			}
+		}
+	}
+	for _, name := range []string{"cpu.usage", "memory", "vm.events", "softnet.events", "netprotocol.sockets", "system.pressure", "snmp.rate"} {
+		if names[name] == 0 {
+			t.Errorf("metric %s should be exported, got %v", name, names)
+		}
+	}
+}
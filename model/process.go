@@ -11,6 +11,7 @@ import (
 	"github.com/expr-lang/expr"
 	"github.com/expr-lang/expr/vm"
 	"github.com/mattn/go-runewidth"
+	"github.com/xixiliguo/etop/procfs"
 	"github.com/xixiliguo/etop/store"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/sdk/instrumentation"
@@ -27,9 +28,10 @@ var (
 )
 
 var DefaultProcessFields = []string{"Pid", "Comm", "State", "CPU", "Mem", "ReadBytePerSec", "WriteBytePerSec"}
-var AllProcessFields = []string{"Pid", "Comm", "State", "Ppid", "NumThreads", "StartTime", "OnCPU", "CmdLine", "Cgroup",
-	"User", "System", "Priority", "Nice", "Policy", "CPU", "RunDelay", "BlkDelay",
+var AllProcessFields = []string{"Pid", "Comm", "State", "Ppid", "NumThreads", "StartTime", "OnCPU", "CmdLine", "Cgroup", "Uid", "UserName",
+	"User", "System", "Priority", "Nice", "Policy", "CPU", "RunDelay", "BlkDelay", "VolCtxSwPerSec", "InvolCtxSwPerSec",
 	"MinFlt", "MajFlt", "VSize", "RSS", "Mem",
+	"RssAnon", "RssFile", "RssShmem", "Swap", "Pss", "PssDirty", "SwapPss", "Locked", "Hugetlb",
 	"ReadCharPerSec", "WriteCharPerSec",
 	"SyscRPerSec", "SyscWPerSec",
 	"ReadBytePerSec", "WriteBytePerSec", "CancelledWriteBytePerSec", "Disk"}
@@ -46,6 +48,8 @@ type Process struct {
 	OnCPU      int
 	CmdLine    string
 	Cgroup     string
+	Uid        uint64
+	UserName   string
 	PCPU
 	PMEM
 	PIO
@@ -86,6 +90,9 @@ type PCPU struct {
 	CPU      float64
 	RunDelay uint64
 	BlkDelay uint64
+	// voluntary and involuntary context switches per second
+	VolCtxSwPerSec   float64
+	InvolCtxSwPerSec float64
 }
 
 func (c *PCPU) DefaultConfig(field string) Field {
@@ -107,6 +114,10 @@ func (c *PCPU) DefaultConfig(field string) Field {
 		cfg = Field{"RunDelay", Raw, 1, " ms", 10, false}
 	case "BlkDelay":
 		cfg = Field{"BlkDelay", Raw, 1, " ms", 10, false}
+	case "VolCtxSwPerSec":
+		cfg = Field{"VolCtxSw/s", Raw, 1, "/s", 10, false}
+	case "InvolCtxSwPerSec":
+		cfg = Field{"InvolCtxSw/s", Raw, 1, "/s", 12, false}
 	}
 	return cfg
 }
@@ -132,6 +143,10 @@ func (c *PCPU) GetRenderValue(field string, opt FieldOpt) string {
 		s = cfg.Render(c.RunDelay)
 	case "BlkDelay":
 		s = cfg.Render(c.BlkDelay)
+	case "VolCtxSwPerSec":
+		s = cfg.Render(c.VolCtxSwPerSec)
+	case "InvolCtxSwPerSec":
+		s = cfg.Render(c.InvolCtxSwPerSec)
 	default:
 		s = "no " + field + " for process cpu stat"
 	}
@@ -144,6 +159,17 @@ type PMEM struct {
 	VSize  uint64
 	RSS    int
 	Mem    float64
+	// breakdown of RSS and swap from /proc/<pid>/status, in bytes
+	RssAnon  uint64
+	RssFile  uint64
+	RssShmem uint64
+	Swap     uint64
+	Locked   uint64
+	Hugetlb  uint64
+	// proportional usage from /proc/<pid>/smaps_rollup, in bytes
+	Pss      uint64
+	PssDirty uint64
+	SwapPss  uint64
 }
 
 func (m *PMEM) DefaultConfig(field string) Field {
@@ -159,6 +185,24 @@ func (m *PMEM) DefaultConfig(field string) Field {
 		cfg = Field{"RSS", HumanReadableSize, 0, "", 10, false}
 	case "Mem":
 		cfg = Field{"Mem", Raw, 1, "%", 10, false}
+	case "RssAnon":
+		cfg = Field{"RssAnon", HumanReadableSize, 0, "", 10, false}
+	case "RssFile":
+		cfg = Field{"RssFile", HumanReadableSize, 0, "", 10, false}
+	case "RssShmem":
+		cfg = Field{"RssShmem", HumanReadableSize, 0, "", 10, false}
+	case "Swap":
+		cfg = Field{"Swap", HumanReadableSize, 0, "", 10, false}
+	case "Locked":
+		cfg = Field{"Locked", HumanReadableSize, 0, "", 10, false}
+	case "Hugetlb":
+		cfg = Field{"Hugetlb", HumanReadableSize, 0, "", 10, false}
+	case "Pss":
+		cfg = Field{"Pss", HumanReadableSize, 0, "", 10, false}
+	case "PssDirty":
+		cfg = Field{"PssDirty", HumanReadableSize, 0, "", 10, false}
+	case "SwapPss":
+		cfg = Field{"SwapPss", HumanReadableSize, 0, "", 10, false}
 	}
 	return cfg
 }
@@ -178,6 +222,24 @@ func (m *PMEM) GetRenderValue(field string, opt FieldOpt) string {
 		s = cfg.Render(m.RSS)
 	case "Mem":
 		s = cfg.Render(m.Mem)
+	case "RssAnon":
+		s = cfg.Render(m.RssAnon)
+	case "RssFile":
+		s = cfg.Render(m.RssFile)
+	case "RssShmem":
+		s = cfg.Render(m.RssShmem)
+	case "Swap":
+		s = cfg.Render(m.Swap)
+	case "Locked":
+		s = cfg.Render(m.Locked)
+	case "Hugetlb":
+		s = cfg.Render(m.Hugetlb)
+	case "Pss":
+		s = cfg.Render(m.Pss)
+	case "PssDirty":
+		s = cfg.Render(m.PssDirty)
+	case "SwapPss":
+		s = cfg.Render(m.SwapPss)
 	default:
 		s = "no " + field + " for process mem stat"
 	}
@@ -298,9 +360,15 @@ func (p *Process) DefaultConfig(field string) Field {
 		cfg = Field{"CmdLine", Raw, 0, "", 10, false}
 	case "Cgroup":
 		cfg = Field{"Cgroup", Raw, 0, "", 50, false}
-	case "User", "System", "Priority", "Nice", "Policy", "CPU", "RunDelay", "BlkDelay":
+	case "Uid":
+		cfg = Field{"Uid", Raw, 0, "", 10, false}
+	case "UserName":
+		cfg = Field{"UserName", Raw, 0, "", 10, false}
+	case "User", "System", "Priority", "Nice", "Policy", "CPU", "RunDelay", "BlkDelay",
+		"VolCtxSwPerSec", "InvolCtxSwPerSec":
 		return p.PCPU.DefaultConfig(field)
-	case "MinFlt", "MajFlt", "VSize", "RSS", "Mem":
+	case "MinFlt", "MajFlt", "VSize", "RSS", "Mem",
+		"RssAnon", "RssFile", "RssShmem", "Swap", "Locked", "Hugetlb", "Pss", "PssDirty", "SwapPss":
 		return p.PMEM.DefaultConfig(field)
 	case "RChar", "WChar", "ReadCharPerSec", "WriteCharPerSec",
 		"SyscR", "SyscW", "SyscRPerSec", "SyscWPerSec",
@@ -352,9 +420,15 @@ func (p *Process) GetRenderValue(field string, opt FieldOpt) string {
 		s = cfg.Render(p.CmdLine)
 	case "Cgroup":
 		s = cfg.Render(p.Cgroup)
-	case "User", "System", "Priority", "Nice", "Policy", "CPU", "RunDelay", "BlkDelay":
+	case "Uid":
+		s = cfg.Render(p.Uid)
+	case "UserName":
+		s = cfg.Render(p.UserName)
+	case "User", "System", "Priority", "Nice", "Policy", "CPU", "RunDelay", "BlkDelay",
+		"VolCtxSwPerSec", "InvolCtxSwPerSec":
 		return p.PCPU.GetRenderValue(field, opt)
-	case "MinFlt", "MajFlt", "VSize", "RSS", "Mem":
+	case "MinFlt", "MajFlt", "VSize", "RSS", "Mem",
+		"RssAnon", "RssFile", "RssShmem", "Swap", "Locked", "Hugetlb", "Pss", "PssDirty", "SwapPss":
 		return p.PMEM.GetRenderValue(field, opt)
 	case "RChar", "WChar", "ReadCharPerSec", "WriteCharPerSec",
 		"SyscR", "SyscW", "SyscRPerSec", "SyscWPerSec",
@@ -411,6 +485,10 @@ func sortByField(res []*Process, sortField string, descOrder bool) {
 			return res[i].OnCPU > res[j].OnCPU
 		case "CmdLine":
 			return res[i].CmdLine > res[j].CmdLine
+		case "Uid":
+			return res[i].Uid > res[j].Uid
+		case "UserName":
+			return res[i].UserName > res[j].UserName
 		case "User":
 			return res[i].User > res[j].User
 		case "System":
@@ -427,6 +505,10 @@ func sortByField(res []*Process, sortField string, descOrder bool) {
 			return res[i].RunDelay > res[j].RunDelay
 		case "BlkDelay":
 			return res[i].BlkDelay > res[j].BlkDelay
+		case "VolCtxSwPerSec":
+			return res[i].VolCtxSwPerSec > res[j].VolCtxSwPerSec
+		case "InvolCtxSwPerSec":
+			return res[i].InvolCtxSwPerSec > res[j].InvolCtxSwPerSec
 		case "MinFlt":
 			return res[i].MinFlt > res[j].MinFlt
 		case "MajFlt":
@@ -437,6 +519,24 @@ func sortByField(res []*Process, sortField string, descOrder bool) {
 			return res[i].RSS > res[j].RSS
 		case "Mem":
 			return res[i].Mem > res[j].Mem
+		case "RssAnon":
+			return res[i].RssAnon > res[j].RssAnon
+		case "RssFile":
+			return res[i].RssFile > res[j].RssFile
+		case "RssShmem":
+			return res[i].RssShmem > res[j].RssShmem
+		case "Swap":
+			return res[i].Swap > res[j].Swap
+		case "Locked":
+			return res[i].Locked > res[j].Locked
+		case "Hugetlb":
+			return res[i].Hugetlb > res[j].Hugetlb
+		case "Pss":
+			return res[i].Pss > res[j].Pss
+		case "PssDirty":
+			return res[i].PssDirty > res[j].PssDirty
+		case "SwapPss":
+			return res[i].SwapPss > res[j].SwapPss
 		case "RChar":
 			return res[i].RChar > res[j].RChar
 		case "WChar":
@@ -560,6 +660,14 @@ func (processMap ProcessMap) Collect(prev, curr *store.Sample) (processes, threa
 			OnCPU:      new.Processor,
 			CmdLine:    new.CmdLine,
 			Cgroup:     new.Cgroup,
+			Uid:        uint64(new.Status.UID),
+			UserName:   new.UserName,
+		}
+
+		if p.UserName == "" {
+			// recorded before user name is recorded, name of uid on this
+			// host may be wrong
+			p.UserName = strconv.FormatUint(p.Uid, 10)
 		}
 
 		if new.EndTime != 0 {
@@ -582,12 +690,42 @@ func (processMap ProcessMap) Collect(prev, curr *store.Sample) (processes, threa
 		}
 		p.RunDelay = Sub(new.WaitingNanoseconds, old.WaitingNanoseconds) / 1000000
 		p.BlkDelay = Sub(new.DelayAcctBlkIOTicks, old.DelayAcctBlkIOTicks) * 10
+		p.VolCtxSwPerSec = SubWithInterval(new.Status.VoluntaryCtxtSwitches, old.Status.VoluntaryCtxtSwitches, interval)
+		p.InvolCtxSwPerSec = SubWithInterval(new.Status.NonVoluntaryCtxtSwitches, old.Status.NonVoluntaryCtxtSwitches, interval)
 
 		p.MinFlt = Sub(new.MinFlt, old.MinFlt)
 		p.MajFlt = Sub(new.MajFlt, old.MajFlt)
 		p.VSize = new.VSize
 		p.RSS = int(new.RSS) * curr.PageSize
 		p.Mem = float64(p.RSS) * 100 / 1024 / float64(curr.MemTotal)
+		p.RssAnon = new.Status.RssAnon * 1024
+		p.RssFile = new.Status.RssFile * 1024
+		p.RssShmem = new.Status.RssShmem * 1024
+		p.Swap = new.Status.VmSwap * 1024
+		p.Locked = new.Status.VmLck * 1024
+		p.Hugetlb = new.Status.HugetlbPages * 1024
+		p.Pss = new.Smaps.Pss * 1024
+		p.PssDirty = new.Smaps.PssDirty * 1024
+		p.SwapPss = new.Smaps.SwapPss * 1024
+		if new.Smaps.Rss == 0 && new.RSS != 0 {
+			// smaps_rollup is not available
+			p.Pss = math.MaxUint64
+			p.PssDirty = math.MaxUint64
+			p.SwapPss = math.MaxUint64
+		}
+		if new.Status == (procfs.ProcStatus{}) {
+			// exited process from ebpf or sample recorded by old version
+			p.Uid = math.MaxUint64
+			p.UserName = "-"
+			p.VolCtxSwPerSec = math.MaxFloat64
+			p.InvolCtxSwPerSec = math.MaxFloat64
+			p.RssAnon = math.MaxUint64
+			p.RssFile = math.MaxUint64
+			p.RssShmem = math.MaxUint64
+			p.Swap = math.MaxUint64
+			p.Locked = math.MaxUint64
+			p.Hugetlb = math.MaxUint64
+		}
 
 		p.RChar = Sub(new.RChar, old.RChar)
 		p.WChar = Sub(new.WChar, old.WChar)
@@ -740,3 +878,36 @@ func init() {
 		enableBootTimeTick = false
 	}
 }
+
+// GetPromMetric export top N processes order by CPU
+func (processMap ProcessMap) GetPromMetric(w *PromWriter, top int) {
+	if top <= 0 {
+		return
+	}
+	procs := processMap.Iterate(nil, "CPU", true)
+	if len(procs) > top {
+		procs = procs[:top]
+	}
+	w.Metric("process_cpu_usage_percent", "gauge", "CPU usage of top processes.")
+	for _, p := range procs {
+		pid := strconv.Itoa(p.Pid)
+		w.Sample("process_cpu_usage_percent", p.User, "pid", pid, "comm", p.Comm, "mode", "user")
+		w.Sample("process_cpu_usage_percent", p.System, "pid", pid, "comm", p.Comm, "mode", "system")
+	}
+	w.Metric("process_memory_rss_bytes", "gauge", "Resident set size of top processes.")
+	for _, p := range procs {
+		w.Sample("process_memory_rss_bytes", float64(p.RSS), "pid", strconv.Itoa(p.Pid), "comm", p.Comm)
+	}
+	w.Metric("process_disk_bytes_per_second", "gauge", "Bytes read/written per second of top processes.")
+	for _, p := range procs {
+		pid := strconv.Itoa(p.Pid)
+		w.Sample("process_disk_bytes_per_second", p.ReadBytePerSec, "pid", pid, "comm", p.Comm, "action", "read")
+		w.Sample("process_disk_bytes_per_second", p.WriteBytePerSec, "pid", pid, "comm", p.Comm, "action", "write")
+	}
+	w.Metric("process_delay_milliseconds", "gauge", "Run queue and block I/O delay of top processes during the last sample interval.")
+	for _, p := range procs {
+		pid := strconv.Itoa(p.Pid)
+		w.Sample("process_delay_milliseconds", float64(p.RunDelay), "pid", pid, "comm", p.Comm, "state", "run")
+		w.Sample("process_delay_milliseconds", float64(p.BlkDelay), "pid", pid, "comm", p.Comm, "state", "block")
+	}
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/xixiliguo/etop/procfs"
+	"github.com/xixiliguo/etop/store"
+)
+
+func TestProcessCollectStatus(t *testing.T) {
+	prev := store.NewSample()
+	prev.TimeStamp = 100
+	prev.ProcSamples[1] = store.ProcSample{
+		ProcStat: procfs.ProcStat{PID: 1, Comm: "init", RSS: 10},
+		Status:   procfs.ProcStatus{VoluntaryCtxtSwitches: 100, NonVoluntaryCtxtSwitches: 10},
+	}
+
+	curr := store.NewSample()
+	curr.TimeStamp = 105
+	curr.PageSize = 4096
+	curr.MemTotal = 1024
+	curr.ProcSamples[1] = store.ProcSample{
+		ProcStat: procfs.ProcStat{PID: 1, Comm: "init", RSS: 10},
+		Status: procfs.ProcStatus{
+			RssAnon: 24, RssFile: 16, RssShmem: 0, VmSwap: 8, VmLck: 4,
+			VoluntaryCtxtSwitches: 150, NonVoluntaryCtxtSwitches: 20,
+		},
+		UserName: "recorded",
+		Smaps:    procfs.ProcSmapsRollup{Rss: 40, Pss: 30, PssDirty: 12, SwapPss: 6},
+	}
+	// exited process which has no status and smaps
+	curr.ProcSamples[2] = store.ProcSample{
+		ProcStat: procfs.ProcStat{PID: 2, Comm: "sh", RSS: 1},
+	}
+
+	processes := make(ProcessMap)
+	processes.Collect(&prev, &curr)
+
+	p := processes[1]
+	got := []string{}
+	for _, f := range []string{"Uid", "UserName", "RssAnon", "RssFile", "Swap", "Locked", "Pss", "PssDirty", "SwapPss", "VolCtxSwPerSec", "InvolCtxSwPerSec"} {
+		got = append(got, p.GetRenderValue(f, FieldOpt{Raw: true}))
+	}
+	want := []string{"0", "recorded", "24576", "16384", "8192", "4096", "30720", "12288", "6144", "10.0", "2.0"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	p = processes[2]
+	if p.Uid != math.MaxUint64 || p.UserName != "-" || p.Pss != math.MaxUint64 || p.RssAnon != math.MaxUint64 {
+		t.Errorf("status of exited process should be unknown, but got %+v", p)
+	}
+	if s := p.GetRenderValue("Swap", FieldOpt{}); s != "-" {
+		t.Errorf("got %q, but want -", s)
+	}
+}
@@ -0,0 +1,121 @@
+package model
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PromWriter write metrics in prometheus text exposition format.
+// see https://prometheus.io/docs/instrumenting/exposition_formats/
+type PromWriter struct {
+	buf *bytes.Buffer
+}
+
+func NewPromWriter(buf *bytes.Buffer) *PromWriter {
+	return &PromWriter{buf: buf}
+}
+
+// Metric write HELP and TYPE line, must be called once before
+// all samples of the metric
+func (w *PromWriter) Metric(name, typ, help string) {
+	w.buf.WriteString("# HELP etop_")
+	w.buf.WriteString(name)
+	w.buf.WriteByte(' ')
+	w.buf.WriteString(help)
+	w.buf.WriteString("\n# TYPE etop_")
+	w.buf.WriteString(name)
+	w.buf.WriteByte(' ')
+	w.buf.WriteString(typ)
+	w.buf.WriteByte('\n')
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// Sample write one sample, labels is pair of label name and value.
+// missing value (MaxUint64/MaxFloat64) is skipped
+func (w *PromWriter) Sample(name string, value float64, labels ...string) {
+	if value == math.MaxFloat64 || value == math.MaxUint64 || math.IsNaN(value) {
+		return
+	}
+	w.buf.WriteString("etop_")
+	w.buf.WriteString(name)
+	if len(labels) != 0 {
+		w.buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i != 0 {
+				w.buf.WriteByte(',')
+			}
+			w.buf.WriteString(labels[i])
+			w.buf.WriteString(`="`)
+			promLabelEscaper.WriteString(w.buf, labels[i+1])
+			w.buf.WriteByte('"')
+		}
+		w.buf.WriteByte('}')
+	}
+	w.buf.WriteByte(' ')
+	w.buf.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	w.buf.WriteByte('\n')
+}
+
+// WritePrometheus write metrics of current sample.
+// only top N processes by cpu are exported, 0 means no process.
+func (s *Model) WritePrometheus(out io.Writer, topProcess int) error {
+	buf := &bytes.Buffer{}
+	w := NewPromWriter(buf)
+
+	w.Metric("sample_timestamp_seconds", "gauge", "Unix time when the sample was collected.")
+	w.Sample("sample_timestamp_seconds", float64(s.Curr.TimeStamp))
+	w.Metric("sample_interval_seconds", "gauge", "Seconds between the last two samples.")
+	w.Sample("sample_interval_seconds", float64(s.Curr.TimeStamp-s.Prev.TimeStamp))
+
+	s.Sys.GetPromMetric(w)
+	s.CPUs.GetPromMetric(w)
+	s.MEM.GetPromMetric(w)
+	s.Vm.GetPromMetric(w)
+	s.Disks.GetPromMetric(w)
+	s.Nets.GetPromMetric(w)
+	s.Cgroup.GetPromMetric(w)
+	s.Processes.GetPromMetric(w, topProcess)
+
+	_, err := out.Write(buf.Bytes())
+	return err
+}
+
+// PromExporter serve the latest metrics on http.
+// Update is called from record loop after each sample.
+type PromExporter struct {
+	sync.Mutex
+	TopProcess int
+	metrics    []byte
+}
+
+func NewPromExporter(topProcess int) *PromExporter {
+	return &PromExporter{
+		TopProcess: topProcess,
+	}
+}
+
+func (e *PromExporter) Update(s *Model) {
+	buf := &bytes.Buffer{}
+	s.WritePrometheus(buf, e.TopProcess)
+	e.Lock()
+	e.metrics = buf.Bytes()
+	e.Unlock()
+}
+
+func (e *PromExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.Lock()
+	metrics := e.metrics
+	e.Unlock()
+	if metrics == nil {
+		http.Error(w, "no sample collected yet", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(metrics)
+}
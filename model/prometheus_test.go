@@ -0,0 +1,93 @@
+package model
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xixiliguo/etop/procfs"
+	"github.com/xixiliguo/etop/store"
+)
+
+func TestWritePrometheus(t *testing.T) {
+
+	prev := store.NewSample()
+	prev.TimeStamp = 100
+	prev.PageSize = 4096
+	prev.MemTotal = 1024
+	prev.DiskStats["sda"] = procfs.DiskStatLine{DeviceName: "sda", ReadIOs: 10}
+	prev.ProcSamples[1] = store.ProcSample{
+		ProcStat: procfs.ProcStat{PID: 1, Comm: "init", UTime: 100},
+	}
+	prev.ProcSamples[2] = store.ProcSample{
+		ProcStat: procfs.ProcStat{PID: 2, Comm: `a"b`, UTime: 100},
+	}
+
+	curr := store.NewSample()
+	curr.TimeStamp = 105
+	curr.PageSize = 4096
+	curr.LoadAvg = procfs.LoadAvg{Load1: 1.5}
+	curr.MemTotal = 1024
+	curr.DiskStats["sda"] = procfs.DiskStatLine{DeviceName: "sda", ReadIOs: 60}
+	curr.ProcSamples[1] = store.ProcSample{
+		ProcStat: procfs.ProcStat{PID: 1, Comm: "init", UTime: 600},
+	}
+	curr.ProcSamples[2] = store.ProcSample{
+		ProcStat: procfs.ProcStat{PID: 2, Comm: `a"b`, UTime: 200},
+	}
+
+	sm, _ := NewSysModel(nil, slog.Default())
+	if sm.CollectSample(&prev) {
+		t.Fatalf("first sample should not be collected")
+	}
+	if !sm.CollectSample(&curr) {
+		t.Fatalf("second sample should be collected")
+	}
+
+	buf := &bytes.Buffer{}
+	if err := sm.WritePrometheus(buf, 1); err != nil {
+		t.Fatalf("WritePrometheus: %s", err)
+	}
+	got := buf.String()
+
+	want := []string{
+		"# TYPE etop_load1 gauge\n",
+		"etop_load1 1.5\n",
+		"etop_sample_interval_seconds 5\n",
+		`etop_memory_bytes{state="total"} 1.048576e+06` + "\n",
+		`etop_disk_io_per_second{disk="sda",action="read"} 10` + "\n",
+		`etop_process_cpu_usage_percent{pid="1",comm="init",mode="user"} 100` + "\n",
+	}
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("output should contain %q\n%s", w, got)
+		}
+	}
+	if strings.Contains(got, `pid="2"`) {
+		t.Errorf("only top 1 process should be exported\n%s", got)
+	}
+
+	e := NewPromExporter(1)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != 503 {
+		t.Errorf("got status %d before update, but want 503", rec.Code)
+	}
+	e.Update(sm)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != 200 || rec.Body.String() != got {
+		t.Errorf("got status %d and body\n%s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestPromWriterEscape(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewPromWriter(buf)
+	w.Sample("x", 1, "comm", "a\"b\\c\nd")
+	if want := `etop_x{comm="a\"b\\c\nd"} 1` + "\n"; buf.String() != want {
+		t.Errorf("got %q, but want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,197 @@
+package model
+
+import (
+	"github.com/xixiliguo/etop/store"
+)
+
+var DefaultSocketFields = []string{"TCP", "Established", "SynRecv", "TimeWait",
+	"CloseWait", "Listen", "UDP", "Unix"}
+
+var DefaultConnectionFields = []string{"Proto", "State", "Local", "Remote",
+	"RecvQ", "SendQ", "Pid", "Comm"}
+
+// Socket is summary of sockets in current sample
+type Socket struct {
+	TCP         uint64 // tcp and tcp6 sockets in all states
+	Established uint64
+	SynRecv     uint64 // include request socket
+	TimeWait    uint64
+	CloseWait   uint64
+	Listen      uint64
+	UDP         uint64
+	Unix        uint64
+}
+
+func (s *Socket) DefaultConfig(field string) Field {
+	cfg := Field{}
+	switch field {
+	case "TCP":
+		cfg = Field{"TCP", Raw, 0, "", 10, false}
+	case "Established":
+		cfg = Field{"Estab", Raw, 0, "", 10, false}
+	case "SynRecv":
+		cfg = Field{"SynRecv", Raw, 0, "", 10, false}
+	case "TimeWait":
+		cfg = Field{"TimeWait", Raw, 0, "", 10, false}
+	case "CloseWait":
+		cfg = Field{"CloseWait", Raw, 0, "", 10, false}
+	case "Listen":
+		cfg = Field{"Listen", Raw, 0, "", 10, false}
+	case "UDP":
+		cfg = Field{"UDP", Raw, 0, "", 10, false}
+	case "Unix":
+		cfg = Field{"Unix", Raw, 0, "", 10, false}
+	}
+	return cfg
+}
+
+func (s *Socket) GetRenderValue(field string, opt FieldOpt) string {
+	cfg := s.DefaultConfig(field)
+	cfg.ApplyOpt(opt)
+	v := ""
+	switch field {
+	case "TCP":
+		v = cfg.Render(s.TCP)
+	case "Established":
+		v = cfg.Render(s.Established)
+	case "SynRecv":
+		v = cfg.Render(s.SynRecv)
+	case "TimeWait":
+		v = cfg.Render(s.TimeWait)
+	case "CloseWait":
+		v = cfg.Render(s.CloseWait)
+	case "Listen":
+		v = cfg.Render(s.Listen)
+	case "UDP":
+		v = cfg.Render(s.UDP)
+	case "Unix":
+		v = cfg.Render(s.Unix)
+	default:
+		v = "no " + field + " for socket stat"
+	}
+	return v
+}
+
+func (s *Socket) Collect(prev, curr *store.Sample) {
+	states := curr.Sockets.TCPStates
+	*s = Socket{
+		Established: states["ESTABLISHED"],
+		SynRecv:     states["SYN_RECV"] + states["NEW_SYN_RECV"],
+		TimeWait:    states["TIME_WAIT"],
+		CloseWait:   states["CLOSE_WAIT"],
+		Listen:      states["LISTEN"],
+		UDP:         curr.Sockets.UDP,
+		Unix:        curr.Sockets.Unix,
+	}
+	for _, n := range states {
+		s.TCP += n
+	}
+}
+
+// Connection is listening socket or connection with queued bytes. like
+// ss, RecvQ and SendQ of listening socket are accept queue and backlog
+type Connection struct {
+	Proto  string
+	State  string
+	Local  string
+	Remote string
+	RecvQ  uint64
+	SendQ  uint64
+	Pid    int // 0 if owner is not found
+	Comm   string
+}
+
+func (c *Connection) DefaultConfig(field string) Field {
+	cfg := Field{}
+	switch field {
+	case "Proto":
+		cfg = Field{"Proto", Raw, 0, "", 5, false}
+	case "State":
+		cfg = Field{"State", Raw, 0, "", 11, false}
+	case "Local":
+		cfg = Field{"Local", Raw, 0, "", 25, false}
+	case "Remote":
+		cfg = Field{"Remote", Raw, 0, "", 25, false}
+	case "RecvQ":
+		cfg = Field{"RecvQ", Raw, 0, "", 10, false}
+	case "SendQ":
+		cfg = Field{"SendQ", Raw, 0, "", 10, false}
+	case "Pid":
+		cfg = Field{"Pid", Raw, 0, "", 8, false}
+	case "Comm":
+		cfg = Field{"Comm", Raw, 0, "", 16, false}
+	}
+	return cfg
+}
+
+func (c *Connection) GetRenderValue(field string, opt FieldOpt) string {
+	cfg := c.DefaultConfig(field)
+	cfg.ApplyOpt(opt)
+	s := ""
+	switch field {
+	case "Proto":
+		s = cfg.Render(c.Proto)
+	case "State":
+		s = cfg.Render(c.State)
+	case "Local":
+		s = cfg.Render(c.Local)
+	case "Remote":
+		remote := c.Remote
+		if remote == "" {
+			remote = "-"
+		}
+		s = cfg.Render(remote)
+	case "RecvQ":
+		s = cfg.Render(c.RecvQ)
+	case "SendQ":
+		s = cfg.Render(c.SendQ)
+	case "Pid":
+		if c.Pid == 0 {
+			s = cfg.Render("-")
+		} else {
+			s = cfg.Render(c.Pid)
+		}
+	case "Comm":
+		comm := c.Comm
+		if comm == "" {
+			comm = "-"
+		}
+		s = cfg.Render(comm)
+	default:
+		s = "no " + field + " for connection stat"
+	}
+	return s
+}
+
+// ConnectionSlice is listening sockets followed by top connections by
+// queued bytes
+type ConnectionSlice []Connection
+
+func (cs *ConnectionSlice) Collect(prev, curr *store.Sample) {
+	*cs = (*cs)[:0]
+	for _, entries := range [][]store.SocketEntry{curr.Sockets.Listens, curr.Sockets.Connections} {
+		for _, e := range entries {
+			*cs = append(*cs, Connection{
+				Proto:  e.Proto,
+				State:  e.State,
+				Local:  e.Local,
+				Remote: e.Remote,
+				RecvQ:  uint64(e.RecvQ),
+				SendQ:  uint64(e.SendQ),
+				Pid:    e.Pid,
+				Comm:   e.Comm,
+			})
+		}
+	}
+}
+
+// OfPid return listening sockets and connections owned by pid
+func (cs ConnectionSlice) OfPid(pid int) []*Connection {
+	res := []*Connection{}
+	for i := range cs {
+		if cs[i].Pid == pid {
+			res = append(res, &cs[i])
+		}
+	}
+	return res
+}
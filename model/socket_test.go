@@ -0,0 +1,64 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/xixiliguo/etop/procfs"
+	"github.com/xixiliguo/etop/store"
+)
+
+func TestSocket(t *testing.T) {
+	curr := store.NewSample()
+	curr.Sockets = store.SocketSample{
+		TCPStates: map[string]uint64{
+			"ESTABLISHED":  3,
+			"LISTEN":       2,
+			"SYN_RECV":     1,
+			"NEW_SYN_RECV": 4,
+			"TIME_WAIT":    6,
+		},
+		UDP:  7,
+		Unix: 8,
+		Listens: []store.SocketEntry{
+			{Socket: procfs.Socket{Proto: "tcp", State: "LISTEN", Local: "0.0.0.0:80", RecvQ: 1, SendQ: 128}, Pid: 10, Comm: "nginx"},
+		},
+		Connections: []store.SocketEntry{
+			{Socket: procfs.Socket{Proto: "tcp", State: "ESTABLISHED", Local: "127.0.0.1:80", Remote: "127.0.0.1:4000", RecvQ: 5}},
+		},
+	}
+
+	s := Socket{}
+	s.Collect(nil, &curr)
+	for field, want := range map[string]string{
+		"TCP":         "16",
+		"Established": "3",
+		"SynRecv":     "5",
+		"TimeWait":    "6",
+		"Listen":      "2",
+		"UDP":         "7",
+		"Unix":        "8",
+	} {
+		if got := s.GetRenderValue(field, FieldOpt{}); got != want {
+			t.Errorf("%s: got %s, but want %s", field, got, want)
+		}
+	}
+
+	cs := ConnectionSlice{}
+	cs.Collect(nil, &curr)
+	if len(cs) != 2 {
+		t.Fatalf("got %d connections, but want 2", len(cs))
+	}
+	if got := cs.OfPid(10); len(got) != 1 || got[0].Local != "0.0.0.0:80" {
+		t.Errorf("got %v sockets of pid 10, but want listening socket", got)
+	}
+	for field, want := range map[string]string{
+		"Remote": "127.0.0.1:4000",
+		"RecvQ":  "5",
+		"Pid":    "-",
+		"Comm":   "-",
+	} {
+		if got := cs[1].GetRenderValue(field, FieldOpt{}); got != want {
+			t.Errorf("%s: got %s, but want %s", field, got, want)
+		}
+	}
+}
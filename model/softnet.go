@@ -1,7 +1,12 @@
 package model
 
 import (
+	"time"
+
 	"github.com/xixiliguo/etop/store"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 )
 
 var DefaultSoftnetFields = []string{"CPU", "Processed", "Dropped",
@@ -102,3 +107,45 @@ func (softnets *SoftnetSlice) Collect(prev, curr *store.Sample) {
 		*softnets = append(*softnets, s)
 	}
 }
+
+func (softnets *SoftnetSlice) GetOtelMetric(timeStamp int64, sm *metricdata.ScopeMetrics) {
+
+	sm.Scope = instrumentation.Scope{Name: "softnet", Version: "0.0.1"}
+	md := metricdata.Metrics{
+		Name: "softnet.events",
+	}
+	data := metricdata.Gauge[int64]{}
+
+	for _, s := range *softnets {
+		cpu := attribute.Int64("cpu", int64(s.CPU))
+		data.DataPoints = append(data.DataPoints, []metricdata.DataPoint[int64]{
+			{
+				Attributes: attribute.NewSet(cpu, attribute.String("event", "processed")),
+				Time:       time.Unix(timeStamp, 0),
+				Value:      int64(s.Processed),
+			},
+			{
+				Attributes: attribute.NewSet(cpu, attribute.String("event", "dropped")),
+				Time:       time.Unix(timeStamp, 0),
+				Value:      int64(s.Dropped),
+			},
+			{
+				Attributes: attribute.NewSet(cpu, attribute.String("event", "time.squeezed")),
+				Time:       time.Unix(timeStamp, 0),
+				Value:      int64(s.TimeSqueezed),
+			},
+			{
+				Attributes: attribute.NewSet(cpu, attribute.String("event", "received.rps")),
+				Time:       time.Unix(timeStamp, 0),
+				Value:      int64(s.ReceivedRps),
+			},
+			{
+				Attributes: attribute.NewSet(cpu, attribute.String("event", "flow.limit")),
+				Time:       time.Unix(timeStamp, 0),
+				Value:      int64(s.FlowLimitCount),
+			},
+		}...)
+	}
+	md.Data = data
+	sm.Metrics = append(sm.Metrics, md)
+}
@@ -0,0 +1,118 @@
+package model
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/xixiliguo/etop/store"
+)
+
+// recordWriter write one record per line in csv or ndjson format,
+// so that dump can be streamed into other tools without buffering
+type recordWriter struct {
+	format  string
+	out     io.Writer
+	csv     *csv.Writer
+	columns []string
+	m       map[string]string
+}
+
+func newRecordWriter(opt DumpOption, columns []string) *recordWriter {
+	w := &recordWriter{
+		format:  opt.Format,
+		out:     opt.Output,
+		columns: columns,
+		m:       make(map[string]string, len(columns)),
+	}
+	if w.format == "csv" {
+		w.csv = csv.NewWriter(opt.Output)
+		if !opt.DisableTitle {
+			w.csv.Write(columns)
+		}
+	}
+	return w
+}
+
+// Write write values of one record, which is in the same order of columns
+func (w *recordWriter) Write(values []string) error {
+	if w.format == "csv" {
+		return w.csv.Write(values)
+	}
+	clear(w.m)
+	for i, c := range w.columns {
+		w.m[c] = values[i]
+	}
+	b, err := json.Marshal(w.m)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	_, err = w.out.Write(b)
+	return err
+}
+
+func (w *recordWriter) Flush() error {
+	if w.format == "csv" {
+		w.csv.Flush()
+		return w.csv.Error()
+	}
+	return nil
+}
+
+// hasKey return true if module have multiple objects in one sample
+func hasKey(module string) bool {
+	switch module {
+	case "system", "memory", "vm", "etop", "snmp", "socket", "event", "inventory":
+		return false
+	}
+	return true
+}
+
+// recordColumns return column names of record
+func recordColumns(opt DumpOption) []string {
+	columns := []string{"Timestamp"}
+	if hasKey(opt.Module) {
+		columns = append(columns, "Key")
+	}
+	for _, f := range opt.Fields {
+		name, _ := getNameAndWidthOfField(opt.Module, f)
+		columns = append(columns, name)
+	}
+	return columns
+}
+
+// dumpRecord output one record per object of every sample in csv or
+// ndjson format. cgroup tree is flattened and keyed by FullPath.
+func (s *Model) dumpRecord(opt DumpOption) error {
+
+	if err := s.CollectSampleByTime(opt.Begin); err != nil {
+		return err
+	}
+
+	w := newRecordWriter(opt, recordColumns(opt))
+	values := make([]string, 0, len(opt.Fields)+2)
+	for opt.End >= s.Curr.TimeStamp {
+		dateTime := time.Unix(s.Curr.TimeStamp, 0).Format(time.RFC3339)
+		for key, m := range s.iterateDump(opt) {
+			values = append(values[:0], dateTime)
+			if hasKey(opt.Module) {
+				values = append(values, key)
+			}
+			for _, f := range opt.Fields {
+				values = append(values, m.GetRenderValue(f, FieldOpt{Raw: opt.RawData}))
+			}
+			if err := w.Write(values); err != nil {
+				return err
+			}
+		}
+		if err := s.CollectNext(); err != nil {
+			if err == store.ErrOutOfRange {
+				break
+			}
+			return err
+		}
+	}
+	return w.Flush()
+}
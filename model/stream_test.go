@@ -0,0 +1,85 @@
+package model
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDumpCSV(t *testing.T) {
+
+	sm := newAggregateStore(t)
+	got := dumpToString(t, sm, DumpOption{
+		Begin:      1000,
+		End:        1010,
+		Module:     "process",
+		Format:     "csv",
+		Fields:     []string{"Pid", "Comm", "CmdLine"},
+		FilterText: `Comm == "sh"`,
+		SortField:  "Pid",
+	})
+	records, err := csv.NewReader(strings.NewReader(got)).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv %s: %s", got, err)
+	}
+	want := [][]string{
+		{"Timestamp", "Key", "Pid", "Comm", "CmdLine"},
+		{time.Unix(1005, 0).Format(time.RFC3339), "1-0", "1", "sh", `sh -c "echo a,b"`},
+		{time.Unix(1010, 0).Format(time.RFC3339), "1-0", "1", "sh", `sh -c "echo a,b"`},
+	}
+	if diff := cmp.Diff(want, records); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDumpNDJson(t *testing.T) {
+
+	sm := newAggregateStore(t)
+	got := dumpToString(t, sm, DumpOption{
+		Begin:   1000,
+		End:     1005,
+		Module:  "disk",
+		Format:  "ndjson",
+		Fields:  []string{"Disk", "ReadPerSec"},
+		RawData: true,
+	})
+	rows := []map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(got), "\n") {
+		row := map[string]string{}
+		if err := json.Unmarshal([]byte(line), &row); err != nil {
+			t.Fatalf("unmarshal %s: %s", line, err)
+		}
+		rows = append(rows, row)
+	}
+	ts := time.Unix(1005, 0).Format(time.RFC3339)
+	want := []map[string]string{
+		{"Timestamp": ts, "Key": "sda", "Disk": "sda", "Read/s": "2"},
+		{"Timestamp": ts, "Key": "sdb", "Disk": "sdb", "Read/s": "4"},
+	}
+	if diff := cmp.Diff(want, rows); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	// aggregate also support ndjson, Key is always present
+	got = dumpToString(t, sm, DumpOption{
+		Begin:     1000,
+		End:       1030,
+		Module:    "system",
+		Format:    "ndjson",
+		Fields:    []string{"Load1"},
+		Aggregate: 2 * time.Minute,
+		Stats:     []string{"max"},
+	})
+	row := map[string]string{}
+	if err := json.Unmarshal([]byte(got), &row); err != nil {
+		t.Fatalf("unmarshal %s: %s", got, err)
+	}
+	wantRow := map[string]string{"Timestamp": time.Unix(960, 0).Format(time.RFC3339), "Stat": "max", "Key": "", "Load1": "7.00"}
+	if diff := cmp.Diff(wantRow, row); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
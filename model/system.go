@@ -1,7 +1,15 @@
 package model
 
 import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/xixiliguo/etop/cgroupfs"
 	"github.com/xixiliguo/etop/store"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 )
 
 var DefaultSystemFields = []string{"Load1", "Load5", "Load15", "NumCPU",
@@ -9,6 +17,34 @@ var DefaultSystemFields = []string{"Load1", "Load5", "Load15", "NumCPU",
 	"ProcessesRunning", "ProcessesBlocked",
 	"ClonePerSec", "ContextSwitchPerSec"}
 
+// AllSystemFields also include system-wide pressure, e.g CPUSomeAvg10
+var AllSystemFields = func() []string {
+	fs := append([]string{}, DefaultSystemFields...)
+	for _, r := range pressureResources {
+		for _, stat := range pressureStats {
+			fs = append(fs, r+stat)
+		}
+	}
+	return fs
+}()
+
+// pressure fields are named as resource and stat, e.g MemoryFullStall
+var (
+	pressureResources = []string{"CPUSome", "CPUFull", "MemorySome", "MemoryFull",
+		"IOSome", "IOFull", "IRQFull"}
+	pressureStats = []string{"Avg10", "Avg60", "Avg300", "Stall"}
+)
+
+// Pressure is pressure of one resource from /proc/pressure, Avg are
+// computed by kernel, Stall is percent of time stalled during interval.
+// unknown value is math.MaxFloat64, e.g irq without CONFIG_IRQ_TIME_ACCOUNTING
+type Pressure struct {
+	Avg10  float64
+	Avg60  float64
+	Avg300 float64
+	Stall  float64
+}
+
 type System struct {
 	Load1               float64
 	Load5               float64
@@ -20,6 +56,40 @@ type System struct {
 	ProcessesBlocked    uint64
 	ClonePerSec         float64
 	ContextSwitchPerSec float64
+	CPUSome             Pressure
+	CPUFull             Pressure
+	MemorySome          Pressure
+	MemoryFull          Pressure
+	IOSome              Pressure
+	IOFull              Pressure
+	IRQFull             Pressure
+}
+
+// pressureField return pressure and stat of field like CPUSomeAvg10
+func (sys *System) pressureField(field string) (*Pressure, string, bool) {
+	for _, stat := range pressureStats {
+		r, ok := strings.CutSuffix(field, stat)
+		if !ok {
+			continue
+		}
+		switch r {
+		case "CPUSome":
+			return &sys.CPUSome, stat, true
+		case "CPUFull":
+			return &sys.CPUFull, stat, true
+		case "MemorySome":
+			return &sys.MemorySome, stat, true
+		case "MemoryFull":
+			return &sys.MemoryFull, stat, true
+		case "IOSome":
+			return &sys.IOSome, stat, true
+		case "IOFull":
+			return &sys.IOFull, stat, true
+		case "IRQFull":
+			return &sys.IRQFull, stat, true
+		}
+	}
+	return nil, "", false
 }
 
 func (sys *System) DefaultConfig(field string) Field {
@@ -46,6 +116,10 @@ func (sys *System) DefaultConfig(field string) Field {
 		cfg = Field{"Clone/s", Raw, 1, "/s", 10, false}
 	case "ContextSwitchPerSec":
 		cfg = Field{"CtxSw/s", Raw, 1, "/s", 10, false}
+	default:
+		if _, _, ok := sys.pressureField(field); ok {
+			cfg = Field{field, Raw, 2, "%", 16, false}
+		}
 	}
 	return cfg
 }
@@ -77,16 +151,32 @@ func (sys *System) GetRenderValue(field string, opt FieldOpt) string {
 	case "ContextSwitchPerSec":
 		s = cfg.Render(sys.ContextSwitchPerSec)
 	default:
-		s = "no " + field + " for cpu stat"
+		if p, stat, ok := sys.pressureField(field); ok {
+			switch stat {
+			case "Avg10":
+				s = cfg.Render(p.Avg10)
+			case "Avg60":
+				s = cfg.Render(p.Avg60)
+			case "Avg300":
+				s = cfg.Render(p.Avg300)
+			case "Stall":
+				s = cfg.Render(p.Stall)
+			}
+		} else {
+			s = "no " + field + " for cpu stat"
+		}
 	}
 	return s
 }
 
 func (sys *System) Collect(prev, curr *store.Sample) {
 
-	sys.Load1 = curr.Load1
-	sys.Load5 = curr.Load5
-	sys.Load15 = curr.Load15
+	// load is kept if it was not collected
+	if curr.Has(store.ModuleLoad) {
+		sys.Load1 = curr.Load1
+		sys.Load5 = curr.Load5
+		sys.Load15 = curr.Load15
+	}
 	sys.NumCPU = uint64(len(curr.CPU))
 	sys.ProcessesRunning = curr.ProcessesRunning
 	sys.ProcessesBlocked = curr.ProcessesBlocked
@@ -96,3 +186,104 @@ func (sys *System) Collect(prev, curr *store.Sample) {
 	sys.ContextSwitchPerSec = float64(curr.ContextSwitches-prev.ContextSwitches) / float64(interval)
 
 }
+
+// CollectPressure compute system-wide pressure, stall is from increase of
+// total stall time in microseconds
+func (sys *System) CollectPressure(prev, curr *store.Sample) {
+
+	interval := float64(curr.TimeStamp - prev.TimeStamp)
+	collect := func(p *Pressure, prev, curr cgroupfs.PSIData) {
+		p.Avg10, p.Avg60, p.Avg300 = curr.Avg10, curr.Avg60, curr.Avg300
+		p.Stall = math.MaxFloat64
+		if curr.Total != math.MaxUint64 && prev.Total != math.MaxUint64 && curr.Total >= prev.Total {
+			p.Stall = float64(curr.Total-prev.Total) / interval / 1e4
+		}
+	}
+	p, c := &prev.Pressure, &curr.Pressure
+	collect(&sys.CPUSome, p.CPU.Some, c.CPU.Some)
+	collect(&sys.CPUFull, p.CPU.Full, c.CPU.Full)
+	collect(&sys.MemorySome, p.Memory.Some, c.Memory.Some)
+	collect(&sys.MemoryFull, p.Memory.Full, c.Memory.Full)
+	collect(&sys.IOSome, p.IO.Some, c.IO.Some)
+	collect(&sys.IOFull, p.IO.Full, c.IO.Full)
+	collect(&sys.IRQFull, p.IRQ.Full, c.IRQ.Full)
+}
+
+// resetPressure mark pressure as unknown if it was not collected
+func (sys *System) resetPressure() {
+	unknown := Pressure{math.MaxFloat64, math.MaxFloat64, math.MaxFloat64, math.MaxFloat64}
+	sys.CPUSome, sys.CPUFull = unknown, unknown
+	sys.MemorySome, sys.MemoryFull = unknown, unknown
+	sys.IOSome, sys.IOFull = unknown, unknown
+	sys.IRQFull = unknown
+}
+
+func (sys *System) GetOtelMetric(timeStamp int64, sm *metricdata.ScopeMetrics) {
+
+	sm.Scope = instrumentation.Scope{Name: "system", Version: "0.0.1"}
+	pressure := metricdata.Metrics{
+		Name: "system.pressure",
+	}
+	pressureData := metricdata.Gauge[float64]{}
+	stall := metricdata.Metrics{
+		Name: "system.pressure.stall",
+	}
+	stallData := metricdata.Gauge[float64]{}
+
+	// missing value should not be sent
+	appendFloat := func(data *metricdata.Gauge[float64], value float64, attrs ...attribute.KeyValue) {
+		if value == math.MaxFloat64 {
+			return
+		}
+		data.DataPoints = append(data.DataPoints, metricdata.DataPoint[float64]{
+			Attributes: attribute.NewSet(attrs...),
+			Time:       time.Unix(timeStamp, 0),
+			Value:      value,
+		})
+	}
+	for _, r := range []struct {
+		resource string
+		typ      string
+		p        *Pressure
+	}{
+		{"cpu", "some", &sys.CPUSome},
+		{"cpu", "full", &sys.CPUFull},
+		{"memory", "some", &sys.MemorySome},
+		{"memory", "full", &sys.MemoryFull},
+		{"io", "some", &sys.IOSome},
+		{"io", "full", &sys.IOFull},
+		{"irq", "full", &sys.IRQFull},
+	} {
+		resource, typ := attribute.String("resource", r.resource), attribute.String("type", r.typ)
+		appendFloat(&pressureData, r.p.Avg10, resource, typ, attribute.String("window", "10s"))
+		appendFloat(&pressureData, r.p.Avg60, resource, typ, attribute.String("window", "60s"))
+		appendFloat(&pressureData, r.p.Avg300, resource, typ, attribute.String("window", "300s"))
+		appendFloat(&stallData, r.p.Stall, resource, typ)
+	}
+	pressure.Data = pressureData
+	stall.Data = stallData
+	sm.Metrics = append(sm.Metrics, pressure, stall)
+}
+
+func (sys *System) GetPromMetric(w *PromWriter) {
+	w.Metric("load1", "gauge", "1 minute load average.")
+	w.Sample("load1", sys.Load1)
+	w.Metric("load5", "gauge", "5 minutes load average.")
+	w.Sample("load5", sys.Load5)
+	w.Metric("load15", "gauge", "15 minutes load average.")
+	w.Sample("load15", sys.Load15)
+	w.Metric("cpu_count", "gauge", "Number of CPUs.")
+	w.Sample("cpu_count", float64(sys.NumCPU))
+	w.Metric("processes", "gauge", "Number of processes.")
+	w.Sample("processes", float64(sys.Processes))
+	w.Metric("threads", "gauge", "Number of threads.")
+	w.Sample("threads", float64(sys.Threads))
+	w.Metric("processes_running", "gauge", "Number of processes in runnable state.")
+	w.Sample("processes_running", float64(sys.ProcessesRunning))
+	w.Metric("processes_blocked", "gauge", "Number of processes blocked waiting for I/O.")
+	w.Sample("processes_blocked", float64(sys.ProcessesBlocked))
+	w.Metric("clones_per_second", "gauge", "Processes and threads created per second.")
+	w.Sample("clones_per_second", sys.ClonePerSec)
+	w.Metric("context_switches_per_second", "gauge", "Context switches per second.")
+	w.Sample("context_switches_per_second", sys.ContextSwitchPerSec)
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"log/slog"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/xixiliguo/etop/cgroupfs"
+	"github.com/xixiliguo/etop/store"
+)
+
+func TestSystemPressure(t *testing.T) {
+	sm, _ := NewSysModel(nil, slog.Default())
+	others := slices.DeleteFunc(slices.Clone(store.Modules), func(m string) bool {
+		return m == store.ModulePressure
+	})
+	pressure := func(total uint64) store.Sample {
+		s := store.NewSample()
+		s.Missing = others
+		s.Pressure.CPU = cgroupfs.NewPSIStats()
+		s.Pressure.CPU.Some = cgroupfs.PSIData{Avg10: 1.5, Avg60: 0.5, Avg300: 0.1, Total: total}
+		s.Pressure.Memory = cgroupfs.NewPSIStats()
+		s.Pressure.IO = cgroupfs.NewPSIStats()
+		s.Pressure.IRQ = cgroupfs.NewPSIStats()
+		return s
+	}
+	prev := pressure(1000000)
+	prev.TimeStamp = 100
+	curr := pressure(1500000)
+	curr.TimeStamp = 110
+	sm.Prev, sm.Curr = prev, curr
+	sm.CollectField()
+
+	for field, want := range map[string]string{
+		"CPUSomeAvg10":  "1.50%",
+		"CPUSomeAvg300": "0.10%",
+		"CPUSomeStall":  "5.00%",
+		"CPUFullStall":  "-",
+		"IRQFullAvg10":  "-",
+	} {
+		if got := sm.Sys.GetRenderValue(field, FieldOpt{}); got != want {
+			t.Errorf("%s: got %s, but want %s", field, got, want)
+		}
+	}
+	for _, f := range AllSystemFields {
+		if name, _ := getNameAndWidthOfField("system", f); name == "" {
+			t.Errorf("%s should be a field of system", f)
+		}
+	}
+
+	// pressure is kept if it was not collected, and unknown if it was
+	// never collected before
+	sm.lastSeen = nil
+	sm.Prev.Missing = store.Modules
+	sm.Curr.Missing = store.Modules
+	sm.CollectField()
+	if sm.Sys.CPUSome.Stall != 5 {
+		t.Errorf("got %v, pressure should be kept if it was not collected", sm.Sys.CPUSome)
+	}
+	sm.Curr.Missing = others
+	sm.CollectField()
+	if sm.Sys.CPUSome.Stall != math.MaxFloat64 {
+		t.Errorf("got %v, but want unknown", sm.Sys.CPUSome)
+	}
+}
@@ -0,0 +1,280 @@
+package model
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/mattn/go-runewidth"
+	"github.com/xixiliguo/etop/store"
+)
+
+var DefaultThreadFields = []string{"Tid", "Pid", "Comm", "State", "CPU", "User", "System", "RunDelay", "ReadBytePerSec", "WriteBytePerSec"}
+var AllThreadFields = []string{"Tid", "Pid", "Comm", "State", "StartTime", "OnCPU",
+	"User", "System", "Priority", "Nice", "Policy", "CPU", "RunDelay", "BlkDelay",
+	"MinFlt", "MajFlt",
+	"ReadCharPerSec", "WriteCharPerSec",
+	"SyscRPerSec", "SyscWPerSec",
+	"ReadBytePerSec", "WriteBytePerSec", "CancelledWriteBytePerSec"}
+
+// Thread is one thread of process, only threads of top processes by cpu
+// are collected if etop record --threads is specified
+type Thread struct {
+	Tid       int
+	Pid       int // process which thread belongs to
+	Comm      string
+	State     string
+	StartTime uint64
+	OnCPU     int
+	PCPU
+	MinFlt uint64
+	MajFlt uint64
+	PIO
+	Level int
+}
+
+func (t *Thread) DefaultConfig(field string) Field {
+
+	cfg := Field{}
+	switch field {
+	case "Tid":
+		cfg = Field{"Tid", Raw, 0, "", 10, false}
+	case "Pid":
+		cfg = Field{"Pid", Raw, 0, "", 10, false}
+	case "Comm":
+		cfg = Field{"Comm", Raw, 0, "", 16, false}
+	case "State":
+		cfg = Field{"State", Raw, 0, "", 10, false}
+	case "StartTime":
+		cfg = Field{"StartTime", Raw, 0, "", 10, false}
+	case "OnCPU":
+		cfg = Field{"OnCPU", Raw, 0, "", 10, false}
+	case "MinFlt":
+		cfg = Field{"MinFlt", Raw, 0, "", 10, false}
+	case "MajFlt":
+		cfg = Field{"MajFlt", Raw, 0, "", 10, false}
+	case "User", "System", "Priority", "Nice", "Policy", "CPU", "RunDelay", "BlkDelay":
+		return t.PCPU.DefaultConfig(field)
+	case "RChar", "WChar", "ReadCharPerSec", "WriteCharPerSec",
+		"SyscR", "SyscW", "SyscRPerSec", "SyscWPerSec",
+		"ReadBytes", "WriteBytes", "CancelledWriteBytes", "ReadBytePerSec", "WriteBytePerSec", "CancelledWriteBytePerSec":
+		return t.PIO.DefaultConfig(field)
+	}
+	return cfg
+}
+
+// GetRenderValue return empty string for field which thread does not
+// have, so that thread can be shown under its process
+func (t *Thread) GetRenderValue(field string, opt FieldOpt) string {
+
+	cfg := t.DefaultConfig(field)
+	cfg.ApplyOpt(opt)
+	s := ""
+	switch field {
+	case "Tid":
+		s = cfg.Render(t.Tid)
+	case "Pid":
+		s = cfg.Render(t.Pid)
+	case "Comm":
+		s = cfg.Render(t.Comm)
+		if t.Level > 0 {
+			s = strings.Repeat("   ", t.Level-1) + "└─ " + t.Comm
+			if cfg.FixWidth {
+				pad := cfg.Width - runewidth.StringWidth(s)
+				if pad > 0 {
+					s = s + strings.Repeat(" ", pad)
+				}
+			}
+		}
+	case "State":
+		s = cfg.Render(t.State)
+	case "StartTime":
+		startTime := time.Unix(int64(t.StartTime), 0).Format(time.RFC3339)
+		s = cfg.Render(startTime)
+	case "OnCPU":
+		s = cfg.Render(t.OnCPU)
+	case "MinFlt":
+		s = cfg.Render(t.MinFlt)
+	case "MajFlt":
+		s = cfg.Render(t.MajFlt)
+	case "User", "System", "Priority", "Nice", "Policy", "CPU", "RunDelay", "BlkDelay":
+		return t.PCPU.GetRenderValue(field, opt)
+	case "RChar", "WChar", "ReadCharPerSec", "WriteCharPerSec",
+		"SyscR", "SyscW", "SyscRPerSec", "SyscWPerSec",
+		"ReadBytes", "WriteBytes", "CancelledWriteBytes", "ReadBytePerSec", "WriteBytePerSec", "CancelledWriteBytePerSec":
+		return t.PIO.GetRenderValue(field, opt)
+	}
+	return s
+}
+
+type ThreadMap map[int]*Thread
+
+// Iterate return threads which match searchprogram, pid is process which
+// threads belong to, 0 means all processes
+func (threadMap ThreadMap) Iterate(pid int, searchprogram *vm.Program, sortField string, descOrder bool) []*Thread {
+
+	res := make([]*Thread, 0, len(threadMap))
+	for _, t := range threadMap {
+		if pid != 0 && t.Pid != pid {
+			continue
+		}
+		if searchprogram != nil {
+			output, _ := expr.Run(searchprogram, t)
+			if !output.(bool) {
+				continue
+			}
+		}
+		res = append(res, t)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Tid < res[j].Tid
+	})
+	sort.SliceStable(res, func(i, j int) bool {
+		switch sortField {
+		case "Tid":
+			return res[i].Tid > res[j].Tid
+		case "Pid":
+			return res[i].Pid > res[j].Pid
+		case "Comm":
+			return res[i].Comm > res[j].Comm
+		case "State":
+			return res[i].State > res[j].State
+		case "StartTime":
+			return res[i].StartTime > res[j].StartTime
+		case "OnCPU":
+			return res[i].OnCPU > res[j].OnCPU
+		case "User":
+			return res[i].User > res[j].User
+		case "System":
+			return res[i].System > res[j].System
+		case "Priority":
+			return res[i].Priority > res[j].Priority
+		case "Nice":
+			return res[i].Nice > res[j].Nice
+		case "Policy":
+			return res[i].Policy > res[j].Policy
+		case "CPU":
+			return res[i].CPU > res[j].CPU
+		case "RunDelay":
+			return res[i].RunDelay > res[j].RunDelay
+		case "BlkDelay":
+			return res[i].BlkDelay > res[j].BlkDelay
+		case "MinFlt":
+			return res[i].MinFlt > res[j].MinFlt
+		case "MajFlt":
+			return res[i].MajFlt > res[j].MajFlt
+		case "ReadCharPerSec":
+			return res[i].ReadCharPerSec > res[j].ReadCharPerSec
+		case "WriteCharPerSec":
+			return res[i].WriteCharPerSec > res[j].WriteCharPerSec
+		case "SyscRPerSec":
+			return res[i].SyscRPerSec > res[j].SyscRPerSec
+		case "SyscWPerSec":
+			return res[i].SyscWPerSec > res[j].SyscWPerSec
+		case "ReadBytePerSec":
+			return res[i].ReadBytePerSec > res[j].ReadBytePerSec
+		case "WriteBytePerSec":
+			return res[i].WriteBytePerSec > res[j].WriteBytePerSec
+		case "CancelledWriteBytePerSec":
+			return res[i].CancelledWriteBytePerSec > res[j].CancelledWriteBytePerSec
+		}
+		return false
+	})
+	if !descOrder {
+		for i := 0; i < len(res)/2; i++ {
+			res[i], res[len(res)-1-i] = res[len(res)-1-i], res[i]
+		}
+	}
+	return res
+}
+
+// Collect compute threads of processes which have threads in curr.
+// rates are unknown if threads of process were not collected in prev
+func (threadMap ThreadMap) Collect(prev, curr *store.Sample) {
+
+	clear(threadMap)
+
+	interval := curr.TimeStamp - prev.TimeStamp
+	bootTime := curr.BootTimeTick
+	if bootTime == 0 || !enableBootTimeTick {
+		bootTime = curr.BootTime * 100
+	}
+
+	for pid, proc := range curr.ProcSamples {
+		if proc.Threads == nil {
+			continue
+		}
+		oldProc := prev.ProcSamples[pid]
+		known := oldProc.Threads != nil && oldProc.Starttime == proc.Starttime
+
+		for tid, new := range proc.Threads {
+			old, ok := oldProc.Threads[tid]
+			if !ok || old.Starttime != new.Starttime {
+				// created after prev
+				old = store.ThreadSample{}
+			}
+
+			t := Thread{
+				Tid:       tid,
+				Pid:       pid,
+				Comm:      new.Comm,
+				State:     new.State.String(),
+				StartTime: (bootTime + new.Starttime) / userHZ,
+				OnCPU:     new.Processor,
+			}
+			t.Priority = new.Priority
+			t.Nice = new.Nice
+			t.Policy = new.Policy.String()
+
+			if !known {
+				t.User = math.MaxFloat64
+				t.System = math.MaxFloat64
+				t.CPU = math.MaxFloat64
+				t.RunDelay = math.MaxUint64
+				t.BlkDelay = math.MaxUint64
+				t.MinFlt = math.MaxUint64
+				t.MajFlt = math.MaxUint64
+				t.ReadCharPerSec = math.MaxFloat64
+				t.WriteCharPerSec = math.MaxFloat64
+				t.SyscRPerSec = math.MaxFloat64
+				t.SyscWPerSec = math.MaxFloat64
+				t.ReadBytePerSec = math.MaxFloat64
+				t.WriteBytePerSec = math.MaxFloat64
+				t.CancelledWriteBytePerSec = math.MaxFloat64
+				threadMap[tid] = &t
+				continue
+			}
+
+			t.User = SubWithInterval(new.UTime, old.UTime, interval)
+			t.System = SubWithInterval(new.STime, old.STime, interval)
+			t.CPU = math.MaxFloat64
+			if t.User != math.MaxFloat64 && t.System != math.MaxFloat64 {
+				t.CPU = t.User + t.System
+			}
+			t.RunDelay = Sub(new.WaitingNanoseconds, old.WaitingNanoseconds) / 1000000
+			t.BlkDelay = Sub(new.DelayAcctBlkIOTicks, old.DelayAcctBlkIOTicks) * 10
+			t.MinFlt = Sub(new.MinFlt, old.MinFlt)
+			t.MajFlt = Sub(new.MajFlt, old.MajFlt)
+
+			t.RChar = Sub(new.RChar, old.RChar)
+			t.WChar = Sub(new.WChar, old.WChar)
+			t.ReadCharPerSec = SubWithInterval(new.RChar, old.RChar, interval)
+			t.WriteCharPerSec = SubWithInterval(new.WChar, old.WChar, interval)
+			t.SyscR = Sub(new.SyscR, old.SyscR)
+			t.SyscW = Sub(new.SyscW, old.SyscW)
+			t.SyscRPerSec = SubWithInterval(new.SyscR, old.SyscR, interval)
+			t.SyscWPerSec = SubWithInterval(new.SyscW, old.SyscW, interval)
+			t.ReadBytes = Sub(new.ReadBytes, old.ReadBytes)
+			t.WriteBytes = Sub(new.WriteBytes, old.WriteBytes)
+			t.CancelledWriteBytes = int64(Sub(new.CancelledWriteBytes, old.CancelledWriteBytes))
+			t.ReadBytePerSec = float64(t.ReadBytes) / float64(interval)
+			t.WriteBytePerSec = float64(t.WriteBytes) / float64(interval)
+			t.CancelledWriteBytePerSec = float64(t.CancelledWriteBytes) / float64(interval)
+			threadMap[tid] = &t
+		}
+	}
+}
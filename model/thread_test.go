@@ -0,0 +1,78 @@
+package model
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/xixiliguo/etop/procfs"
+	"github.com/xixiliguo/etop/store"
+)
+
+func TestThreadCollect(t *testing.T) {
+	dir := t.TempDir()
+	local, err := store.NewLocalStore(
+		store.WithPathAndLogger(dir, slog.Default()),
+		store.WithWriteOnly(store.NoCompress, 0),
+	)
+	if err != nil {
+		t.Fatalf("new store: %s", err)
+	}
+	// threads of pid 10 are collected in both samples, thread 12 is created
+	// after first sample. threads of pid 20 are only in second sample
+	for i, ticks := range []uint64{100, 150} {
+		s := store.NewSample()
+		s.TimeStamp = 1000 + int64(i)*5
+		s.PageSize = 4096
+		threads := store.TidMap{
+			11: {ProcStat: procfs.ProcStat{PID: 11, Comm: "worker-1", UTime: ticks}},
+		}
+		if i == 1 {
+			threads[12] = store.ThreadSample{ProcStat: procfs.ProcStat{PID: 12, Comm: "worker-2", STime: 25}}
+			s.ProcSamples[20] = store.ProcSample{
+				ProcStat: procfs.ProcStat{PID: 20, Comm: "go", NumThreads: 2},
+				Threads:  store.TidMap{21: {ProcStat: procfs.ProcStat{PID: 21, Comm: "go"}}},
+			}
+		}
+		s.ProcSamples[10] = store.ProcSample{
+			ProcStat: procfs.ProcStat{PID: 10, Comm: "java", NumThreads: 2},
+			Threads:  threads,
+		}
+		if _, err := local.WriteSample(&s); err != nil {
+			t.Fatalf("write sample: %s", err)
+		}
+	}
+	local.Close()
+
+	read, err := store.NewLocalStore(store.WithPathAndLogger(dir, slog.Default()))
+	if err != nil {
+		t.Fatalf("new store: %s", err)
+	}
+	sm, _ := NewSysModel(read, slog.Default())
+	got := dumpToString(t, sm, DumpOption{
+		Begin:           1000,
+		End:             1005,
+		Module:          "thread",
+		Format:          "csv",
+		Fields:          []string{"Tid", "Pid", "Comm", "CPU"},
+		SortField:       "CPU",
+		DescendingOrder: true,
+		RawData:         true,
+	})
+	want := []string{
+		"Timestamp,Key,Tid,Pid,Comm,CPU",
+		// cpu of threads of pid 20 is unknown since they were not collected before
+		",21-0,21,20,go,-",
+		",11-0,11,10,worker-1,10.0",
+		",12-0,12,10,worker-2,5.0",
+	}
+	lines := strings.Split(strings.TrimSpace(got), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, but want %d:\n%s", len(lines), len(want), got)
+	}
+	for i := range want {
+		if !strings.HasSuffix(lines[i], want[i]) {
+			t.Errorf("line %d: got %q, but want suffix %q", i, lines[i], want[i])
+		}
+	}
+}
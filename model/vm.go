@@ -1,7 +1,12 @@
 package model
 
 import (
+	"time"
+
 	"github.com/xixiliguo/etop/store"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 )
 
 var DefaultVmFields = []string{"PageIn", "PageOut",
@@ -87,3 +92,48 @@ func (v *Vm) Collect(prev, curr *store.Sample) {
 	v.PageStealDirect = curr.PageStealDirect - prev.PageStealDirect
 	v.OOMKill = curr.OOMKill - prev.OOMKill
 }
+
+func (v *Vm) GetPromMetric(w *PromWriter) {
+	w.Metric("vm_events", "gauge", "Number of vm events from /proc/vmstat during the last sample interval.")
+	w.Sample("vm_events", float64(v.PageIn), "event", "page_in")
+	w.Sample("vm_events", float64(v.PageOut), "event", "page_out")
+	w.Sample("vm_events", float64(v.SwapIn), "event", "swap_in")
+	w.Sample("vm_events", float64(v.SwapOut), "event", "swap_out")
+	w.Sample("vm_events", float64(v.PageScanKswapd), "event", "page_scan_kswapd")
+	w.Sample("vm_events", float64(v.PageScanDirect), "event", "page_scan_direct")
+	w.Sample("vm_events", float64(v.PageStealKswapd), "event", "page_steal_kswapd")
+	w.Sample("vm_events", float64(v.PageStealDirect), "event", "page_steal_direct")
+	w.Sample("vm_events", float64(v.OOMKill), "event", "oom_kill")
+}
+
+func (v *Vm) GetOtelMetric(timeStamp int64, sm *metricdata.ScopeMetrics) {
+
+	sm.Scope = instrumentation.Scope{Name: "vm", Version: "0.0.1"}
+	md := metricdata.Metrics{
+		Name: "vm.events",
+	}
+	data := metricdata.Gauge[int64]{}
+
+	for _, e := range []struct {
+		event string
+		value uint64
+	}{
+		{"page.in", v.PageIn},
+		{"page.out", v.PageOut},
+		{"swap.in", v.SwapIn},
+		{"swap.out", v.SwapOut},
+		{"page.scan.kswapd", v.PageScanKswapd},
+		{"page.scan.direct", v.PageScanDirect},
+		{"page.steal.kswapd", v.PageStealKswapd},
+		{"page.steal.direct", v.PageStealDirect},
+		{"oom.kill", v.OOMKill},
+	} {
+		data.DataPoints = append(data.DataPoints, metricdata.DataPoint[int64]{
+			Attributes: attribute.NewSet(attribute.String("event", e.event)),
+			Time:       time.Unix(timeStamp, 0),
+			Value:      int64(e.value),
+		})
+	}
+	md.Data = data
+	sm.Metrics = append(sm.Metrics, md)
+}
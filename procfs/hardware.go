@@ -0,0 +1,253 @@
+package procfs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CPUTopology represent model and topology of online cpus
+type CPUTopology struct {
+	Model   string
+	Sockets int
+	Cores   int // physical cores of all sockets
+	Threads int // logical cpus
+}
+
+// NumaNode represent one numa node
+type NumaNode struct {
+	ID       int
+	CPUs     string // e.g 0-15,32-47
+	MemTotal uint64 // bytes
+}
+
+// BlockDevice represent one physical disk from /sys/block
+type BlockDevice struct {
+	Name       string
+	Model      string
+	Rotational bool
+	Size       uint64 // bytes
+}
+
+// NetDevice represent one physical nic from /sys/class/net
+type NetDevice struct {
+	Name    string
+	Driver  string
+	Address string
+	Speed   int64 // Mb/s, -1 if link is down or unknown
+	MTU     int
+}
+
+// Cmdline return command line of kernel
+func (fs FS) Cmdline() (string, error) {
+	b, err := os.ReadFile(fs.path("cmdline"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// CPUModel return model name of the first cpu in cpuinfo
+func (fs FS) CPUModel() (string, error) {
+	f, err := os.Open(fs.path("cpuinfo"))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	model := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "model name", "cpu model", "cpu":
+			// x86, mips and ppc
+			return strings.TrimSpace(value), nil
+		case "Processor", "Hardware":
+			// arm has no model name on some kernels
+			model = strings.TrimSpace(value)
+		}
+	}
+	return model, scanner.Err()
+}
+
+// SysFS read hardware info from sys filesystem
+type SysFS struct {
+	mountPoint string
+}
+
+func NewSysFS(mount string) *SysFS {
+	fs := &SysFS{
+		mountPoint: DefaultSysMountPoint,
+	}
+	if mount != "" {
+		fs.mountPoint = mount
+	}
+	return fs
+}
+
+func (fs *SysFS) path(elem ...string) string {
+	return filepath.Join(append([]string{fs.mountPoint}, elem...)...)
+}
+
+// readString return content of file without trailing spaces
+func (fs *SysFS) readString(elem ...string) (string, error) {
+	b, err := os.ReadFile(fs.path(elem...))
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSpace(b)), nil
+}
+
+func (fs *SysFS) readInt(elem ...string) (int64, error) {
+	s, err := fs.readString(elem...)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// CPUTopology return number of sockets, cores and threads of online
+// cpus, Model is not filled
+func (fs *SysFS) CPUTopology() (CPUTopology, error) {
+	t := CPUTopology{}
+	dirs, err := filepath.Glob(fs.path("devices/system/cpu/cpu[0-9]*"))
+	if err != nil {
+		return t, err
+	}
+	sockets := map[int64]bool{}
+	cores := map[[2]int64]bool{}
+	for _, dir := range dirs {
+		name := filepath.Base(dir)
+		// offline cpu has no topology
+		pkg, err := fs.readInt("devices/system/cpu", name, "topology/physical_package_id")
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return t, err
+		}
+		core, err := fs.readInt("devices/system/cpu", name, "topology/core_id")
+		if err != nil {
+			return t, err
+		}
+		sockets[pkg] = true
+		cores[[2]int64{pkg, core}] = true
+		t.Threads++
+	}
+	t.Sockets = len(sockets)
+	t.Cores = len(cores)
+	return t, nil
+}
+
+// NumaNodes return numa nodes order by id, or nothing if kernel has no
+// numa support
+func (fs *SysFS) NumaNodes() ([]NumaNode, error) {
+	dirs, err := filepath.Glob(fs.path("devices/system/node/node[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+	nodes := []NumaNode{}
+	for _, dir := range dirs {
+		n := NumaNode{}
+		if _, err := fmt.Sscanf(filepath.Base(dir), "node%d", &n.ID); err != nil {
+			return nil, err
+		}
+		if n.CPUs, err = fs.readString("devices/system/node", filepath.Base(dir), "cpulist"); err != nil {
+			return nil, err
+		}
+		meminfo, err := fs.readString("devices/system/node", filepath.Base(dir), "meminfo")
+		if err != nil {
+			return nil, err
+		}
+		// Node 0 MemTotal:       65842112 kB
+		for _, line := range strings.Split(meminfo, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) >= 4 && fields[2] == "MemTotal:" {
+				v, err := strconv.ParseUint(fields[3], 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("%s/meminfo: %w", dir, err)
+				}
+				n.MemTotal = v * 1024
+			}
+		}
+		nodes = append(nodes, n)
+	}
+	slices.SortFunc(nodes, func(a, b NumaNode) int {
+		return a.ID - b.ID
+	})
+	return nodes, nil
+}
+
+// BlockDevices return disks which are backed by device, so that loop,
+// device mapper and md are skipped. order by name
+func (fs *SysFS) BlockDevices() ([]BlockDevice, error) {
+	entries, err := os.ReadDir(fs.path("block"))
+	if err != nil {
+		return nil, err
+	}
+	disks := []BlockDevice{}
+	for _, e := range entries {
+		name := e.Name()
+		if _, err := os.Stat(fs.path("block", name, "device")); err != nil {
+			continue
+		}
+		d := BlockDevice{Name: name}
+		// model is missing for some virtual disks, e.g virtio
+		d.Model, _ = fs.readString("block", name, "device/model")
+		if r, err := fs.readInt("block", name, "queue/rotational"); err == nil {
+			d.Rotational = r == 1
+		}
+		sectors, err := fs.readInt("block", name, "size")
+		if err != nil {
+			return nil, err
+		}
+		// size is always in 512 bytes sector
+		d.Size = uint64(sectors) * 512
+		disks = append(disks, d)
+	}
+	slices.SortFunc(disks, func(a, b BlockDevice) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return disks, nil
+}
+
+// NetDevices return nics which are backed by device, so that lo, bridge
+// and veth are skipped. order by name
+func (fs *SysFS) NetDevices() ([]NetDevice, error) {
+	entries, err := os.ReadDir(fs.path("class/net"))
+	if err != nil {
+		return nil, err
+	}
+	nics := []NetDevice{}
+	for _, e := range entries {
+		name := e.Name()
+		if _, err := os.Stat(fs.path("class/net", name, "device")); err != nil {
+			continue
+		}
+		n := NetDevice{Name: name, Speed: -1}
+		if driver, err := os.Readlink(fs.path("class/net", name, "device/driver")); err == nil {
+			n.Driver = filepath.Base(driver)
+		}
+		n.Address, _ = fs.readString("class/net", name, "address")
+		// reading speed fail with EINVAL if link is down
+		if speed, err := fs.readInt("class/net", name, "speed"); err == nil && speed > 0 {
+			n.Speed = speed
+		}
+		if mtu, err := fs.readInt("class/net", name, "mtu"); err == nil {
+			n.MTU = int(mtu)
+		}
+		nics = append(nics, n)
+	}
+	slices.SortFunc(nics, func(a, b NetDevice) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return nics, nil
+}
@@ -0,0 +1,104 @@
+package procfs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// InterruptLine is one line of /proc/interrupts or /proc/softirqs
+type InterruptLine struct {
+	Name   string // irq number, arch-specific name like NMI, or softirq like NET_RX
+	Chip   string // interrupt controller and hwirq, e.g "IO-APIC 2-edge"
+	Device string // devices of irq, or description of arch-specific line
+	// count per cpu in order of Interrupts.CPUs. lines like ERR and MIS
+	// have only one count for all cpus
+	Counts []uint64
+}
+
+// Interrupts is per cpu counts of /proc/interrupts or /proc/softirqs
+type Interrupts struct {
+	CPUs  []int // online cpus in header
+	Lines []InterruptLine
+}
+
+// Interrupts reads data from /proc/interrupts.
+func (fs FS) Interrupts() (Interrupts, error) {
+	return fs.parseInterrupts("interrupts")
+}
+
+// Softirqs reads data from /proc/softirqs.
+func (fs FS) Softirqs() (Interrupts, error) {
+	return fs.parseInterrupts("softirqs")
+}
+
+func (fs FS) parseInterrupts(file string) (Interrupts, error) {
+
+	irqs := Interrupts{}
+
+	path := fs.path(file)
+
+	err := fs.processFile(path, func(i int, line string) error {
+		// line refers to buffer of fs, which is reused
+		fields := strings.Fields(strings.Clone(line))
+		if i == 0 {
+			for _, f := range fields {
+				cpu, err := strconv.Atoi(strings.TrimPrefix(f, "CPU"))
+				if err != nil {
+					return fmt.Errorf("unexpected header in %s: '%s'", path, line)
+				}
+				irqs.CPUs = append(irqs.CPUs, cpu)
+			}
+			return nil
+		}
+		if len(fields) < 2 || !strings.HasSuffix(fields[0], ":") {
+			return fmt.Errorf("unexpected line in %s: '%s'", path, line)
+		}
+		l := InterruptLine{
+			Name:   strings.TrimSuffix(fields[0], ":"),
+			Counts: make([]uint64, 0, len(irqs.CPUs)),
+		}
+		rest := fields[1:]
+		for len(rest) > 0 && len(l.Counts) < len(irqs.CPUs) {
+			v, err := strconv.ParseUint(rest[0], 10, 64)
+			if err != nil {
+				break
+			}
+			l.Counts = append(l.Counts, v)
+			rest = rest[1:]
+		}
+		if _, err := strconv.Atoi(l.Name); err == nil && len(rest) > 0 {
+			l.Chip, rest = parseChip(rest)
+		}
+		l.Device = strings.Join(rest, " ")
+		irqs.Lines = append(irqs.Lines, l)
+		return nil
+	})
+
+	return irqs, err
+}
+
+// parseChip return chip with its hwirq and trigger, and the rest fields.
+// hwirq and trigger are not printed by old kernel. x86 joins them like
+// "IO-APIC 2-edge", while GIC of arm64 prints "GICv3 27 Level"
+func parseChip(fields []string) (string, []string) {
+	chip, rest := fields[0], fields[1:]
+	if len(rest) == 0 || rest[0][0] < '0' || rest[0][0] > '9' {
+		return chip, rest
+	}
+	hwirq, trigger, joined := strings.Cut(rest[0], "-")
+	if _, err := strconv.ParseUint(hwirq, 10, 64); err != nil {
+		return chip, rest
+	}
+	if joined {
+		if trigger == "" {
+			return chip, rest
+		}
+		return chip + " " + rest[0], rest[1:]
+	}
+	chip, rest = chip+" "+rest[0], rest[1:]
+	if len(rest) > 0 && (rest[0] == "Level" || rest[0] == "Edge") {
+		chip, rest = chip+" "+rest[0], rest[1:]
+	}
+	return chip, rest
+}
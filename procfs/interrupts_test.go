@@ -0,0 +1,78 @@
+package procfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseInterrupts(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want Interrupts
+	}{
+		{
+			name: "x86",
+			data: `           CPU0       CPU1
+  0:         35          0   IO-APIC   2-edge      timer
+  9:          0          4   IO-APIC   9-fasteoi   acpi
+ 24:        100        200   PCI-MSI 327680-edge      xhci_hcd
+ 25:          1          2   IO-APIC-edge      rtc0
+NMI:          3          4   Non-maskable interrupts
+ERR:          0
+`,
+			want: Interrupts{
+				CPUs: []int{0, 1},
+				Lines: []InterruptLine{
+					{Name: "0", Chip: "IO-APIC 2-edge", Device: "timer", Counts: []uint64{35, 0}},
+					{Name: "9", Chip: "IO-APIC 9-fasteoi", Device: "acpi", Counts: []uint64{0, 4}},
+					{Name: "24", Chip: "PCI-MSI 327680-edge", Device: "xhci_hcd", Counts: []uint64{100, 200}},
+					{Name: "25", Chip: "IO-APIC-edge", Device: "rtc0", Counts: []uint64{1, 2}},
+					{Name: "NMI", Device: "Non-maskable interrupts", Counts: []uint64{3, 4}},
+					{Name: "ERR", Counts: []uint64{0}},
+				},
+			},
+		},
+		{
+			name: "arm64",
+			data: `           CPU0       CPU1       CPU2       CPU3
+ 11:       1234       5678       9012       3456     GICv3  27 Level     arch_timer
+ 14:          0          0          0          0     GICv3  79 Edge      uart-pl011
+ 48:         10          0          0          0   ITS-MSI 16384 Edge      virtio0-config
+ 50:          0          0          0          0     GICv3  23 Level
+IPI0:        20         30         40         50       Rescheduling interrupts
+Err:          0
+`,
+			want: Interrupts{
+				CPUs: []int{0, 1, 2, 3},
+				Lines: []InterruptLine{
+					{Name: "11", Chip: "GICv3 27 Level", Device: "arch_timer", Counts: []uint64{1234, 5678, 9012, 3456}},
+					{Name: "14", Chip: "GICv3 79 Edge", Device: "uart-pl011", Counts: []uint64{0, 0, 0, 0}},
+					{Name: "48", Chip: "ITS-MSI 16384 Edge", Device: "virtio0-config", Counts: []uint64{10, 0, 0, 0}},
+					{Name: "50", Chip: "GICv3 23 Level", Counts: []uint64{0, 0, 0, 0}},
+					{Name: "IPI0", Device: "Rescheduling interrupts", Counts: []uint64{20, 30, 40, 50}},
+					{Name: "Err", Counts: []uint64{0}},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "interrupts"), []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := NewFS(dir).Interrupts()
+			if err != nil {
+				t.Fatalf("parse interrupts: %s", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package procfs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Mount is one line of /proc/self/mountinfo
+type Mount struct {
+	MountID    int
+	Major      uint32
+	Minor      uint32
+	Root       string // root of mount within filesystem, not / for bind mount
+	MountPoint string
+	FSType     string
+	Source     string // e.g /dev/sda1, /dev/mapper/vg-root or tmpfs
+}
+
+// MountInfo reads mounts of current mount namespace from self/mountinfo
+func (fs FS) MountInfo() ([]Mount, error) {
+
+	mounts := []Mount{}
+
+	path := fs.path("self/mountinfo")
+
+	err := fs.processFile(path, func(i int, line string) error {
+		// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw
+		left, right, ok := strings.Cut(line, " - ")
+		if !ok {
+			return fmt.Errorf("unexpected line in %s: '%s'", path, line)
+		}
+		fields := strings.Fields(left)
+		super := strings.Fields(right)
+		if len(fields) < 5 || len(super) < 2 {
+			return fmt.Errorf("unexpected line in %s: '%s'", path, line)
+		}
+		id, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return fmt.Errorf("unexpected mount id in %s: '%s'", path, line)
+		}
+		major, minor, ok := strings.Cut(fields[2], ":")
+		if !ok {
+			return fmt.Errorf("unexpected device in %s: '%s'", path, line)
+		}
+		ma, err := strconv.ParseUint(major, 10, 32)
+		if err != nil {
+			return fmt.Errorf("unexpected device in %s: '%s'", path, line)
+		}
+		mi, err := strconv.ParseUint(minor, 10, 32)
+		if err != nil {
+			return fmt.Errorf("unexpected device in %s: '%s'", path, line)
+		}
+		// line refer to buffer of fs, so strings are copied
+		mounts = append(mounts, Mount{
+			MountID:    id,
+			Major:      uint32(ma),
+			Minor:      uint32(mi),
+			Root:       unescapeMount(fields[3]),
+			MountPoint: unescapeMount(fields[4]),
+			FSType:     strings.Clone(super[0]),
+			Source:     unescapeMount(super[1]),
+		})
+		return nil
+	})
+	return mounts, err
+}
+
+// unescapeMount return copy of s whose space, tab, newline and backslash
+// are unescaped from octal like \040
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return strings.Clone(s)
+	}
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b = append(b, byte(v))
+				i += 3
+				continue
+			}
+		}
+		b = append(b, s[i])
+	}
+	return string(b)
+}
+
+// BlockDevName return name of block device major:minor like sda1, dm-0
+// or md0, which is the same as DeviceName in diskstats. empty if it is
+// not a block device, e.g btrfs or nfs
+func BlockDevName(major, minor uint32) string {
+	link, err := os.Readlink(fmt.Sprintf("%s/dev/block/%d:%d", DefaultSysMountPoint, major, minor))
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(link)
+}
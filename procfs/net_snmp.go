@@ -0,0 +1,88 @@
+package procfs
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// NetSNMP is counters of /proc/net/snmp, /proc/net/netstat and
+// /proc/net/snmp6, key is protocol and counter like Tcp.RetransSegs,
+// TcpExt.ListenOverflows or Ip6.InReceives. negative value like
+// Tcp.MaxConn is skipped
+type NetSNMP map[string]uint64
+
+// snmp6Protocols are prefixes of counters in /proc/net/snmp6
+var snmp6Protocols = []string{"Ip6", "Icmp6", "UdpLite6", "Udp6"}
+
+// NetSNMP reads counters from /proc/net/snmp and /proc/net/netstat, and
+// /proc/net/snmp6 if ipv6 is enabled.
+func (fs FS) NetSNMP() (NetSNMP, error) {
+
+	snmp := NetSNMP{}
+	for _, file := range []string{"net/snmp", "net/netstat"} {
+		if err := fs.parseSNMP(file, snmp); err != nil {
+			return snmp, err
+		}
+	}
+
+	path := fs.path("net/snmp6")
+	err := fs.processFile(path, func(i int, line string) error {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return fmt.Errorf("unexpected line in %s: '%s'", path, line)
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil
+		}
+		for _, p := range snmp6Protocols {
+			if name, ok := strings.CutPrefix(fields[0], p); ok {
+				snmp[p+"."+name] = v
+				return nil
+			}
+		}
+		snmp[strings.Clone(fields[0])] = v
+		return nil
+	})
+	if err != nil && !errors.Is(err, unix.ENOENT) {
+		return snmp, err
+	}
+	return snmp, nil
+}
+
+// parseSNMP parse file which has a line of names followed by a line of
+// values for every protocol
+func (fs FS) parseSNMP(file string, snmp NetSNMP) error {
+
+	var names []string
+
+	path := fs.path(file)
+
+	return fs.processFile(path, func(i int, line string) error {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.HasSuffix(fields[0], ":") {
+			return fmt.Errorf("unexpected line in %s: '%s'", path, line)
+		}
+		// names refer to buffer of fs, which is valid until file is parsed
+		if i%2 == 0 {
+			names = fields
+			return nil
+		}
+		if fields[0] != names[0] || len(fields) != len(names) {
+			return fmt.Errorf("unexpected values in %s: '%s'", path, line)
+		}
+		proto := strings.TrimSuffix(fields[0], ":")
+		for j := 1; j < len(fields); j++ {
+			v, err := strconv.ParseUint(fields[j], 10, 64)
+			if err != nil {
+				continue
+			}
+			snmp[proto+"."+names[j]] = v
+		}
+		return nil
+	})
+}
@@ -0,0 +1,49 @@
+package procfs
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/xixiliguo/etop/cgroupfs"
+	"golang.org/x/sys/unix"
+)
+
+// Pressure is system-wide pressure stall information in /proc/pressure.
+// values of missing file or line are left as unknown, see cgroupfs.NewPSIStats
+type Pressure struct {
+	CPU    cgroupfs.PSIStats
+	Memory cgroupfs.PSIStats
+	IO     cgroupfs.PSIStats
+	IRQ    cgroupfs.PSIStats // only exist with CONFIG_IRQ_TIME_ACCOUNTING
+}
+
+func (fs FS) Pressure() (Pressure, error) {
+
+	p := Pressure{}
+	for _, r := range []struct {
+		file string
+		psi  *cgroupfs.PSIStats
+	}{
+		{"cpu", &p.CPU},
+		{"memory", &p.Memory},
+		{"io", &p.IO},
+		{"irq", &p.IRQ},
+	} {
+		*r.psi = cgroupfs.NewPSIStats()
+		path := fs.path("pressure/" + r.file)
+		err := fs.processFile(path, func(i int, line string) error {
+			if err := cgroupfs.ParsePSILine(line, r.psi); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			return nil
+		})
+		if err != nil {
+			// irq is optional, but the others exist once psi is enabled
+			if r.file == "irq" && errors.Is(err, unix.ENOENT) {
+				continue
+			}
+			return p, err
+		}
+	}
+	return p, nil
+}
@@ -1,19 +1,24 @@
 package procfs
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"syscall"
 
+	"github.com/xixiliguo/etop/internal/fileutil"
 	"github.com/xixiliguo/etop/internal/stringutil"
 )
 
 //go:generate go tool stringer -linecomment -output=proc_string.go -type=ProcState,ProcPolicy
 
-// Proc provides information about a running process.
+// Proc provides information about a running process, or a thread
+// if it is from EachThread.
 type Proc struct {
-	PID int
-	fs  *FS
+	PID  int
+	fs   *FS
+	tgid int // process which thread belongs to, 0 for process
 }
 
 func (p Proc) path(file string) string {
@@ -21,6 +26,11 @@ func (p Proc) path(file string) string {
 	p.fs.bufName = append(p.fs.bufName, p.fs.mountPoint...)
 	p.fs.bufName = append(p.fs.bufName, "/"...)
 
+	if p.tgid != 0 {
+		p.fs.bufName = strconv.AppendInt(p.fs.bufName, int64(p.tgid), 10)
+		p.fs.bufName = append(p.fs.bufName, "/task/"...)
+	}
+
 	p.fs.bufName = strconv.AppendInt(p.fs.bufName, int64(p.PID), 10)
 
 	p.fs.bufName = append(p.fs.bufName, "/"...)
@@ -388,3 +398,163 @@ func (p Proc) CmdLine() (string, error) {
 
 	return string(b), err
 }
+
+// EachThread call fn with every thread of the process, PID of thread is
+// tid and its files are read from /proc/<pid>/task/<tid>
+func (p Proc) EachThread(fn func(thread Proc) error) error {
+
+	dir := p.fs.mountPoint + "/" + strconv.Itoa(p.PID) + "/task"
+	return fileutil.SubDirWalk(dir, func(name string) error {
+
+		tid, err := strconv.ParseInt(name, 10, 64)
+		if err != nil {
+			return nil
+		}
+		err = fn(Proc{PID: int(tid), fs: p.fs, tgid: p.PID})
+		if err != nil {
+			if _, ok := errors.AsType[syscall.Errno](err); ok {
+				return nil
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// ProcStatus provides memory, owner and context switch information of the
+// process, read from /proc/[pid]/status. memory is in kB.
+type ProcStatus struct {
+	// Real and effective UID of the process.
+	UID  uint32
+	EUID uint32
+	// Locked memory.
+	VmLck uint64
+	// Size of resident anonymous, file mappings and shared memory.
+	RssAnon  uint64
+	RssFile  uint64
+	RssShmem uint64
+	// Swapped-out anonymous memory.
+	VmSwap uint64
+	// Size of hugetlb memory portions.
+	HugetlbPages uint64
+	// Number of voluntary and involuntary context switches.
+	VoluntaryCtxtSwitches    uint64
+	NonVoluntaryCtxtSwitches uint64
+}
+
+// Status returns the current status information of the process.
+func (p Proc) Status() (ProcStatus, error) {
+
+	path := p.path("status")
+
+	s := ProcStatus{}
+
+	err := p.fs.processFile(path, func(i int, line string) error {
+		var fields [3]string
+		nFields := stringutil.FieldsN(line, fields[:])
+		if nFields < 2 {
+			return nil
+		}
+		var (
+			err error
+			val uint64
+		)
+		switch fields[0] {
+		case "Uid:":
+			if nFields < 3 {
+				return fmt.Errorf("pid %d: unexpected line in status: '%s'", p.PID, line)
+			}
+			if val, err = strconv.ParseUint(fields[1], 10, 32); err != nil {
+				return err
+			}
+			s.UID = uint32(val)
+			if val, err = strconv.ParseUint(fields[2], 10, 32); err != nil {
+				return err
+			}
+			s.EUID = uint32(val)
+		case "VmLck:":
+			s.VmLck, err = strconv.ParseUint(fields[1], 10, 64)
+		case "RssAnon:":
+			s.RssAnon, err = strconv.ParseUint(fields[1], 10, 64)
+		case "RssFile:":
+			s.RssFile, err = strconv.ParseUint(fields[1], 10, 64)
+		case "RssShmem:":
+			s.RssShmem, err = strconv.ParseUint(fields[1], 10, 64)
+		case "VmSwap:":
+			s.VmSwap, err = strconv.ParseUint(fields[1], 10, 64)
+		case "HugetlbPages:":
+			s.HugetlbPages, err = strconv.ParseUint(fields[1], 10, 64)
+		case "voluntary_ctxt_switches:":
+			s.VoluntaryCtxtSwitches, err = strconv.ParseUint(fields[1], 10, 64)
+		case "nonvoluntary_ctxt_switches:":
+			s.NonVoluntaryCtxtSwitches, err = strconv.ParseUint(fields[1], 10, 64)
+		}
+		return err
+	})
+	return s, err
+}
+
+// ProcSmapsRollup provides proportional memory usage of the process,
+// read from /proc/[pid]/smaps_rollup (since Linux 4.14). memory is in kB.
+type ProcSmapsRollup struct {
+	Rss      uint64
+	Pss      uint64
+	PssDirty uint64
+	PssAnon  uint64
+	PssFile  uint64
+	PssShmem uint64
+	Swap     uint64
+	SwapPss  uint64
+	Locked   uint64
+	// Sum of Shared_Hugetlb and Private_Hugetlb.
+	Hugetlb uint64
+}
+
+// SmapsRollup returns the accumulated smaps of all mappings of the process.
+func (p Proc) SmapsRollup() (ProcSmapsRollup, error) {
+
+	path := p.path("smaps_rollup")
+
+	s := ProcSmapsRollup{}
+
+	err := p.fs.processFile(path, func(i int, line string) error {
+		if i == 0 {
+			// first line is address range of [rollup]
+			return nil
+		}
+		var fields [3]string
+		nFields := stringutil.FieldsN(line, fields[:])
+		if nFields < 2 {
+			return fmt.Errorf("pid %d: unexpected line in smaps_rollup: '%s'", p.PID, line)
+		}
+		var (
+			err error
+			val uint64
+		)
+		switch fields[0] {
+		case "Rss:":
+			s.Rss, err = strconv.ParseUint(fields[1], 10, 64)
+		case "Pss:":
+			s.Pss, err = strconv.ParseUint(fields[1], 10, 64)
+		case "Pss_Dirty:":
+			s.PssDirty, err = strconv.ParseUint(fields[1], 10, 64)
+		case "Pss_Anon:":
+			s.PssAnon, err = strconv.ParseUint(fields[1], 10, 64)
+		case "Pss_File:":
+			s.PssFile, err = strconv.ParseUint(fields[1], 10, 64)
+		case "Pss_Shmem:":
+			s.PssShmem, err = strconv.ParseUint(fields[1], 10, 64)
+		case "Swap:":
+			s.Swap, err = strconv.ParseUint(fields[1], 10, 64)
+		case "SwapPss:":
+			s.SwapPss, err = strconv.ParseUint(fields[1], 10, 64)
+		case "Locked:":
+			s.Locked, err = strconv.ParseUint(fields[1], 10, 64)
+		case "Shared_Hugetlb:", "Private_Hugetlb:":
+			val, err = strconv.ParseUint(fields[1], 10, 64)
+			s.Hugetlb += val
+		}
+		return err
+	})
+	return s, err
+}
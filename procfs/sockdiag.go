@@ -0,0 +1,249 @@
+package procfs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Socket is one socket dumped by NETLINK_SOCK_DIAG. like ss, RecvQ and
+// SendQ of listening socket are accept queue and backlog
+type Socket struct {
+	Proto  string // tcp, tcp6, udp, udp6 or unix
+	State  string // e.g ESTABLISHED, LISTEN
+	Local  string // ip:port, or path of unix socket
+	Remote string
+	RecvQ  uint32
+	SendQ  uint32
+	UID    uint32 // not known for unix socket
+	Inode  uint32 // 0 for timewait and request socket
+}
+
+// tcpStates are names of tcp state in include/net/tcp_states.h
+var tcpStates = [...]string{"UNKNOWN", "ESTABLISHED", "SYN_SENT", "SYN_RECV",
+	"FIN_WAIT1", "FIN_WAIT2", "TIME_WAIT", "CLOSE", "CLOSE_WAIT", "LAST_ACK",
+	"LISTEN", "CLOSING", "NEW_SYN_RECV"}
+
+func tcpState(state uint8) string {
+	if int(state) < len(tcpStates) {
+		return tcpStates[state]
+	}
+	return tcpStates[0]
+}
+
+const (
+	sizeofInetDiagReqV2 = 56
+	sizeofInetDiagMsg   = 72
+	sizeofUnixDiagReq   = 24
+	sizeofUnixDiagMsg   = 16
+
+	udiagShowName  = 0x01
+	udiagShowRqlen = 0x10
+	unixDiagName   = 0
+	unixDiagRqlen  = 4
+)
+
+// SockDiag dump tcp, udp and unix sockets of current network namespace
+// by NETLINK_SOCK_DIAG, fn is called for every socket
+func SockDiag(fn func(s *Socket)) error {
+
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_SOCK_DIAG)
+	if err != nil {
+		return fmt.Errorf("sock_diag socket: %w", err)
+	}
+	defer unix.Close(fd)
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return fmt.Errorf("sock_diag bind: %w", err)
+	}
+
+	buf := make([]byte, 32*1024)
+	for _, r := range []struct {
+		proto    string
+		family   uint8
+		protocol uint8
+	}{
+		{"tcp", unix.AF_INET, unix.IPPROTO_TCP},
+		{"tcp6", unix.AF_INET6, unix.IPPROTO_TCP},
+		{"udp", unix.AF_INET, unix.IPPROTO_UDP},
+		{"udp6", unix.AF_INET6, unix.IPPROTO_UDP},
+	} {
+		req := make([]byte, sizeofInetDiagReqV2)
+		req[0], req[1] = r.family, r.protocol
+		binary.NativeEndian.PutUint32(req[4:], 0xffffffff) // all states
+		err := sockDiagDump(fd, buf, req, func(b []byte) {
+			if len(b) < sizeofInetDiagMsg {
+				return
+			}
+			s := Socket{
+				Proto: r.proto,
+				RecvQ: binary.NativeEndian.Uint32(b[56:]),
+				SendQ: binary.NativeEndian.Uint32(b[60:]),
+				UID:   binary.NativeEndian.Uint32(b[64:]),
+				Inode: binary.NativeEndian.Uint32(b[68:]),
+			}
+			s.State = tcpState(b[1])
+			if r.protocol == unix.IPPROTO_UDP {
+				// udp use tcp states, but only established means connected
+				if b[1] != 1 {
+					s.State = "UNCONN"
+				}
+			}
+			s.Local = inetAddr(b[0], b[8:24], b[4:6])
+			s.Remote = inetAddr(b[0], b[24:40], b[6:8])
+			fn(&s)
+		})
+		if err != nil {
+			return fmt.Errorf("sock_diag %s: %w", r.proto, err)
+		}
+	}
+
+	req := make([]byte, sizeofUnixDiagReq)
+	req[0] = unix.AF_UNIX
+	binary.NativeEndian.PutUint32(req[4:], 0xffffffff)
+	binary.NativeEndian.PutUint32(req[12:], udiagShowName|udiagShowRqlen)
+	err = sockDiagDump(fd, buf, req, func(b []byte) {
+		if len(b) < sizeofUnixDiagMsg {
+			return
+		}
+		s := Socket{
+			Proto: "unix",
+			State: tcpState(b[2]),
+			Inode: binary.NativeEndian.Uint32(b[4:]),
+		}
+		attrs := b[sizeofUnixDiagMsg:]
+		for len(attrs) >= 4 {
+			l := int(binary.NativeEndian.Uint16(attrs))
+			if l < 4 || l > len(attrs) {
+				break
+			}
+			data := attrs[4:l]
+			switch binary.NativeEndian.Uint16(attrs[2:]) {
+			case unixDiagName:
+				s.Local = unixPath(data)
+			case unixDiagRqlen:
+				if len(data) >= 8 {
+					s.RecvQ = binary.NativeEndian.Uint32(data)
+					s.SendQ = binary.NativeEndian.Uint32(data[4:])
+				}
+			}
+			attrs = attrs[min((l+3)&^3, len(attrs)):]
+		}
+		fn(&s)
+	})
+	if err != nil {
+		return fmt.Errorf("sock_diag unix: %w", err)
+	}
+	return nil
+}
+
+// sockDiagDump send request of SOCK_DIAG_BY_FAMILY, and call fn with
+// payload of every message until dump is done
+func sockDiagDump(fd int, buf []byte, req []byte, fn func(b []byte)) error {
+
+	b := make([]byte, unix.SizeofNlMsghdr+len(req))
+	binary.NativeEndian.PutUint32(b[0:], uint32(len(b)))
+	binary.NativeEndian.PutUint16(b[4:], unix.SOCK_DIAG_BY_FAMILY)
+	binary.NativeEndian.PutUint16(b[6:], unix.NLM_F_REQUEST|unix.NLM_F_DUMP)
+	copy(b[unix.SizeofNlMsghdr:], req)
+	if err := unix.Sendto(fd, b, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return err
+	}
+
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err == unix.EINTR {
+			continue
+		} else if err != nil {
+			return err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			switch m.Header.Type {
+			case unix.NLMSG_DONE:
+				return nil
+			case unix.NLMSG_ERROR:
+				if len(m.Data) >= 4 {
+					if errno := -int32(binary.NativeEndian.Uint32(m.Data)); errno != 0 {
+						return syscall.Errno(errno)
+					}
+				}
+				return nil
+			default:
+				fn(m.Data)
+			}
+		}
+	}
+}
+
+func inetAddr(family uint8, addr []byte, port []byte) string {
+	var ip netip.Addr
+	if family == unix.AF_INET {
+		ip = netip.AddrFrom4([4]byte(addr[:4]))
+	} else {
+		ip = netip.AddrFrom16([16]byte(addr[:16]))
+	}
+	return netip.AddrPortFrom(ip, binary.BigEndian.Uint16(port)).String()
+}
+
+// unixPath return path of unix socket, abstract one start with @
+func unixPath(b []byte) string {
+	if len(b) > 0 && b[0] == 0 {
+		return "@" + strings.TrimRight(string(b[1:]), "\x00")
+	}
+	return strings.TrimRight(string(b), "\x00")
+}
+
+// SocketOwners find pid of socket inodes by fd of all processes, walk is
+// stopped once all are found. pid of inode which is not found is left 0
+func (fs *FS) SocketOwners(owners map[uint32]int) error {
+
+	left := len(owners)
+	if left == 0 {
+		return nil
+	}
+	errStop := errors.New("all socket owners are found")
+	err := fs.EachProc(func(proc Proc) error {
+		dir := fmt.Sprintf("%s/%d/fd", fs.mountPoint, proc.PID)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			// process exited or no permission
+			return nil
+		}
+		for _, e := range entries {
+			link, err := os.Readlink(dir + "/" + e.Name())
+			if err != nil {
+				continue
+			}
+			ino, ok := strings.CutPrefix(link, "socket:[")
+			if !ok {
+				continue
+			}
+			inode, err := strconv.ParseUint(strings.TrimSuffix(ino, "]"), 10, 32)
+			if err != nil {
+				continue
+			}
+			if pid, ok := owners[uint32(inode)]; ok && pid == 0 {
+				owners[uint32(inode)] = proc.PID
+				left--
+			}
+		}
+		if left == 0 {
+			return errStop
+		}
+		return nil
+	})
+	if errors.Is(err, errStop) {
+		return nil
+	}
+	return err
+}
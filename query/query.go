@@ -0,0 +1,195 @@
+// Package query read data recorded by etop record, and compute the same
+// metrics as etop report and etop dump.
+//
+//	db, err := query.Open("/var/log/etop")
+//	if err != nil {
+//		return err
+//	}
+//	defer db.Close()
+//	for snap, err := range db.Range(begin, end) {
+//		if err != nil {
+//			return err
+//		}
+//		for pid, p := range snap.Module("process") {
+//			fmt.Println(snap.Time(), pid, p.(*model.Process).User)
+//		}
+//	}
+package query
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/xixiliguo/etop/model"
+	"github.com/xixiliguo/etop/store"
+)
+
+// ErrNoData is returned if there is no sample at requested time
+var ErrNoData = errors.New("no data")
+
+// DB is recorded data of one host. it is not safe for concurrent use,
+// since samples are read by moving cursor of store
+type DB struct {
+	store store.Store
+	log   *slog.Logger
+	sm    *model.Model
+}
+
+type Option func(*DB)
+
+// WithLogger set logger of store and model, log is discarded by default
+func WithLogger(log *slog.Logger) Option {
+	return func(db *DB) {
+		db.log = log
+	}
+}
+
+// Open open data at path, which is --path of etop record
+func Open(path string, opts ...Option) (*DB, error) {
+	db := newDB(opts...)
+	local, err := store.NewLocalStore(store.WithPathAndLogger(path, db.log))
+	if err != nil {
+		if local != nil {
+			local.Close()
+		}
+		return nil, err
+	}
+	return db.init(local)
+}
+
+// OpenRemote open data served by etop serve (or etop record --listen)
+// at host:port
+func OpenRemote(host string, opts ...Option) (*DB, error) {
+	db := newDB(opts...)
+	remote, err := store.NewRemoteStore(host, db.log)
+	if err != nil {
+		return nil, err
+	}
+	return db.init(remote)
+}
+
+// New create DB over any store, e.g store which read samples from other
+// place. store is closed by DB.Close if it implement io.Closer
+func New(s store.Store, opts ...Option) (*DB, error) {
+	return newDB(opts...).init(s)
+}
+
+func newDB(opts ...Option) *DB {
+	db := &DB{
+		log: slog.New(slog.DiscardHandler),
+	}
+	for _, opt := range opts {
+		opt(db)
+	}
+	return db
+}
+
+func (db *DB) init(s store.Store) (*DB, error) {
+	sm, err := model.NewSysModel(s, db.log)
+	if err != nil {
+		return nil, err
+	}
+	db.store = s
+	db.sm = sm
+	return db, nil
+}
+
+func (db *DB) Close() error {
+	if c, ok := db.store.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Snapshot is metrics computed from one sample and its previous sample.
+// it is reused by Range and At, so it is only valid until next sample is
+// read from the same DB
+type Snapshot struct {
+	*model.Model
+}
+
+// Time return time when sample was collected
+func (s *Snapshot) Time() time.Time {
+	return time.Unix(s.Curr.TimeStamp, 0)
+}
+
+// Interval return duration between sample and its previous sample, which
+// rates are computed over
+func (s *Snapshot) Interval() time.Duration {
+	return time.Duration(s.Curr.TimeStamp-s.Prev.TimeStamp) * time.Second
+}
+
+// Module yield objects of module (see model.Modules), key is name of
+// object (e.g disk name, "pid(comm)") and empty for single object module
+// like system. nothing is yielded for unknown module
+func (s *Snapshot) Module(module string) iter.Seq2[string, model.Render] {
+	return s.IterateModule(module)
+}
+
+// Events return events happened between previous sample and this one
+func (s *Snapshot) Events() ([]model.Event, error) {
+	return s.CurrEvents()
+}
+
+// Range yield snapshots of samples whose time is in [begin, end]. error is
+// yielded once and iteration stop if sample can not be read
+func (db *DB) Range(begin, end time.Time) iter.Seq2[*Snapshot, error] {
+	return func(yield func(*Snapshot, error) bool) {
+		if err := db.sm.CollectSampleByTime(begin.Unix()); err != nil {
+			if !errors.Is(err, store.ErrOutOfRange) {
+				yield(nil, err)
+			}
+			return
+		}
+		snap := &Snapshot{db.sm}
+		for end.Unix() >= db.sm.Curr.TimeStamp {
+			// jump may stop at nearest sample before begin
+			if db.sm.Curr.TimeStamp >= begin.Unix() && !yield(snap, nil) {
+				return
+			}
+			if err := db.sm.CollectNext(); err != nil {
+				if !errors.Is(err, store.ErrOutOfRange) {
+					yield(nil, err)
+				}
+				return
+			}
+		}
+	}
+}
+
+// At return snapshot of the first sample at or after t, or the last
+// sample if t is after all samples
+func (db *DB) At(t time.Time) (*Snapshot, error) {
+	if err := db.sm.CollectSampleByTime(t.Unix()); err != nil {
+		if errors.Is(err, store.ErrOutOfRange) {
+			return nil, fmt.Errorf("%w at %s", ErrNoData, t.Format(time.RFC3339))
+		}
+		return nil, err
+	}
+	return &Snapshot{db.sm}, nil
+}
+
+// Bounds return time of the first and last sample. the first sample has
+// no previous sample, so Range and At start from the second one
+func (db *DB) Bounds() (time.Time, time.Time, error) {
+	s := store.NewSample()
+	if err := db.store.JumpSampleByTimeStamp(math.MaxInt64, &s); err != nil {
+		if errors.Is(err, store.ErrOutOfRange) {
+			err = ErrNoData
+		}
+		return time.Time{}, time.Time{}, err
+	}
+	last := s.TimeStamp
+	if err := db.store.JumpSampleByTimeStamp(0, &s); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if err := db.store.NextSample(-1, &s); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return time.Unix(s.TimeStamp, 0), time.Unix(last, 0), nil
+}
@@ -0,0 +1,138 @@
+package query
+
+import (
+	"errors"
+	"log/slog"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/xixiliguo/etop/model"
+	"github.com/xixiliguo/etop/procfs"
+	"github.com/xixiliguo/etop/store"
+)
+
+// newSample return sample at ts, process 1 use one tick per second
+func newSample(ts int64) store.Sample {
+	s := store.NewSample()
+	s.TimeStamp = ts
+	s.BootTime = 1
+	s.PageSize = 4096
+	s.LoadAvg = procfs.LoadAvg{Load1: float64(ts - 1000)}
+	s.ProcSamples[1] = store.ProcSample{
+		ProcStat: procfs.ProcStat{PID: 1, Comm: "init", UTime: uint64(ts - 1000)},
+	}
+	return s
+}
+
+// sliceStore is store.Store over samples in memory
+type sliceStore struct {
+	samples []store.Sample
+	cur     int
+}
+
+func (s *sliceStore) NextSample(step int, sample *store.Sample) error {
+	target := s.cur + step
+	if target < 0 || target >= len(s.samples) {
+		return store.ErrOutOfRange
+	}
+	*sample = s.samples[target]
+	s.cur = target
+	return nil
+}
+
+func (s *sliceStore) JumpSampleByTimeStamp(timestamp int64, sample *store.Sample) error {
+	if len(s.samples) < 2 {
+		return store.ErrOutOfRange
+	}
+	target := sort.Search(len(s.samples), func(i int) bool {
+		return s.samples[i].TimeStamp >= timestamp
+	})
+	target = max(min(target, len(s.samples)-1), 1)
+	s.cur = target - 1
+	return s.NextSample(1, sample)
+}
+
+func TestDB(t *testing.T) {
+	dir := t.TempDir()
+	local, err := store.NewLocalStore(
+		store.WithPathAndLogger(dir, slog.Default()),
+		store.WithWriteOnly(store.NoCompress, 0),
+	)
+	if err != nil {
+		t.Fatalf("new store: %s", err)
+	}
+	mem := &sliceStore{cur: -1}
+	for i := 0; i < 7; i++ {
+		s := newSample(1000 + int64(i)*5)
+		if _, err := local.WriteSample(&s); err != nil {
+			t.Fatalf("write sample: %s", err)
+		}
+		mem.samples = append(mem.samples, newSample(1000+int64(i)*5))
+	}
+	local.Close()
+
+	opened, err := Open(dir, WithLogger(slog.Default()))
+	if err != nil {
+		t.Fatalf("open: %s", err)
+	}
+	defer opened.Close()
+	fromMem, err := New(mem)
+	if err != nil {
+		t.Fatalf("new: %s", err)
+	}
+
+	for name, db := range map[string]*DB{"local": opened, "memory": fromMem} {
+		first, last, err := db.Bounds()
+		if err != nil || first.Unix() != 1000 || last.Unix() != 1030 {
+			t.Fatalf("%s: bounds %s %s %v", name, first, last, err)
+		}
+
+		times := []int64{}
+		for snap, err := range db.Range(time.Unix(1007, 0), time.Unix(1025, 0)) {
+			if err != nil {
+				t.Fatalf("%s: range: %s", name, err)
+			}
+			if snap.Interval() != 5*time.Second || snap.Sys.Load1 != float64(snap.Time().Unix()-1000) {
+				t.Errorf("%s: got interval %s load %f at %s", name, snap.Interval(), snap.Sys.Load1, snap.Time())
+			}
+			for key, m := range snap.Module("process") {
+				// one tick per second is 1% of cpu
+				if p, ok := m.(*model.Process); !ok || key != "1(init)" || p.User != 1 {
+					t.Errorf("%s: got process %s %+v", name, key, m)
+				}
+			}
+			times = append(times, snap.Time().Unix())
+		}
+		if len(times) != 4 || times[0] != 1010 || times[3] != 1025 {
+			t.Errorf("%s: got samples at %v", name, times)
+		}
+
+		// stop early
+		for range db.Range(time.Unix(0, 0), time.Unix(2000, 0)) {
+			break
+		}
+
+		snap, err := db.At(time.Unix(1012, 0))
+		if err != nil || snap.Time().Unix() != 1015 {
+			t.Errorf("%s: at 1012: %v %v", name, snap, err)
+		}
+		if snap, err = db.At(time.Unix(5000, 0)); err != nil || snap.Time().Unix() != 1030 {
+			t.Errorf("%s: at 5000: %v %v", name, snap, err)
+		}
+	}
+
+	empty, err := New(&sliceStore{cur: -1})
+	if err != nil {
+		t.Fatalf("new: %s", err)
+	}
+	if _, _, err := empty.Bounds(); !errors.Is(err, ErrNoData) {
+		t.Errorf("got %v, but want ErrNoData", err)
+	}
+	if _, err := empty.At(time.Unix(1000, 0)); !errors.Is(err, ErrNoData) {
+		t.Errorf("got %v, but want ErrNoData", err)
+	}
+	for _, err := range empty.Range(time.Unix(0, 0), time.Unix(2000, 0)) {
+		t.Errorf("nothing should be yielded, but got %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var compressModes = map[string]uint32{
+	"none":  NoCompress,
+	"zstd":  ZstdCompress,
+	"dict":  ZstdCompressWithDict,
+	"delta": ZstdCompressWithDelta,
+}
+
+// ParseCompressMode parse name of compress mode: none, zstd, dict or delta
+func ParseCompressMode(s string) (uint32, error) {
+	if m, ok := compressModes[s]; ok {
+		return m, nil
+	}
+	names := []string{}
+	for name := range compressModes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return 0, fmt.Errorf("unknown compress mode %s, valid modes: %s", s, strings.Join(names, ","))
+}
+
+// ConvertResult is the result of converting one shard
+type ConvertResult struct {
+	Shard   int64
+	Samples int
+	Before  int64 // bytes of index and data file
+	After   int64
+}
+
+func (r *ConvertResult) String() string {
+	if r.Samples == 0 {
+		return fmt.Sprintf("shard %d: no samples", r.Shard)
+	}
+	return fmt.Sprintf("shard %d: %d samples, %d -> %d bytes, %d -> %d bytes/sample",
+		r.Shard, r.Samples, r.Before, r.After, r.Before/int64(r.Samples), r.After/int64(r.Samples))
+}
+
+// Convert rewrite all shards in path with compress mode and chunk, so that
+// store can be moved between modes. it take the same lock with etop record,
+// so it can not run while recording.
+func Convert(path string, mode, chunk uint32, log *slog.Logger) ([]ConvertResult, error) {
+
+	l := &LocalStore{Path: path}
+	if err := l.lock(); err != nil {
+		return nil, err
+	}
+	defer l.unlock()
+	if err := finishReplace(path, log); err != nil {
+		return nil, err
+	}
+
+	shards, err := listShards(path)
+	if err != nil {
+		return nil, err
+	}
+	results := []ConvertResult{}
+	for _, shard := range shards {
+		r, err := convertShard(path, shard, mode, chunk, log)
+		if err != nil {
+			return results, fmt.Errorf("convert shard %d: %w", shard, err)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+func convertShard(path string, shard int64, mode, chunk uint32, log *slog.Logger) (ConvertResult, error) {
+
+	result := ConvertResult{Shard: shard}
+	var err error
+	if result.Before, err = shardSize(path, shard); err != nil {
+		return result, err
+	}
+
+	r, err := NewLocalStore(WithPathAndLogger(path, log))
+	if err != nil {
+		return result, err
+	}
+	defer r.Close()
+	if r.idxs, _, err = readShardFrames(path, shard); err != nil {
+		return result, err
+	}
+	if len(r.idxs) == 0 {
+		// nothing to convert, events and inventories are never compressed
+		result.After = result.Before
+		return result, nil
+	}
+	if err := r.changeFile(shard, false); err != nil {
+		return result, err
+	}
+
+	tempPath, err := os.MkdirTemp(path, ".convert")
+	if err != nil {
+		return result, err
+	}
+	defer os.RemoveAll(tempPath)
+	w, err := NewLocalStore(
+		WithPathAndLogger(tempPath, log),
+		WithWriteOnly(mode, chunk),
+	)
+	if err != nil {
+		return result, err
+	}
+	defer w.Close()
+
+	for i := range r.idxs {
+		s := NewSample()
+		if err := r.getSample(i, &s); err != nil {
+			return result, err
+		}
+		if _, err := w.WriteSample(&s); err != nil {
+			return result, err
+		}
+		result.Samples++
+	}
+	if err := r.copyRecords(w, shard, shard+ShardTime-1); err != nil {
+		return result, err
+	}
+	if err := w.Close(); err != nil {
+		return result, err
+	}
+	if result.After, err = shardSize(tempPath, shard); err != nil {
+		return result, err
+	}
+	return result, replaceShard(tempPath, path, shard)
+}
+
+// shardSize return bytes of index and data file of shard
+func shardSize(path string, shard int64) (int64, error) {
+	size := int64(0)
+	for _, prefix := range []string{"data", "index"} {
+		info, err := os.Stat(filepath.Join(path, fmt.Sprintf("%s_%011d", prefix, shard)))
+		if err != nil {
+			return 0, err
+		}
+		size += info.Size()
+	}
+	return size, nil
+}
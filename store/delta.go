@@ -0,0 +1,458 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// deltaSample is how Sample is encoded in ZstdCompressWithDelta mode.
+// processes, threads and cgroups are stored as tables, every column is one
+// scalar field and value is the difference with the row of same key in
+// previous frame, so that unchanged fields are encoded as 0. strings are
+// interned and only strings first seen in the chain are stored. the first
+// frame of every chunk has no previous frame and store full value.
+type deltaSample struct {
+	_            struct{} `cbor:",toarray"`
+	TimeStamp    int64
+	SystemSample SystemSample
+	Missing      []string
+	Strings      []string // strings interned in this frame
+	Procs        deltaTable
+	Threads      deltaTable // key is pid<<32 | tid
+	Cgroups      deltaTable // key is id of FullPath, order by pre-order walk
+}
+
+type deltaTable struct {
+	_       struct{}   `cbor:",toarray"`
+	Keys    []int64    // difference with key of previous row
+	Columns [][]int64  // Columns[field][row] for scalar fields
+	Others  [][][]byte // Others[field][row] cbor of other fields, empty if unchanged
+}
+
+// rowField is a leaf field of row struct, index is for FieldByIndex
+type rowField struct {
+	index []int
+	kind  reflect.Kind
+}
+
+// rowCodec convert between struct and its scalar values. bool, number
+// and interned string are scalar, other fields like slice are encoded
+// by cbor as a whole
+type rowCodec struct {
+	scalars []rowField
+	others  []rowField
+}
+
+func newRowCodec(t reflect.Type, skip ...string) *rowCodec {
+	c := &rowCodec{}
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() || f.Tag.Get("cbor") == "-" {
+				continue
+			}
+			if len(index) == 0 && slices.Contains(skip, f.Name) {
+				continue
+			}
+			idx := append(slices.Clone(index), i)
+			switch f.Type.Kind() {
+			case reflect.Struct:
+				walk(f.Type, idx)
+			case reflect.Bool, reflect.String,
+				reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+				reflect.Float32, reflect.Float64:
+				c.scalars = append(c.scalars, rowField{idx, f.Type.Kind()})
+			default:
+				c.others = append(c.others, rowField{idx, f.Type.Kind()})
+			}
+		}
+	}
+	walk(t, nil)
+	return c
+}
+
+var (
+	procCodec   = newRowCodec(reflect.TypeOf(ProcSample{}), "Threads")
+	threadCodec = newRowCodec(reflect.TypeOf(ThreadSample{}))
+	cgroupCodec = newRowCodec(reflect.TypeOf(CgroupSample{}), "Child")
+)
+
+// values return scalar values of v, intern is called for strings
+func (c *rowCodec) values(v reflect.Value, intern func(string) uint64) []uint64 {
+	row := make([]uint64, len(c.scalars))
+	for i, f := range c.scalars {
+		fv := v.FieldByIndex(f.index)
+		switch f.kind {
+		case reflect.Bool:
+			if fv.Bool() {
+				row[i] = 1
+			}
+		case reflect.String:
+			row[i] = intern(fv.String())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			row[i] = uint64(fv.Int())
+		case reflect.Float32, reflect.Float64:
+			row[i] = math.Float64bits(fv.Float())
+		default:
+			row[i] = fv.Uint()
+		}
+	}
+	return row
+}
+
+// setValues set scalar fields of v, which should be addressable
+func (c *rowCodec) setValues(v reflect.Value, row []uint64, strs []string) error {
+	for i, f := range c.scalars {
+		fv := v.FieldByIndex(f.index)
+		switch f.kind {
+		case reflect.Bool:
+			fv.SetBool(row[i] != 0)
+		case reflect.String:
+			if row[i] >= uint64(len(strs)) {
+				return fmt.Errorf("%w: string %d is not interned", ErrDataCorrupt, row[i])
+			}
+			fv.SetString(strs[row[i]])
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			fv.SetInt(int64(row[i]))
+		case reflect.Float32, reflect.Float64:
+			fv.SetFloat(math.Float64frombits(row[i]))
+		default:
+			fv.SetUint(row[i])
+		}
+	}
+	return nil
+}
+
+// deltaRows is rows of one table in previous frame
+type deltaRows struct {
+	keys   []int64
+	values map[int64][]uint64
+	others map[int64][][]byte
+}
+
+func newDeltaRows() deltaRows {
+	return deltaRows{
+		values: make(map[int64][]uint64),
+		others: make(map[int64][][]byte),
+	}
+}
+
+// deltaEncoder keep state of current chain for writer
+type deltaEncoder struct {
+	strings map[string]uint64
+	frames  uint32 // number of frames in current chain
+	procs   deltaRows
+	threads deltaRows
+	cgroups deltaRows
+}
+
+func newDeltaEncoder() *deltaEncoder {
+	e := &deltaEncoder{}
+	e.reset()
+	return e
+}
+
+// reset start new chain, next frame is encoded with full value
+func (e *deltaEncoder) reset() {
+	e.strings = make(map[string]uint64)
+	e.frames = 0
+	e.procs = newDeltaRows()
+	e.threads = newDeltaRows()
+	e.cgroups = newDeltaRows()
+}
+
+// deltaRow is one row of table to be encoded
+type deltaRow struct {
+	key   int64
+	value reflect.Value
+}
+
+// intern return id of s, and add s to ds if it is first seen in chain
+func (e *deltaEncoder) intern(s string, ds *deltaSample) uint64 {
+	id, ok := e.strings[s]
+	if !ok {
+		id = uint64(len(e.strings))
+		e.strings[s] = id
+		ds.Strings = append(ds.Strings, s)
+	}
+	return id
+}
+
+func (e *deltaEncoder) encodeTable(c *rowCodec, rows []deltaRow, prev *deltaRows, ds *deltaSample) (deltaTable, error) {
+	intern := func(s string) uint64 {
+		return e.intern(s, ds)
+	}
+	t := deltaTable{
+		Keys:    make([]int64, len(rows)),
+		Columns: make([][]int64, len(c.scalars)),
+		Others:  make([][][]byte, len(c.others)),
+	}
+	for i := range t.Columns {
+		t.Columns[i] = make([]int64, len(rows))
+	}
+	for i := range t.Others {
+		t.Others[i] = make([][]byte, len(rows))
+	}
+	curr := newDeltaRows()
+	lastKey := int64(0)
+	for r, rw := range rows {
+		t.Keys[r] = rw.key - lastKey
+		lastKey = rw.key
+		values := c.values(rw.value, intern)
+		old := prev.values[rw.key]
+		for i, v := range values {
+			if old != nil {
+				v -= old[i]
+			}
+			t.Columns[i][r] = int64(v)
+		}
+		others := make([][]byte, len(c.others))
+		oldOthers := prev.others[rw.key]
+		for i, f := range c.others {
+			b, err := cbor.Marshal(rw.value.FieldByIndex(f.index).Interface())
+			if err != nil {
+				return t, err
+			}
+			others[i] = b
+			if oldOthers == nil || !bytes.Equal(oldOthers[i], b) {
+				t.Others[i][r] = b
+			}
+		}
+		curr.keys = append(curr.keys, rw.key)
+		curr.values[rw.key] = values
+		curr.others[rw.key] = others
+	}
+	*prev = curr
+	return t, nil
+}
+
+// encode write s as delta against previous frame to buf
+func (e *deltaEncoder) encode(s *Sample, buf *bytes.Buffer) error {
+	ds := deltaSample{
+		TimeStamp:    s.TimeStamp,
+		SystemSample: s.SystemSample,
+		Missing:      s.Missing,
+	}
+
+	pids := make([]int, 0, len(s.ProcSamples))
+	for pid := range s.ProcSamples {
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+	procs := make([]deltaRow, 0, len(pids))
+	threads := []deltaRow{}
+	for _, pid := range pids {
+		p := s.ProcSamples[pid]
+		procs = append(procs, deltaRow{int64(pid), reflect.ValueOf(p)})
+		tids := make([]int, 0, len(p.Threads))
+		for tid := range p.Threads {
+			tids = append(tids, tid)
+		}
+		sort.Ints(tids)
+		for _, tid := range tids {
+			threads = append(threads, deltaRow{int64(pid)<<32 | int64(tid), reflect.ValueOf(p.Threads[tid])})
+		}
+	}
+
+	cgroups := []deltaRow{}
+	var walk func(cg *CgroupSample)
+	walk = func(cg *CgroupSample) {
+		cgroups = append(cgroups, deltaRow{value: reflect.ValueOf(*cg)})
+		names := make([]string, 0, len(cg.Child))
+		for name := range cg.Child {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			child := cg.Child[name]
+			walk(&child)
+		}
+	}
+	if !reflect.ValueOf(s.CgroupSample).IsZero() {
+		walk(&s.CgroupSample)
+	}
+	for i := range cgroups {
+		// FullPath is interned anyway, and its id is stable in chain
+		cgroups[i].key = int64(e.intern(cgroups[i].value.FieldByName("FullPath").String(), &ds))
+	}
+
+	var err error
+	if ds.Procs, err = e.encodeTable(procCodec, procs, &e.procs, &ds); err != nil {
+		return err
+	}
+	if ds.Threads, err = e.encodeTable(threadCodec, threads, &e.threads, &ds); err != nil {
+		return err
+	}
+	if ds.Cgroups, err = e.encodeTable(cgroupCodec, cgroups, &e.cgroups, &ds); err != nil {
+		return err
+	}
+	e.frames++
+	return cbor.MarshalToBuffer(&ds, buf)
+}
+
+// deltaDecoder keep state of current chain for reader
+type deltaDecoder struct {
+	strings []string
+	procs   deltaRows
+	threads deltaRows
+	cgroups deltaRows
+	shard   int64 // shard and position in idxs of last decoded frame
+	pos     int
+}
+
+func newDeltaDecoder() *deltaDecoder {
+	d := &deltaDecoder{}
+	d.reset()
+	return d
+}
+
+func (d *deltaDecoder) reset() {
+	d.strings = nil
+	d.procs = newDeltaRows()
+	d.threads = newDeltaRows()
+	d.cgroups = newDeltaRows()
+	d.shard = -1
+	d.pos = -1
+}
+
+func (d *deltaDecoder) decodeTable(c *rowCodec, t *deltaTable, prev *deltaRows) error {
+	if len(t.Columns) != len(c.scalars) || len(t.Others) != len(c.others) {
+		return fmt.Errorf("%w: table has %d columns, but %d is expected",
+			ErrDataCorrupt, len(t.Columns)+len(t.Others), len(c.scalars)+len(c.others))
+	}
+	for _, col := range t.Columns {
+		if len(col) != len(t.Keys) {
+			return fmt.Errorf("%w: column has %d rows, but %d is expected", ErrDataCorrupt, len(col), len(t.Keys))
+		}
+	}
+	for _, col := range t.Others {
+		if len(col) != len(t.Keys) {
+			return fmt.Errorf("%w: column has %d rows, but %d is expected", ErrDataCorrupt, len(col), len(t.Keys))
+		}
+	}
+	curr := newDeltaRows()
+	key := int64(0)
+	for r := range t.Keys {
+		key += t.Keys[r]
+		values := make([]uint64, len(c.scalars))
+		old := prev.values[key]
+		for i := range values {
+			values[i] = uint64(t.Columns[i][r])
+			if old != nil {
+				values[i] += old[i]
+			}
+		}
+		others := make([][]byte, len(c.others))
+		oldOthers := prev.others[key]
+		for i := range others {
+			others[i] = t.Others[i][r]
+			if len(others[i]) == 0 {
+				if oldOthers == nil {
+					return fmt.Errorf("%w: unchanged field of new row %d", ErrDataCorrupt, key)
+				}
+				others[i] = oldOthers[i]
+			}
+		}
+		curr.keys = append(curr.keys, key)
+		curr.values[key] = values
+		curr.others[key] = others
+	}
+	*prev = curr
+	return nil
+}
+
+// apply decode ds against state, and fill sample with result
+func (d *deltaDecoder) apply(ds *deltaSample, sample *Sample) error {
+	d.strings = append(d.strings, ds.Strings...)
+	if err := d.decodeTable(procCodec, &ds.Procs, &d.procs); err != nil {
+		return err
+	}
+	if err := d.decodeTable(threadCodec, &ds.Threads, &d.threads); err != nil {
+		return err
+	}
+	if err := d.decodeTable(cgroupCodec, &ds.Cgroups, &d.cgroups); err != nil {
+		return err
+	}
+	if sample == nil {
+		return nil
+	}
+
+	sample.Reset()
+	sample.TimeStamp = ds.TimeStamp
+	sample.SystemSample = ds.SystemSample
+	sample.Missing = ds.Missing
+	if sample.ProcSamples == nil {
+		sample.ProcSamples = make(PidMap, len(d.procs.keys))
+	}
+
+	set := func(c *rowCodec, rows *deltaRows, key int64, v reflect.Value) error {
+		if err := c.setValues(v, rows.values[key], d.strings); err != nil {
+			return err
+		}
+		for i, f := range c.others {
+			if err := cbor.Unmarshal(rows.others[key][i], v.FieldByIndex(f.index).Addr().Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, key := range d.procs.keys {
+		p := ProcSample{}
+		if err := set(procCodec, &d.procs, key, reflect.ValueOf(&p).Elem()); err != nil {
+			return err
+		}
+		sample.ProcSamples[int(key)] = p
+	}
+	for _, key := range d.threads.keys {
+		pid, tid := int(key>>32), int(key&math.MaxUint32)
+		t := ThreadSample{}
+		if err := set(threadCodec, &d.threads, key, reflect.ValueOf(&t).Elem()); err != nil {
+			return err
+		}
+		p, ok := sample.ProcSamples[pid]
+		if !ok {
+			return fmt.Errorf("%w: process %d of thread %d is missing", ErrDataCorrupt, pid, tid)
+		}
+		if p.Threads == nil {
+			p.Threads = make(TidMap)
+		}
+		p.Threads[tid] = t
+		sample.ProcSamples[pid] = p
+	}
+
+	// Child of decoded cgroup is never nil, same as walkCgroupNode
+	nodes := make([]CgroupSample, len(d.cgroups.keys))
+	for i, key := range d.cgroups.keys {
+		if err := set(cgroupCodec, &d.cgroups, key, reflect.ValueOf(&nodes[i]).Elem()); err != nil {
+			return err
+		}
+	}
+	var build func(i int) (CgroupSample, int)
+	build = func(i int) (CgroupSample, int) {
+		cg := nodes[i]
+		cg.Child = make(map[string]CgroupSample)
+		j := i + 1
+		for j < len(nodes) && nodes[j].Level == cg.Level+1 {
+			var child CgroupSample
+			child, j = build(j)
+			cg.Child[child.Name] = child
+		}
+		return cg, j
+	}
+	sample.CgroupSample = CgroupSample{}
+	if len(nodes) != 0 {
+		var next int
+		if sample.CgroupSample, next = build(0); next != len(nodes) {
+			return fmt.Errorf("%w: cgroup %s is not in tree", ErrDataCorrupt, nodes[next].FullPath)
+		}
+	}
+	return nil
+}
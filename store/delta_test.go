@@ -0,0 +1,247 @@
+package store
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/xixiliguo/etop/cgroupfs"
+	"github.com/xixiliguo/etop/procfs"
+	"github.com/xixiliguo/etop/util"
+)
+
+// testSamples return n samples based on testdata, which change like real
+// samples: counters increase, processes start and exit, threads and
+// cgroups are changed
+func testSamples(t testing.TB, n int) []Sample {
+	src, err := os.ReadFile("testdata/sample.data")
+	if err != nil {
+		t.Fatalf("read testdata: %s", err)
+	}
+	samples := []Sample{}
+	for i := 0; i < n; i++ {
+		s := NewSample()
+		if err := s.Unmarshal(src); err != nil {
+			t.Fatalf("testdata: %s", err)
+		}
+		s.TimeStamp = 1697760000 + int64(i)*5
+		for pid, p := range s.ProcSamples {
+			if pid%3 == 0 {
+				p.UTime += uint64(i * pid % 7)
+				p.STime += uint64(i)
+				p.RChar += uint64(i * 4096)
+				p.MinFlt += uint64(i * 3)
+			}
+			if pid%11 == 0 && i%2 == 1 {
+				p.Comm = "renamed"
+				p.CmdLine = "renamed --flag"
+			}
+			if pid%13 == 0 {
+				p.Threads = TidMap{
+					pid:     {ProcStat: procfs.ProcStat{PID: pid, Comm: p.Comm, UTime: uint64(i)}},
+					pid + 1: {ProcStat: procfs.ProcStat{PID: pid + 1, Comm: "worker", STime: uint64(i * 2)}},
+				}
+			}
+			s.ProcSamples[pid] = p
+		}
+		// process exit and start
+		if i%3 == 2 {
+			for pid := range s.ProcSamples {
+				delete(s.ProcSamples, pid)
+				break
+			}
+		}
+		s.ProcSamples[100000+i] = ProcSample{
+			ProcStat: procfs.ProcStat{PID: 100000 + i, Comm: "short", UTime: 1},
+			Cgroup:   "/system.slice/short.service",
+		}
+
+		child := func(name string, level int) CgroupSample {
+			return CgroupSample{
+				FullPath: "/system.slice/" + name,
+				Name:     name,
+				Level:    level,
+				Child:    map[string]CgroupSample{},
+				CPUStat:  cgroupfs.CPUStat{UsageUsec: uint64(1000 * i)},
+				IOStats:  []cgroupfs.IOStat{{Major: 8, Rbytes: uint64(512 * (i / 2))}},
+			}
+		}
+		sys := child("", 1)
+		sys.FullPath = "/system.slice"
+		sys.Name = "system.slice"
+		sys.Child["a.service"] = child("a.service", 2)
+		if i%2 == 0 {
+			sys.Child["b.service"] = child("b.service", 2)
+		}
+		s.CgroupSample = CgroupSample{
+			FullPath: "/",
+			Child:    map[string]CgroupSample{"system.slice": sys},
+		}
+		s.CgroupSample.CpuPressure.Some.Avg10 = float64(i) / 3
+		if i == n-1 {
+			s.Missing = []string{ModuleCgroup}
+			s.CgroupSample = CgroupSample{}
+		}
+
+		// samples are compared after encoding, same as normal mode
+		b, err := s.Marshal()
+		if err != nil {
+			t.Fatalf("marshal: %s", err)
+		}
+		want := NewSample()
+		if err := want.Unmarshal(b); err != nil {
+			t.Fatalf("unmarshal: %s", err)
+		}
+		samples = append(samples, want)
+	}
+	return samples
+}
+
+func writeTestSamples(t testing.TB, dir string, mode, chunk uint32, samples []Sample) {
+	writeStore, err := NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+		WithWriteOnly(mode, chunk),
+	)
+	if err != nil {
+		t.Fatalf("new writeStore: %s\n", err)
+	}
+	defer writeStore.Close()
+	for i := range samples {
+		if _, err := writeStore.WriteSample(&samples[i]); err != nil {
+			t.Fatalf("write sample: %s\n", err)
+		}
+	}
+}
+
+func checkTestSamples(t *testing.T, dir string, samples []Sample) {
+	t.Helper()
+	readStore, err := NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+	)
+	if err != nil {
+		t.Fatalf("new readStore: %s\n", err)
+	}
+	defer readStore.Close()
+
+	opts := []cmp.Option{
+		cmpopts.IgnoreUnexported(Sample{}, procfs.ProcStat{}),
+	}
+	check := func(i int, err error, got Sample) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("read sample %d: %s\n", i, err)
+		}
+		if !cmp.Equal(samples[i], got, opts...) {
+			t.Fatalf("sample %d should be the same\n%s\n", i, cmp.Diff(samples[i], got, opts...))
+		}
+	}
+	for i := range samples {
+		s := NewSample()
+		check(i, readStore.NextSample(1, &s), s)
+	}
+	for i := len(samples) - 2; i >= 0; i-- {
+		s := NewSample()
+		check(i, readStore.NextSample(-1, &s), s)
+	}
+	// 1st sample is ignored by JumpSampleByTimeStamp
+	for _, i := range []int{5, 2, 7, 6, 1} {
+		i = 1 + i%(len(samples)-1)
+		s := NewSample()
+		check(i, readStore.JumpSampleByTimeStamp(samples[i].TimeStamp, &s), s)
+	}
+}
+
+func TestDeltaSample(t *testing.T) {
+	dir := t.TempDir()
+	samples := testSamples(t, 9)
+	writeTestSamples(t, dir, ZstdCompressWithDelta, 4, samples)
+	checkTestSamples(t, dir, samples)
+
+	results, err := Fsck(dir, false, slog.Default())
+	if err != nil {
+		t.Fatalf("fsck: %s\n", err)
+	}
+	if len(results) != 1 || !results[0].OK() || results[0].Good != len(samples) {
+		t.Fatalf("got %+v, but want %d good frames", results, len(samples))
+	}
+
+	// corrupt 2nd sample, following samples of the chain can not be decoded
+	idxFile := filepath.Join(dir, "index_01697760000")
+	dataFile := filepath.Join(dir, "data_01697760000")
+	b, _ := os.ReadFile(idxFile)
+	idx := Index{}
+	idx.Unmarshal(b[sizeIndex:])
+	data, _ := os.ReadFile(dataFile)
+	for i := idx.Offset; i < idx.Offset+idx.Len; i++ {
+		data[i] = 0xff
+	}
+	os.WriteFile(dataFile, data, 0644)
+
+	results, err = Fsck(dir, true, slog.Default())
+	if err != nil {
+		t.Fatalf("fsck: %s\n", err)
+	}
+	if r := results[0]; r.Good != 6 || len(r.Bad) != 3 || !r.Repaired {
+		t.Fatalf("got %s", r.String())
+	}
+	samples = append(samples[:1], samples[4:]...)
+	checkTestSamples(t, dir, samples)
+}
+
+func TestConvert(t *testing.T) {
+	dir := t.TempDir()
+	samples := testSamples(t, 9)
+	writeTestSamples(t, dir, ZstdCompressWithDict, 4, samples)
+
+	if _, err := ParseCompressMode("lz4"); err == nil {
+		t.Fatalf("lz4 should be invalid")
+	}
+	for _, name := range []string{"delta", "none", "zstd", "dict"} {
+		mode, err := ParseCompressMode(name)
+		if err != nil {
+			t.Fatalf("parse %s: %s", name, err)
+		}
+		results, err := Convert(dir, mode, 4, slog.Default())
+		if err != nil {
+			t.Fatalf("convert to %s: %s", name, err)
+		}
+		if len(results) != 1 || results[0].Samples != len(samples) {
+			t.Fatalf("convert to %s: got %+v", name, results)
+		}
+		t.Logf("convert to %s: %s", name, results[0].String())
+		checkTestSamples(t, dir, samples)
+	}
+}
+
+// BenchmarkWriteSampleSequence measure bytes per sample of samples which
+// change like real ones, unlike BenchmarkWriteSample which write the same
+// sample repeatedly
+func BenchmarkWriteSampleSequence(b *testing.B) {
+	samples := testSamples(b, 64)
+	devNull, _ := os.Open(os.DevNull)
+
+	for _, name := range []string{"none", "zstd", "dict", "delta"} {
+		b.Run(name, func(b *testing.B) {
+			mode, _ := ParseCompressMode(name)
+			writeStore, err := NewLocalStore(
+				WithPathAndLogger(b.TempDir(), util.CreateLogger(devNull, true)),
+				WithWriteOnly(mode, 64),
+			)
+			if err != nil {
+				b.Fatalf("new writeStore: %s\n", err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				s := samples[n%len(samples)]
+				s.TimeStamp += int64(n/len(samples)) * 5 * int64(len(samples))
+				writeStore.WriteSample(&s)
+			}
+			writeStore.Close()
+			b.ReportMetric(float64(writeStore.DataOffset/int64(b.N)), "after/op")
+		})
+	}
+}
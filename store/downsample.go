@@ -0,0 +1,353 @@
+package store
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xixiliguo/etop/util"
+)
+
+// Tier is one level of retention, shard younger than Age is kept at
+// Resolution, 0 Resolution means all samples are kept
+type Tier struct {
+	Age        time.Duration
+	Resolution time.Duration
+}
+
+// ParseTiers parse tiers like "3d,30d:1m,365d:10m", which means keep all
+// samples for 3 days, one sample per minute for 30 days and one sample
+// per 10 minutes for a year. ages and resolutions should be increasing
+func ParseTiers(s string) ([]Tier, error) {
+	tiers := []Tier{}
+	for _, field := range strings.Split(s, ",") {
+		age, res, _ := strings.Cut(strings.TrimSpace(field), ":")
+		t := Tier{}
+		var err error
+		if t.Age, err = util.ParseDuration(age); err != nil {
+			return nil, fmt.Errorf("cannot parse tier %s: %w", field, err)
+		}
+		if res != "" {
+			if t.Resolution, err = util.ParseDuration(res); err != nil {
+				return nil, fmt.Errorf("cannot parse tier %s: %w", field, err)
+			}
+		}
+		if t.Age <= 0 || t.Resolution < 0 || t.Resolution%time.Second != 0 {
+			return nil, fmt.Errorf("cannot parse tier %s: invalid age or resolution", field)
+		}
+		if n := len(tiers); n != 0 && (t.Age <= tiers[n-1].Age || t.Resolution <= tiers[n-1].Resolution) {
+			return nil, fmt.Errorf("cannot parse tier %s: age and resolution should be increasing", field)
+		}
+		tiers = append(tiers, t)
+	}
+	return tiers, nil
+}
+
+// resolutionOf return resolution of shard by its age, false if shard is
+// older than all tiers
+func resolutionOf(tiers []Tier, age time.Duration) (time.Duration, bool) {
+	for _, t := range tiers {
+		if age < t.Age {
+			return t.Resolution, true
+		}
+	}
+	return 0, false
+}
+
+// Downsample rewrite shards whose tier has lower resolution, only last
+// sample of every resolution is kept. counters in sample are cumulative,
+// so rate between kept samples is still right. processes which are not
+// top opt.TopProcess (0 means all) by cpu and threads are dropped.
+// shard of today is never touched, and shard older than all tiers is
+// left to CleanOldFiles.
+func (local *LocalStore) Downsample(opt WriteOption) {
+	if len(opt.Tiers) == 0 {
+		return
+	}
+	shards, _, _, err := getIndexAndDataInfo(local.Path)
+	if err != nil {
+		msg := fmt.Sprintf("get index and data files: %s", err)
+		local.Log.Warn(msg)
+		return
+	}
+	now := time.Now()
+	currShard := calcshard(now.Unix())
+	for _, shard := range shards {
+		if shard >= currShard {
+			continue
+		}
+		// stop between shards if etop record is exiting
+		local.Lock()
+		closed := local.closed
+		local.Unlock()
+		if closed {
+			return
+		}
+		age := now.Sub(time.Unix(shard+ShardTime, 0))
+		res, ok := resolutionOf(opt.Tiers, age)
+		if !ok || res == 0 {
+			continue
+		}
+		start := time.Now()
+		before, after, err := downsampleShard(local.Path, shard, int64(res/time.Second), opt.TopProcess, local)
+		if err != nil {
+			msg := fmt.Sprintf("downsample shard %d to %s: %s", shard, res, err)
+			local.Log.Warn(msg)
+			continue
+		}
+		if before != after {
+			msg := fmt.Sprintf("downsample shard %d to %s: %d samples to %d, take %s",
+				shard, res, before, after, time.Since(start))
+			local.Log.Info(msg)
+		}
+	}
+}
+
+// downsampleShard rewrite shard with one sample per res seconds, and
+// return number of samples before and after. shard is not rewritten if
+// it already has at most one sample per res seconds
+func downsampleShard(path string, shard int64, res int64, top int, local *LocalStore) (int, int, error) {
+
+	r, err := NewLocalStore(WithPathAndLogger(path, local.Log))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer r.Close()
+	idxs, _, err := readShardFrames(path, shard)
+	if err != nil {
+		return 0, 0, err
+	}
+	r.idxs = idxs
+	if err := r.changeFile(shard, false); err != nil {
+		return 0, 0, err
+	}
+
+	buckets := 0
+	for i := range idxs {
+		if i == 0 || idxs[i].TimeStamp/res != idxs[i-1].TimeStamp/res {
+			buckets++
+		}
+	}
+	if buckets == len(idxs) {
+		return len(idxs), len(idxs), nil
+	}
+
+	tempPath, err := os.MkdirTemp(path, ".downsample")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer os.RemoveAll(tempPath)
+	w, err := NewLocalStore(
+		WithPathAndLogger(tempPath, local.Log),
+		WithWriteOnly(local.mode, local.chunk),
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer w.Close()
+
+	// last sample of every bucket. processes are filtered one bucket later,
+	// so that top processes of next bucket are also kept in previous one,
+	// otherwise they are regarded as new processes when computing rate
+	var prev, pending *Sample
+	pendingTop := map[int]bool{}
+	write := func(s *Sample, keep map[int]bool) error {
+		for pid, p := range s.ProcSamples {
+			if top > 0 && !keep[pid] {
+				delete(s.ProcSamples, pid)
+				continue
+			}
+			p.Threads = nil
+			s.ProcSamples[pid] = p
+		}
+		_, err := w.WriteSample(s)
+		return err
+	}
+
+	after := 0
+	for i := range idxs {
+		if i != len(idxs)-1 && idxs[i].TimeStamp/res == idxs[i+1].TimeStamp/res {
+			continue
+		}
+		s := NewSample()
+		if err := r.getSample(i, &s); err != nil {
+			return 0, 0, err
+		}
+		currTop := topProcesses(prev, &s, top)
+		if pending != nil {
+			for pid := range currTop {
+				pendingTop[pid] = true
+			}
+			if err := write(pending, pendingTop); err != nil {
+				return 0, 0, err
+			}
+			after++
+		}
+		// samples are modified by write, keep a copy for ranking
+		prev = &s
+		cp := s
+		cp.ProcSamples = make(map[int]ProcSample, len(s.ProcSamples))
+		for pid, p := range s.ProcSamples {
+			cp.ProcSamples[pid] = p
+		}
+		pending, pendingTop = &cp, currTop
+	}
+	if pending != nil {
+		if err := write(pending, pendingTop); err != nil {
+			return 0, 0, err
+		}
+		after++
+	}
+
+	if err := r.copyRecords(w, shard, shard+ShardTime-1); err != nil {
+		return 0, 0, err
+	}
+	if err := w.Close(); err != nil {
+		return 0, 0, err
+	}
+
+	if err := replaceShard(tempPath, path, shard); err != nil {
+		return 0, 0, err
+	}
+	return len(idxs), after, nil
+}
+
+// replaceMarker is created in path while index and data file of shard are
+// being replaced, it contains name of temp dir which has the new files
+func replaceMarker(path string, shard int64) string {
+	return filepath.Join(path, fmt.Sprintf(".replace_%011d", shard))
+}
+
+// replaceShard move index and data file of shard from tempPath to path.
+// marker is written before renaming, so that readers do not mix index and
+// data file of different generations, and replacing is finished by
+// finishReplace if crash happen between renames
+func replaceShard(tempPath, path string, shard int64) error {
+	for _, prefix := range []string{"index", "data"} {
+		if err := syncPath(filepath.Join(tempPath, fmt.Sprintf("%s_%011d", prefix, shard))); err != nil {
+			return err
+		}
+	}
+	marker := replaceMarker(path, shard)
+	if err := os.WriteFile(marker, []byte(filepath.Base(tempPath)), 0644); err != nil {
+		return err
+	}
+	if err := syncPath(marker); err != nil {
+		return err
+	}
+	if err := syncPath(path); err != nil {
+		return err
+	}
+	if err := moveShard(tempPath, path, shard); err != nil {
+		return err
+	}
+	if err := syncPath(path); err != nil {
+		return err
+	}
+	return os.Remove(marker)
+}
+
+// moveShard rename index and data file of shard from tempPath to path,
+// file which was moved already is skipped
+func moveShard(tempPath, path string, shard int64) error {
+	for _, prefix := range []string{"index", "data"} {
+		name := fmt.Sprintf("%s_%011d", prefix, shard)
+		err := os.Rename(filepath.Join(tempPath, name), filepath.Join(path, name))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// finishReplace finish replacing shards which was interrupted by crash,
+// and remove temp dirs left by downsample and convert. it is called with
+// lock of path held, so that no replacing is running
+func finishReplace(path string, log *slog.Logger) error {
+	markers, err := filepath.Glob(filepath.Join(path, ".replace_*"))
+	if err != nil {
+		return err
+	}
+	for _, marker := range markers {
+		var shard int64
+		if _, err := fmt.Sscanf(filepath.Base(marker), ".replace_%d", &shard); err != nil {
+			continue
+		}
+		temp, err := os.ReadFile(marker)
+		if err != nil {
+			return err
+		}
+		if err := moveShard(filepath.Join(path, filepath.Base(string(temp))), path, shard); err != nil {
+			return err
+		}
+		if err := syncPath(path); err != nil {
+			return err
+		}
+		if err := os.Remove(marker); err != nil {
+			return err
+		}
+		msg := fmt.Sprintf("finish replacing shard %d interrupted by crash", shard)
+		log.Info(msg)
+	}
+	for _, pattern := range []string{".downsample*", ".convert*"} {
+		dirs, err := filepath.Glob(filepath.Join(path, pattern))
+		if err != nil {
+			return err
+		}
+		for _, dir := range dirs {
+			msg := fmt.Sprintf("remove temp dir %s", dir)
+			log.Info(msg)
+			if err := os.RemoveAll(dir); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// syncPath fsync file or dir of name
+func syncPath(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+// topProcesses return pid of top n processes by cpu ticks between prev
+// and curr, prev can be nil. all processes are returned if n is 0
+func topProcesses(prev, curr *Sample, n int) map[int]bool {
+	type usage struct {
+		pid   int
+		ticks uint64
+	}
+	usages := make([]usage, 0, len(curr.ProcSamples))
+	for pid, p := range curr.ProcSamples {
+		ticks := p.UTime + p.STime
+		if prev != nil {
+			if old, ok := prev.ProcSamples[pid]; ok && old.Starttime == p.Starttime {
+				ticks -= min(ticks, old.UTime+old.STime)
+			}
+		}
+		usages = append(usages, usage{pid, ticks})
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].ticks != usages[j].ticks {
+			return usages[i].ticks > usages[j].ticks
+		}
+		return usages[i].pid < usages[j].pid
+	})
+	if n <= 0 {
+		n = len(usages)
+	}
+	top := make(map[int]bool, n)
+	for i := 0; i < n && i < len(usages); i++ {
+		top[usages[i].pid] = true
+	}
+	return top
+}
@@ -0,0 +1,201 @@
+package store
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/xixiliguo/etop/procfs"
+)
+
+func TestParseTiers(t *testing.T) {
+	tiers, err := ParseTiers("3d,30d:1m, 365d:10m")
+	if err != nil {
+		t.Fatalf("parse tiers: %s", err)
+	}
+	day := 24 * time.Hour
+	want := []Tier{{3 * day, 0}, {30 * day, time.Minute}, {365 * day, 10 * time.Minute}}
+	if diff := cmp.Diff(want, tiers); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	for _, s := range []string{"", "3d:x", "3d,2d:1m", "3d:1m,30d:1m", "3d:500ms"} {
+		if _, err := ParseTiers(s); err == nil {
+			t.Errorf("input: %q expected error but got no", s)
+		}
+	}
+}
+
+func TestDownsample(t *testing.T) {
+	dir := t.TempDir()
+
+	local, err := NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+		WithWriteOnly(ZstdCompressWithDict, 8),
+	)
+	if err != nil {
+		t.Fatalf("new writeStore: %s\n", err)
+	}
+	defer local.Close()
+
+	// sample every 10s for 5 minutes, pid 1 is busy in first 3 minutes,
+	// then pid 2 is busy. pid 3 is always idle
+	shard := calcshard(time.Now().Unix()) - 2*ShardTime
+	ticks := map[int]uint64{}
+	for i := int64(0); i < 30; i++ {
+		s := NewSample()
+		s.TimeStamp = shard + i*10
+		busy := 1
+		if i >= 18 {
+			busy = 2
+		}
+		ticks[busy] += 100
+		for pid := 1; pid <= 3; pid++ {
+			s.ProcSamples[pid] = ProcSample{
+				ProcStat: procfs.ProcStat{PID: pid, UTime: ticks[pid]},
+				Threads:  TidMap{pid: ThreadSample{}},
+			}
+		}
+		if _, err := local.WriteSample(&s); err != nil {
+			t.Fatalf("write sample: %s\n", err)
+		}
+	}
+	e := Event{TimeStamp: shard + 100, Kind: EventStart, Severity: "info"}
+	if err := local.WriteEvent(&e); err != nil {
+		t.Fatalf("write event: %s\n", err)
+	}
+
+	// reader which has cached index of the shard before it is downsampled,
+	// and then moves to today's shard
+	today := calcshard(time.Now().Unix())
+	early, err := NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+	)
+	if err != nil {
+		t.Fatalf("new readStore: %s\n", err)
+	}
+	defer early.Close()
+	s := NewSample()
+	if err := early.NextSample(1, &s); err != nil || s.TimeStamp != shard {
+		t.Fatalf("got sample at %d %v, but want %d", s.TimeStamp, err, shard)
+	}
+	s = NewSample()
+	s.TimeStamp = today
+	if _, err := local.WriteSample(&s); err != nil {
+		t.Fatalf("write sample: %s\n", err)
+	}
+	if err := early.JumpSampleByTimeStamp(today, &s); err != nil || s.TimeStamp != today {
+		t.Fatalf("got sample at %d %v, but want %d", s.TimeStamp, err, today)
+	}
+
+	tiers, _ := ParseTiers("1d,30d:1m")
+	opt := WriteOption{Tiers: tiers, TopProcess: 1, RetainDay: 3, RetainSize: 1 << 30}
+	// downsample in background, next one is skipped while it is running
+	local.maintain(opt)
+	done := local.maintainDone
+	local.maintain(opt)
+	<-done
+	<-local.maintainDone
+
+	// stale index is reloaded when the replaced data file is opened
+	s = NewSample()
+	if err := early.NextSample(-1, &s); err != nil || s.TimeStamp != shard+290 {
+		t.Errorf("got sample at %d %v after downsample, but want %d", s.TimeStamp, err, shard+290)
+	}
+	if events, err := early.Events(shard, shard+ShardTime); err != nil || len(events) != 1 {
+		t.Errorf("got events %v %v after downsample", events, err)
+	}
+
+	r, err := NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+	)
+	if err != nil {
+		t.Fatalf("new readStore: %s\n", err)
+	}
+	defer r.Close()
+	type kept struct {
+		TimeStamp int64
+		Pids      []int
+	}
+	got := []kept{}
+	for {
+		s := NewSample()
+		if err := r.NextSample(1, &s); err != nil {
+			break
+		}
+		k := kept{TimeStamp: s.TimeStamp - shard}
+		for pid, p := range s.ProcSamples {
+			if p.Threads != nil {
+				t.Errorf("threads of %d should be dropped", pid)
+			}
+			k.Pids = append(k.Pids, pid)
+		}
+		sort.Ints(k.Pids)
+		got = append(got, k)
+	}
+	// pid 2 is also kept at 170, so that its rate can be computed at 230
+	want := []kept{
+		{50, []int{1}},
+		{110, []int{1}},
+		{170, []int{1, 2}},
+		{230, []int{2}},
+		{290, []int{2}},
+		{today - shard, nil},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	events, err := r.Events(shard, shard+ShardTime)
+	if err != nil || len(events) != 1 || events[0] != e {
+		t.Errorf("got events %v %v, but want %v", events, err, e)
+	}
+
+	// already downsampled
+	before, after, err := downsampleShard(dir, shard, 60, 1, local)
+	if err != nil || before != 5 || after != 5 {
+		t.Errorf("got %d %d %v, but want 5 5", before, after, err)
+	}
+}
+
+func TestFinishReplace(t *testing.T) {
+	dir := t.TempDir()
+	shard := int64(1697760000)
+	write := func(dir, content string) {
+		os.MkdirAll(dir, 0755)
+		for _, prefix := range []string{"index", "data"} {
+			os.WriteFile(filepath.Join(dir, fmt.Sprintf("%s_%011d", prefix, shard)), []byte(content), 0644)
+		}
+	}
+
+	// crash after index was renamed, and temp dir of other crash
+	write(dir, "old")
+	write(filepath.Join(dir, ".downsample123"), "new")
+	os.Rename(filepath.Join(dir, ".downsample123", "index_01697760000"), filepath.Join(dir, "index_01697760000"))
+	os.WriteFile(replaceMarker(dir, shard), []byte(".downsample123"), 0644)
+	os.MkdirAll(filepath.Join(dir, ".convert456"), 0755)
+
+	w, err := NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+		WithWriteOnly(ZstdCompress, 0),
+	)
+	if err != nil {
+		t.Fatalf("new writeStore: %s\n", err)
+	}
+	w.Close()
+	for _, prefix := range []string{"index", "data"} {
+		b, _ := os.ReadFile(filepath.Join(dir, fmt.Sprintf("%s_%011d", prefix, shard)))
+		if string(b) != "new" {
+			t.Errorf("got %s file %q, but want new", prefix, b)
+		}
+	}
+	for _, name := range []string{".replace_01697760000", ".downsample123", ".convert456"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed, but got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package store
+
+import (
+	"os"
+	"time"
+
+	"github.com/xixiliguo/etop/procfs"
+)
+
+// EtopSample is overhead of etop itself, so that its footprint can be
+// proved. write time and bytes are of the previous sample, since they are
+// known after the sample is encoded
+type EtopSample struct {
+	ModuleTimes map[string]int64 // time to collect module in microseconds
+	CollectTime int64            // time to collect sample in microseconds
+	WriteTime   int64            // time to write previous sample in microseconds
+	RawBytes    int64            // bytes of previous sample before compress
+	StoredBytes int64            // bytes of previous sample in data file
+	RSS         uint64           // bytes
+	UTime       uint64           // cpu time in user mode in ticks
+	STime       uint64           // cpu time in kernel mode in ticks
+	LostExits   uint64           // exited processes lost by perf reader, cumulative
+	SmapsErrors uint64           // processes whose smaps_rollup can not be read
+}
+
+// collectEtop collect overhead of etop, except write time and bytes which
+// are known by writer
+func collectEtop(s *Sample, fs *procfs.FS, exit *ExitProcess, start time.Time) {
+	if stat, err := fs.Proc(os.Getpid()).Stat(); err == nil {
+		s.Etop.RSS = stat.RSS * uint64(s.PageSize)
+		s.Etop.UTime = stat.UTime
+		s.Etop.STime = stat.STime
+	}
+	if exit != nil {
+		exit.Lock()
+		s.Etop.LostExits = exit.Lost
+		exit.Unlock()
+	}
+	s.Etop.CollectTime = time.Since(start).Microseconds()
+}
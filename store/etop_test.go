@@ -0,0 +1,27 @@
+package store
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestCollectEtop(t *testing.T) {
+	sc, err := NewSchedule([]string{ModuleLoad, ModuleMeminfo}, nil)
+	if err != nil {
+		t.Fatalf("new schedule: %s", err)
+	}
+	exit := NewExitProcess(slog.Default())
+	exit.Lost = 3
+	s := NewSample()
+	if err := CollectSampleFromSys(&s, &Collectors{Exit: exit, Schedule: sc}); err != nil {
+		t.Fatalf("collect sample: %s", err)
+	}
+	e := s.Etop
+	// only collected modules have time
+	if _, ok := e.ModuleTimes[ModuleMeminfo]; !ok || len(e.ModuleTimes) != 3 {
+		t.Errorf("got module times %v, but want load, stat and meminfo", e.ModuleTimes)
+	}
+	if e.CollectTime <= 0 || e.RSS == 0 || e.LostExits != 3 {
+		t.Errorf("got %+v", e)
+	}
+}
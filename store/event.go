@@ -0,0 +1,235 @@
+package store
+
+import (
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"unsafe"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// kind of record which index point to
+const (
+	SampleRecord = uint32(iota)
+	EventRecord
+	InventoryRecord
+	FormatRecord // header of etop appending to data file of older format
+)
+
+// kind of event
+const (
+	EventStart     = "start"      // etop record started
+	EventReboot    = "reboot"     // system boot time changed
+	EventOOMKill   = "oom_kill"   // process was killed by OOM killer
+	EventSkipWrite = "skip_write" // samples were not written because of low free space
+	EventAlert     = "alert"      // alert rule fired or resolved
+)
+
+// Event represent something notable happened at TimeStamp, it is stored
+// in the same index and data files with samples, but as EventRecord.
+type Event struct {
+	TimeStamp int64  // unix time when event happened
+	Kind      string // e.g EventOOMKill
+	Severity  string // info, warning or critical
+	Object    string // what event is about, e.g cgroup path or pid, optional
+	Message   string
+}
+
+func (e *Event) Marshal() ([]byte, error) {
+	return cbor.Marshal(e)
+}
+
+func (e *Event) Unmarshal(b []byte) error {
+	return cbor.Unmarshal(b, e)
+}
+
+// EventStore is implemented by store which keep events besides samples
+type EventStore interface {
+	// Events return all events between begin and end (both included),
+	// order by timestamp
+	Events(begin, end int64) ([]Event, error)
+}
+
+// WriteEvent write e into shard file of e.TimeStamp, without compress.
+// e is dropped if WriteLoop skips samples because of low free space.
+func (local *LocalStore) WriteEvent(e *Event) error {
+	local.Lock()
+	skipping := local.skipping
+	if skipping {
+		local.dropped++
+	}
+	local.Unlock()
+	if skipping {
+		return nil
+	}
+	b, err := e.Marshal()
+	if err != nil {
+		return err
+	}
+	return local.writeRecord(e.TimeStamp, EventRecord, b)
+}
+
+// writeRecord write b as record of kind into shard file of timestamp,
+// without compress.
+func (local *LocalStore) writeRecord(timestamp int64, kind uint32, b []byte) error {
+
+	shard := calcshard(timestamp)
+	if shard != local.shard {
+		if err := local.changeFile(shard, true); err != nil {
+			return err
+		}
+	}
+
+	if info, err := local.Data.Stat(); err != nil {
+		return err
+	} else {
+		local.DataOffset = info.Size()
+	}
+
+	idx := Index{
+		TimeStamp: timestamp,
+		Offset:    local.DataOffset,
+		Len:       int64(len(b)),
+	}
+	idx.SetCompressMode(NoCompress, 0)
+	idx.SetRecordKind(kind)
+
+	if _, err := local.Data.Write(b); err != nil {
+		return err
+	}
+
+	idx.CRC = crc32.ChecksumIEEE((*[32]byte)(unsafe.Pointer(&idx))[:28])
+
+	if _, err := local.Index.Write(idx.Marshal()); err != nil {
+		return err
+	}
+	local.DataOffset += int64(len(b))
+	return local.written()
+}
+
+// Events return all events between begin and end (both included).
+// index of events is cached per shard, see shardRecords
+func (local *LocalStore) Events(begin, end int64) ([]Event, error) {
+
+	// shards of readonly store are loaded when it is opened, and
+	// reloaded by refresh
+	shards := local.shards
+	if len(shards) == 0 {
+		var err error
+		if shards, err = listShards(local.Path); err != nil {
+			return nil, err
+		}
+	}
+	events := []Event{}
+	err := local.readRecords(shards, EventRecord, begin, end, func(idx Index, b []byte) error {
+		e := Event{}
+		if err := e.Unmarshal(b); err != nil {
+			return err
+		}
+		events = append(events, e)
+		return nil
+	})
+	return events, err
+}
+
+// readRecords call fn with index and data of records of kind between begin
+// and end (both included) in shards, order by timestamp
+func (local *LocalStore) readRecords(shards []int64, kind uint32, begin, end int64, fn func(idx Index, b []byte) error) error {
+
+	idxs := []Index{}
+	inos := make(map[int64]uint64) // inode of data file which records point to
+	for _, shard := range shards {
+		if shard+ShardTime <= begin || shard > end {
+			continue
+		}
+		records, ino, err := local.shardRecords(shard)
+		if err != nil {
+			return err
+		}
+		inos[shard] = ino
+		for _, idx := range records {
+			if idx.RecordKind() == kind {
+				idxs = append(idxs, idx)
+			}
+		}
+	}
+	start := sort.Search(len(idxs), func(i int) bool {
+		return idxs[i].TimeStamp >= begin
+	})
+
+	for _, idx := range idxs[start:] {
+		if idx.TimeStamp > end {
+			break
+		}
+		shard := calcshard(idx.TimeStamp)
+		if shard != local.shard || local.dataIno != inos[shard] {
+			if err := local.changeFile(shard, false); err != nil {
+				return err
+			}
+		}
+		if local.dataIno != inos[shard] {
+			return fmt.Errorf("%w: shard %d is replaced while reading", ErrDataCorrupt, shard)
+		}
+		buff := make([]byte, idx.Len)
+		if err := local.getDataBytes(idx, &buff); err != nil {
+			return err
+		}
+		if err := fn(idx, buff); err != nil {
+			return fmt.Errorf("%s timestamp %d: %w", local.Data.Name(), idx.TimeStamp, err)
+		}
+	}
+	return nil
+}
+
+// recordIndex is index of records other than samples in one shard, size
+// of index file when it was read and inode of data file it point to
+type recordIndex struct {
+	size    int64
+	records []Index
+	dataIno uint64
+}
+
+// shardRecords return index of records other than samples in shard and
+// inode of data file they point to. it is read from index file again only
+// if the file size or data file is changed, e.g new event is written by
+// etop record or shard is downsampled
+func (local *LocalStore) shardRecords(shard int64) ([]Index, uint64, error) {
+	info, err := os.Stat(filepath.Join(local.Path, fmt.Sprintf("index_%011d", shard)))
+	if err != nil {
+		return nil, 0, err
+	}
+	if local.records == nil {
+		local.records = make(map[int64]recordIndex)
+	}
+	if r, ok := local.records[shard]; ok && r.size == info.Size() && r.dataIno == dataInode(local.Path, shard) {
+		return r.records, r.dataIno, nil
+	}
+	f, err := loadShardFrames(local.Path, shard)
+	if err != nil {
+		return nil, 0, err
+	}
+	local.records[shard] = recordIndex{size: info.Size(), records: f.records, dataIno: f.dataIno}
+	return f.records, f.dataIno, nil
+}
+
+// copyRecords copy events and inventories between begin and end (both
+// included) to dest as they are
+func (local *LocalStore) copyRecords(dest *LocalStore, begin, end int64) error {
+
+	shards, err := listShards(local.Path)
+	if err != nil {
+		return err
+	}
+	for _, kind := range []uint32{EventRecord, InventoryRecord} {
+		err := local.readRecords(shards, kind, begin, end, func(idx Index, b []byte) error {
+			return dest.writeRecord(idx.TimeStamp, kind, b)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
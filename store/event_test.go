@@ -0,0 +1,191 @@
+package store
+
+import (
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRecordKind(t *testing.T) {
+	idx := Index{}
+	idx.SetCompressMode(ZstdCompressWithDict, 1023)
+	idx.SetRecordKind(EventRecord)
+	if mode, offset := idx.CompressMode(); mode != ZstdCompressWithDict || offset != 1023 {
+		t.Errorf("got mode %d offset %d, but want %d %d", mode, offset, ZstdCompressWithDict, 1023)
+	}
+	if k := idx.RecordKind(); k != EventRecord {
+		t.Errorf("got record kind %d, but want %d", k, EventRecord)
+	}
+	// index written before record kind was introduced is sample
+	old := Index{}
+	old.SetCompressMode(ZstdCompressWithDict, 5)
+	if k := old.RecordKind(); k != SampleRecord {
+		t.Errorf("got record kind %d, but want %d", k, SampleRecord)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	dir := t.TempDir()
+
+	writeStore, err := NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+		WithWriteOnly(ZstdCompressWithDict, 2),
+	)
+	if err != nil {
+		t.Fatalf("new writeStore: %s\n", err)
+	}
+	base := int64(1697760000)
+	want := []Event{}
+	for i := int64(0); i < 6; i++ {
+		s := NewSample()
+		s.TimeStamp = base + i*5
+		s.HostName = "event"
+		if _, err := writeStore.WriteSample(&s); err != nil {
+			t.Fatalf("write sample: %s\n", err)
+		}
+		if i%2 == 1 {
+			e := Event{
+				TimeStamp: s.TimeStamp,
+				Kind:      EventOOMKill,
+				Severity:  "critical",
+				Object:    "/sys/fs/cgroup/a",
+				Message:   "1 processes were killed by OOM killer",
+			}
+			if err := writeStore.WriteEvent(&e); err != nil {
+				t.Fatalf("write event: %s\n", err)
+			}
+			want = append(want, e)
+		}
+	}
+	// event in next shard
+	e := Event{TimeStamp: base + ShardTime, Kind: EventReboot, Severity: "warning"}
+	if err := writeStore.WriteEvent(&e); err != nil {
+		t.Fatalf("write event: %s\n", err)
+	}
+	want = append(want, e)
+	writeStore.Close()
+
+	readStore, err := NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+	)
+	if err != nil {
+		t.Fatalf("new readStore: %s\n", err)
+	}
+	defer readStore.Close()
+
+	// events should not be visible as samples
+	for i := int64(0); i < 6; i++ {
+		s := NewSample()
+		if err := readStore.NextSample(1, &s); err != nil {
+			t.Fatalf("read sample %d: %s\n", i, err)
+		}
+		if s.TimeStamp != base+i*5 || s.HostName != "event" {
+			t.Fatalf("got sample %d %s, but want %d", s.TimeStamp, s.HostName, base+i*5)
+		}
+	}
+	s := NewSample()
+	if err := readStore.NextSample(1, &s); err != ErrOutOfRange {
+		t.Fatalf("should be out of range, but got %v\n", err)
+	}
+
+	got, err := readStore.Events(base, base+ShardTime)
+	if err != nil {
+		t.Fatalf("read events: %s\n", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("events mismatch (-want +got):\n%s", diff)
+	}
+	got, err = readStore.Events(base+6, base+25)
+	if err != nil {
+		t.Fatalf("read events: %s\n", err)
+	}
+	if diff := cmp.Diff(want[1:3], got); diff != "" {
+		t.Errorf("events mismatch (-want +got):\n%s", diff)
+	}
+
+	// index of events is cached, but event written later is visible
+	writeStore, err = NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+		WithWriteOnly(ZstdCompressWithDict, 2),
+	)
+	if err != nil {
+		t.Fatalf("new writeStore: %s\n", err)
+	}
+	late := Event{TimeStamp: base + 30, Kind: EventAlert, Severity: "warning"}
+	if err := writeStore.WriteEvent(&late); err != nil {
+		t.Fatalf("write event: %s\n", err)
+	}
+	writeStore.Close()
+	got, err = readStore.Events(base+6, base+30)
+	if err != nil {
+		t.Fatalf("read events: %s\n", err)
+	}
+	if diff := cmp.Diff(append(want[1:3:3], late), got); diff != "" {
+		t.Errorf("events mismatch (-want +got):\n%s", diff)
+	}
+	want = append(want[:3:3], late, want[3])
+
+	srv := httptest.NewServer(NewServer(readStore, slog.Default()))
+	defer srv.Close()
+	remote, err := NewRemoteStore(srv.Listener.Addr().String(), slog.Default())
+	if err != nil {
+		t.Fatalf("new remote store: %s\n", err)
+	}
+	defer remote.Close()
+	got, err = remote.Events(base, base+ShardTime)
+	if err != nil {
+		t.Fatalf("read remote events: %s\n", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("remote events mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriteEventWhileSkipping(t *testing.T) {
+	dir := t.TempDir()
+
+	local, err := NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+		WithWriteOnly(ZstdCompressWithDict, 2),
+	)
+	if err != nil {
+		t.Fatalf("new writeStore: %s\n", err)
+	}
+	defer local.Close()
+	base := int64(1697760000)
+	s := NewSample()
+	s.TimeStamp = base
+	if _, err := local.WriteSample(&s); err != nil {
+		t.Fatalf("write sample: %s\n", err)
+	}
+
+	// WriteLoop skips samples because of low free space
+	local.skipping = true
+	e := Event{TimeStamp: base + 5, Kind: EventAlert, Severity: "warning"}
+	if err := local.WriteEvent(&e); err != nil {
+		t.Fatalf("write event: %s\n", err)
+	}
+	if local.dropped != 1 {
+		t.Errorf("got %d dropped events, but want 1", local.dropped)
+	}
+	local.skipping = false
+	e.TimeStamp = base + 10
+	if err := local.WriteEvent(&e); err != nil {
+		t.Fatalf("write event: %s\n", err)
+	}
+
+	r, err := NewLocalStore(WithPathAndLogger(dir, slog.Default()))
+	if err != nil {
+		t.Fatalf("new readStore: %s\n", err)
+	}
+	defer r.Close()
+	if r.collectors.Sockets != nil {
+		t.Errorf("read only store should not have socket collector")
+	}
+	events, err := r.Events(base, base+ShardTime)
+	if err != nil || len(events) != 1 || events[0].TimeStamp != base+10 {
+		t.Errorf("got events %v %v, but want only event at %d", events, err, base+10)
+	}
+}
@@ -22,6 +22,7 @@ import (
 type ExitProcess struct {
 	sync.Mutex
 	Samples map[int]ProcSample
+	Lost    uint64 // samples lost by perf reader since start
 	log     *slog.Logger
 }
 
@@ -87,6 +88,9 @@ func (e *ExitProcess) Collect() {
 		if record.LostSamples != 0 {
 			msg := fmt.Sprintf("perf event lost %d samples", record.LostSamples)
 			e.log.Info(msg)
+			e.Lock()
+			e.Lost += record.LostSamples
+			e.Unlock()
 			continue
 		}
 
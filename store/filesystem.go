@@ -0,0 +1,165 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xixiliguo/etop/procfs"
+	"golang.org/x/sys/unix"
+)
+
+// DefaultSkipFSTypes are pseudo filesystems which are not collected by
+// default, overlay of containers is skipped too since it is backed by
+// filesystem of host
+var DefaultSkipFSTypes = []string{"autofs", "binfmt_misc", "bpf", "cgroup", "cgroup2",
+	"configfs", "debugfs", "devpts", "devtmpfs", "efivarfs", "fusectl", "fuse.lxcfs",
+	"hugetlbfs", "mqueue", "nsfs", "overlay", "proc", "pstore", "ramfs", "rpc_pipefs",
+	"securityfs", "selinuxfs", "squashfs", "sysfs", "tmpfs", "tracefs"}
+
+// Filesystem is capacity and inode usage of one mounted filesystem
+type Filesystem struct {
+	MountPoint string
+	Source     string
+	FSType     string
+	Device     string // DeviceName in diskstats, empty if not a block device
+	Size       uint64 // bytes
+	Used       uint64
+	Avail      uint64 // bytes available to unprivileged user
+	Inodes     uint64 // 0 if filesystem has no fixed inodes, e.g btrfs
+	InodesFree uint64
+}
+
+// statfsTimeout is how long statfs of one mount is waited. statfs of
+// network filesystem, e.g nfs, may hang if server is not responding
+const statfsTimeout = time.Second
+
+var errStatfsTimeout = errors.New("statfs timeout")
+
+// FilesystemCollector collect filesystems which are not skipped by type.
+// mount whose statfs does not return in time is skipped until that
+// statfs return, so that hung mount does not block every sample
+type FilesystemCollector struct {
+	skip    map[string]bool
+	timeout time.Duration
+	statfs  func(path string, st *unix.Statfs_t) error
+	mu      sync.Mutex
+	stuck   map[string]bool // mount points whose statfs is not returned
+}
+
+// NewFilesystemCollector create collector which skip filesystems whose
+// type is in skip, DefaultSkipFSTypes if it is empty
+func NewFilesystemCollector(skip []string) *FilesystemCollector {
+	if len(skip) == 0 {
+		skip = DefaultSkipFSTypes
+	}
+	f := &FilesystemCollector{
+		skip:    make(map[string]bool),
+		timeout: statfsTimeout,
+		statfs:  unix.Statfs,
+		stuck:   make(map[string]bool),
+	}
+	for _, t := range skip {
+		f.skip[t] = true
+	}
+	return f
+}
+
+var defaultFilesystemCollector = NewFilesystemCollector(nil)
+
+// Collect statfs every mount which is not skipped. filesystem mounted on
+// several mount points is collected once by the first one, and mount
+// which can not be statfs-ed, e.g no permission or stuck, is ignored
+func (f *FilesystemCollector) Collect(fs *procfs.FS) ([]Filesystem, error) {
+
+	mounts, err := fs.MountInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	type dev struct{ major, minor uint32 }
+	seen := make(map[dev]bool)
+	res := []Filesystem{}
+	for _, m := range mounts {
+		if f.skip[m.FSType] || seen[dev{m.Major, m.Minor}] {
+			continue
+		}
+		st, err := f.statfsWithTimeout(m.MountPoint)
+		if err != nil {
+			continue
+		}
+		// pseudo filesystem which is not in skip
+		if st.Blocks == 0 {
+			continue
+		}
+		seen[dev{m.Major, m.Minor}] = true
+		bsize := uint64(st.Frsize)
+		if bsize == 0 {
+			bsize = uint64(st.Bsize)
+		}
+		fsys := Filesystem{
+			MountPoint: m.MountPoint,
+			Source:     m.Source,
+			FSType:     m.FSType,
+			Device:     procfs.BlockDevName(m.Major, m.Minor),
+			Size:       st.Blocks * bsize,
+			Used:       (st.Blocks - st.Bfree) * bsize,
+			Avail:      st.Bavail * bsize,
+			Inodes:     st.Files,
+			InodesFree: st.Ffree,
+		}
+		// btrfs use anonymous device, find it by source instead
+		if fsys.Device == "" && strings.HasPrefix(m.Source, "/dev/") {
+			if p, err := filepath.EvalSymlinks(m.Source); err == nil {
+				fsys.Device = filepath.Base(p)
+			}
+		}
+		res = append(res, fsys)
+	}
+	return res, nil
+}
+
+// statfsWithTimeout statfs path in background and wait it for f.timeout.
+// path is marked as stuck if it is timeout, and the mark is cleared
+// once the statfs return
+func (f *FilesystemCollector) statfsWithTimeout(path string) (unix.Statfs_t, error) {
+	f.mu.Lock()
+	if f.stuck[path] {
+		f.mu.Unlock()
+		return unix.Statfs_t{}, errStatfsTimeout
+	}
+	f.mu.Unlock()
+
+	type result struct {
+		st  unix.Statfs_t
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		r := result{}
+		r.err = f.statfs(path, &r.st)
+		done <- r
+		f.mu.Lock()
+		delete(f.stuck, path)
+		f.mu.Unlock()
+	}()
+	timer := time.NewTimer(f.timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.st, r.err
+	case <-timer.C:
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// statfs may return right after timeout
+	select {
+	case r := <-done:
+		return r.st, r.err
+	default:
+		f.stuck[path] = true
+		return unix.Statfs_t{}, errStatfsTimeout
+	}
+}
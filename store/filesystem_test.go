@@ -0,0 +1,97 @@
+package store
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xixiliguo/etop/procfs"
+	"golang.org/x/sys/unix"
+)
+
+func TestCollectFilesystems(t *testing.T) {
+	sc, err := NewSchedule([]string{ModuleFilesystem}, nil)
+	if err != nil {
+		t.Fatalf("new schedule: %s", err)
+	}
+	s := NewSample()
+	if err := CollectSampleFromSys(&s, &Collectors{Schedule: sc}); err != nil {
+		t.Fatalf("collect sample: %s", err)
+	}
+	if !s.Has(ModuleFilesystem) {
+		t.Fatalf("got missing %v", s.Missing)
+	}
+	var root *Filesystem
+	for i, f := range s.Filesystems {
+		if f.MountPoint == "/" {
+			root = &s.Filesystems[i]
+		}
+		for _, skip := range DefaultSkipFSTypes {
+			if f.FSType == skip {
+				t.Errorf("%s of %s should be skipped", f.MountPoint, f.FSType)
+			}
+		}
+	}
+	if root == nil {
+		t.Skipf("root is not collected, it is %v", s.Filesystems)
+	}
+	if root.Size == 0 || root.Used > root.Size || root.Avail > root.Size || root.InodesFree > root.Inodes {
+		t.Errorf("got unexpected root %+v", root)
+	}
+
+	// root is not collected if its type is skipped
+	fs, err := NewFilesystemCollector([]string{root.FSType}).Collect(procfs.NewFS(""))
+	if err != nil {
+		t.Fatalf("collect filesystems: %s", err)
+	}
+	for _, f := range fs {
+		if f.MountPoint == "/" {
+			t.Errorf("got root %+v, but %s should be skipped", f, root.FSType)
+		}
+	}
+}
+
+func TestStatfsTimeout(t *testing.T) {
+	f := NewFilesystemCollector(nil)
+	f.timeout = 10 * time.Millisecond
+	release := make(chan struct{})
+	var calls atomic.Int32
+	f.statfs = func(path string, st *unix.Statfs_t) error {
+		calls.Add(1)
+		if path == "/hung" {
+			<-release
+		}
+		st.Blocks = 1
+		return nil
+	}
+
+	if _, err := f.statfsWithTimeout("/hung"); !errors.Is(err, errStatfsTimeout) {
+		t.Fatalf("got %v, but want timeout", err)
+	}
+	// stuck mount is skipped without statfs again, others are not blocked
+	if _, err := f.statfsWithTimeout("/hung"); !errors.Is(err, errStatfsTimeout) {
+		t.Errorf("got %v, but want timeout", err)
+	}
+	if st, err := f.statfsWithTimeout("/"); err != nil || st.Blocks != 1 {
+		t.Errorf("got %+v %v", st, err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("got %d statfs calls, but want 2", n)
+	}
+
+	// mount is collected again once the hung statfs return
+	close(release)
+	for i := 0; i < 100; i++ {
+		f.mu.Lock()
+		stuck := f.stuck["/hung"]
+		f.mu.Unlock()
+		if !stuck {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if st, err := f.statfsWithTimeout("/hung"); err != nil || st.Blocks != 1 {
+		t.Errorf("got %+v %v after statfs returned", st, err)
+	}
+}
@@ -0,0 +1,320 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/klauspost/compress/zstd"
+)
+
+// BadRange is a range of data file which can not be read
+type BadRange struct {
+	Begin     int64 // offset of data file, included
+	End       int64 // offset of data file, excluded
+	TimeStamp int64 // timestamp of index, 0 if no index point to the range
+	Reason    string
+}
+
+// FsckResult is the result of checking one pair of index and data file
+type FsckResult struct {
+	Shard     int64
+	Index     string
+	Data      string
+	Header    Header
+	Frames    int // number of index in index file
+	Good      int // number of frames which can be decoded
+	Bad       []BadRange
+	IndexTail int64 // bytes of partial index at the end of index file
+	DataTail  int64 // bytes after last good frame of data file
+	Repaired  bool
+}
+
+// OK return true if nothing wrong was found
+func (r *FsckResult) OK() bool {
+	return len(r.Bad) == 0 && r.IndexTail == 0 && r.DataTail == 0
+}
+
+func (r *FsckResult) String() string {
+	s := fmt.Sprintf("%s: %s, %d frames, %d good", r.Index, r.Header, r.Frames, r.Good)
+	if r.IndexTail != 0 {
+		s += fmt.Sprintf(", %d bytes partial index", r.IndexTail)
+	}
+	if r.DataTail != 0 {
+		s += fmt.Sprintf(", %d bytes torn tail", r.DataTail)
+	}
+	for _, b := range r.Bad {
+		s += fmt.Sprintf("\n  bad range [%d, %d) timestamp %d: %s", b.Begin, b.End, b.TimeStamp, b.Reason)
+	}
+	if r.Repaired {
+		s += "\n  repaired"
+	}
+	return s
+}
+
+// Fsck check all index and data files in path. every index is checked
+// with its CRC and data file size, and frame it point to is decompressed
+// and decoded. if repair is true, index file is rebuilt from good frames
+// and torn tail of data file is truncated.
+func Fsck(path string, repair bool, log *slog.Logger) ([]FsckResult, error) {
+
+	shards, _, _, err := getIndexAndDataInfo(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if repair {
+		// same lock with etop record
+		l := &LocalStore{Path: path}
+		if err := l.lock(); err != nil {
+			return nil, err
+		}
+		defer l.unlock()
+	}
+
+	dec, _ := zstd.NewReader(
+		nil,
+		zstd.WithDecoderLowmem(true),
+		zstd.WithDecoderConcurrency(1),
+	)
+	defer dec.Close()
+
+	results := []FsckResult{}
+	for _, shard := range shards {
+		r, good, err := fsckShard(path, shard, dec)
+		if err != nil {
+			return results, err
+		}
+		if repair && !r.OK() {
+			if err := repairShard(&r, good); err != nil {
+				return results, err
+			}
+			msg := fmt.Sprintf("repair %s: keep %d frames, truncate %d bytes", r.Index, len(good), r.DataTail)
+			log.Info(msg)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// goodFrame is frame which can be decoded, dict is data offset of its
+// dict frame, -1 if it does not need dict
+type goodFrame struct {
+	idx  Index
+	dict int64
+}
+
+func fsckShard(path string, shard int64, dec *zstd.Decoder) (FsckResult, []goodFrame, error) {
+
+	r := FsckResult{
+		Shard: shard,
+		Index: filepath.Join(path, fmt.Sprintf("index_%011d", shard)),
+		Data:  filepath.Join(path, fmt.Sprintf("data_%011d", shard)),
+	}
+
+	idxBytes, err := os.ReadFile(r.Index)
+	if err != nil {
+		return r, nil, err
+	}
+	// data file may be large, frames are read one by one
+	data, err := os.Open(r.Data)
+	if err != nil {
+		return r, nil, err
+	}
+	defer data.Close()
+	info, err := data.Stat()
+	if err != nil {
+		return r, nil, err
+	}
+	dataSize := info.Size()
+	if r.Header, err = readHeader(data); err != nil {
+		r.Bad = append(r.Bad, BadRange{0, 0, 0, err.Error()})
+	} else if err := r.Header.check(r.Data); err != nil {
+		return r, nil, err
+	}
+	r.IndexTail = int64(len(idxBytes) % sizeIndex)
+	r.Frames = len(idxBytes) / sizeIndex
+
+	type frame struct {
+		idx Index
+		raw []byte // decompressed data, only kept for dict frame
+		ok  bool
+	}
+	good := []goodFrame{}
+	samples := []frame{} // sample frames in order of index file, for dict offset
+	end := r.Header.size
+	delta := newDeltaDecoder() // pos is position in samples of last decoded frame
+	for i := 0; i < r.Frames; i++ {
+		idx := Index{}
+		idx.Unmarshal(idxBytes[i*sizeIndex:])
+
+		bad := func(reason string) {
+			r.Bad = append(r.Bad, BadRange{idx.Offset, idx.Offset + idx.Len, idx.TimeStamp, reason})
+		}
+
+		if idx.CRC != crc32.ChecksumIEEE((*[32]byte)(unsafe.Pointer(&idx))[:28]) {
+			// offset and len can not be trusted. most frames are samples,
+			// keep position for dict offset of following frames
+			r.Bad = append(r.Bad, BadRange{-1, -1, idx.TimeStamp, ErrIndexCorrupt.Error()})
+			samples = append(samples, frame{})
+			continue
+		}
+		kind := idx.RecordKind()
+		f := frame{idx: idx}
+		if kind == SampleRecord {
+			// keep position even if it is bad, so that dict offset of
+			// following frames is still right
+			samples = append(samples, f)
+		}
+		if idx.Offset < 0 || idx.Len <= 0 || idx.Offset+idx.Len > dataSize {
+			bad(fmt.Sprintf("out of data file size %d", dataSize))
+			continue
+		}
+		buff := make([]byte, idx.Len)
+		if _, err := data.ReadAt(buff, idx.Offset); err != nil {
+			return r, nil, err
+		}
+
+		raw := buff
+		dict := int64(-1)
+		mode, offset := idx.CompressMode()
+		switch {
+		case kind != SampleRecord || mode == NoCompress:
+		case mode == ZstdCompress || (mode == ZstdCompressWithDict && offset == 0):
+			raw, err = dec.DecodeAll(buff, nil)
+		case mode == ZstdCompressWithDict:
+			pos := len(samples) - 1 - int(offset)
+			if pos < 0 || !samples[pos].ok {
+				bad("dict frame is missing or corrupt")
+				continue
+			}
+			if m, o := samples[pos].idx.CompressMode(); m != ZstdCompressWithDict || o != 0 {
+				bad("dict frame is not compressed with dict")
+				continue
+			}
+			dict = samples[pos].idx.Offset
+			var decDict *zstd.Decoder
+			decDict, err = zstd.NewReader(nil,
+				zstd.WithDecoderLowmem(true),
+				zstd.WithDecoderConcurrency(1),
+				zstd.WithDecoderDictRaw(0, samples[pos].raw))
+			if err == nil {
+				raw, err = decDict.DecodeAll(buff, nil)
+				decDict.Close()
+			}
+		case mode == ZstdCompressWithDelta:
+			pos := len(samples) - 1
+			if offset == 0 {
+				delta.reset()
+			} else if delta.pos != pos-1 || pos-int(offset) < 0 {
+				bad("previous frame of delta chain is missing or corrupt")
+				continue
+			} else {
+				dict = samples[pos-int(offset)].idx.Offset
+			}
+			raw, err = dec.DecodeAll(buff, nil)
+		default:
+			err = fmt.Errorf("unknown compress mode %d", mode)
+		}
+		if err != nil {
+			bad(fmt.Sprintf("decompress: %s", err))
+			continue
+		}
+
+		if kind == EventRecord {
+			e := Event{}
+			err = e.Unmarshal(raw)
+		} else if kind == InventoryRecord {
+			inv := Inventory{}
+			err = inv.Unmarshal(raw)
+		} else if kind == FormatRecord {
+			h := Header{}
+			err = cbor.Unmarshal(raw, &h)
+		} else if mode == ZstdCompressWithDelta {
+			ds := deltaSample{}
+			if err = cbor.Unmarshal(raw, &ds); err == nil {
+				s := NewSample()
+				err = delta.apply(&ds, &s)
+			}
+		} else {
+			s := NewSample()
+			err = s.Unmarshal(raw)
+		}
+		if err != nil {
+			bad(fmt.Sprintf("decode: %s", err))
+			continue
+		}
+
+		if kind == SampleRecord {
+			if mode != ZstdCompressWithDict || offset != 0 {
+				raw = nil
+			}
+			samples[len(samples)-1] = frame{idx, raw, true}
+			delta.pos = len(samples) - 1
+		}
+		good = append(good, goodFrame{idx, dict})
+		end = max(end, idx.Offset+idx.Len)
+	}
+	r.Good = len(good)
+	r.DataTail = dataSize - end
+	return r, good, nil
+}
+
+// repairShard rewrite index file with good frames and truncate data file
+// after last good frame. dict offset is recalculated since bad frames
+// were dropped.
+func repairShard(r *FsckResult, good []goodFrame) error {
+
+	// file being written by etop record is also locked
+	lockFile, err := os.Open(r.Index)
+	if err != nil {
+		return err
+	}
+	defer lockFile.Close()
+	if syscall.Flock(int(lockFile.Fd()), syscall.LOCK_EX|syscall.LOCK_NB) != nil {
+		return fmt.Errorf("can not acquire lock for file %s", r.Index)
+	}
+
+	buf := &bytes.Buffer{}
+	n := 0
+	pos := map[int64]int{} // data offset of sample frame -> new position
+	for _, f := range good {
+		idx := f.idx
+		if idx.RecordKind() == SampleRecord {
+			if f.dict != -1 {
+				// dict is always kept since frame is good
+				mode, _ := idx.CompressMode()
+				idx.SetCompressMode(mode, uint32(n-pos[f.dict]))
+			}
+			pos[idx.Offset] = n
+			n++
+		}
+		idx.CRC = crc32.ChecksumIEEE((*[32]byte)(unsafe.Pointer(&idx))[:28])
+		buf.Write(idx.Marshal())
+	}
+
+	tmp := r.Index + ".fsck"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, r.Index); err != nil {
+		return err
+	}
+	if r.DataTail != 0 {
+		info, err := os.Stat(r.Data)
+		if err != nil {
+			return err
+		}
+		if err := os.Truncate(r.Data, info.Size()-r.DataTail); err != nil {
+			return err
+		}
+	}
+	r.Repaired = true
+	return nil
+}
@@ -0,0 +1,156 @@
+package store
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFsck(t *testing.T) {
+	dir := t.TempDir()
+
+	writeStore, err := NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+		WithWriteOnly(ZstdCompressWithDict, 4),
+	)
+	if err != nil {
+		t.Fatalf("new writeStore: %s\n", err)
+	}
+	base := int64(1697760000)
+	for i := int64(0); i < 6; i++ {
+		s := NewSample()
+		s.TimeStamp = base + i*5
+		s.HostName = "fsck"
+		if _, err := writeStore.WriteSample(&s); err != nil {
+			t.Fatalf("write sample: %s\n", err)
+		}
+		if i == 2 {
+			e := Event{TimeStamp: s.TimeStamp, Kind: EventStart, Severity: "info"}
+			if err := writeStore.WriteEvent(&e); err != nil {
+				t.Fatalf("write event: %s\n", err)
+			}
+		}
+	}
+	writeStore.Close()
+
+	results, err := Fsck(dir, false, slog.Default())
+	if err != nil {
+		t.Fatalf("fsck: %s\n", err)
+	}
+	if len(results) != 1 || !results[0].OK() || results[0].Good != 7 {
+		t.Fatalf("got %+v, but want 7 good frames", results)
+	}
+
+	// corrupt 2nd sample, which is compressed with dict of 1st sample,
+	// and append partial frame and partial index as power loss
+	idxFile := filepath.Join(dir, "index_01697760000")
+	dataFile := filepath.Join(dir, "data_01697760000")
+	b, _ := os.ReadFile(idxFile)
+	idx := Index{}
+	idx.Unmarshal(b[sizeIndex:])
+	data, _ := os.ReadFile(dataFile)
+	for i := idx.Offset; i < idx.Offset+idx.Len; i++ {
+		data[i] = 0xff
+	}
+	data = append(data, 1, 2, 3)
+	os.WriteFile(dataFile, data, 0644)
+	os.WriteFile(idxFile, append(b, 1, 2), 0644)
+
+	results, err = Fsck(dir, true, slog.Default())
+	if err != nil {
+		t.Fatalf("fsck: %s\n", err)
+	}
+	r := results[0]
+	if r.Good != 6 || len(r.Bad) != 1 || r.DataTail != 3 || r.IndexTail != 2 || !r.Repaired {
+		t.Fatalf("got %s", r.String())
+	}
+	if r.Bad[0].TimeStamp != base+5 {
+		t.Errorf("got bad timestamp %d, but want %d", r.Bad[0].TimeStamp, base+5)
+	}
+
+	results, err = Fsck(dir, false, slog.Default())
+	if err != nil {
+		t.Fatalf("fsck: %s\n", err)
+	}
+	if !results[0].OK() || results[0].Good != 6 {
+		t.Fatalf("got %s after repair", results[0].String())
+	}
+
+	// dict offset of 3rd and 4th sample was changed
+	readStore, err := NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+	)
+	if err != nil {
+		t.Fatalf("new readStore: %s\n", err)
+	}
+	got := []int64{}
+	for {
+		s := NewSample()
+		if err := readStore.NextSample(1, &s); err != nil {
+			break
+		}
+		if s.HostName != "fsck" {
+			t.Errorf("got hostname %q, but want fsck", s.HostName)
+		}
+		got = append(got, s.TimeStamp)
+	}
+	if len(got) != 5 || got[1] != base+10 {
+		t.Errorf("got samples %v after repair", got)
+	}
+}
+
+func TestFsckCorruptIndex(t *testing.T) {
+	dir := t.TempDir()
+
+	writeStore, err := NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+		WithWriteOnly(ZstdCompressWithDict, 4),
+	)
+	if err != nil {
+		t.Fatalf("new writeStore: %s\n", err)
+	}
+	base := int64(1697760000)
+	for i := int64(0); i < 6; i++ {
+		s := NewSample()
+		s.TimeStamp = base + i*5
+		s.HostName = "fsck"
+		if _, err := writeStore.WriteSample(&s); err != nil {
+			t.Fatalf("write sample: %s\n", err)
+		}
+	}
+	writeStore.Close()
+
+	// index of 2nd sample fail crc, 3rd and 4th sample still find dict
+	idxFile := filepath.Join(dir, "index_01697760000")
+	b, _ := os.ReadFile(idxFile)
+	b[sizeIndex] ^= 0xff
+	os.WriteFile(idxFile, b, 0644)
+
+	results, err := Fsck(dir, true, slog.Default())
+	if err != nil {
+		t.Fatalf("fsck: %s\n", err)
+	}
+	r := results[0]
+	if r.Good != 5 || len(r.Bad) != 1 || r.Bad[0].Reason != ErrIndexCorrupt.Error() || !r.Repaired {
+		t.Fatalf("got %s", r.String())
+	}
+
+	readStore, err := NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+	)
+	if err != nil {
+		t.Fatalf("new readStore: %s\n", err)
+	}
+	got := []int64{}
+	for {
+		s := NewSample()
+		if err := readStore.NextSample(1, &s); err != nil {
+			break
+		}
+		got = append(got, s.TimeStamp-base)
+	}
+	if len(got) != 5 || got[1] != 10 || got[2] != 15 {
+		t.Errorf("got samples %v after repair", got)
+	}
+}
@@ -0,0 +1,291 @@
+package store
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/xixiliguo/etop/version"
+)
+
+// FormatVersion is version of data file written by this binary. bump it
+// and register migration if Sample is changed incompatibly
+const FormatVersion = uint32(10)
+
+const dataMagic = "ETOPDATA"
+
+var ErrNewerFormat = errors.New("data file is newer than etop")
+
+// Header is at the beginning of data file since format 2, it is magic,
+// length of encoded header (uint32) and cbor encoded Header. data file
+// without header is format 1. etop appending to data file of older format
+// write its header as FormatRecord, see loadFormats
+type Header struct {
+	FormatVersion uint32
+	WriterVersion string // version of etop which create data file
+	HostName      string
+	CompressMode  uint32
+	SchemaHash    string // hash of Sample struct, see SchemaHash
+	size          int64  // bytes of header in data file
+}
+
+func newHeader(mode uint32) Header {
+	host, _ := os.Hostname()
+	return Header{
+		FormatVersion: FormatVersion,
+		WriterVersion: version.Version,
+		HostName:      host,
+		CompressMode:  mode,
+		SchemaHash:    SchemaHash(),
+	}
+}
+
+func (h *Header) Marshal() ([]byte, error) {
+	b, err := cbor.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBufferString(dataMagic)
+	binary.Write(buf, binary.LittleEndian, uint32(len(b)))
+	buf.Write(b)
+	h.size = int64(buf.Len())
+	return buf.Bytes(), nil
+}
+
+// readHeader read header from data file, header of format 1 is returned
+// if r has no header
+func readHeader(r io.ReaderAt) (Header, error) {
+	h := Header{FormatVersion: 1}
+	prefix := make([]byte, len(dataMagic)+4)
+	n, err := r.ReadAt(prefix, 0)
+	if err != nil && err != io.EOF {
+		return h, err
+	}
+	if n < len(prefix) || string(prefix[:len(dataMagic)]) != dataMagic {
+		return h, nil
+	}
+	b := make([]byte, binary.LittleEndian.Uint32(prefix[len(dataMagic):]))
+	if _, err := r.ReadAt(b, int64(len(prefix))); err != nil {
+		return h, fmt.Errorf("read header: %w", err)
+	}
+	if err := cbor.Unmarshal(b, &h); err != nil {
+		return h, fmt.Errorf("decode header: %w", err)
+	}
+	h.size = int64(len(prefix) + len(b))
+	return h, nil
+}
+
+// check return ErrNewerFormat if data file can not be read by this binary
+func (h *Header) check(name string) error {
+	if h.FormatVersion > FormatVersion {
+		return fmt.Errorf("%w: %s is format %d written by etop %s, but etop %s only support format up to %d",
+			ErrNewerFormat, name, h.FormatVersion, h.WriterVersion, version.Version, FormatVersion)
+	}
+	return nil
+}
+
+// frameFormat is format of frames after offset in data file
+type frameFormat struct {
+	offset  int64
+	version uint32
+}
+
+// loadFormats read FormatRecord of shard. etop appending to data file of
+// older format write its header as FormatRecord first, so that frames
+// after it are not migrated as frames of the format in data file header
+func (local *LocalStore) loadFormats(shard int64) error {
+	local.formats = nil
+	records, ino, err := local.shardRecords(shard)
+	if err != nil {
+		return err
+	}
+	if ino != local.dataIno {
+		// replaced by downsample after data file was opened
+		return fmt.Errorf("%w: shard %d is replaced while reading", ErrDataCorrupt, shard)
+	}
+	for _, idx := range records {
+		if idx.RecordKind() != FormatRecord {
+			continue
+		}
+		b := make([]byte, idx.Len)
+		if err := local.getDataBytes(idx, &b); err != nil {
+			return err
+		}
+		h := Header{}
+		if err := cbor.Unmarshal(b, &h); err != nil {
+			return fmt.Errorf("%s: decode format record: %w", local.Data.Name(), err)
+		}
+		if err := h.check(local.Data.Name()); err != nil {
+			return err
+		}
+		local.formats = append(local.formats, frameFormat{idx.Offset, h.FormatVersion})
+	}
+	return nil
+}
+
+// formatOf return format of frame which idx point to, it is the format of
+// last FormatRecord before it, or format in data file header
+func (local *LocalStore) formatOf(idx Index) uint32 {
+	f := frameFormat{-1, local.header.FormatVersion}
+	for _, ff := range local.formats {
+		if ff.offset < idx.Offset && ff.offset > f.offset {
+			f = ff
+		}
+	}
+	return f.version
+}
+
+// writeFormat write header of this binary as FormatRecord if frames
+// appended to current data file would be read as older format
+func (local *LocalStore) writeFormat() error {
+	if local.formatOf(Index{Offset: local.DataOffset}) == FormatVersion {
+		return nil
+	}
+	h := newHeader(local.mode)
+	b, err := cbor.Marshal(&h)
+	if err != nil {
+		return err
+	}
+	if err := local.writeRecord(local.shard, FormatRecord, b); err != nil {
+		return err
+	}
+	local.formats = append(local.formats, frameFormat{local.DataOffset - int64(len(b)), FormatVersion})
+	return nil
+}
+
+func (h Header) String() string {
+	if h.FormatVersion == 1 {
+		return "format 1 (no header)"
+	}
+	return fmt.Sprintf("format %d written by etop %s on %s", h.FormatVersion, h.WriterVersion, h.HostName)
+}
+
+// checkHeaders check header of newest data file in path, so that store
+// written by newer etop is rejected early. other data files are checked
+// when they are opened
+func checkHeaders(path string) error {
+	shards, err := listShards(path)
+	if err != nil || len(shards) == 0 {
+		return err
+	}
+	name := filepath.Join(path, fmt.Sprintf("data_%011d", shards[len(shards)-1]))
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	h, err := readHeader(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return h.check(name)
+}
+
+// migrations upgrade sample decoded from older format, key is format
+// which is upgraded from, and result is the next format
+var migrations = map[uint32]func(s *Sample){
+	// format 2 only add header, frames are the same
+	1: func(s *Sample) {},
+	// format 3 add Sample.Missing, all modules were collected before
+	2: func(s *Sample) {},
+	// format 4 add frames of ZstdCompressWithDelta, sample is the same
+	3: func(s *Sample) {},
+	// format 5 add SystemSample.Etop, overhead of etop was not recorded
+	4: func(s *Sample) {},
+	// format 6 add SystemSample.Pressure, which was not collected before
+	5: func(s *Sample) {
+		if s.Has(ModulePressure) {
+			s.Missing = append(s.Missing, ModulePressure)
+		}
+	},
+	// format 7 add SystemSample.Interrupts and Softirqs
+	6: func(s *Sample) {
+		if s.Has(ModuleInterrupts) {
+			s.Missing = append(s.Missing, ModuleInterrupts)
+		}
+		if s.Has(ModuleSoftirqs) {
+			s.Missing = append(s.Missing, ModuleSoftirqs)
+		}
+	},
+	// format 8 add SystemSample.NetSNMP
+	7: func(s *Sample) {
+		if s.Has(ModuleSNMP) {
+			s.Missing = append(s.Missing, ModuleSNMP)
+		}
+	},
+	// format 9 add SystemSample.Sockets
+	8: func(s *Sample) {
+		if s.Has(ModuleSockets) {
+			s.Missing = append(s.Missing, ModuleSockets)
+		}
+	},
+	// format 10 add SystemSample.Filesystems
+	9: func(s *Sample) {
+		if s.Has(ModuleFilesystem) {
+			s.Missing = append(s.Missing, ModuleFilesystem)
+		}
+	},
+}
+
+// migrate upgrade s decoded from data file of format from
+func migrate(s *Sample, from uint32) {
+	for v := from; v < FormatVersion; v++ {
+		if m, ok := migrations[v]; ok {
+			m(s)
+		}
+	}
+}
+
+// SchemaHash return hash of name, kind and cbor tag of all fields in
+// Sample recursively, which decide what frame is decoded to
+func SchemaHash() string {
+	var b strings.Builder
+	seen := map[reflect.Type]bool{} // structs being walked
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Array:
+			b.WriteString(t.Kind().String() + " ")
+			walk(t.Elem())
+		case reflect.Map:
+			b.WriteString("map[")
+			walk(t.Key())
+			b.WriteString("]")
+			walk(t.Elem())
+		case reflect.Struct:
+			if seen[t] {
+				b.WriteString("recursive")
+				return
+			}
+			seen[t] = true
+			b.WriteString("{")
+			for i := 0; i < t.NumField(); i++ {
+				f := t.Field(i)
+				if !f.IsExported() {
+					continue
+				}
+				fmt.Fprintf(&b, "%s %q ", f.Name, f.Tag.Get("cbor"))
+				walk(f.Type)
+				b.WriteString(";")
+			}
+			b.WriteString("}")
+			seen[t] = false
+		default:
+			b.WriteString(t.Kind().String())
+		}
+	}
+	walk(reflect.TypeOf(Sample{}))
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"errors"
+	"hash/crc32"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"unsafe"
+
+	"github.com/xixiliguo/etop/version"
+)
+
+func TestSchemaHash(t *testing.T) {
+	// if it fails, Sample was changed. bump FormatVersion and register
+	// migration if old data file can not be decoded to new Sample as is,
+	// then update the hash
+	want := "1def39dae6b969b3"
+	if got := SchemaHash(); got != want {
+		t.Errorf("got schema hash %s, but want %s", got, want)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	// module already missing in old sample is not added again
+	s := NewSample()
+	s.Missing = []string{ModuleSNMP}
+	migrate(&s, 5)
+	want := []string{ModuleSNMP, ModulePressure, ModuleInterrupts, ModuleSoftirqs, ModuleSockets, ModuleFilesystem}
+	if !reflect.DeepEqual(s.Missing, want) {
+		t.Errorf("got missing %v, but want %v", s.Missing, want)
+	}
+}
+
+func TestHeader(t *testing.T) {
+	dir := t.TempDir()
+	shard := int64(1697760000)
+
+	// data file of format 1 has no header
+	s := NewSample()
+	s.TimeStamp = shard
+	s.HostName = "legacy"
+	b, _ := s.Marshal()
+	idx := Index{TimeStamp: shard, Offset: 0, Len: int64(len(b))}
+	idx.SetCompressMode(NoCompress, 0)
+	idx.CRC = crc32.ChecksumIEEE((*[32]byte)(unsafe.Pointer(&idx))[:28])
+	os.WriteFile(filepath.Join(dir, "data_01697760000"), b, 0644)
+	os.WriteFile(filepath.Join(dir, "index_01697760000"), idx.Marshal(), 0644)
+
+	// append to it, and the next shard is created with header
+	w, err := NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+		WithWriteOnly(ZstdCompress, 0),
+	)
+	if err != nil {
+		t.Fatalf("new writeStore: %s\n", err)
+	}
+	for _, ts := range []int64{shard + 5, shard + ShardTime} {
+		s := NewSample()
+		s.TimeStamp = ts
+		s.HostName = "legacy"
+		if _, err := w.WriteSample(&s); err != nil {
+			t.Fatalf("write sample: %s\n", err)
+		}
+	}
+	w.Close()
+
+	// format is recorded once even if legacy data file is appended again
+	w, err = NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+		WithWriteOnly(ZstdCompress, 0),
+	)
+	if err != nil {
+		t.Fatalf("new writeStore: %s\n", err)
+	}
+	s = NewSample()
+	s.TimeStamp = shard + 6
+	s.HostName = "legacy"
+	if _, err := w.WriteSample(&s); err != nil {
+		t.Fatalf("write sample: %s\n", err)
+	}
+	w.Close()
+	_, records, _ := readShardFrames(dir, shard)
+	if len(records) != 1 || records[0].RecordKind() != FormatRecord {
+		t.Errorf("got records %+v, but want one format record", records)
+	}
+
+	f, _ := os.Open(filepath.Join(dir, "data_01697846400"))
+	h, err := readHeader(f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("read header: %s", err)
+	}
+	if h.FormatVersion != FormatVersion || h.WriterVersion != version.Version ||
+		h.CompressMode != ZstdCompress || h.SchemaHash != SchemaHash() || h.size == 0 {
+		t.Errorf("got unexpected header %+v", h)
+	}
+
+	r, err := NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+	)
+	if err != nil {
+		t.Fatalf("new readStore: %s\n", err)
+	}
+	got := []int64{}
+	for {
+		s := NewSample()
+		if err := r.NextSample(1, &s); err != nil {
+			break
+		}
+		if s.HostName != "legacy" {
+			t.Errorf("got hostname %q, but want legacy", s.HostName)
+		}
+		// pressure and interrupts were not collected by old format, but
+		// sample appended to legacy data file has them
+		legacy := s.TimeStamp == shard
+		for _, m := range []string{ModulePressure, ModuleInterrupts, ModuleSoftirqs, ModuleSNMP, ModuleSockets, ModuleFilesystem} {
+			if legacy == s.Has(m) {
+				t.Errorf("sample at %d has %s: %t", s.TimeStamp, m, s.Has(m))
+			}
+		}
+		got = append(got, s.TimeStamp-shard)
+	}
+	r.Close()
+	if len(got) != 4 || got[3] != ShardTime {
+		t.Errorf("got samples %v", got)
+	}
+
+	// data file written by newer etop
+	newer := Header{FormatVersion: FormatVersion + 1, WriterVersion: "9.9.9"}
+	b, _ = newer.Marshal()
+	os.WriteFile(filepath.Join(dir, "data_01697932800"), b, 0644)
+	os.WriteFile(filepath.Join(dir, "index_01697932800"), nil, 0644)
+	if _, err := NewLocalStore(WithPathAndLogger(dir, slog.Default())); !errors.Is(err, ErrNewerFormat) {
+		t.Errorf("got %v, but want %s", err, ErrNewerFormat)
+	}
+	w, _ = NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+		WithWriteOnly(ZstdCompress, 0),
+	)
+	defer w.Close()
+	s = NewSample()
+	s.TimeStamp = shard + 2*ShardTime
+	if _, err := w.WriteSample(&s); !errors.Is(err, ErrNewerFormat) {
+		t.Errorf("got %v, but want %s", err, ErrNewerFormat)
+	}
+}
@@ -0,0 +1,323 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// maxCachedShards is the number of shard index kept in memory, so that
+// moving back and forth around boundary of shards does not read index
+// file again
+const maxCachedShards = 4
+
+// listShards return shards which have index file in path, order by time
+func listShards(path string) ([]int64, error) {
+	ds, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	shards := []int64{}
+	for _, d := range ds {
+		if !d.Type().IsRegular() || !strings.HasPrefix(d.Name(), "index_") {
+			continue
+		}
+		var shard int64
+		if _, err := fmt.Sscanf(d.Name(), "index_%d", &shard); err != nil {
+			return nil, fmt.Errorf("%s: %w", d.Name(), err)
+		}
+		shards = append(shards, shard)
+	}
+	sort.Slice(shards, func(i, j int) bool {
+		return shards[i] < shards[j]
+	})
+	return shards, nil
+}
+
+// readShardFrames read index file of shard, index of samples and other
+// records (e.g events) are returned separately, order by timestamp
+func readShardFrames(path string, shard int64) ([]Index, []Index, error) {
+
+	b, err := os.ReadFile(filepath.Join(path, fmt.Sprintf("index_%011d", shard)))
+	if err != nil {
+		return nil, nil, err
+	}
+	dataSize := int64(0)
+	if info, err := os.Stat(filepath.Join(path, fmt.Sprintf("data_%011d", shard))); err == nil {
+		dataSize = info.Size()
+	} else if !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+	frames, _ := validIndexFrames(b, dataSize)
+	idxs := []Index{}
+	records := []Index{}
+	for _, index := range frames {
+		if index.RecordKind() == SampleRecord {
+			idxs = append(idxs, index)
+		} else {
+			records = append(records, index)
+		}
+	}
+	sort.SliceStable(idxs, func(i, j int) bool {
+		return idxs[i].TimeStamp < idxs[j].TimeStamp
+	})
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].TimeStamp < records[j].TimeStamp
+	})
+	return idxs, records, nil
+}
+
+// shardIndex return index of samples of shard at pos of local.shards,
+// it is read from index file if not cached
+func (local *LocalStore) shardIndex(pos int) ([]Index, error) {
+	if pos < 0 || pos >= len(local.shards) {
+		return nil, nil
+	}
+	shard := local.shards[pos]
+	if f, ok := local.cache[shard]; ok {
+		return f.idxs, nil
+	}
+	f, err := loadShardFrames(local.Path, shard)
+	if err != nil {
+		return nil, err
+	}
+	if len(local.cache) >= maxCachedShards {
+		for s := range local.cache {
+			if s != local.shard {
+				delete(local.cache, s)
+			}
+		}
+	}
+	local.cache[shard] = shardFrames{idxs: f.idxs, dataIno: f.dataIno}
+	return f.idxs, nil
+}
+
+// shardFrames is index of one shard, and inode of data file it point to
+type shardFrames struct {
+	idxs    []Index
+	records []Index
+	dataIno uint64
+}
+
+// replaceWait is how long shard being replaced is waited. marker of
+// replacing interrupted by crash is left until next etop record
+const replaceWait = time.Second
+
+// loadShardFrames read index of shard like readShardFrames, and inode of
+// data file which the index belong to. index is not read while shard is
+// being replaced by downsample, see replaceShard
+func loadShardFrames(path string, shard int64) (shardFrames, error) {
+	replacing := func() bool {
+		_, err := os.Stat(replaceMarker(path, shard))
+		return err == nil
+	}
+	deadline := time.Now().Add(replaceWait)
+	for {
+		if !replacing() || time.Now().After(deadline) {
+			ino := dataInode(path, shard)
+			idxs, records, err := readShardFrames(path, shard)
+			if err != nil {
+				return shardFrames{}, err
+			}
+			if (!replacing() && dataInode(path, shard) == ino) || time.Now().After(deadline) {
+				return shardFrames{idxs, records, ino}, nil
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// dataInode return inode of data file of shard, 0 if it does not exist
+func dataInode(path string, shard int64) uint64 {
+	info, err := os.Stat(filepath.Join(path, fmt.Sprintf("data_%011d", shard)))
+	if err != nil {
+		return 0
+	}
+	return inodeOf(info)
+}
+
+func inodeOf(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return st.Ino
+	}
+	return 0
+}
+
+// refresh read shards and index of last shard again, so that new data
+// written after opening is available. old shards may be deleted, so it
+// return new position of shard at pos
+func (local *LocalStore) refresh(pos int) (int, error) {
+	shards, err := listShards(local.Path)
+	if err != nil {
+		return 0, err
+	}
+	remap := func(pos int) int {
+		if pos < 0 || pos >= len(local.shards) {
+			return 0
+		}
+		shard := local.shards[pos]
+		return sort.Search(len(shards), func(i int) bool {
+			return shards[i] >= shard
+		})
+	}
+	if len(local.shards) != 0 {
+		delete(local.cache, local.shards[len(local.shards)-1])
+	}
+	pos, local.shardPos = remap(pos), remap(local.shardPos)
+	local.shards = shards
+	// etop record may be restarted and append FormatRecord to current shard
+	if local.Data != nil {
+		if err := local.loadFormats(local.shard); err != nil && !os.IsNotExist(err) {
+			return 0, err
+		}
+	}
+	return pos, nil
+}
+
+// locate move target, which is position relative to shard at pos, into
+// the shard which has it. ErrOutOfRange is returned if there is no such
+// sample
+func (local *LocalStore) locate(pos, target int) (int, []Index, int, error) {
+	idxs, err := local.shardIndex(pos)
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	for target < 0 {
+		if pos <= 0 {
+			return 0, nil, 0, ErrOutOfRange
+		}
+		pos--
+		if idxs, err = local.shardIndex(pos); err != nil {
+			return 0, nil, 0, err
+		}
+		target += len(idxs)
+	}
+	for target >= len(idxs) {
+		if pos >= len(local.shards)-1 {
+			// try to read file again and check if have new data available
+			if pos, err = local.refresh(pos); err != nil {
+				return 0, nil, 0, err
+			}
+			if idxs, err = local.shardIndex(pos); err != nil {
+				return 0, nil, 0, err
+			}
+			if target < len(idxs) {
+				break
+			}
+			if pos >= len(local.shards)-1 {
+				return 0, nil, 0, ErrOutOfRange
+			}
+		}
+		target -= len(idxs)
+		pos++
+		if idxs, err = local.shardIndex(pos); err != nil {
+			return 0, nil, 0, err
+		}
+	}
+	return pos, idxs, target, nil
+}
+
+// moveTo get sample at target of shard at pos, and make it current. if
+// shard was replaced by downsample, index and data file are reloaded and
+// target is moved to first sample not before it
+func (local *LocalStore) moveTo(pos int, idxs []Index, target int, sample *Sample) error {
+	shard := local.shards[pos]
+	if shard != local.shard || local.dataIno != local.cache[shard].dataIno {
+		if err := local.changeFile(shard, false); err != nil {
+			return err
+		}
+	}
+	if local.dataIno != local.cache[shard].dataIno {
+		timestamp := idxs[target].TimeStamp
+		delete(local.cache, shard)
+		var err error
+		if idxs, err = local.shardIndex(pos); err != nil {
+			return err
+		}
+		if local.dataIno != local.cache[shard].dataIno {
+			return fmt.Errorf("%w: shard %d is replaced while reading", ErrDataCorrupt, shard)
+		}
+		target = sort.Search(len(idxs), func(i int) bool {
+			return idxs[i].TimeStamp >= timestamp
+		})
+		if target == len(idxs) {
+			return ErrOutOfRange
+		}
+	}
+	local.shardPos = pos
+	local.idxs = idxs
+	if err := local.getSample(target, sample); err != nil {
+		return err
+	}
+	local.curIdx = target
+	return nil
+}
+
+// search return position of first sample whose timestamp >= timestamp,
+// target may be len of index of shard at pos if there is no such sample
+// in it
+func (local *LocalStore) search(timestamp int64) (int, int, error) {
+	shard := calcshard(timestamp)
+	pos := sort.Search(len(local.shards), func(i int) bool {
+		return local.shards[i] >= shard
+	})
+	if pos == len(local.shards) {
+		if _, err := local.refresh(0); err != nil {
+			return 0, 0, err
+		}
+		pos = sort.Search(len(local.shards), func(i int) bool {
+			return local.shards[i] >= shard
+		})
+		if pos == len(local.shards) {
+			// after all samples
+			pos = max(len(local.shards)-1, 0)
+			idxs, err := local.shardIndex(pos)
+			return pos, len(idxs), err
+		}
+	}
+	idxs, err := local.shardIndex(pos)
+	if err != nil {
+		return 0, 0, err
+	}
+	target := sort.Search(len(idxs), func(i int) bool {
+		return idxs[i].TimeStamp >= timestamp
+	})
+	return pos, target, nil
+}
+
+// last return position of last sample
+func (local *LocalStore) last() (int, []Index, int, error) {
+	if _, err := local.refresh(0); err != nil {
+		return 0, nil, 0, err
+	}
+	for pos := len(local.shards) - 1; pos >= 0; pos-- {
+		idxs, err := local.shardIndex(pos)
+		if err != nil {
+			return 0, nil, 0, err
+		}
+		if len(idxs) != 0 {
+			return pos, idxs, len(idxs) - 1, nil
+		}
+	}
+	return 0, nil, 0, ErrOutOfRange
+}
+
+// seek set current position to first sample whose timestamp >= timestamp,
+// so that NextSample(0) get it. -1 means before first sample, same as new
+// LocalStore
+func (local *LocalStore) seek(timestamp int64) error {
+	if timestamp == -1 {
+		local.shardPos, local.curIdx = 0, -1
+		return nil
+	}
+	pos, target, err := local.search(timestamp)
+	if err != nil {
+		return err
+	}
+	local.shardPos, local.curIdx = pos, target
+	return nil
+}
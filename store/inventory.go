@@ -0,0 +1,186 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/xixiliguo/etop/procfs"
+	"github.com/xixiliguo/etop/util"
+	"golang.org/x/sys/unix"
+)
+
+// Inventory is static metadata of host, which rarely change. it is
+// stored as InventoryRecord at start of every shard and when it change,
+// so that samples can be read without knowing the host.
+type Inventory struct {
+	TimeStamp     int64 // unix time when inventory was collected
+	HostName      string
+	KernelVersion string
+	Cmdline       string
+	MemTotal      uint64 // bytes
+	procfs.CPUTopology
+	Nodes []procfs.NumaNode
+	Disks []procfs.BlockDevice
+	NICs  []procfs.NetDevice
+}
+
+func (inv *Inventory) Marshal() ([]byte, error) {
+	return cbor.Marshal(inv)
+}
+
+func (inv *Inventory) Unmarshal(b []byte) error {
+	return cbor.Unmarshal(b, inv)
+}
+
+// Equal return true if inv and other are the same except TimeStamp
+func (inv *Inventory) Equal(other *Inventory) bool {
+	a, b := *inv, *other
+	a.TimeStamp, b.TimeStamp = 0, 0
+	return reflect.DeepEqual(a, b)
+}
+
+// InventoryItem is one part of inventory, e.g one disk
+type InventoryItem struct {
+	Kind   string // host, cpu, node, disk or nic
+	Name   string
+	Detail string
+}
+
+// Items return inventory as list, order by kind
+func (inv *Inventory) Items() []InventoryItem {
+	items := []InventoryItem{
+		{"host", inv.HostName, fmt.Sprintf("kernel %s, memory %s, cmdline %s",
+			inv.KernelVersion, util.GetHumanSize(inv.MemTotal), inv.Cmdline)},
+		{"cpu", inv.Model, fmt.Sprintf("%d sockets, %d cores, %d threads",
+			inv.Sockets, inv.Cores, inv.Threads)},
+	}
+	for _, n := range inv.Nodes {
+		items = append(items, InventoryItem{"node", fmt.Sprintf("node%d", n.ID),
+			fmt.Sprintf("cpus %s, memory %s", n.CPUs, util.GetHumanSize(n.MemTotal))})
+	}
+	for _, d := range inv.Disks {
+		kind := "ssd"
+		if d.Rotational {
+			kind = "hdd"
+		}
+		model := d.Model
+		if model == "" {
+			model = "unknown model"
+		}
+		items = append(items, InventoryItem{"disk", d.Name,
+			fmt.Sprintf("%s, %s, %s", model, kind, util.GetHumanSize(d.Size))})
+	}
+	for _, n := range inv.NICs {
+		speed := "unknown speed"
+		if n.Speed > 0 {
+			speed = fmt.Sprintf("%dMb/s", n.Speed)
+		}
+		items = append(items, InventoryItem{"nic", n.Name,
+			fmt.Sprintf("%s, %s, mtu %d, %s", n.Driver, speed, n.MTU, n.Address)})
+	}
+	return items
+}
+
+func (inv *Inventory) String() string {
+	b := strings.Builder{}
+	for _, item := range inv.Items() {
+		fmt.Fprintf(&b, "%-5s: %s %s\n", item.Kind, item.Name, item.Detail)
+	}
+	return b.String()
+}
+
+// CollectInventory collect inventory of host. parts which can not be
+// collected are left empty and their errors are returned together
+func CollectInventory(inv *Inventory) error {
+
+	u := unix.Utsname{}
+	unix.Uname(&u)
+	inv.HostName = unix.ByteSliceToString(u.Nodename[:])
+	inv.KernelVersion = unix.ByteSliceToString(u.Release[:])
+
+	fs := procfs.NewFS("")
+	sys := procfs.NewSysFS("")
+	errs := []error{}
+	var err error
+	if inv.Cmdline, err = fs.Cmdline(); err != nil {
+		errs = append(errs, err)
+	}
+	if m, err := fs.Meminfo(); err != nil {
+		errs = append(errs, err)
+	} else {
+		// meminfo is in kB
+		inv.MemTotal = m.MemTotal * 1024
+	}
+	if inv.CPUTopology, err = sys.CPUTopology(); err != nil {
+		errs = append(errs, err)
+	}
+	if inv.Model, err = fs.CPUModel(); err != nil {
+		errs = append(errs, err)
+	}
+	if inv.Nodes, err = sys.NumaNodes(); err != nil {
+		errs = append(errs, err)
+	}
+	if inv.Disks, err = sys.BlockDevices(); err != nil {
+		errs = append(errs, err)
+	}
+	if inv.NICs, err = sys.NetDevices(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// InventoryStore is implemented by store which keep inventory of host
+type InventoryStore interface {
+	// Inventories return inventory in effect at begin, and inventories
+	// recorded after begin until end (included), order by timestamp
+	Inventories(begin, end int64) ([]Inventory, error)
+}
+
+// WriteInventory write inv into shard file of inv.TimeStamp, without compress.
+func (local *LocalStore) WriteInventory(inv *Inventory) error {
+	b, err := inv.Marshal()
+	if err != nil {
+		return err
+	}
+	return local.writeRecord(inv.TimeStamp, InventoryRecord, b)
+}
+
+func (local *LocalStore) Inventories(begin, end int64) ([]Inventory, error) {
+
+	shards, err := listShards(local.Path)
+	if err != nil {
+		return nil, err
+	}
+	invs := []Inventory{}
+	read := func(shards []int64, begin, end int64) error {
+		return local.readRecords(shards, InventoryRecord, begin, end, func(idx Index, b []byte) error {
+			inv := Inventory{}
+			if err := inv.Unmarshal(b); err != nil {
+				return err
+			}
+			invs = append(invs, inv)
+			return nil
+		})
+	}
+
+	// every shard start with inventory, so the one in effect is usually
+	// found in shard of begin. shards written by old version have nothing
+	for i := len(shards) - 1; i >= 0 && len(invs) == 0; i-- {
+		if shards[i] > begin {
+			continue
+		}
+		if err := read(shards[i:i+1], shards[i], begin); err != nil {
+			return nil, err
+		}
+	}
+	if len(invs) != 0 {
+		invs = invs[len(invs)-1:]
+	}
+	if err := read(shards, begin+1, end); err != nil {
+		return nil, err
+	}
+	return invs, nil
+}
@@ -0,0 +1,162 @@
+package store
+
+import (
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/xixiliguo/etop/procfs"
+)
+
+func TestInventories(t *testing.T) {
+	dir := t.TempDir()
+
+	writeStore, err := NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+		WithWriteOnly(ZstdCompressWithDict, 2),
+	)
+	if err != nil {
+		t.Fatalf("new writeStore: %s\n", err)
+	}
+	base := int64(1697760000)
+	inv := Inventory{
+		HostName:    "host",
+		MemTotal:    64 << 30,
+		CPUTopology: procfs.CPUTopology{Model: "Xeon", Sockets: 2, Cores: 8, Threads: 16},
+		Nodes:       []procfs.NumaNode{{ID: 0, CPUs: "0-7", MemTotal: 32 << 30}, {ID: 1, CPUs: "8-15", MemTotal: 32 << 30}},
+		Disks:       []procfs.BlockDevice{{Name: "sda", Model: "ST4000", Rotational: true, Size: 4 << 40}},
+		NICs:        []procfs.NetDevice{{Name: "eth0", Driver: "ixgbe", Speed: 10000, MTU: 1500}},
+	}
+	want := []Inventory{}
+	write := func(ts int64, change func(inv *Inventory)) {
+		change(&inv)
+		inv.TimeStamp = ts
+		if err := writeStore.WriteInventory(&inv); err != nil {
+			t.Fatalf("write inventory: %s\n", err)
+		}
+		want = append(want, inv)
+		// slices are shared between inventories
+		inv.NICs = append([]procfs.NetDevice{}, inv.NICs...)
+	}
+	for i := int64(0); i < 6; i++ {
+		s := NewSample()
+		s.TimeStamp = base + i*5
+		if _, err := writeStore.WriteSample(&s); err != nil {
+			t.Fatalf("write sample: %s\n", err)
+		}
+		switch i {
+		case 0:
+			write(s.TimeStamp, func(inv *Inventory) {})
+		case 3:
+			write(s.TimeStamp, func(inv *Inventory) { inv.NICs[0].Speed = 1000 })
+		}
+	}
+	// inventory at start of next shard
+	s := NewSample()
+	s.TimeStamp = base + ShardTime
+	if _, err := writeStore.WriteSample(&s); err != nil {
+		t.Fatalf("write sample: %s\n", err)
+	}
+	write(s.TimeStamp, func(inv *Inventory) {})
+	writeStore.Close()
+
+	readStore, err := NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+	)
+	if err != nil {
+		t.Fatalf("new readStore: %s\n", err)
+	}
+	defer readStore.Close()
+
+	for _, c := range []struct {
+		begin, end int64
+		want       []Inventory
+	}{
+		{base, base + ShardTime, want},
+		{base + 7, base + 20, want[:2]},
+		{base + 15, base + 15, want[1:2]},
+		{base + ShardTime + 100, base + ShardTime + 200, want[2:]},
+		{base - 100, base - 1, []Inventory{}},
+	} {
+		got, err := readStore.Inventories(c.begin, c.end)
+		if err != nil {
+			t.Fatalf("read inventories: %s\n", err)
+		}
+		if diff := cmp.Diff(c.want, got); diff != "" {
+			t.Errorf("inventories between %d and %d mismatch (-want +got):\n%s", c.begin, c.end, diff)
+		}
+	}
+	if want[0].Equal(&want[1]) || !want[1].Equal(&want[2]) {
+		t.Errorf("only timestamp should be ignored by Equal")
+	}
+
+	// inventories are neither samples nor events
+	events, err := readStore.Events(base, base+ShardTime)
+	if err != nil || len(events) != 0 {
+		t.Errorf("got events %v %v, but want nothing", events, err)
+	}
+	result, err := readStore.FileStatInfo()
+	if err != nil {
+		t.Fatalf("file stat info: %s\n", err)
+	}
+	if !strings.Contains(result, "7 samples") || !strings.Contains(result, "disk : sda ST4000, hdd, 4.0 TB") {
+		t.Errorf("unexpected stat info:\n%s", result)
+	}
+
+	srv := httptest.NewServer(NewServer(readStore, slog.Default()))
+	defer srv.Close()
+	remote, err := NewRemoteStore(srv.Listener.Addr().String(), slog.Default())
+	if err != nil {
+		t.Fatalf("new remote store: %s\n", err)
+	}
+	defer remote.Close()
+	got, err := remote.Inventories(base+7, base+20)
+	if err != nil {
+		t.Fatalf("read remote inventories: %s\n", err)
+	}
+	if diff := cmp.Diff(want[:2], got); diff != "" {
+		t.Errorf("remote inventories mismatch (-want +got):\n%s", diff)
+	}
+
+	results, err := Fsck(dir, false, slog.Default())
+	if err != nil {
+		t.Fatalf("fsck: %s\n", err)
+	}
+	for _, r := range results {
+		if !r.OK() {
+			t.Errorf("fsck: %s", r.String())
+		}
+	}
+
+	// inventories are kept by convert
+	if _, err := Convert(dir, ZstdCompressWithDelta, 4, slog.Default()); err != nil {
+		t.Fatalf("convert: %s\n", err)
+	}
+	converted, err := NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+	)
+	if err != nil {
+		t.Fatalf("new readStore: %s\n", err)
+	}
+	defer converted.Close()
+	got, err = converted.Inventories(base, base+ShardTime)
+	if err != nil {
+		t.Fatalf("read inventories: %s\n", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("converted inventories mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCollectInventory(t *testing.T) {
+	inv := Inventory{}
+	if err := CollectInventory(&inv); err != nil {
+		t.Logf("collect inventory: %s", err)
+	}
+	if inv.HostName == "" || inv.KernelVersion == "" || inv.MemTotal == 0 {
+		t.Errorf("got %+v, host and memory should be collected", inv)
+	}
+	t.Logf("\n%s", inv.String())
+}
@@ -27,18 +27,29 @@ var (
 	ErrOutOfRange                   = errors.New("data is out of range")
 	ErrIndexCorrupt                 = errors.New("corrupt index")
 	ErrDataCorrupt                  = errors.New("corrupt data")
+	ErrLocked                       = errors.New("store is locked by another writer")
 	MinimumFreeSpaceForStore uint64 = 500 * (1 << 20) // 500MB
 	ShardTime                       = int64(24 * 60 * 60)
 )
 
+// lockFileName is created in path of store by writer, which hold flock on
+// it until Close. it is never removed, otherwise another writer may lock
+// the removed one while the third one lock new file of the same name
+const lockFileName = "lock"
+
 const (
 	NoCompress = uint32(1 << iota)
 	ZstdCompress
 	ZstdCompressWithDict
+	// processes and cgroups are encoded as delta against previous sample,
+	// dict offset is the distance to first sample of the chain
+	ZstdCompressWithDelta
 )
 const (
 	CompressModeShift = 0
-	CompressModeLen   = 8
+	CompressModeLen   = 5
+	RecordKindShift   = 5
+	RecordKindLen     = 3
 	DictOffsetShift   = 8
 	DictOffsetLen     = 24
 	MaxDictOffset     = 1<<DictOffsetLen - 1
@@ -50,7 +61,7 @@ type Index struct {
 	TimeStamp int64  // unix time when sample was generated
 	Offset    int64  // offset of file where sample is exist
 	Len       int64  // length of one sample
-	Flag      uint32 // compress mode and dict offset
+	Flag      uint32 // compress mode, record kind and dict offset
 	CRC       uint32 // crc for whole index, except CRC self
 }
 
@@ -81,6 +92,15 @@ func (idx *Index) CompressMode() (uint32, uint32) {
 	return mode, offset
 }
 
+// SetRecordKind mark what the data of index is, default is SampleRecord
+func (idx *Index) SetRecordKind(k uint32) {
+	idx.Flag = writeBits(idx.Flag, RecordKindLen, RecordKindShift, k)
+}
+
+func (idx *Index) RecordKind() uint32 {
+	return readBits(idx.Flag, RecordKindLen, RecordKindShift)
+}
+
 func (idx *Index) Marshal() []byte {
 	b := make([]byte, sizeIndex)
 	binary.LittleEndian.PutUint64(b[0:], uint64(idx.TimeStamp))
@@ -130,16 +150,60 @@ func WithWriteOnly(mode, chunk uint32) Option {
 
 func WithExitProcess(log *slog.Logger) Option {
 	return func(local *LocalStore) error {
-		local.exit = NewExitProcess(log)
-		go local.exit.Collect()
+		local.collectors.Exit = NewExitProcess(log)
+		go local.collectors.Exit.Collect()
 		return nil
 	}
 }
 
 func WithCgroupNetStat(log *slog.Logger) Option {
 	return func(local *LocalStore) error {
-		local.c = NewCgroupNetStat(log)
-		local.c.Collect()
+		local.collectors.CgroupNet = NewCgroupNetStat(log)
+		local.collectors.CgroupNet.Collect()
+		return nil
+	}
+}
+
+// WithSync fsync data and index file every n samples or every interval,
+// whichever comes first. 0 disable the corresponding policy, and default
+// is to leave it to kernel writeback
+func WithSync(n uint32, interval time.Duration) Option {
+	return func(local *LocalStore) error {
+		local.syncEvery = n
+		local.syncInterval = interval
+		return nil
+	}
+}
+
+// WithSchedule collect modules by sched instead of all modules in every
+// sample
+func WithSchedule(sched *Schedule) Option {
+	return func(local *LocalStore) error {
+		local.collectors.Schedule = sched
+		return nil
+	}
+}
+
+// WithThreads collect threads of top processes by cpu usage
+func WithThreads(top int) Option {
+	return func(local *LocalStore) error {
+		local.collectors.Threads = NewThreadCollector(top)
+		return nil
+	}
+}
+
+// WithSmaps read smaps_rollup of top processes by rss
+func WithSmaps(top int) Option {
+	return func(local *LocalStore) error {
+		local.collectors.Smaps = NewSmapsCollector(top)
+		return nil
+	}
+}
+
+// WithSkipFSTypes skip filesystems of types instead of DefaultSkipFSTypes
+func WithSkipFSTypes(types []string) Option {
+	return func(local *LocalStore) error {
+		local.collectors.Filesystem = NewFilesystemCollector(types)
 		return nil
 	}
 }
@@ -156,32 +220,54 @@ type LocalStore struct {
 	Log             *slog.Logger
 	DataOffset      int64 // file offset which next sample was written to
 	lastSampleBytes int
+	lastRawBytes    int // bytes of last sample before compress
 	writeOnly       bool
 	mode            uint32 // compress mode
 	// increment after writing one sample
 	// reset to 0 when opening new file or initializing instance of LocalStore
-	next    uint32
-	curDict int64         // timestamp of sample which was used as dict
-	chunk   uint32        // a number of adjacent samples as one group
-	dec     *zstd.Decoder // zstd decoder
-	encDict *zstd.Encoder // zstd encoder with dict
-	decDict *zstd.Decoder // zstd decoder with dict
+	next     uint32
+	curDict  int64         // timestamp of sample which was used as dict
+	chunk    uint32        // a number of adjacent samples as one group
+	dec      *zstd.Decoder // zstd decoder
+	encDict  *zstd.Encoder // zstd encoder with dict
+	decDict  *zstd.Decoder // zstd decoder with dict
+	encDelta *deltaEncoder
+	decDelta *deltaDecoder
 	sync.Mutex
 	closed   bool
+	skipping bool // samples are not written because of low free space
+	dropped  int  // events not written while skipping
 	closeSig chan os.Signal
-	idxs     []Index // all indexs from Path
+	shards   []int64               // all shards from Path, index is loaded on demand
+	cache    map[int64]shardFrames // index of recently used shards
+	records  map[int64]recordIndex // index of events and inventories by shard
+	shardPos int                   // position of current shard in shards
+	dataIno  uint64                // inode of Data, see loadShardFrames
+	idxs     []Index               // index of samples in shards[shardPos]
 	shard    int64
-	curIdx   int
-	exit     *ExitProcess
-	c        *CgroupNetStat
-	buffer   *bytes.Buffer
-	idxBuf   Index
-	zstdBuf  []byte
+	curIdx   int // position of current sample in idxs
+	lockFile *os.File
+	header   Header        // header of current data file
+	formats  []frameFormat // FormatRecord of current data file
+	// fsync policy and state, see WithSync
+	syncEvery    uint32
+	syncInterval time.Duration
+	unsynced     uint32
+	lastSync     time.Time
+	// closed when background downsample and clean is done, see maintain
+	maintainDone chan struct{}
+	buffer       *bytes.Buffer
+	idxBuf       Index
+	zstdBuf      []byte
+
+	// state of collection across samples, see WithThreads etc
+	collectors Collectors
 }
 
 func NewLocalStore(opts ...Option) (*LocalStore, error) {
 	local := &LocalStore{
 		buffer: &bytes.Buffer{},
+		cache:  make(map[int64]shardFrames),
 	}
 	for _, opt := range opts {
 		if err := opt(local); err != nil {
@@ -195,12 +281,16 @@ func NewLocalStore(opts ...Option) (*LocalStore, error) {
 	local.curIdx = -1
 
 	if local.writeOnly {
+		local.collectors.Sockets = NewSocketCollector()
 		local.encDict, _ = zstd.NewWriter(
 			nil,
 			zstd.WithLowerEncoderMem(true),
 			zstd.WithEncoderConcurrency(1),
 			zstd.WithEncoderDictRaw(0, []byte{}),
 		)
+		if local.mode == ZstdCompressWithDelta {
+			local.encDelta = newDeltaEncoder()
+		}
 
 	} else {
 		local.dec, _ = zstd.NewReader(
@@ -214,17 +304,28 @@ func NewLocalStore(opts ...Option) (*LocalStore, error) {
 			zstd.WithDecoderConcurrency(1),
 			zstd.WithDecoderDictRaw(0, []byte{}),
 		)
+		local.decDelta = newDeltaDecoder()
 	}
 
 	if local.writeOnly == true {
+		if err := local.lock(); err != nil {
+			return nil, err
+		}
+		if err := finishReplace(local.Path, local.Log); err != nil {
+			local.unlock()
+			return nil, err
+		}
 		return local, nil
 	}
 
 	// readonly mode
-	if idxs, err := getIndexFrames(local.Path); err != nil {
+	if err := checkHeaders(local.Path); err != nil {
+		return local, err
+	}
+	if shards, err := listShards(local.Path); err != nil {
 		return local, err
 	} else {
-		local.idxs = idxs
+		local.shards = shards
 	}
 
 	return local, nil
@@ -234,57 +335,163 @@ func calcshard(sec int64) int64 {
 	return sec - sec%ShardTime
 }
 
-// getIndexFrames walk path and get data from all index file
-// if no any data, return ErrNoIndexFile
-func getIndexFrames(path string) ([]Index, error) {
+// validIndexFrames parse index file b, frames at the tail which are
+// partially written or point out of data file are discarded, they are
+// left by crash or power loss during writing. discard is the number of
+// bytes discarded from b
+func validIndexFrames(b []byte, dataSize int64) (idxs []Index, discard int) {
 
-	idxFiles := []string{}
-	if ds, err := os.ReadDir(path); err == nil {
-		for _, d := range ds {
-			if d.Type().IsRegular() && strings.HasPrefix(d.Name(), "index_") {
-				idxFiles = append(idxFiles, d.Name())
-			}
+	idxs = make([]Index, len(b)/sizeIndex)
+	for i := range idxs {
+		idxs[i].Unmarshal(b[i*sizeIndex:])
+	}
+	for len(idxs) > 0 {
+		idx := idxs[len(idxs)-1]
+		if idx.CRC == crc32.ChecksumIEEE((*[32]byte)(unsafe.Pointer(&idx))[:28]) &&
+			idx.Offset >= 0 && idx.Len > 0 && idx.Offset+idx.Len <= dataSize {
+			break
 		}
-	} else {
-		return nil, err
+		idxs = idxs[:len(idxs)-1]
 	}
+	return idxs, len(b) - len(idxs)*sizeIndex
+}
 
-	idxs := []Index{}
-	for _, file := range idxFiles {
+// lock acquire lock file in path, so that only one writer append into
+// the same store. pid of writer is recorded in lock file
+func (local *LocalStore) lock() error {
+	f, err := os.OpenFile(filepath.Join(local.Path, lockFileName), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	if syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB) != nil {
+		pid, _ := io.ReadAll(f)
+		f.Close()
+		return fmt.Errorf("%w: %s is held by pid %s", ErrLocked, local.Path, strings.TrimSpace(string(pid)))
+	}
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := fmt.Fprintf(f, "%d\n", os.Getpid()); err != nil {
+		f.Close()
+		return err
+	}
+	local.lockFile = f
+	return nil
+}
 
-		if f, err := os.Open(filepath.Join(path, file)); err == nil {
-			buf := make([]byte, sizeIndex)
-			for {
-				n, err := f.Read(buf)
-				if err == io.EOF {
-					break
-				}
-				if err != nil {
-					return nil, err
-				}
-				if n != sizeIndex {
-					return nil, fmt.Errorf("request %d byte, but got %d from %s", sizeIndex, n, f.Name())
-				}
-				index := Index{}
-				index.Unmarshal(buf)
-				idxs = append(idxs, index)
-			}
+// openHeader read header of current data file, and write header into
+// it if it is new one in writeonly mode
+func (local *LocalStore) openHeader(writeonly bool) error {
+	info, err := local.Data.Stat()
+	if err != nil {
+		return err
+	}
+	if writeonly && info.Size() == 0 {
+		local.header = newHeader(local.mode)
+		b, err := local.header.Marshal()
+		if err != nil {
+			return err
+		}
+		_, err = local.Data.Write(b)
+		return err
+	}
+	if local.header, err = readHeader(local.Data); err != nil {
+		return fmt.Errorf("%s: %w", local.Data.Name(), err)
+	}
+	if err := local.header.check(local.Data.Name()); err != nil {
+		return err
+	}
+	if local.header.FormatVersion == FormatVersion && local.header.SchemaHash != SchemaHash() {
+		msg := fmt.Sprintf("schema of %s is %s, but %s is expected. format version should be bumped",
+			local.Data.Name(), local.header.SchemaHash, SchemaHash())
+		local.Log.Warn(msg)
+	}
+	return nil
+}
 
-		} else {
-			return nil, err
+// unlock clear pid in lock file and release flock by closing it
+func (local *LocalStore) unlock() {
+	if local.lockFile == nil {
+		return
+	}
+	local.lockFile.Truncate(0)
+	local.lockFile.Close()
+	local.lockFile = nil
+}
+
+// recoverShard discard partially written frame at the tail of current
+// index and data file, so that new frame is appended after last good one
+func (local *LocalStore) recoverShard() error {
+
+	b, err := os.ReadFile(local.Index.Name())
+	if err != nil {
+		return err
+	}
+	info, err := local.Data.Stat()
+	if err != nil {
+		return err
+	}
+	idxs, discard := validIndexFrames(b, info.Size())
+	if discard != 0 {
+		msg := fmt.Sprintf("discard %d bytes partial index at the tail of %s", discard, local.Index.Name())
+		local.Log.Warn(msg)
+		if err := local.Index.Truncate(int64(len(b) - discard)); err != nil {
+			return err
 		}
 	}
-	sort.Slice(idxs, func(i, j int) bool {
-		return idxs[i].TimeStamp < idxs[j].TimeStamp
-	})
-	return idxs, nil
+	end := local.header.size
+	for _, idx := range idxs {
+		end = max(end, idx.Offset+idx.Len)
+	}
+	if info.Size() > end {
+		msg := fmt.Sprintf("discard %d bytes partial data at the tail of %s", info.Size()-end, local.Data.Name())
+		local.Log.Warn(msg)
+		if err := local.Data.Truncate(end); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// written is called after one frame was written, and fsync files
+// according to policy of WithSync
+func (local *LocalStore) written() error {
+	local.unsynced++
+	if (local.syncEvery != 0 && local.unsynced >= local.syncEvery) ||
+		(local.syncInterval != 0 && time.Since(local.lastSync) >= local.syncInterval) {
+		return local.sync()
+	}
+	return nil
+}
+
+// sync fsync data file before index file, so that index never point to
+// data which is lost
+func (local *LocalStore) sync() error {
+	if local.unsynced == 0 || local.Data == nil {
+		return nil
+	}
+	if err := local.Data.Sync(); err != nil {
+		return err
+	}
+	if err := local.Index.Sync(); err != nil {
+		return err
+	}
+	local.unsynced = 0
+	local.lastSync = time.Now()
+	return nil
 }
 
 func (local *LocalStore) handelSignal() {
-	local.closeSig = make(chan os.Signal, 1)
-	signal.Notify(local.closeSig, syscall.SIGINT, syscall.SIGKILL, syscall.SIGTERM)
+	sig := make(chan os.Signal, 1)
+	local.closeSig = sig
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGKILL, syscall.SIGTERM)
 	go func() {
-		s := <-local.closeSig
+		s, ok := <-sig
+		if !ok {
+			// stopped by Close
+			return
+		}
 		local.Lock()
 		defer local.Unlock()
 		local.closed = true
@@ -307,9 +514,40 @@ func (local *LocalStore) FileStatInfo() (result string, err error) {
 	result += fmt.Sprintf("%-5s: %d files %s\n", "Index", len(suffixs), util.GetHumanSize(indexSize))
 	result += fmt.Sprintf("%-5s: %d files %s\n", "Data", len(suffixs), util.GetHumanSize(dataSize))
 
-	start := time.Unix(local.idxs[0].TimeStamp, 0).Format(time.RFC3339)
-	end := time.Unix(local.idxs[len(local.idxs)-1].TimeStamp, 0).Format(time.RFC3339)
-	result += fmt.Sprintf("%d samples from %s to %s", len(local.idxs), start, end)
+	// index of shards are read one by one, and not cached
+	count := 0
+	first, last := int64(-1), int64(-1)
+	for _, shard := range suffixs {
+		idxs, _, err := readShardFrames(local.Path, shard)
+		if err != nil {
+			return "", err
+		}
+		if len(idxs) == 0 {
+			continue
+		}
+		if first == -1 {
+			first = idxs[0].TimeStamp
+		}
+		last = idxs[len(idxs)-1].TimeStamp
+		count += len(idxs)
+	}
+	if count == 0 {
+		result += "0 samples"
+		return
+	}
+	start := time.Unix(first, 0).Format(time.RFC3339)
+	end := time.Unix(last, 0).Format(time.RFC3339)
+	result += fmt.Sprintf("%d samples from %s to %s", count, start, end)
+
+	invs, err := local.Inventories(last, last)
+	if err != nil {
+		return "", err
+	}
+	if len(invs) != 0 {
+		inv := invs[0]
+		result += fmt.Sprintf("\n\nInventory at %s\n%s", time.Unix(inv.TimeStamp, 0).Format(time.RFC3339), inv.String())
+		result = strings.TrimSuffix(result, "\n")
+	}
 	return
 }
 
@@ -361,7 +599,8 @@ func (local *LocalStore) openFile(shard int64, writeonly bool) (err error) {
 
 	flags := os.O_RDONLY
 	if writeonly == true {
-		flags = os.O_APPEND | os.O_CREATE | os.O_WRONLY
+		// header is read from data file before appending
+		flags = os.O_APPEND | os.O_CREATE | os.O_RDWR
 	}
 
 	lock := syscall.LOCK_EX | syscall.LOCK_NB
@@ -382,6 +621,14 @@ func (local *LocalStore) openFile(shard int64, writeonly bool) (err error) {
 	if writeonly == true && syscall.Flock(int(local.Data.Fd()), lock) != nil {
 		return fmt.Errorf("can not acquire lock for file %s", dataPath)
 	}
+	if err := local.openHeader(writeonly); err != nil {
+		return err
+	}
+	if writeonly == true {
+		if err := local.recoverShard(); err != nil {
+			return err
+		}
+	}
 
 	// should be new group for zstd dict compress when opening new file
 	// reset next to 0
@@ -389,22 +636,53 @@ func (local *LocalStore) openFile(shard int64, writeonly bool) (err error) {
 	// data file may already exist and take file size as initail DataOffset
 	if info, err := local.Data.Stat(); err == nil {
 		local.DataOffset = info.Size()
+		local.dataIno = inodeOf(info)
 	} else {
 		return err
 	}
+	// data file may be replaced by downsample, decoded dict and delta
+	// chain of old one can not be used
+	local.curDict = 0
+	if local.decDelta != nil {
+		local.decDelta.reset()
+	}
 
 	local.shard = shard
+	if err := local.loadFormats(shard); err != nil {
+		return err
+	}
+	if writeonly == true {
+		return local.writeFormat()
+	}
 	return nil
 }
 
 func (local *LocalStore) changeFile(shard int64, writeOnly bool) error {
 
+	if writeOnly {
+		if err := local.sync(); err != nil {
+			return err
+		}
+	}
 	local.Index.Close()
 	local.Data.Close()
 	return local.openFile(shard, writeOnly)
 }
 
 func (local *LocalStore) Close() error {
+	// writer for downsample or convert is closed every time, stop signal
+	// handling so that channel and goroutine of it are not leaked
+	if local.closeSig != nil {
+		signal.Stop(local.closeSig)
+		close(local.closeSig)
+		local.closeSig = nil
+	}
+	if local.lockFile != nil {
+		defer local.unlock()
+		if err := local.sync(); err != nil {
+			return err
+		}
+	}
 	if err := local.Index.Close(); err != nil {
 		return err
 	}
@@ -412,36 +690,11 @@ func (local *LocalStore) Close() error {
 }
 
 func (local *LocalStore) NextSample(step int, sample *Sample) error {
-	target := local.curIdx + step
-	if target < 0 {
-		return ErrOutOfRange
-	}
-	if target >= len(local.idxs) {
-		// try to read file again and check if have new data available
-		if idxs, err := getIndexFrames(local.Path); err != nil {
-			return err
-		} else {
-			local.idxs = idxs
-			if target >= len(local.idxs) {
-				return ErrOutOfRange
-			}
-		}
-	}
-
-	shard := calcshard(local.idxs[target].TimeStamp)
-
-	if shard != local.shard {
-		if err := local.changeFile(shard, false); err != nil {
-			return err
-		}
-	}
-
-	if err := local.getSample(target, sample); err != nil {
+	pos, idxs, target, err := local.locate(local.shardPos, local.curIdx+step)
+	if err != nil {
 		return err
 	}
-
-	local.curIdx = target
-	return nil
+	return local.moveTo(pos, idxs, target, sample)
 }
 
 // JumpSampleByTimeStamp get sample by specific timestamp (unix time)
@@ -449,58 +702,43 @@ func (local *LocalStore) NextSample(step int, sample *Sample) error {
 // ignore 1st sample for better handle corner case.
 func (local *LocalStore) JumpSampleByTimeStamp(timestamp int64, sample *Sample) error {
 
-	target := sort.Search(len(local.idxs), func(i int) bool {
-		return local.idxs[i].TimeStamp >= timestamp
-	})
-
-	if target >= len(local.idxs) {
-		// try to read file again and check if have new data available
-		if idxs, err := getIndexFrames(local.Path); err != nil {
-			return err
-		} else {
-			local.idxs = idxs
-			target = sort.Search(len(local.idxs), func(i int) bool {
-				return local.idxs[i].TimeStamp >= timestamp
-			})
+	pos, target, err := local.search(timestamp)
+	if err != nil {
+		return err
+	}
+	pos, idxs, target, err := local.locate(pos, target)
+	if err == ErrOutOfRange {
+		pos, idxs, target, err = local.last()
+	}
+	if err == nil && target == 0 {
+		if _, _, _, err = local.locate(pos, -1); err == ErrOutOfRange {
+			pos, idxs, target, err = local.locate(pos, 1)
 		}
 	}
-	if len(local.idxs) == 0 || len(local.idxs) == 1 {
+	if err == ErrOutOfRange {
 		local.Log.Warn("None or only one sample", slog.String("path", local.Path))
 		return ErrOutOfRange
 	}
-	if target >= len(local.idxs) {
-		target = len(local.idxs) - 1
-	}
-	if target == 0 {
-		target = 1
-	}
-
-	shard := calcshard(local.idxs[target].TimeStamp)
-
-	if shard != local.shard {
-		if err := local.changeFile(shard, false); err != nil {
-			return err
-		}
-	}
-
-	if err := local.getSample(target, sample); err != nil {
+	if err != nil {
 		return err
 	}
-
-	local.curIdx = target
-	return nil
+	return local.moveTo(pos, idxs, target, sample)
 }
 
 func (local *LocalStore) getSample(target int, sample *Sample) error {
 
 	idx := local.idxs[target]
+	mode, offset := idx.CompressMode()
+	if mode == ZstdCompressWithDelta {
+		return local.getDeltaSample(target, int(offset), sample)
+	}
+
 	buff := make([]byte, idx.Len)
 	var err error
 	if err = local.getDataBytes(idx, &buff); err != nil {
 		return err
 	}
 
-	mode, offset := idx.CompressMode()
 	if mode == ZstdCompress || (mode == ZstdCompressWithDict && offset == 0) {
 		if buff, err = local.dec.DecodeAll(buff, make([]byte, 0, len(buff))); err != nil {
 			return err
@@ -533,10 +771,56 @@ func (local *LocalStore) getSample(target int, sample *Sample) error {
 	if err := sample.Unmarshal(buff); err != nil {
 		return err
 	}
+	migrate(sample, local.formatOf(idx))
 
 	return nil
 }
 
+// getDeltaSample decode frames of delta chain from its first frame to
+// target. frames already decoded are skipped if samples are read in order
+func (local *LocalStore) getDeltaSample(target, offset int, sample *Sample) error {
+	start := target - offset
+	if start < 0 {
+		return fmt.Errorf("%w: delta chain of %+v is out of range", ErrDataCorrupt, local.idxs[target])
+	}
+	d := local.decDelta
+	from := d.pos + 1
+	if d.shard != local.shard || d.pos < start || d.pos >= target {
+		d.reset()
+		from = start
+	}
+	for i := from; i <= target; i++ {
+		idx := local.idxs[i]
+		if mode, o := idx.CompressMode(); mode != ZstdCompressWithDelta || int(o) != i-start {
+			d.reset()
+			return fmt.Errorf("%w: %+v is not in delta chain of %+v", ErrDataCorrupt, idx, local.idxs[target])
+		}
+		buff := make([]byte, idx.Len)
+		err := local.getDataBytes(idx, &buff)
+		if err == nil {
+			buff, err = local.dec.DecodeAll(buff, make([]byte, 0, len(buff)))
+		}
+		ds := deltaSample{}
+		if err == nil {
+			err = cbor.Unmarshal(buff, &ds)
+		}
+		if err == nil {
+			if i == target {
+				err = d.apply(&ds, sample)
+			} else {
+				err = d.apply(&ds, nil)
+			}
+		}
+		if err != nil {
+			d.reset()
+			return err
+		}
+		d.shard, d.pos = local.shard, i
+	}
+	migrate(sample, local.formatOf(local.idxs[target]))
+	return nil
+}
+
 func (local *LocalStore) getDataBytes(idx Index, buff *[]byte) error {
 	if idx.CRC != crc32.ChecksumIEEE((*[32]byte)(unsafe.Pointer(&idx))[:28]) {
 		return fmt.Errorf("%s timestamp %d: %w", local.Index.Name(), idx.TimeStamp, ErrIndexCorrupt)
@@ -554,7 +838,8 @@ func (local *LocalStore) getDataBytes(idx Index, buff *[]byte) error {
 }
 
 func (local *LocalStore) CollectSample(s *Sample) error {
-	return CollectSampleFromSys(s, local.exit, local.c, local.Log)
+	local.collectors.Log = local.Log
+	return CollectSampleFromSys(s, &local.collectors)
 }
 
 func (local *LocalStore) WriteSample(s *Sample) (bool, error) {
@@ -579,12 +864,33 @@ func (local *LocalStore) WriteSample(s *Sample) (bool, error) {
 	// }
 
 	local.buffer.Reset()
+	if local.mode == ZstdCompressWithDelta {
+		// first sample of file or chunk start new chain
+		if local.next == 0 || local.encDelta.frames >= local.chunk {
+			local.encDelta.reset()
+		}
+		offset := local.encDelta.frames
+		if err = local.encDelta.encode(s, local.buffer); err != nil {
+			local.encDelta.reset()
+			return newSuffix, err
+		}
+		local.lastRawBytes = local.buffer.Len()
+		local.zstdBuf = local.encDict.EncodeAll(local.buffer.Bytes(), local.zstdBuf[:0])
+		if err = local.writeFrame(s.TimeStamp, offset); err != nil {
+			// frame may be lost, next sample should not depend on it
+			local.encDelta.reset()
+		}
+		return newSuffix, err
+	}
 	if err = cbor.MarshalToBuffer(s, local.buffer); err != nil {
 		return newSuffix, err
 	}
 	marshalBytes := local.buffer.Bytes()
+	local.lastRawBytes = len(marshalBytes)
 	offset := uint32(0)
-	if local.mode == ZstdCompress {
+	if local.mode == NoCompress {
+		local.zstdBuf = append(local.zstdBuf[:0], marshalBytes...)
+	} else if local.mode == ZstdCompress {
 		local.zstdBuf = local.encDict.EncodeAll(marshalBytes, local.zstdBuf[:0])
 	} else if local.mode == ZstdCompressWithDict {
 		offset = local.next % local.chunk
@@ -609,8 +915,14 @@ func (local *LocalStore) WriteSample(s *Sample) (bool, error) {
 		}
 	}
 
+	return newSuffix, local.writeFrame(s.TimeStamp, offset)
+}
+
+// writeFrame write local.zstdBuf as sample frame to data file and its index
+func (local *LocalStore) writeFrame(timestamp int64, offset uint32) error {
+
 	if info, err := local.Data.Stat(); err != nil {
-		return newSuffix, err
+		return err
 	} else {
 		if s := info.Size(); s != local.DataOffset {
 			msg := fmt.Sprintf("Data file length mismatch, expect %d, but got %d", local.DataOffset, s)
@@ -620,32 +932,40 @@ func (local *LocalStore) WriteSample(s *Sample) (bool, error) {
 	}
 
 	local.idxBuf = Index{
-		TimeStamp: s.TimeStamp,
+		TimeStamp: timestamp,
 		Offset:    local.DataOffset,
 		Len:       int64(len(local.zstdBuf)),
 	}
 	local.idxBuf.SetCompressMode(local.mode, offset)
 
-	if _, err = local.Data.Write(local.zstdBuf); err != nil {
-		return newSuffix, err
+	if _, err := local.Data.Write(local.zstdBuf); err != nil {
+		return err
 	}
 
 	local.idxBuf.CRC = crc32.ChecksumIEEE((*[32]byte)(unsafe.Pointer(&local.idxBuf))[:28])
 
-	if _, err = local.Index.Write(local.idxBuf.Marshal()); err != nil {
-		return newSuffix, err
+	if _, err := local.Index.Write(local.idxBuf.Marshal()); err != nil {
+		return err
 	}
 
 	local.next++
 	local.lastSampleBytes = len(local.zstdBuf)
 	local.DataOffset += int64(len(local.zstdBuf))
-	return newSuffix, nil
+	return local.written()
 }
 
 type WriteOption struct {
 	Interval   time.Duration
 	RetainDay  int
 	RetainSize int64
+	// Tiers downsample old shards, see Downsample
+	Tiers []Tier
+	// TopProcess is number of processes kept in downsampled sample
+	TopProcess int
+	// Hooks are called with every collected sample, even if it was not
+	// written because of low free space. events written by hooks are
+	// dropped then
+	Hooks []func(s *Sample)
 }
 
 func (local *LocalStore) WriteLoop(opt WriteOption) error {
@@ -655,12 +975,20 @@ func (local *LocalStore) WriteLoop(opt WriteOption) error {
 		interval.String())
 	local.Log.Info(msg)
 	isSkip := 0
+	skipSince := int64(0)
+	first := true
+	inventory := Inventory{}
+	inventoryAt := int64(0)
+	writeTime := time.Duration(0) // of last sample
 	for {
 		var shouldClose bool
 		local.Lock()
 		shouldClose = local.closed
 		local.Unlock()
 		if shouldClose == true {
+			if local.maintainDone != nil {
+				<-local.maintainDone
+			}
 			local.Close()
 			return nil
 		}
@@ -676,24 +1004,61 @@ func (local *LocalStore) WriteLoop(opt WriteOption) error {
 			return err
 		}
 		if statInfo.Bavail*uint64(statInfo.Bsize) > MinimumFreeSpaceForStore {
+			local.Lock()
+			local.skipping = false
+			dropped := local.dropped
+			local.dropped = 0
+			local.Unlock()
+			events := []Event{}
+			forceInventory := first
+			if first {
+				events = local.startEvents(&s, interval)
+				first = false
+			}
 			if isSkip != 0 {
 				msg := fmt.Sprintf("resume to write sample (%d skipped)", isSkip)
 				local.Log.Info(msg)
+				events = append(events, Event{
+					TimeStamp: s.TimeStamp,
+					Kind:      EventSkipWrite,
+					Severity:  "warning",
+					Message: fmt.Sprintf("%d samples and %d events were not written since %s because free space below %s",
+						isSkip, dropped, time.Unix(skipSince, 0).Format(time.RFC3339),
+						util.GetHumanSize(MinimumFreeSpaceForStore)),
+				})
 				isSkip = 0
 			}
 
+			s.Etop.WriteTime = writeTime.Microseconds()
+			s.Etop.RawBytes = int64(local.lastRawBytes)
+			s.Etop.StoredBytes = int64(local.lastSampleBytes)
 			newSuffix, err := local.WriteSample(&s)
 			if err != nil {
 				return err
 			}
+			writeTime = time.Since(writeStart)
 			if newSuffix == true {
 				// it is time to check if clean old data or not.
-				// oldest first.
-				local.CleanOldFiles(opt)
-
+				local.maintain(opt)
+			}
+			for _, e := range events {
+				if err := local.WriteEvent(&e); err != nil {
+					msg := fmt.Sprintf("write %s event: %s", e.Kind, err)
+					local.Log.Warn(msg)
+				}
+			}
+			// every shard start with inventory, so that it can be read
+			// without reading other shards
+			if forceInventory || newSuffix || s.TimeStamp-inventoryAt >= int64(inventoryInterval/time.Second) {
+				local.recordInventory(s.TimeStamp, &inventory, forceInventory || newSuffix)
+				inventoryAt = s.TimeStamp
 			}
 		} else {
+			local.Lock()
+			local.skipping = true
+			local.Unlock()
 			if isSkip == 0 {
+				skipSince = s.TimeStamp
 				msg := fmt.Sprintf("filesystem free space %s below %s, write sample skipped",
 					util.GetHumanSize(statInfo.Bavail*uint64(statInfo.Bsize)),
 					util.GetHumanSize(MinimumFreeSpaceForStore))
@@ -701,6 +1066,9 @@ func (local *LocalStore) WriteLoop(opt WriteOption) error {
 			}
 			isSkip++
 		}
+		for _, hook := range opt.Hooks {
+			hook(&s)
+		}
 		writeEnd := time.Now()
 		collectDuration := writeEnd.Sub(start)
 		if collectDuration > 500*time.Millisecond {
@@ -720,6 +1088,99 @@ func (local *LocalStore) WriteLoop(opt WriteOption) error {
 	}
 }
 
+// maintain downsample and clean old shards in background, so that
+// collecting sample is not blocked by rewriting shards. it is skipped if
+// last one is still running, e.g the first downsample of months of data
+func (local *LocalStore) maintain(opt WriteOption) {
+	if local.maintainDone != nil {
+		select {
+		case <-local.maintainDone:
+		default:
+			local.Log.Info("last downsample and clean is still running, skip")
+			return
+		}
+	}
+	done := make(chan struct{})
+	local.maintainDone = done
+	go func() {
+		defer close(done)
+		// downsample first, so that less data is deleted by size.
+		// oldest first.
+		local.Downsample(opt)
+		local.CleanOldFiles(opt)
+	}()
+}
+
+// inventoryInterval is how often inventory is collected to detect change
+const inventoryInterval = 5 * time.Minute
+
+// recordInventory collect inventory at timestamp, and write it if force
+// or it is different from last. last is updated by written one
+func (local *LocalStore) recordInventory(timestamp int64, last *Inventory, force bool) {
+	inv := Inventory{TimeStamp: timestamp}
+	if err := CollectInventory(&inv); err != nil && force {
+		msg := fmt.Sprintf("collect inventory: %s", err)
+		local.Log.Warn(msg)
+	}
+	if !force && inv.Equal(last) {
+		return
+	}
+	if err := local.WriteInventory(&inv); err != nil {
+		msg := fmt.Sprintf("write inventory: %s", err)
+		local.Log.Warn(msg)
+		return
+	}
+	*last = inv
+}
+
+// startEvents return events when etop record start, s is the first sample.
+// reboot is detected by comparing boot time with last sample on disk.
+func (local *LocalStore) startEvents(s *Sample, interval time.Duration) []Event {
+	events := []Event{
+		{
+			TimeStamp: s.TimeStamp,
+			Kind:      EventStart,
+			Severity:  "info",
+			Message:   fmt.Sprintf("etop record started, collect sample every %s", interval),
+		},
+	}
+	last := NewSample()
+	if err := lastSample(local.Path, local.Log, &last); err != nil {
+		if err != ErrOutOfRange {
+			msg := fmt.Sprintf("get last sample: %s", err)
+			local.Log.Warn(msg)
+		}
+		return events
+	}
+	if last.BootTime != s.BootTime {
+		events = append(events, Event{
+			TimeStamp: s.TimeStamp,
+			Kind:      EventReboot,
+			Severity:  "warning",
+			Message: fmt.Sprintf("system was booted at %s, last sample was at %s",
+				time.Unix(int64(s.BootTime), 0).Format(time.RFC3339),
+				time.Unix(last.TimeStamp, 0).Format(time.RFC3339)),
+		})
+	}
+	return events
+}
+
+// lastSample get last sample from path
+func lastSample(path string, log *slog.Logger, s *Sample) error {
+	r, err := NewLocalStore(
+		WithPathAndLogger(path, log),
+	)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	pos, idxs, target, err := r.last()
+	if err != nil {
+		return err
+	}
+	return r.moveTo(pos, idxs, target, s)
+}
+
 func (local *LocalStore) CleanOldFiles(opt WriteOption) {
 	shards, _, _, err := getIndexAndDataInfo(local.Path)
 	if err != nil {
@@ -3,13 +3,16 @@ package store
 import (
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"log/slog"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"testing"
 	"time"
+	"unsafe"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -574,6 +577,27 @@ func BenchmarkWriteSample(b *testing.B) {
 
 	})
 
+	b.Run("compresswithdelta", func(b *testing.B) {
+		dir := b.TempDir()
+		if writeStore, err := NewLocalStore(
+			WithPathAndLogger(dir, util.CreateLogger(devNull, true)),
+			WithWriteOnly(ZstdCompressWithDelta, 1024),
+		); err == nil {
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				writeStore.WriteSample(&testCase)
+			}
+			writeStore.Close()
+
+			b.ReportMetric(float64(len(src)), "before/op")
+			b.ReportMetric(float64(writeStore.DataOffset/int64(b.N)), "after/op")
+		} else {
+			b.Fatalf("new writeStore: %s\n", err)
+		}
+
+	})
+
 }
 
 func getDirAndFilesName(path string) ([]string, []string) {
@@ -615,6 +639,8 @@ func TestCleanOldFilesByDays(t *testing.T) {
 		}
 		local.changeFile(shard, true)
 	}
+	// lock file of writer is kept
+	expect = append(expect, lockFileName)
 	sort.Strings(expect)
 
 	local.CleanOldFiles(WriteOption{
@@ -666,6 +692,8 @@ func TestCleanOldFilesBySize(t *testing.T) {
 			t.Fatal(err)
 		}
 	}
+	// lock file of writer is kept
+	expect = append(expect, lockFileName)
 	sort.Strings(expect)
 
 	local.CleanOldFiles(WriteOption{
@@ -678,3 +706,239 @@ func TestCleanOldFilesBySize(t *testing.T) {
 	}
 
 }
+
+func TestLockAndRecover(t *testing.T) {
+	dir := t.TempDir()
+
+	writeStore, err := NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+		WithWriteOnly(ZstdCompressWithDict, 8),
+		WithSync(2, 0),
+	)
+	if err != nil {
+		t.Fatalf("new writeStore: %s\n", err)
+	}
+	if _, err := NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+		WithWriteOnly(NoCompress, 0),
+	); !errors.Is(err, ErrLocked) {
+		t.Fatalf("got %v, but want %s", err, ErrLocked)
+	}
+
+	base := int64(1697760000)
+	for i := int64(0); i < 3; i++ {
+		s := NewSample()
+		s.TimeStamp = base + i*5
+		if _, err := writeStore.WriteSample(&s); err != nil {
+			t.Fatalf("write sample: %s\n", err)
+		}
+	}
+	if writeStore.unsynced != 1 {
+		t.Errorf("got %d unsynced frames, but want 1", writeStore.unsynced)
+	}
+	writeStore.Close()
+	if info, err := os.Stat(filepath.Join(dir, lockFileName)); err != nil || info.Size() != 0 {
+		t.Errorf("lock file should be kept without pid after close: %v", err)
+	}
+
+	// crash during writing 4th and 5th sample, index of 4th point out of
+	// data file and index of 5th is partial
+	idx := Index{TimeStamp: base + 15, Offset: 1 << 20, Len: 10}
+	idx.CRC = crc32.ChecksumIEEE((*[32]byte)(unsafe.Pointer(&idx))[:28])
+	appendFile := func(name string, b []byte) {
+		file, _ := os.OpenFile(filepath.Join(dir, name), os.O_APPEND|os.O_WRONLY, 0644)
+		file.Write(b)
+		file.Close()
+	}
+	appendFile("index_01697760000", append(idx.Marshal(), 1, 2, 3))
+	appendFile("data_01697760000", []byte{1, 2, 3})
+
+	readAll := func() []int64 {
+		readStore, err := NewLocalStore(
+			WithPathAndLogger(dir, slog.Default()),
+		)
+		if err != nil {
+			t.Fatalf("new readStore: %s\n", err)
+		}
+		defer readStore.Close()
+		got := []int64{}
+		for {
+			s := NewSample()
+			if err := readStore.NextSample(1, &s); err != nil {
+				if err != ErrOutOfRange {
+					t.Fatalf("read sample: %s\n", err)
+				}
+				return got
+			}
+			got = append(got, s.TimeStamp)
+		}
+	}
+	if got := readAll(); !cmp.Equal(got, []int64{base, base + 5, base + 10}) {
+		t.Errorf("got %v samples with partial frame", got)
+	}
+
+	// new writer discard partial frame and append after last good one
+	writeStore, err = NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+		WithWriteOnly(ZstdCompressWithDict, 8),
+	)
+	if err != nil {
+		t.Fatalf("new writeStore: %s\n", err)
+	}
+	s := NewSample()
+	s.TimeStamp = base + 15
+	if _, err := writeStore.WriteSample(&s); err != nil {
+		t.Fatalf("write sample: %s\n", err)
+	}
+	writeStore.Close()
+	if got := readAll(); !cmp.Equal(got, []int64{base, base + 5, base + 10, base + 15}) {
+		t.Errorf("got %v samples after recovery", got)
+	}
+}
+
+// writeShards write samples of n shards to dir, one sample every step
+// seconds, and return timestamps of them
+func writeShards(dir string, n int, step int64, log *slog.Logger) ([]int64, error) {
+	writeStore, err := NewLocalStore(
+		WithPathAndLogger(dir, log),
+		WithWriteOnly(ZstdCompressWithDict, 8),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer writeStore.Close()
+	timestamps := []int64{}
+	for ts := int64(0); ts < int64(n)*ShardTime; ts += step {
+		s := NewSample()
+		s.TimeStamp = ts
+		if _, err := writeStore.WriteSample(&s); err != nil {
+			return nil, err
+		}
+		timestamps = append(timestamps, ts)
+	}
+	return timestamps, nil
+}
+
+func TestCloseStopSignal(t *testing.T) {
+	dir := t.TempDir()
+	newWriter := func() {
+		w, err := NewLocalStore(
+			WithPathAndLogger(dir, slog.Default()),
+			WithWriteOnly(NoCompress, 0),
+		)
+		if err != nil {
+			t.Fatalf("new writeStore: %s\n", err)
+		}
+		w.Close()
+	}
+	// signal package start its own goroutine at first time
+	newWriter()
+	before := runtime.NumGoroutine()
+	// like writer of every downsampled shard
+	for i := 0; i < 20; i++ {
+		newWriter()
+	}
+	for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("got %d goroutines, but want at most %d", n, before)
+	}
+}
+
+func TestSampleAcrossShards(t *testing.T) {
+	dir := t.TempDir()
+	timestamps, err := writeShards(dir, 3, 3600*6, slog.Default())
+	if err != nil {
+		t.Fatalf("write shards: %s\n", err)
+	}
+
+	readStore, err := NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+	)
+	if err != nil {
+		t.Fatalf("new readStore: %s\n", err)
+	}
+	defer readStore.Close()
+	if len(readStore.shards) != 3 || len(readStore.cache) != 0 {
+		t.Fatalf("index should not be loaded: shards %v, cache %d\n", readStore.shards, len(readStore.cache))
+	}
+
+	s := NewSample()
+	for i, ts := range timestamps {
+		if err := readStore.NextSample(1, &s); err != nil || s.TimeStamp != ts {
+			t.Fatalf("next sample %d: %d, %v, want %d\n", i, s.TimeStamp, err, ts)
+		}
+	}
+	if err := readStore.NextSample(1, &s); err != ErrOutOfRange {
+		t.Fatalf("next sample after last should fail, but got: %v\n", err)
+	}
+	for i := len(timestamps) - 2; i >= 0; i-- {
+		if err := readStore.NextSample(-1, &s); err != nil || s.TimeStamp != timestamps[i] {
+			t.Fatalf("prev sample %d: %d, %v, want %d\n", i, s.TimeStamp, err, timestamps[i])
+		}
+	}
+	if err := readStore.NextSample(-1, &s); err != ErrOutOfRange {
+		t.Fatalf("prev sample before first should fail, but got: %v\n", err)
+	}
+
+	// step over a whole shard
+	if err := readStore.NextSample(len(timestamps)-1, &s); err != nil || s.TimeStamp != timestamps[len(timestamps)-1] {
+		t.Fatalf("next sample: %d, %v\n", s.TimeStamp, err)
+	}
+
+	testCases := []struct {
+		timestamp int64
+		want      int64
+	}{
+		{-100, timestamps[1]},
+		{ShardTime - 1, ShardTime},
+		{ShardTime, ShardTime},
+		{ShardTime + 1, ShardTime + 3600*6},
+		{3 * ShardTime, timestamps[len(timestamps)-1]},
+	}
+	for _, c := range testCases {
+		if err := readStore.JumpSampleByTimeStamp(c.timestamp, &s); err != nil || s.TimeStamp != c.want {
+			t.Fatalf("jump to %d: %d, %v, want %d\n", c.timestamp, s.TimeStamp, err, c.want)
+		}
+	}
+
+	if err := readStore.seek(ShardTime); err != nil {
+		t.Fatalf("seek: %s\n", err)
+	}
+	if err := readStore.NextSample(-1, &s); err != nil || s.TimeStamp != timestamps[3] {
+		t.Fatalf("prev sample after seek: %d, %v\n", s.TimeStamp, err)
+	}
+	if len(readStore.cache) > maxCachedShards {
+		t.Fatalf("%d shards are cached\n", len(readStore.cache))
+	}
+}
+
+func BenchmarkOpenStore(b *testing.B) {
+	devNull, _ := os.Open(os.DevNull)
+	log := util.CreateLogger(devNull, true)
+
+	for _, days := range []int{1, 30, 90} {
+		b.Run(fmt.Sprintf("%ddays", days), func(b *testing.B) {
+			dir := b.TempDir()
+			timestamps, err := writeShards(dir, days, 60, log)
+			if err != nil {
+				b.Fatalf("write shards: %s\n", err)
+			}
+			last := timestamps[len(timestamps)-1]
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				readStore, err := NewLocalStore(WithPathAndLogger(dir, log))
+				if err != nil {
+					b.Fatalf("new readStore: %s\n", err)
+				}
+				s := NewSample()
+				if err := readStore.JumpSampleByTimeStamp(last, &s); err != nil {
+					b.Fatalf("jump: %s\n", err)
+				}
+				readStore.Close()
+			}
+		})
+	}
+}
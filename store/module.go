@@ -0,0 +1,140 @@
+package store
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// modules which can be disabled or collected at its own interval by
+// etop record
+const (
+	ModuleLoad      = "load"
+	ModuleStat      = "stat"
+	ModuleMeminfo   = "meminfo"
+	ModuleVmstat    = "vmstat"
+	ModuleNetDev    = "netdev"
+	ModuleProtocols = "protocols"
+	ModuleSNMP      = "snmp"
+	ModuleSockets   = "sockets"
+	ModuleSoftnet   = "softnet"
+	ModuleDiskstats = "diskstats"
+	// capacity of filesystems, statfs may hang on dead nfs
+	ModuleFilesystem = "filesystem"
+	ModulePressure   = "pressure"
+	// per cpu counts are large on host with many cpus, collect them at
+	// longer interval if size matters
+	ModuleInterrupts = "interrupts"
+	ModuleSoftirqs   = "softirqs"
+	ModuleProcess    = "process"
+	ModuleCgroup     = "cgroup"
+)
+
+var Modules = []string{ModuleLoad, ModuleStat, ModuleMeminfo, ModuleVmstat,
+	ModuleNetDev, ModuleProtocols, ModuleSNMP, ModuleSockets, ModuleSoftnet, ModuleDiskstats,
+	ModuleFilesystem, ModulePressure, ModuleInterrupts, ModuleSoftirqs, ModuleProcess, ModuleCgroup}
+
+// Has return true if module was collected in s
+func (s *Sample) Has(module string) bool {
+	return !slices.Contains(s.Missing, module)
+}
+
+// Schedule decide which modules are collected in one sample. modules can
+// be disabled, or collected at longer interval than sample
+type Schedule struct {
+	enabled   map[string]bool
+	intervals map[string]int64 // seconds
+	last      map[string]int64 // timestamp of last collection
+	failed    map[string]bool  // failure is logged once until it succeed
+}
+
+// NewSchedule create schedule which collect modules, all modules if it is
+// empty. module which is not in intervals is collected in every sample.
+// stat is always collected in every sample, since boot time in it is
+// used to detect reboot
+func NewSchedule(modules []string, intervals map[string]time.Duration) (*Schedule, error) {
+	sc := &Schedule{
+		enabled:   make(map[string]bool),
+		intervals: make(map[string]int64),
+		last:      make(map[string]int64),
+		failed:    make(map[string]bool),
+	}
+	if len(modules) == 0 {
+		modules = Modules
+	}
+	for _, m := range modules {
+		if !slices.Contains(Modules, m) {
+			return nil, fmt.Errorf("unknown module %s, valid modules: %s", m, strings.Join(Modules, ","))
+		}
+		sc.enabled[m] = true
+	}
+	sc.enabled[ModuleStat] = true
+	for m, d := range intervals {
+		if !slices.Contains(Modules, m) {
+			return nil, fmt.Errorf("unknown module %s, valid modules: %s", m, strings.Join(Modules, ","))
+		}
+		if m == ModuleStat {
+			return nil, fmt.Errorf("stat is always collected in every sample")
+		}
+		sc.intervals[m] = int64(d / time.Second)
+	}
+	return sc, nil
+}
+
+// ParseModuleIntervals parse intervals like "process=5s", "cgroup=30s"
+func ParseModuleIntervals(s []string) (map[string]time.Duration, error) {
+	intervals := make(map[string]time.Duration)
+	for _, kv := range s {
+		m, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("cannot parse %s: should be MODULE=DURATION", kv)
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Second {
+			return nil, fmt.Errorf("cannot parse %s: duration should be at least 1s", kv)
+		}
+		intervals[strings.TrimSpace(m)] = d
+	}
+	return intervals, nil
+}
+
+// due return true if module should be collected at now. nil schedule
+// collect all modules. one second is tolerated since sample is not
+// generated at exact interval
+func (sc *Schedule) due(module string, now int64) bool {
+	if sc == nil {
+		return true
+	}
+	if !sc.enabled[module] {
+		return false
+	}
+	last, ok := sc.last[module]
+	return !ok || now-last >= sc.intervals[module]-1
+}
+
+// done record result of collecting module at now
+func (sc *Schedule) done(module string, now int64, err error, log *slog.Logger) {
+	if sc == nil {
+		if err != nil {
+			msg := fmt.Sprintf("collect %s: %s", module, err)
+			log.Warn(msg)
+		}
+		return
+	}
+	if err != nil {
+		if !sc.failed[module] {
+			msg := fmt.Sprintf("collect %s: %s, it is marked missing until it succeed", module, err)
+			log.Warn(msg)
+		}
+		sc.failed[module] = true
+		return
+	}
+	if sc.failed[module] {
+		msg := fmt.Sprintf("collect %s succeed again", module)
+		log.Info(msg)
+	}
+	sc.failed[module] = false
+	sc.last[module] = now
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSchedule(t *testing.T) {
+	intervals, err := ParseModuleIntervals([]string{"cgroup=30s", "protocols=1m"})
+	if err != nil {
+		t.Fatalf("parse intervals: %s", err)
+	}
+	for _, s := range []string{"cgroup", "cgroup=500ms", "cgroup=x"} {
+		if _, err := ParseModuleIntervals([]string{s}); err == nil {
+			t.Errorf("input: %q expected error but got no", s)
+		}
+	}
+	if _, err := NewSchedule([]string{"foo"}, nil); err == nil {
+		t.Errorf("unknown module should fail")
+	}
+	if _, err := NewSchedule(nil, map[string]time.Duration{ModuleStat: time.Minute}); err == nil {
+		t.Errorf("interval of stat should fail")
+	}
+
+	sc, err := NewSchedule([]string{ModuleProcess, ModuleCgroup, ModuleProtocols}, intervals)
+	if err != nil {
+		t.Fatalf("new schedule: %s", err)
+	}
+	// sample every 10s, and one second late sometimes
+	got := map[string][]int64{}
+	for _, now := range []int64{0, 10, 20, 29, 40, 50, 60, 70} {
+		for _, m := range []string{ModuleStat, ModuleMeminfo, ModuleProcess, ModuleCgroup, ModuleProtocols} {
+			if sc.due(m, now) {
+				got[m] = append(got[m], now)
+				sc.done(m, now, nil, slog.Default())
+			}
+		}
+	}
+	want := map[string][]int64{
+		ModuleStat:      {0, 10, 20, 29, 40, 50, 60, 70},
+		ModuleProcess:   {0, 10, 20, 29, 40, 50, 60, 70},
+		ModuleCgroup:    {0, 29, 60},
+		ModuleProtocols: {0, 60},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	// failed module is tried again in next sample
+	sc.done(ModuleCgroup, 90, ErrDataCorrupt, slog.Default())
+	if !sc.due(ModuleCgroup, 95) {
+		t.Errorf("failed module should be due")
+	}
+}
@@ -0,0 +1,156 @@
+package store
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/klauspost/compress/zstd"
+)
+
+// RemoteStore read samples from etop serve running at another machine.
+// It implement Store interface, so model and tui can use it
+// the same as LocalStore.
+type RemoteStore struct {
+	Host      string // host:port of etop serve
+	Log       *slog.Logger
+	client    *http.Client
+	dec       *zstd.Decoder
+	curTime   int64 // timestamp of current sample
+	hasSample bool  // false if no sample was read yet
+}
+
+func NewRemoteStore(host string, log *slog.Logger) (*RemoteStore, error) {
+	if host == "" {
+		return nil, fmt.Errorf("remote host is empty")
+	}
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	if _, err := url.Parse(host); err != nil {
+		return nil, err
+	}
+	dec, err := zstd.NewReader(
+		nil,
+		zstd.WithDecoderLowmem(true),
+		zstd.WithDecoderConcurrency(1),
+	)
+	if err != nil {
+		return nil, err
+	}
+	remote := &RemoteStore{
+		Host: strings.TrimSuffix(host, "/"),
+		Log:  log,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		dec: dec,
+	}
+	return remote, nil
+}
+
+func (remote *RemoteStore) NextSample(step int, sample *Sample) error {
+	q := url.Values{}
+	q.Set("step", strconv.Itoa(step))
+	if remote.hasSample {
+		q.Set("timestamp", strconv.FormatInt(remote.curTime, 10))
+	}
+	return remote.getSample(SamplePath, q, sample)
+}
+
+// JumpSampleByTimeStamp get sample by specific timestamp (unix time)
+// if no, search the nearest one.
+func (remote *RemoteStore) JumpSampleByTimeStamp(timestamp int64, sample *Sample) error {
+	q := url.Values{}
+	q.Set("timestamp", strconv.FormatInt(timestamp, 10))
+	return remote.getSample(JumpPath, q, sample)
+}
+
+func (remote *RemoteStore) FileStatInfo() (string, error) {
+	b, err := remote.get(StatPath, nil)
+	return string(b), err
+}
+
+// Events return events between begin and end from etop serve
+func (remote *RemoteStore) Events(begin, end int64) ([]Event, error) {
+	q := url.Values{}
+	q.Set("begin", strconv.FormatInt(begin, 10))
+	q.Set("end", strconv.FormatInt(end, 10))
+	b, err := remote.get(EventPath, q)
+	if err != nil {
+		return nil, err
+	}
+	events := []Event{}
+	if err := cbor.Unmarshal(b, &events); err != nil {
+		return nil, fmt.Errorf("%s: %w", remote.Host, err)
+	}
+	return events, nil
+}
+
+// Inventories return inventories between begin and end from etop serve
+func (remote *RemoteStore) Inventories(begin, end int64) ([]Inventory, error) {
+	q := url.Values{}
+	q.Set("begin", strconv.FormatInt(begin, 10))
+	q.Set("end", strconv.FormatInt(end, 10))
+	b, err := remote.get(InventoryPath, q)
+	if err != nil {
+		return nil, err
+	}
+	invs := []Inventory{}
+	if err := cbor.Unmarshal(b, &invs); err != nil {
+		return nil, fmt.Errorf("%s: %w", remote.Host, err)
+	}
+	return invs, nil
+}
+
+func (remote *RemoteStore) Close() error {
+	remote.dec.Close()
+	return nil
+}
+
+func (remote *RemoteStore) getSample(path string, q url.Values, sample *Sample) error {
+	b, err := remote.get(path, q)
+	if err != nil {
+		return err
+	}
+	if b, err = remote.dec.DecodeAll(b, make([]byte, 0, len(b)*4)); err != nil {
+		return fmt.Errorf("%s: %w", remote.Host, err)
+	}
+	if err := sample.Unmarshal(b); err != nil {
+		return fmt.Errorf("%s: %w", remote.Host, err)
+	}
+	remote.curTime = sample.TimeStamp
+	remote.hasSample = true
+	return nil
+}
+
+func (remote *RemoteStore) get(path string, q url.Values) ([]byte, error) {
+	u := remote.Host + path
+	if len(q) != 0 {
+		u += "?" + q.Encode()
+	}
+	resp, err := remote.client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response from %s: %w", u, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return b, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		return nil, ErrOutOfRange
+	default:
+		return nil, fmt.Errorf("%s: %s: %s", u, resp.Status, strings.TrimSpace(string(b)))
+	}
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/xixiliguo/etop/procfs"
+)
+
+func TestRemoteStore(t *testing.T) {
+	dir := t.TempDir()
+
+	base := NewSample()
+	base.TimeStamp = 1697760000
+	base.HostName = "remote"
+	base.LoadAvg = procfs.LoadAvg{Load1: 1, Load5: 5, Load15: 15}
+	base.Meminfo = procfs.Meminfo{MemTotal: 1024, MemFree: 512}
+	base.ProcSamples[1] = ProcSample{
+		ProcStat: procfs.ProcStat{
+			PID:   1,
+			Comm:  "init",
+			State: procfs.Sleeping,
+		},
+		CmdLine: "/sbin/init",
+	}
+
+	writeStore, err := NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+		WithWriteOnly(ZstdCompressWithDict, 2),
+	)
+	if err != nil {
+		t.Fatalf("new writeStore: %s\n", err)
+	}
+	want := []int64{}
+	for i := int64(0); i < 5; i++ {
+		s := base
+		s.TimeStamp = base.TimeStamp + i*5
+		if _, err := writeStore.WriteSample(&s); err != nil {
+			t.Fatalf("write sample: %s\n", err)
+		}
+		want = append(want, s.TimeStamp)
+	}
+	writeStore.Close()
+
+	serveStore, err := NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+	)
+	if err != nil {
+		t.Fatalf("new serveStore: %s\n", err)
+	}
+	srv := httptest.NewServer(NewServer(serveStore, slog.Default()))
+	defer srv.Close()
+
+	remote, err := NewRemoteStore(srv.Listener.Addr().String(), slog.Default())
+	if err != nil {
+		t.Fatalf("new remote store: %s\n", err)
+	}
+	defer remote.Close()
+
+	localStore, err := NewLocalStore(
+		WithPathAndLogger(dir, slog.Default()),
+	)
+	if err != nil {
+		t.Fatalf("new localStore: %s\n", err)
+	}
+	defer localStore.Close()
+
+	opts := []cmp.Option{
+		cmpopts.IgnoreUnexported(Sample{}, procfs.ProcStat{}),
+	}
+
+	if err := remote.NextSample(-1, &base); err != ErrOutOfRange {
+		t.Fatalf("read sample before first should fail, but got: %v\n", err)
+	}
+
+	for i, ts := range want {
+		r := NewSample()
+		l := NewSample()
+		if err := remote.NextSample(1, &r); err != nil {
+			t.Fatalf("remote next sample %d: %s\n", i, err)
+		}
+		if err := localStore.NextSample(1, &l); err != nil {
+			t.Fatalf("local next sample %d: %s\n", i, err)
+		}
+		if r.TimeStamp != ts {
+			t.Fatalf("got timestamp %d, but want %d\n", r.TimeStamp, ts)
+		}
+		if cmp.Equal(l, r, opts...) == false {
+			t.Fatalf("data should be the same\n%s\n", cmp.Diff(l, r, opts...))
+		}
+	}
+
+	s := NewSample()
+	if err := remote.NextSample(1, &s); err != ErrOutOfRange {
+		t.Fatalf("read sample after last should fail, but got: %v\n", err)
+	}
+	if err := remote.NextSample(-2, &s); err != nil || s.TimeStamp != want[2] {
+		t.Fatalf("got timestamp %d (%v), but want %d\n", s.TimeStamp, err, want[2])
+	}
+
+	// same as LocalStore, the 1st sample is ignored
+	testCases := []struct {
+		timestamp int64
+		expected  int64
+	}{
+		{want[0], want[1]},
+		{want[2] - 1, want[2]},
+		{want[3], want[3]},
+		{want[4] + 100, want[4]},
+	}
+	for _, testCase := range testCases {
+		if err := remote.JumpSampleByTimeStamp(testCase.timestamp, &s); err != nil {
+			t.Fatalf("jump to %d: %s\n", testCase.timestamp, err)
+		}
+		if s.TimeStamp != testCase.expected {
+			t.Fatalf("jump to %d: got %d, but want %d\n", testCase.timestamp, s.TimeStamp, testCase.expected)
+		}
+	}
+
+	if err := remote.NextSample(-1, &s); err != nil || s.TimeStamp != want[3] {
+		t.Fatalf("got timestamp %d (%v), but want %d\n", s.TimeStamp, err, want[3])
+	}
+
+	if _, err := remote.FileStatInfo(); err != nil {
+		t.Fatalf("stat: %s\n", err)
+	}
+}
+
+func TestServerBusy(t *testing.T) {
+	local, err := NewLocalStore(WithPathAndLogger(t.TempDir(), slog.Default()))
+	if err != nil {
+		t.Fatalf("new store: %s\n", err)
+	}
+	defer local.Close()
+	srv := NewServer(local, slog.Default())
+
+	defer func(d time.Duration) { lockTimeout = d }(lockTimeout)
+	lockTimeout = 10 * time.Millisecond
+	// other request is reading store
+	srv.sem <- struct{}{}
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("GET", StatPath, nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d, but want %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	srv.unlock()
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("GET", StatPath, nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("got status %d %s, but want %d", w.Code, w.Body, http.StatusOK)
+	}
+}
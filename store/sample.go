@@ -3,6 +3,9 @@ package store
 import (
 	"log/slog"
 	"os"
+	"os/user"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/fxamacker/cbor/v2"
@@ -19,6 +22,8 @@ type Sample struct {
 	SystemSample        // system information
 	ProcSamples  PidMap // process information
 	CgroupSample CgroupSample
+	// modules which were not collected, e.g ModuleCgroup
+	Missing []string `cbor:",omitempty"`
 }
 
 type SystemSample struct {
@@ -32,8 +37,15 @@ type SystemSample struct {
 	procfs.VmStat
 	NetDevStats procfs.NetDev
 	DiskStats   procfs.DiskStat
+	Filesystems []Filesystem `cbor:",omitempty"`
 	procfs.NetProtocolStats
+	NetSNMP      procfs.NetSNMP // counters of snmp, netstat and snmp6
+	Sockets      SocketSample
 	SoftNetStats []procfs.SoftnetStat
+	Pressure     procfs.Pressure // system-wide pressure stall information
+	Interrupts   procfs.Interrupts
+	Softirqs     procfs.Interrupts
+	Etop         EtopSample // overhead of etop itself
 }
 
 type PidMap map[int]ProcSample
@@ -54,10 +66,31 @@ type ProcSample struct {
 	procfs.ProcStat
 	procfs.ProcIO
 	procfs.ProcSchedstat
+	Status   procfs.ProcStatus
+	UserName string // name of Status.UID on recording host
+	Smaps    procfs.ProcSmapsRollup
 	CmdLine  string
 	Cgroup   string
 	EndTime  uint64
 	ExitCode uint64
+	Threads  TidMap `cbor:",omitempty"` // only top processes by cpu if enabled
+}
+
+var userNames sync.Map
+
+// lookupUserName return name of uid from user database of this host, uid
+// is returned as string if no such user
+func lookupUserName(uid uint32) string {
+	if name, ok := userNames.Load(uid); ok {
+		return name.(string)
+	}
+	id := strconv.FormatUint(uint64(uid), 10)
+	name := id
+	if u, err := user.LookupId(id); err == nil {
+		name = u.Username
+	}
+	userNames.Store(uid, name)
+	return name
 }
 
 func NewSample() Sample {
@@ -67,6 +100,7 @@ func NewSample() Sample {
 			NetDevStats:      make(procfs.NetDev),
 			DiskStats:        make(procfs.DiskStat),
 			NetProtocolStats: make(procfs.NetProtocolStats),
+			NetSNMP:          make(procfs.NetSNMP),
 		},
 		ProcSamples: make(PidMap),
 	}
@@ -78,7 +112,10 @@ func (s *Sample) Reset() {
 	clear(s.NetDevStats)
 	clear(s.DiskStats)
 	clear(s.NetProtocolStats)
+	clear(s.NetSNMP)
 	clear(s.ProcSamples)
+	s.Filesystems = nil
+	s.Missing = nil
 	return
 }
 
@@ -90,13 +127,35 @@ func (s *Sample) Unmarshal(b []byte) error {
 	return cbor.Unmarshal(b, s)
 }
 
-func CollectSampleFromSys(s *Sample, exit *ExitProcess, c *CgroupNetStat, log *slog.Logger) error {
+// Collectors keep state of collection across samples. all fields are
+// optional, nil field collect without the state
+type Collectors struct {
+	Exit       *ExitProcess         // exited processes are kept if not nil
+	CgroupNet  *CgroupNetStat       // network of cgroups
+	Threads    *ThreadCollector     // threads of top processes
+	Smaps      *SmapsCollector      // smaps_rollup of top processes
+	Sockets    *SocketCollector     // default look up owners in every sample
+	Filesystem *FilesystemCollector // default skip DefaultSkipFSTypes
+	Schedule   *Schedule            // default collect all modules
+	Log        *slog.Logger         // default slog.Default()
+}
+
+// CollectSampleFromSys collect modules which are due in cs.Schedule, nil
+// cs collect all modules without state. module which is not collected or
+// failed is recorded in s.Missing instead of failing whole sample
+func CollectSampleFromSys(s *Sample, cs *Collectors) error {
+
+	if cs == nil {
+		cs = &Collectors{}
+	}
+	log := cs.Log
+	if log == nil {
+		log = slog.Default()
+	}
 
 	//collect one sample
-	var (
-		err error
-	)
-	s.TimeStamp = time.Now().Unix()
+	start := time.Now()
+	s.TimeStamp = start.Unix()
 	u := unix.Utsname{}
 	unix.Uname(&u)
 
@@ -107,83 +166,163 @@ func CollectSampleFromSys(s *Sample, exit *ExitProcess, c *CgroupNetStat, log *s
 	s.PageSize = os.Getpagesize()
 	s.BootTimeTick = bootTimeTick
 
-	if s.LoadAvg, err = newFS.Load(); err != nil {
-		return err
+	s.Etop.ModuleTimes = make(map[string]int64)
+	collect := func(module string, fn func() error) {
+		if !cs.Schedule.due(module, s.TimeStamp) {
+			s.Missing = append(s.Missing, module)
+			return
+		}
+		moduleStart := time.Now()
+		err := fn()
+		s.Etop.ModuleTimes[module] = time.Since(moduleStart).Microseconds()
+		cs.Schedule.done(module, s.TimeStamp, err, log)
+		if err != nil {
+			s.Missing = append(s.Missing, module)
+		}
 	}
 
-	if s.Stat, err = newFS.Stat(); err != nil {
+	collect(ModuleLoad, func() (err error) {
+		s.LoadAvg, err = newFS.Load()
 		return err
-	}
+	})
 
-	if s.Meminfo, err = newFS.Meminfo(); err != nil {
+	collect(ModuleStat, func() (err error) {
+		s.Stat, err = newFS.Stat()
 		return err
-	}
+	})
 
-	if s.VmStat, err = newFS.VmStat(); err != nil {
+	collect(ModuleMeminfo, func() (err error) {
+		s.Meminfo, err = newFS.Meminfo()
 		return err
-	}
+	})
 
-	if s.NetDevStats, err = newFS.NetDev(); err != nil {
+	collect(ModuleVmstat, func() (err error) {
+		s.VmStat, err = newFS.VmStat()
 		return err
-	}
+	})
 
-	if s.NetProtocolStats, err = newFS.NetProtocols(); err != nil {
+	collect(ModuleNetDev, func() (err error) {
+		s.NetDevStats, err = newFS.NetDev()
 		return err
-	}
+	})
 
-	if s.SoftNetStats, err = newFS.NetSoftnetStat(); err != nil {
+	collect(ModuleProtocols, func() (err error) {
+		s.NetProtocolStats, err = newFS.NetProtocols()
 		return err
-	}
+	})
 
-	if s.DiskStats, err = newFS.DiskStat(); err != nil {
+	collect(ModuleSNMP, func() (err error) {
+		s.NetSNMP, err = newFS.NetSNMP()
 		return err
-	}
-
-	err = newFS.EachProc(func(proc procfs.Proc) error {
-		p := ProcSample{}
-		var err error
-		if p.ProcStat, err = proc.Stat(); err != nil {
+	})
 
-			return err
+	collect(ModuleSockets, func() error {
+		sc := cs.Sockets
+		if sc == nil {
+			sc = NewSocketCollector()
 		}
-		if p.ProcIO, err = proc.IO(); err != nil {
+		return sc.Collect(&s.Sockets, newFS)
+	})
 
-			return err
+	collect(ModuleSoftnet, func() (err error) {
+		s.SoftNetStats, err = newFS.NetSoftnetStat()
+		return err
+	})
+
+	collect(ModuleDiskstats, func() (err error) {
+		s.DiskStats, err = newFS.DiskStat()
+		return err
+	})
+
+	collect(ModuleFilesystem, func() (err error) {
+		f := cs.Filesystem
+		if f == nil {
+			f = defaultFilesystemCollector
 		}
-		if p.ProcSchedstat, err = proc.Schedstat(); err != nil {
+		s.Filesystems, err = f.Collect(newFS)
+		return err
+	})
 
+	collect(ModulePressure, func() (err error) {
+		s.Pressure, err = newFS.Pressure()
+		return err
+	})
+
+	collect(ModuleInterrupts, func() (err error) {
+		s.Interrupts, err = newFS.Interrupts()
+		return err
+	})
+
+	collect(ModuleSoftirqs, func() (err error) {
+		s.Softirqs, err = newFS.Softirqs()
+		return err
+	})
+
+	collect(ModuleProcess, func() error {
+		err := newFS.EachProc(func(proc procfs.Proc) error {
+			p := ProcSample{}
+			var err error
+			if p.ProcStat, err = proc.Stat(); err != nil {
+
+				return err
+			}
+			if p.ProcIO, err = proc.IO(); err != nil {
+
+				return err
+			}
+			if p.ProcSchedstat, err = proc.Schedstat(); err != nil {
+
+				return err
+			}
+			if p.Status, err = proc.Status(); err != nil {
+
+				return err
+			}
+			// name is resolved when recording, since data may be read on
+			// other host whose user database is different
+			p.UserName = lookupUserName(p.Status.UID)
+			if p.CmdLine, err = proc.CmdLine(); err != nil {
+
+				return err
+			}
+			if isCgroup2() {
+				if p.Cgroup, err = proc.Cgroup(); err != nil {
+					return err
+				}
+			}
+			s.ProcSamples[p.PID] = p
+			return nil
+		})
+		if err != nil {
+			clear(s.ProcSamples)
 			return err
 		}
-		if p.CmdLine, err = proc.CmdLine(); err != nil {
 
-			return err
+		if cs.Threads != nil {
+			cs.Threads.Collect(newFS, s)
 		}
-		if isCgroup2() {
-			if p.Cgroup, err = proc.Cgroup(); err != nil {
-				return err
-			}
+		if cs.Smaps != nil {
+			cs.Smaps.Collect(newFS, s)
+		}
+
+		// exited processes are kept until processes are collected
+		if cs.Exit != nil {
+			s.ProcSamples.mergeWithExitProcess(cs.Exit)
 		}
-		s.ProcSamples[p.PID] = p
 		return nil
 	})
 
-	if err != nil {
-		return err
-	}
-
-	if exit != nil {
-		s.ProcSamples.mergeWithExitProcess(exit)
-	}
-
 	// collect cgroupv2 if enabled
 	if isCgroup2() {
-
-		cgRoot := cgroupfs.NewCgroup("/", "/")
-		s.CgroupSample, err = walkCgroupNode(0, cgRoot, c)
-		if err != nil {
+		collect(ModuleCgroup, func() (err error) {
+			cgRoot := cgroupfs.NewCgroup("/", "/")
+			if s.CgroupSample, err = walkCgroupNode(0, cgRoot, cs.CgroupNet); err != nil {
+				s.CgroupSample = CgroupSample{}
+			}
 			return err
-		}
+		})
 	}
+	collectEtop(s, newFS, cs.Exit, start)
 	return nil
 }
 
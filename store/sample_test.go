@@ -1,7 +1,7 @@
 package store
 
 import (
-	"log/slog"
+	"math"
 	"os"
 	"testing"
 
@@ -107,7 +107,7 @@ func TestSampleMarshalAndUnmarshal(t *testing.T) {
 		},
 	}
 	realData := NewSample()
-	CollectSampleFromSys(&realData, nil, nil, slog.Default())
+	CollectSampleFromSys(&realData, nil)
 	testCases = append(testCases, realData)
 	for i, testCase := range testCases {
 		var b []byte
@@ -128,6 +128,90 @@ func TestSampleMarshalAndUnmarshal(t *testing.T) {
 	}
 }
 
+func TestCollectPressure(t *testing.T) {
+	if _, err := os.Stat("/proc/pressure/cpu"); err != nil {
+		t.Skipf("psi is not available: %s", err)
+	}
+	sc, err := NewSchedule([]string{ModulePressure}, nil)
+	if err != nil {
+		t.Fatalf("new schedule: %s", err)
+	}
+	s := NewSample()
+	if err := CollectSampleFromSys(&s, &Collectors{Schedule: sc}); err != nil {
+		t.Fatalf("collect sample: %s", err)
+	}
+	p := s.Pressure
+	if !s.Has(ModulePressure) || p.CPU.Some.Total == math.MaxUint64 ||
+		p.Memory.Full.Total == math.MaxUint64 || p.IO.Some.Avg10 == math.MaxFloat64 {
+		t.Errorf("got %+v", p)
+	}
+	// irq has no some line
+	if p.IRQ.Some.Total != math.MaxUint64 {
+		t.Errorf("got irq %+v, but some should be unknown", p.IRQ)
+	}
+}
+
+func TestCollectInterrupts(t *testing.T) {
+	sc, err := NewSchedule([]string{ModuleInterrupts, ModuleSoftirqs}, nil)
+	if err != nil {
+		t.Fatalf("new schedule: %s", err)
+	}
+	s := NewSample()
+	if err := CollectSampleFromSys(&s, &Collectors{Schedule: sc}); err != nil {
+		t.Fatalf("collect sample: %s", err)
+	}
+	if !s.Has(ModuleInterrupts) || !s.Has(ModuleSoftirqs) {
+		t.Fatalf("got missing %v", s.Missing)
+	}
+	for _, irqs := range []procfs.Interrupts{s.Interrupts, s.Softirqs} {
+		if len(irqs.CPUs) == 0 || len(irqs.Lines) == 0 {
+			t.Fatalf("got %+v", irqs)
+		}
+	}
+	for _, l := range s.Interrupts.Lines {
+		if l.Name == "" || len(l.Counts) == 0 || len(l.Counts) > len(s.Interrupts.CPUs) {
+			t.Errorf("got unexpected line %+v", l)
+		}
+	}
+	netRX := false
+	for _, l := range s.Softirqs.Lines {
+		if l.Name == "NET_RX" && len(l.Counts) == len(s.Softirqs.CPUs) {
+			netRX = true
+		}
+	}
+	if !netRX {
+		t.Errorf("got softirqs %+v, but want NET_RX", s.Softirqs)
+	}
+}
+
+func TestCollectNetSNMP(t *testing.T) {
+	sc, err := NewSchedule([]string{ModuleSNMP}, nil)
+	if err != nil {
+		t.Fatalf("new schedule: %s", err)
+	}
+	s := NewSample()
+	if err := CollectSampleFromSys(&s, &Collectors{Schedule: sc}); err != nil {
+		t.Fatalf("collect sample: %s", err)
+	}
+	if !s.Has(ModuleSNMP) {
+		t.Fatalf("got missing %v", s.Missing)
+	}
+	for _, k := range []string{"Ip.InReceives", "Tcp.RetransSegs", "Udp.InErrors", "TcpExt.ListenOverflows", "IpExt.InOctets"} {
+		if _, ok := s.NetSNMP[k]; !ok {
+			t.Errorf("%s should be collected", k)
+		}
+	}
+	// -1 is skipped
+	if _, ok := s.NetSNMP["Tcp.MaxConn"]; ok {
+		t.Errorf("got Tcp.MaxConn %d", s.NetSNMP["Tcp.MaxConn"])
+	}
+	if _, err := os.Stat("/proc/net/snmp6"); err == nil {
+		if _, ok := s.NetSNMP["Ip6.InReceives"]; !ok {
+			t.Errorf("Ip6.InReceives should be collected")
+		}
+	}
+}
+
 func BenchmarkSampleMarshal(b *testing.B) {
 
 	testCase := NewSample()
@@ -0,0 +1,235 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// SamplePath returns sample which is step away from sample at timestamp
+	SamplePath = "/api/v1/sample"
+	// JumpPath returns sample by JumpSampleByTimeStamp semantic
+	JumpPath = "/api/v1/jump"
+	// StatPath returns FileStatInfo of store
+	StatPath = "/api/v1/stat"
+	// EventPath returns events between begin and end
+	EventPath = "/api/v1/events"
+	// InventoryPath returns inventories between begin and end
+	InventoryPath = "/api/v1/inventories"
+
+	// SampleContentType is zstd compressed cbor of one sample
+	SampleContentType = "application/x-etop-sample+zstd"
+	// EventContentType is cbor of event array
+	EventContentType = "application/x-etop-events+cbor"
+	// InventoryContentType is cbor of inventory array
+	InventoryContentType = "application/x-etop-inventories+cbor"
+)
+
+// lockTimeout is how long a request waits for requests before it
+var lockTimeout = 10 * time.Second
+
+// Server expose LocalStore over http, so that other machine can read
+// samples from it via RemoteStore. there is no authentication, and
+// samples contain full process table.
+// LocalStore keep cursor internally, so reading store is serialized,
+// but response is written to client without lock, so that slow client
+// does not block others.
+type Server struct {
+	local *LocalStore
+	log   *slog.Logger
+	mux   *http.ServeMux
+	enc   *zstd.Encoder
+	sem   chan struct{} // held while reading local and using enc
+}
+
+func NewServer(local *LocalStore, log *slog.Logger) *Server {
+	enc, _ := zstd.NewWriter(nil,
+		zstd.WithLowerEncoderMem(true),
+		zstd.WithEncoderConcurrency(1),
+	)
+	srv := &Server{
+		local: local,
+		log:   log,
+		mux:   http.NewServeMux(),
+		enc:   enc,
+		sem:   make(chan struct{}, 1),
+	}
+	srv.mux.HandleFunc("GET "+SamplePath, srv.handleSample)
+	srv.mux.HandleFunc("GET "+JumpPath, srv.handleJump)
+	srv.mux.HandleFunc("GET "+StatPath, srv.handleStat)
+	srv.mux.HandleFunc("GET "+EventPath, srv.handleEvents)
+	srv.mux.HandleFunc("GET "+InventoryPath, srv.handleInventories)
+	return srv
+}
+
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	srv.mux.ServeHTTP(w, r)
+}
+
+// lock wait until other requests finish reading store. false is returned
+// if client is gone, or it waits longer than lockTimeout, then error is
+// replied
+func (srv *Server) lock(w http.ResponseWriter, r *http.Request) bool {
+	timer := time.NewTimer(lockTimeout)
+	defer timer.Stop()
+	select {
+	case srv.sem <- struct{}{}:
+		return true
+	case <-r.Context().Done():
+		return false
+	case <-timer.C:
+		http.Error(w, "server is busy, try again later", http.StatusServiceUnavailable)
+		return false
+	}
+}
+
+func (srv *Server) unlock() {
+	<-srv.sem
+}
+
+func (srv *Server) handleSample(w http.ResponseWriter, r *http.Request) {
+	step, err := strconv.Atoi(r.URL.Query().Get("step"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid step: %s", err), http.StatusBadRequest)
+		return
+	}
+	timestamp := int64(-1)
+	if ts := r.URL.Query().Get("timestamp"); ts != "" {
+		if timestamp, err = strconv.ParseInt(ts, 10, 64); err != nil {
+			http.Error(w, fmt.Sprintf("invalid timestamp: %s", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if !srv.lock(w, r) {
+		return
+	}
+	// no timestamp means before first sample, same as new LocalStore
+	s := NewSample()
+	err = srv.local.seek(timestamp)
+	if err == nil {
+		err = srv.local.NextSample(step, &s)
+	}
+	b, err := srv.encodeSample(err, &s)
+	srv.unlock()
+	srv.reply(w, "sample", SampleContentType, b, err)
+}
+
+func (srv *Server) handleJump(w http.ResponseWriter, r *http.Request) {
+	timestamp, err := strconv.ParseInt(r.URL.Query().Get("timestamp"), 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid timestamp: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	if !srv.lock(w, r) {
+		return
+	}
+	s := NewSample()
+	b, err := srv.encodeSample(srv.local.JumpSampleByTimeStamp(timestamp, &s), &s)
+	srv.unlock()
+	srv.reply(w, "sample", SampleContentType, b, err)
+}
+
+func (srv *Server) handleStat(w http.ResponseWriter, r *http.Request) {
+	if !srv.lock(w, r) {
+		return
+	}
+	result, err := srv.local.FileStatInfo()
+	srv.unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, result)
+}
+
+func (srv *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	begin, end, ok := parseRange(w, r)
+	if !ok {
+		return
+	}
+
+	if !srv.lock(w, r) {
+		return
+	}
+	events, err := srv.local.Events(begin, end)
+	srv.unlock()
+	var b []byte
+	if err == nil {
+		b, err = cbor.Marshal(events)
+	}
+	srv.reply(w, "events", EventContentType, b, err)
+}
+
+func (srv *Server) handleInventories(w http.ResponseWriter, r *http.Request) {
+	begin, end, ok := parseRange(w, r)
+	if !ok {
+		return
+	}
+
+	if !srv.lock(w, r) {
+		return
+	}
+	invs, err := srv.local.Inventories(begin, end)
+	srv.unlock()
+	var b []byte
+	if err == nil {
+		b, err = cbor.Marshal(invs)
+	}
+	srv.reply(w, "inventories", InventoryContentType, b, err)
+}
+
+// parseRange parse begin and end of query, bad request is replied if
+// they are invalid
+func parseRange(w http.ResponseWriter, r *http.Request) (int64, int64, bool) {
+	begin, err := strconv.ParseInt(r.URL.Query().Get("begin"), 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid begin: %s", err), http.StatusBadRequest)
+		return 0, 0, false
+	}
+	end, err := strconv.ParseInt(r.URL.Query().Get("end"), 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid end: %s", err), http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return begin, end, true
+}
+
+// encodeSample return compressed s if err of reading it is nil. it must
+// be called with srv locked, since encoder is shared
+func (srv *Server) encodeSample(err error, s *Sample) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return srv.enc.EncodeAll(b, nil), nil
+}
+
+// reply write b or err of what to client. it is called without srv
+// locked, so that slow client does not block others
+func (srv *Server) reply(w http.ResponseWriter, what string, contentType string, b []byte, err error) {
+	if errors.Is(err, ErrOutOfRange) {
+		http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	if err != nil {
+		msg := fmt.Sprintf("serve %s: %s", what, err)
+		srv.log.Warn(msg)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(b)
+}
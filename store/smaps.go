@@ -0,0 +1,57 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"sort"
+
+	"github.com/xixiliguo/etop/procfs"
+)
+
+// SmapsCollector read smaps_rollup of top N processes by rss. kernel walk
+// all mappings of the process with mmap_lock held to build it, so reading
+// it for every process is too expensive and block page faults of them
+type SmapsCollector struct {
+	top int
+}
+
+func NewSmapsCollector(top int) *SmapsCollector {
+	return &SmapsCollector{top: top}
+}
+
+// Collect fill Smaps of top processes in s. processes whose smaps_rollup
+// can not be read, e.g no permission of ptrace or kernel before 4.14, are
+// counted in s.Etop.SmapsErrors
+func (c *SmapsCollector) Collect(fs *procfs.FS, s *Sample) {
+
+	pids := []int{}
+	for pid, p := range s.ProcSamples {
+		if p.RSS != 0 {
+			pids = append(pids, pid)
+		}
+	}
+	sort.Slice(pids, func(i, j int) bool {
+		ri, rj := s.ProcSamples[pids[i]].RSS, s.ProcSamples[pids[j]].RSS
+		if ri != rj {
+			return ri > rj
+		}
+		return pids[i] < pids[j]
+	})
+	if len(pids) > c.top {
+		pids = pids[:c.top]
+	}
+
+	for _, pid := range pids {
+		smaps, err := fs.Proc(pid).SmapsRollup()
+		if err != nil {
+			// process exited is not an error
+			if !errors.Is(err, os.ErrNotExist) {
+				s.Etop.SmapsErrors++
+			}
+			continue
+		}
+		p := s.ProcSamples[pid]
+		p.Smaps = smaps
+		s.ProcSamples[pid] = p
+	}
+}
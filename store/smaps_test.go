@@ -0,0 +1,31 @@
+package store
+
+import (
+	"os"
+	"testing"
+
+	"github.com/xixiliguo/etop/procfs"
+)
+
+func TestSmapsCollector(t *testing.T) {
+	pid := os.Getpid()
+	s := NewSample()
+	s.ProcSamples[pid] = ProcSample{ProcStat: procfs.ProcStat{PID: pid, RSS: 1000}}
+	s.ProcSamples[1<<30] = ProcSample{ProcStat: procfs.ProcStat{PID: 1 << 30, RSS: 2000}}
+	s.ProcSamples[1] = ProcSample{ProcStat: procfs.ProcStat{PID: 1, RSS: 10}}
+
+	// exited process is not an error, and process out of top is not read
+	NewSmapsCollector(2).Collect(procfs.NewFS(""), &s)
+	if _, err := os.Stat("/proc/self/smaps_rollup"); err != nil {
+		t.Skipf("smaps_rollup is not available: %s", err)
+	}
+	if s.ProcSamples[pid].Smaps.Rss == 0 {
+		t.Errorf("smaps of %d should be read, but got %+v", pid, s.ProcSamples[pid].Smaps)
+	}
+	if s.ProcSamples[1].Smaps.Rss != 0 {
+		t.Errorf("smaps of process out of top should not be read")
+	}
+	if s.Etop.SmapsErrors != 0 {
+		t.Errorf("got %d smaps errors, but want 0", s.Etop.SmapsErrors)
+	}
+}
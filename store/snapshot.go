@@ -29,6 +29,7 @@ func (local *LocalStore) Snapshot(begin int64, end int64) (string, error) {
 	if err := local.JumpSampleByTimeStamp(begin, &sample); err != nil {
 		return "", err
 	}
+	first := sample.TimeStamp
 
 	for sample.TimeStamp <= end {
 		if _, err := dest.WriteSample(&sample); err != nil {
@@ -42,6 +43,27 @@ func (local *LocalStore) Snapshot(begin int64, end int64) (string, error) {
 		}
 	}
 
+	if err := local.copyRecords(dest, begin, end); err != nil {
+		return "", err
+	}
+	// inventory recorded before begin is still in effect, keep it with
+	// the first sample
+	invs, err := local.Inventories(begin, begin)
+	if err != nil {
+		return "", err
+	}
+	if len(invs) != 0 && invs[0].TimeStamp < begin {
+		invs[0].TimeStamp = first
+		if err := dest.WriteInventory(&invs[0]); err != nil {
+			return "", err
+		}
+	}
+
+	// flush files and remove lock file before archiving
+	if err := dest.Close(); err != nil {
+		return "", err
+	}
+
 	tarFileName := fmt.Sprintf("snapshot_%s_%s",
 		time.Unix(begin, 0).Format("200601021504"),
 		time.Unix(end, 0).Format("200601021504"))
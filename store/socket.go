@@ -0,0 +1,134 @@
+package store
+
+import (
+	"sort"
+
+	"github.com/xixiliguo/etop/procfs"
+)
+
+// topConnections is max number of connections recorded in one sample
+const topConnections = 20
+
+// SocketSample is summary of sockets by sock_diag. only listening
+// sockets and connections with the most queued bytes are recorded one
+// by one, since there may be millions of sockets
+type SocketSample struct {
+	TCPStates   map[string]uint64 // tcp and tcp6 sockets by state
+	UDP         uint64
+	Unix        uint64
+	Listens     []SocketEntry // tcp and unix listening sockets
+	Connections []SocketEntry // top connections by RecvQ+SendQ, queue is not empty
+}
+
+// SocketEntry is socket with its owner
+type SocketEntry struct {
+	procfs.Socket
+	Pid  int // 0 if owner is not found, e.g timewait socket
+	Comm string
+}
+
+// SocketCollector collect sockets and keep owners of socket inodes across
+// samples, so that fd of all processes are walked only when there are
+// new sockets. inode whose owner is not found, e.g kernel socket or
+// process hidden by hidepid, is not looked up again
+type SocketCollector struct {
+	owners map[uint32]socketOwner // socket inode -> owner
+}
+
+// socketOwner is owner of socket found in previous sample, pid is 0 if
+// owner is not found. start is Starttime of pid when it was checked, so
+// that a reused pid is not taken as owner
+type socketOwner struct {
+	pid   int
+	start uint64
+}
+
+func NewSocketCollector() *SocketCollector {
+	return &SocketCollector{owners: make(map[uint32]socketOwner)}
+}
+
+// Collect collect sockets of current network namespace, and find owners
+// of listening sockets and top connections by fd of processes
+func (c *SocketCollector) Collect(s *SocketSample, fs *procfs.FS) error {
+
+	*s = SocketSample{TCPStates: make(map[string]uint64)}
+	err := procfs.SockDiag(func(sock *procfs.Socket) {
+		switch sock.Proto {
+		case "tcp", "tcp6":
+			s.TCPStates[sock.State]++
+		case "udp", "udp6":
+			s.UDP++
+		case "unix":
+			s.Unix++
+		}
+		if sock.State == "LISTEN" {
+			s.Listens = append(s.Listens, SocketEntry{Socket: *sock})
+		} else if sock.RecvQ != 0 || sock.SendQ != 0 {
+			s.Connections = append(s.Connections, SocketEntry{Socket: *sock})
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(s.Connections, func(i, j int) bool {
+		ci, cj := s.Connections[i], s.Connections[j]
+		return uint64(ci.RecvQ)+uint64(ci.SendQ) > uint64(cj.RecvQ)+uint64(cj.SendQ)
+	})
+	if len(s.Connections) > topConnections {
+		s.Connections = s.Connections[:topConnections]
+	}
+
+	// only sockets of this sample are kept in cache
+	owners := make(map[uint32]socketOwner)
+	lookup := make(map[uint32]int)
+	for _, entries := range [][]SocketEntry{s.Listens, s.Connections} {
+		for _, e := range entries {
+			if e.Inode == 0 {
+				continue
+			}
+			if o, ok := c.owners[e.Inode]; ok {
+				owners[e.Inode] = o
+			} else {
+				lookup[e.Inode] = 0
+			}
+		}
+	}
+	if err := fs.SocketOwners(lookup); err != nil {
+		return err
+	}
+	for inode, pid := range lookup {
+		owners[inode] = socketOwner{pid: pid}
+	}
+	c.owners = owners
+
+	stats := make(map[int]procfs.ProcStat)
+	for _, entries := range [][]SocketEntry{s.Listens, s.Connections} {
+		for i := range entries {
+			inode := entries[i].Inode
+			o := owners[inode]
+			if o.pid == 0 {
+				continue
+			}
+			stat, ok := stats[o.pid]
+			if !ok {
+				var err error
+				if stat, err = fs.Proc(o.pid).Stat(); err != nil {
+					// owner exited, socket is inherited or passed to other
+					// process. look it up again in next sample
+					delete(c.owners, inode)
+					continue
+				}
+				stats[o.pid] = stat
+			}
+			if o.start != 0 && o.start != stat.Starttime {
+				// owner exited and its pid is reused by other process
+				delete(c.owners, inode)
+				continue
+			}
+			c.owners[inode] = socketOwner{pid: o.pid, start: stat.Starttime}
+			entries[i].Pid, entries[i].Comm = o.pid, stat.Comm
+		}
+	}
+	return nil
+}
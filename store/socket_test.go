@@ -0,0 +1,127 @@
+package store
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xixiliguo/etop/procfs"
+)
+
+func TestCollectSockets(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen: %s", err)
+	}
+	defer l.Close()
+	path := filepath.Join(t.TempDir(), "etop.sock")
+	ul, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listen unix: %s", err)
+	}
+	defer ul.Close()
+
+	// one connection is accepted with unread data, and the other is
+	// left in accept queue
+	c1, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %s", err)
+	}
+	defer c1.Close()
+	server, err := l.Accept()
+	if err != nil {
+		t.Fatalf("accept: %s", err)
+	}
+	defer server.Close()
+	c1.Write([]byte("hello"))
+	c2, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %s", err)
+	}
+	defer c2.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	s := SocketSample{}
+	sc := NewSocketCollector()
+	if err := sc.Collect(&s, procfs.NewFS("")); err != nil {
+		t.Skipf("sock_diag is not available: %s", err)
+	}
+	if s.TCPStates["ESTABLISHED"] < 4 || s.Unix == 0 {
+		t.Errorf("got tcp states %v and %d unix sockets", s.TCPStates, s.Unix)
+	}
+	listens := map[string]SocketEntry{}
+	for _, e := range s.Listens {
+		listens[e.Local] = e
+	}
+	for addr, queue := range map[string]uint32{l.Addr().String(): 1, path: 0} {
+		e, ok := listens[addr]
+		if !ok {
+			t.Errorf("listening socket %s is not found in %+v", addr, s.Listens)
+			continue
+		}
+		if e.Pid != os.Getpid() || e.Comm == "" || e.RecvQ != queue || e.SendQ == 0 {
+			t.Errorf("got listening socket %+v, but want accept queue %d", e, queue)
+		}
+	}
+	found := false
+	for _, e := range s.Connections {
+		if e.Local == server.LocalAddr().String() && e.Remote == c1.LocalAddr().String() {
+			found = true
+			if e.RecvQ != 5 || e.State != "ESTABLISHED" || e.Pid != os.Getpid() {
+				t.Errorf("got connection %+v", e)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("connection with unread data is not found in %+v", s.Connections)
+	}
+
+	// owner is taken from cache in next sample, and cache only keep
+	// sockets which are still there
+	e := listens[l.Addr().String()]
+	self, _ := procfs.NewFS("").Proc(os.Getpid()).Stat()
+	if o := sc.owners[e.Inode]; o.pid != os.Getpid() || o.start != self.Starttime {
+		t.Errorf("got owner %+v in cache, but want pid %d start %d", o, os.Getpid(), self.Starttime)
+	}
+	pid1, err := procfs.NewFS("").Proc(1).Stat()
+	if err != nil {
+		t.Skipf("stat of pid 1: %s", err)
+	}
+	sc.owners[e.Inode] = socketOwner{pid: 1, start: pid1.Starttime}
+	sc.owners[0xffffffff] = socketOwner{pid: os.Getpid()}
+	if err := sc.Collect(&s, procfs.NewFS("")); err != nil {
+		t.Fatalf("collect sockets: %s", err)
+	}
+	for _, got := range s.Listens {
+		if got.Inode == e.Inode && got.Pid != 1 {
+			t.Errorf("got listening socket %+v, but want pid 1 from cache", got)
+		}
+	}
+	if _, ok := sc.owners[0xffffffff]; ok {
+		t.Errorf("inode of closed socket is kept in cache")
+	}
+
+	// pid in cache is reused by other process
+	sc.owners[e.Inode] = socketOwner{pid: 1, start: pid1.Starttime + 1}
+	if err := sc.Collect(&s, procfs.NewFS("")); err != nil {
+		t.Fatalf("collect sockets: %s", err)
+	}
+	for _, got := range s.Listens {
+		if got.Inode == e.Inode && got.Pid != 0 {
+			t.Errorf("got listening socket %+v, but owner with other start time should be dropped", got)
+		}
+	}
+	if _, ok := sc.owners[e.Inode]; ok {
+		t.Errorf("owner with reused pid is kept in cache")
+	}
+	if err := sc.Collect(&s, procfs.NewFS("")); err != nil {
+		t.Fatalf("collect sockets: %s", err)
+	}
+	for _, got := range s.Listens {
+		if got.Inode == e.Inode && got.Pid != os.Getpid() {
+			t.Errorf("got listening socket %+v, but want owner looked up again", got)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"sort"
+
+	"github.com/xixiliguo/etop/procfs"
+)
+
+// ThreadSample represent one thread, read from /proc/<pid>/task/<tid>
+type ThreadSample struct {
+	procfs.ProcStat
+	procfs.ProcIO
+	procfs.ProcSchedstat
+}
+
+type TidMap map[int]ThreadSample
+
+type procTicks struct {
+	starttime uint64
+	ticks     uint64
+}
+
+// ThreadCollector collect threads of top N processes by cpu usage since
+// previous collection, so that storage is bounded for processes with
+// thousands of threads
+type ThreadCollector struct {
+	top  int
+	prev map[int]procTicks
+}
+
+func NewThreadCollector(top int) *ThreadCollector {
+	return &ThreadCollector{
+		top:  top,
+		prev: make(map[int]procTicks),
+	}
+}
+
+// Collect fill Threads of top processes in s, single-threaded processes
+// are skipped since thread is the same with process
+func (t *ThreadCollector) Collect(fs *procfs.FS, s *Sample) {
+
+	type usage struct {
+		pid   int
+		ticks uint64
+	}
+	usages := []usage{}
+	curr := make(map[int]procTicks, len(s.ProcSamples))
+	for pid, p := range s.ProcSamples {
+		ticks := p.UTime + p.STime
+		curr[pid] = procTicks{p.Starttime, ticks}
+		if p.NumThreads <= 1 {
+			continue
+		}
+		if old, ok := t.prev[pid]; ok && old.starttime == p.Starttime && ticks >= old.ticks {
+			ticks -= old.ticks
+		}
+		if ticks != 0 {
+			usages = append(usages, usage{pid, ticks})
+		}
+	}
+	t.prev = curr
+
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].ticks != usages[j].ticks {
+			return usages[i].ticks > usages[j].ticks
+		}
+		return usages[i].pid < usages[j].pid
+	})
+	if len(usages) > t.top {
+		usages = usages[:t.top]
+	}
+
+	for _, u := range usages {
+		threads := make(TidMap)
+		fs.Proc(u.pid).EachThread(func(thread procfs.Proc) error {
+			th := ThreadSample{}
+			var err error
+			if th.ProcStat, err = thread.Stat(); err != nil {
+				return err
+			}
+			if th.ProcIO, err = thread.IO(); err != nil {
+				return err
+			}
+			if th.ProcSchedstat, err = thread.Schedstat(); err != nil {
+				return err
+			}
+			threads[th.PID] = th
+			return nil
+		})
+		p := s.ProcSamples[u.pid]
+		p.Threads = threads
+		s.ProcSamples[u.pid] = p
+	}
+}
@@ -0,0 +1,33 @@
+package store
+
+import (
+	"os"
+	"testing"
+
+	"github.com/xixiliguo/etop/procfs"
+)
+
+func TestThreadCollector(t *testing.T) {
+	pid := os.Getpid()
+	s := NewSample()
+	s.ProcSamples[pid] = ProcSample{ProcStat: procfs.ProcStat{PID: pid, NumThreads: 4, UTime: 100}}
+	s.ProcSamples[1] = ProcSample{ProcStat: procfs.ProcStat{PID: 1, NumThreads: 1, UTime: 1000}}
+	s.ProcSamples[1<<30] = ProcSample{ProcStat: procfs.ProcStat{PID: 1 << 30, NumThreads: 4, UTime: 50}}
+
+	c := NewThreadCollector(1)
+	c.Collect(procfs.NewFS(""), &s)
+	threads := s.ProcSamples[pid].Threads
+	if _, ok := threads[pid]; !ok {
+		t.Fatalf("main thread %d should be collected, but got %v", pid, threads)
+	}
+	if s.ProcSamples[1].Threads != nil || s.ProcSamples[1<<30].Threads != nil {
+		t.Errorf("single-threaded or not top process should not be collected")
+	}
+
+	// no cpu is used since previous collection
+	s.ProcSamples[pid] = ProcSample{ProcStat: procfs.ProcStat{PID: pid, NumThreads: 4, UTime: 100}}
+	c.Collect(procfs.NewFS(""), &s)
+	if s.ProcSamples[pid].Threads != nil {
+		t.Errorf("idle process should not be collected")
+	}
+}
@@ -3,6 +3,7 @@ package tui
 import (
 	"fmt"
 	"log/slog"
+	"math"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
@@ -89,9 +90,7 @@ func (tui *TUI) initSearch() {
 				tui.log.Error(msg)
 				return
 			}
-			tui.header.Update(tui.sm)
-			tui.basic.Update(tui.sm)
-			tui.SetSource(tui.sm)
+			tui.refresh()
 
 			tui.search.form.SetText("")
 			tui.pages.HidePage("search")
@@ -159,7 +158,7 @@ func (tui *TUI) initDetails() {
 func (tui *TUI) initBase() {
 	tui.base.SetDirection(tview.FlexRow).
 		AddItem(tui.header, 3, 1, false).
-		AddItem(tui.basic, 8, 1, false).
+		AddItem(tui.basic, 9, 1, false).
 		AddItem(tui.detail, 0, 1, true).
 		AddItem(tui.status, 3, 0, false)
 }
@@ -190,9 +189,7 @@ func (tui *TUI) initPages() {
 					tui.log.Error(msg)
 					return nil
 				}
-				tui.header.Update(tui.sm)
-				tui.basic.Update(tui.sm)
-				tui.SetSource(tui.sm)
+				tui.refresh()
 
 			}
 			return nil
@@ -204,9 +201,12 @@ func (tui *TUI) initPages() {
 					tui.log.Error(msg)
 					return nil
 				}
-				tui.header.Update(tui.sm)
-				tui.basic.Update(tui.sm)
-				tui.SetSource(tui.sm)
+				tui.refresh()
+			}
+			return nil
+		} else if event.Key() == tcell.KeyRune && (event.Rune() == 'e' || event.Rune() == 'E') {
+			if tui.mode == REPORT {
+				tui.jumpEvent(event.Rune() == 'e')
 			}
 			return nil
 		} else if event.Key() == tcell.KeyRune && event.Rune() == 'b' {
@@ -225,6 +225,64 @@ func (tui *TUI) initPages() {
 	})
 }
 
+// refresh show current sample of report mode, and events happened
+// since previous sample in status bar
+func (tui *TUI) refresh() {
+	events, err := tui.sm.CurrEvents()
+	if err != nil {
+		msg := fmt.Sprintf("get events: %s", err)
+		tui.status.Clear()
+		tui.log.Error(msg)
+	}
+	tui.header.SetEvents(len(events))
+	tui.header.Update(tui.sm)
+	tui.basic.Update(tui.sm)
+	tui.SetSource(tui.sm)
+
+	if len(events) != 0 {
+		tui.status.Clear()
+		for _, e := range events {
+			fmt.Fprintf(tui.status, "%s(%s) %s %s  ", e.Kind, e.Severity,
+				e.GetRenderValue("Object", model.FieldOpt{}), e.Message)
+		}
+	}
+}
+
+// jumpEvent show sample of next event if forward, otherwise previous event
+func (tui *TUI) jumpEvent(forward bool) {
+	var (
+		events []model.Event
+		err    error
+	)
+	if forward {
+		events, err = tui.sm.Events(tui.sm.Curr.TimeStamp+1, math.MaxInt64)
+	} else {
+		events, err = tui.sm.Events(0, tui.sm.Prev.TimeStamp)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("get events: %s", err)
+		tui.status.Clear()
+		tui.log.Error(msg)
+		return
+	}
+	if len(events) == 0 {
+		tui.status.Clear()
+		tui.log.Info("no more event")
+		return
+	}
+	e := events[0]
+	if !forward {
+		e = events[len(events)-1]
+	}
+	if err := tui.sm.CollectSampleByTime(e.TimeStamp); err != nil {
+		msg := fmt.Sprintf("search sample by %s: %s", time.Unix(e.TimeStamp, 0).Format(time.RFC3339), err)
+		tui.status.Clear()
+		tui.log.Error(msg)
+		return
+	}
+	tui.refresh()
+}
+
 func (tui *TUI) SetSource(sm *model.Model) {
 	tui.cgroup.SetSource(sm)
 	tui.system.SetSource(sm)
@@ -232,15 +290,27 @@ func (tui *TUI) SetSource(sm *model.Model) {
 }
 
 func (tui *TUI) Run(path string, beginTime string) error {
-	tui.mode = REPORT
-
 	local, err := store.NewLocalStore(
 		store.WithPathAndLogger(path, tui.log),
 	)
 	if err != nil {
 		return err
 	}
-	sm, err := model.NewSysModel(local, tui.log)
+	return tui.RunWithStore(local, beginTime)
+}
+
+func (tui *TUI) RunWithRemote(host string, beginTime string) error {
+	remote, err := store.NewRemoteStore(host, tui.log)
+	if err != nil {
+		return err
+	}
+	return tui.RunWithStore(remote, beginTime)
+}
+
+func (tui *TUI) RunWithStore(s store.Store, beginTime string) error {
+	tui.mode = REPORT
+
+	sm, err := model.NewSysModel(s, tui.log)
 	if err != nil {
 		return err
 	}
@@ -255,9 +325,7 @@ func (tui *TUI) Run(path string, beginTime string) error {
 	}
 
 	tui.sm = sm
-	tui.header.Update(sm)
-	tui.basic.Update(sm)
-	tui.SetSource(sm)
+	tui.refresh()
 
 	tui.Application.SetRoot(tui.pages, true).SetFocus(tui.pages)
 	tui.process.processView.Select(1, 0)
@@ -269,13 +337,31 @@ func (tui *TUI) Run(path string, beginTime string) error {
 	return nil
 }
 
-func (tui *TUI) RunWithLive(interval time.Duration) error {
-
-	exit := store.NewExitProcess(tui.log)
-	go exit.Collect()
+// Logger return logger which write to status bar
+func (tui *TUI) Logger() *slog.Logger {
+	return tui.log
+}
 
-	c := store.NewCgroupNetStat(tui.log)
-	c.Collect()
+// RunWithLive collect sample from system every interval, threads of top
+// threadTop processes by cpu are collected if threadTop is positive, and
+// smaps_rollup of top smapsTop processes by rss if smapsTop is positive.
+// hooks are called after each sample is computed
+func (tui *TUI) RunWithLive(interval time.Duration, threadTop, smapsTop int, hooks ...func(sm *model.Model)) error {
+
+	cs := &store.Collectors{
+		Exit:      store.NewExitProcess(tui.log),
+		CgroupNet: store.NewCgroupNetStat(tui.log),
+		Sockets:   store.NewSocketCollector(),
+		Log:       tui.log,
+	}
+	go cs.Exit.Collect()
+	cs.CgroupNet.Collect()
+	if threadTop > 0 {
+		cs.Threads = store.NewThreadCollector(threadTop)
+	}
+	if smapsTop > 0 {
+		cs.Smaps = store.NewSmapsCollector(smapsTop)
+	}
 
 	tui.mode = LIVE
 	sm, err := model.NewSysModel(nil, tui.log)
@@ -289,13 +375,19 @@ func (tui *TUI) RunWithLive(interval time.Duration) error {
 		for {
 
 			start := time.Now()
-			if err := sm.CollectLiveSample(exit, c); err != nil {
+			if err := sm.CollectLiveSample(cs); err != nil {
 				return
 			}
 			tui.QueueUpdateDraw(func() {
 				tui.header.Update(sm)
 				tui.basic.Update(sm)
 				tui.SetSource(sm)
+				// first sample has nothing to compare with
+				if sm.Prev.TimeStamp != 0 {
+					for _, hook := range hooks {
+						hook(sm)
+					}
+				}
 			})
 
 			collectDuration := time.Since(start)
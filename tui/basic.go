@@ -14,12 +14,14 @@ const (
 	memBusyFmtStr  = "%-7sTotal %9s%5sFree %10s%5sAvail [red]%9s[white]%5sSlab %10s%5sBuffer %8s%5sCache %9s"
 	diskFmtStr     = "%-5s%10s|%-10s "
 	diskBusyFmtStr = "[red]%-5s%10s|%-10s[white] "
+	psiFmtStr      = "%-7sCPU %11s%5sMem %11s%5sMemFull %7s%5sIO %12s%5sIOFull %8s%5sIRQ %11s"
 )
 
 type Basic struct {
 	*tview.Flex
 	load *tview.TextView
 	proc *tview.TextView
+	psi  *tview.TextView
 	cpu  *tview.TextView
 	mem  *tview.TextView
 	disk *tview.TextView
@@ -32,6 +34,7 @@ func NewBasic() *Basic {
 		Flex: tview.NewFlex(),
 		load: tview.NewTextView(),
 		proc: tview.NewTextView(),
+		psi:  tview.NewTextView(),
 		cpu:  tview.NewTextView().SetDynamicColors(true),
 		mem:  tview.NewTextView().SetDynamicColors(true),
 		disk: tview.NewTextView().SetDynamicColors(true),
@@ -41,6 +44,7 @@ func NewBasic() *Basic {
 	basic.SetDirection(tview.FlexRow).
 		AddItem(basic.load, 1, 0, false).
 		AddItem(basic.proc, 1, 0, false).
+		AddItem(basic.psi, 1, 0, false).
 		AddItem(basic.cpu, 1, 0, false).
 		AddItem(basic.mem, 1, 0, false).
 		AddItem(basic.disk, 1, 0, false).
@@ -66,6 +70,16 @@ func (basic *Basic) Update(sm *model.Model) {
 		sm.Sys.GetRenderValue("ProcessesBlocked", model.FieldOpt{}), "",
 		sm.Sys.GetRenderValue("ClonePerSec", model.FieldOpt{}), "",
 		sm.Sys.GetRenderValue("ContextSwitchPerSec", model.FieldOpt{}))
+
+	// percent of time stalled during interval
+	basic.psi.Clear()
+	fmt.Fprintf(basic.psi, psiFmtStr,
+		"PSI", sm.Sys.GetRenderValue("CPUSomeStall", model.FieldOpt{}), "",
+		sm.Sys.GetRenderValue("MemorySomeStall", model.FieldOpt{}), "",
+		sm.Sys.GetRenderValue("MemoryFullStall", model.FieldOpt{}), "",
+		sm.Sys.GetRenderValue("IOSomeStall", model.FieldOpt{}), "",
+		sm.Sys.GetRenderValue("IOFullStall", model.FieldOpt{}), "",
+		sm.Sys.GetRenderValue("IRQFullStall", model.FieldOpt{}))
 	var fmtStr string
 	c := model.CPU{}
 	for i := 0; i < len(sm.CPUs); i++ {
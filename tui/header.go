@@ -11,6 +11,7 @@ import (
 
 type Header struct {
 	*tview.TextView
+	events int // number of events since previous sample
 }
 
 func NewHeader() *Header {
@@ -19,16 +20,34 @@ func NewHeader() *Header {
 		TextView: tview.NewTextView(),
 	}
 	header.SetBorder(true)
+	header.SetDynamicColors(true)
 	return header
 }
 
+// SetEvents set number of events since previous sample, marker is
+// shown by next Update
+func (header *Header) SetEvents(n int) {
+	header.events = n
+}
+
 func (header *Header) Update(sm *model.Model) {
 	header.Clear()
-	fmt.Fprintf(header, "%s    Elapsed: %ds    %s    Uptime: %-12s    Mode: %s %s\n",
+	fmt.Fprintf(header, "%s    Elapsed: %ds    %s    Uptime: %-12s    Mode: %s %s",
 		time.Unix(sm.Curr.TimeStamp, 0),
 		sm.Curr.TimeStamp-sm.Prev.TimeStamp,
 		sm.Curr.HostName,
 		time.Duration(sm.Curr.TimeStamp-int64(sm.Curr.BootTime))*time.Second,
 		sm.Mode,
 		version.Version)
+	// overhead of etop is not in data recorded by old version
+	if sm.Curr.Etop.CollectTime != 0 {
+		fmt.Fprintf(header, "    Etop: %s %s %s",
+			sm.Etop.GetRenderValue("CPU", model.FieldOpt{}),
+			sm.Etop.GetRenderValue("RSS", model.FieldOpt{}),
+			sm.Etop.GetRenderValue("CollectTime", model.FieldOpt{}))
+	}
+	if header.events != 0 {
+		fmt.Fprintf(header, "    [red]Events: %d[white]", header.events)
+	}
+	fmt.Fprintf(header, "\n")
 }
@@ -12,6 +12,8 @@ var content = `
 	<F1>, <Alt>+1   - switch to process view
 	<F2>, <Alt>+2   - switch to system view
 	'b'             - open dialog to search specific sample
+	'e'             - jump to next event, e.g oom kill, reboot and alerts
+	<Shift>+e       - jump to previous event
 
 process view:
 	's'             - show/hide sort view
@@ -20,13 +22,18 @@ process view:
 	'c'             - show process-level cpu info
 	'm'             - show process-level memory info
 	'd'             - show process-level disk info
+	<Shift>+h       - show/hide threads of selected process (etop record --threads)
 
 system view:
 	'c'             - show system-level cpu info
 	'm'             - show system-level memory info
 	'v'             - show system-level vm info
 	'd'             - show system-level disk info
+	'f'             - show filesystem capacity and inode usage, nearly full one is red
 	'n'             - show system-level network info
+	'o'             - show tcp/udp/ip counters, drops and errors are red
+	'i'             - show interrupts per cpu, imbalanced one is red
+	'r'             - show softirqs per cpu, imbalanced one is red
 
 	Type 'ESC' to close
 `
@@ -14,11 +14,11 @@ import (
 )
 
 var (
-	GENERALLAYOUT       = []string{"Comm", "Pid", "State", "CPU", "Mem", "ReadBytePerSec", "WriteBytePerSec"}
+	GENERALLAYOUT       = []string{"Comm", "Pid", "UserName", "State", "CPU", "Mem", "ReadBytePerSec", "WriteBytePerSec"}
 	GENERALDEFAULTORDER = "CPU"
-	CPULAYOUT           = []string{"Comm", "Pid", "CPU", "User", "System", "RunDelay", "BlkDelay", "Ppid", "NumThreads", "OnCPU", "Policy", "StartTime"}
+	CPULAYOUT           = []string{"Comm", "Pid", "CPU", "User", "System", "RunDelay", "BlkDelay", "VolCtxSwPerSec", "InvolCtxSwPerSec", "Ppid", "NumThreads", "OnCPU", "Policy", "StartTime"}
 	CPUDEFAULTORDER     = "CPU"
-	MEMLAYOUT           = []string{"Comm", "Pid", "Mem", "MajFlt", "MinFlt", "VSize", "RSS"}
+	MEMLAYOUT           = []string{"Comm", "Pid", "Mem", "MajFlt", "MinFlt", "VSize", "RSS", "RssAnon", "RssFile", "RssShmem", "Swap", "Pss", "SwapPss", "Locked", "Hugetlb"}
 	MEMDEFAULTORDER     = "Mem"
 	IOLAYOUT            = []string{"Comm", "Pid", "Disk", "ReadBytePerSec", "WriteBytePerSec", "CancelledWriteBytePerSec", "ReadCharPerSec", "WriteCharPerSec", "SyscRPerSec", "SyscWPerSec"}
 	IODEFAULTORDER      = "Disk"
@@ -47,6 +47,9 @@ type Process struct {
 	defaultOrder       string
 	visbleTree         bool
 	visbleData         []*model.Process
+	threadPid          int              // process whose threads are shown
+	rows               []model.Render   // visbleData and threads of threadPid
+	owners             []*model.Process // process of every row
 	source             *model.Model
 }
 
@@ -241,6 +244,9 @@ func (process *Process) InputHandler() func(event *tcell.EventKey, setFocus func
 				process.visbleTree = !process.visbleTree
 				process.update()
 				return
+			} else if event.Rune() == 'H' {
+				process.toggleThreads()
+				return
 			}
 
 			// fix loop if emplty data in table
@@ -257,12 +263,33 @@ func (process *Process) InputHandler() func(event *tcell.EventKey, setFocus func
 	})
 }
 
+// toggleThreads show or hide threads of selected process
+func (process *Process) toggleThreads() {
+	row, _ := process.processView.GetSelection()
+	if row < 1 || row > len(process.owners) {
+		return
+	}
+	p := process.owners[row-1]
+	if process.threadPid == p.Pid {
+		process.threadPid = 0
+		process.update()
+		return
+	}
+	if len(process.source.Threads.Iterate(p.Pid, nil, "", true)) == 0 {
+		process.status.Clear()
+		fmt.Fprintf(process.status, "no thread info of %d(%s), only top processes by cpu are collected with --threads", p.Pid, p.Comm)
+		return
+	}
+	process.threadPid = p.Pid
+	process.update()
+}
+
 func (process *Process) SelectedCgroupName() string {
 	row, _ := process.processView.GetSelection()
-	if row > len(process.visbleData) {
+	if row < 1 || row > len(process.owners) {
 		return ""
 	}
-	c := process.visbleData[row-1]
+	c := process.owners[row-1]
 	names := strings.Split(c.Cgroup, "/")
 	if len(names) > 0 {
 		return names[len(names)-1]
@@ -315,8 +342,14 @@ func (process *Process) refreshStatus() {
 	row, _ := process.processView.GetSelection()
 	process.status.Clear()
 	idx := row - 1
-	if 0 <= idx && idx < len(process.visbleData) {
-		p := process.visbleData[idx]
+	if 0 <= idx && idx < len(process.rows) {
+		if t, ok := process.rows[idx].(*model.Thread); ok {
+			process.statusText = fmt.Sprintf("thread %d(%s) of process %d(%s)",
+				t.Tid, t.Comm, t.Pid, process.owners[idx].Comm)
+			process.status.SetText(process.statusText)
+			return
+		}
+		p := process.owners[idx]
 
 		extra := "cmdline: " + p.Comm
 		exited := p.State == procfs.Dead.String() || p.State == procfs.Deadx.String()
@@ -336,11 +369,37 @@ func (process *Process) refreshStatus() {
 				p.ShowExitInfo(),
 				time.Unix(int64(p.EndTime), 0).Format(time.RFC3339))
 		}
+		if process.source != nil {
+			extra += socketsOfPid(process.source.Connections, p.Pid)
+		}
 		process.statusText = extra
 		process.status.SetText(process.statusText)
 	}
 }
 
+// socketsOfPid describe listening sockets and queued connections owned
+// by pid, at most maxSockets of them
+func socketsOfPid(cs model.ConnectionSlice, pid int) string {
+	const maxSockets = 5
+	conns := cs.OfPid(pid)
+	if len(conns) == 0 {
+		return ""
+	}
+	extra := " sockets:"
+	for i, c := range conns {
+		if i == maxSockets {
+			extra += fmt.Sprintf(" and %d more", len(conns)-maxSockets)
+			break
+		}
+		if c.State == "LISTEN" {
+			extra += fmt.Sprintf(" [%s listen %s accept %d/%d]", c.Proto, c.Local, c.RecvQ, c.SendQ)
+		} else {
+			extra += fmt.Sprintf(" [%s %s->%s recvq %d sendq %d]", c.Proto, c.Local, c.Remote, c.RecvQ, c.SendQ)
+		}
+	}
+	return extra
+}
+
 func (process *Process) update() {
 	row, column := process.processView.GetOffset()
 	process.processView.Clear()
@@ -356,6 +415,20 @@ func (process *Process) update() {
 		process.visbleData = process.source.Processes.IterateTree(0, 1, process.searchprogram, process.sortField, process.descOrder)
 		process.visbleData = append(process.visbleData, process.source.Processes.IterateTree(0, 2, process.searchprogram, process.sortField, process.descOrder)...)
 	}
+	process.rows = process.rows[:0]
+	process.owners = process.owners[:0]
+	for _, p := range process.visbleData {
+		process.rows = append(process.rows, p)
+		process.owners = append(process.owners, p)
+		if p.Pid != process.threadPid {
+			continue
+		}
+		for _, t := range process.source.Threads.Iterate(p.Pid, nil, process.sortField, process.descOrder) {
+			t.Level = p.Level + 1
+			process.rows = append(process.rows, t)
+			process.owners = append(process.owners, p)
+		}
+	}
 
 	for i, col := range process.visibleColumns {
 		text := process.visibleColumnsText[i]
@@ -369,20 +442,24 @@ func (process *Process) update() {
 		}
 		process.processView.SetCell(0, i, tview.NewTableCell(text+orderFlag).SetTextColor(tcell.ColorTeal).SetSelectable(false))
 	}
-	for r := 0; r < len(process.visbleData); r++ {
+	for r := 0; r < len(process.rows); r++ {
+		_, isThread := process.rows[r].(*model.Thread)
 		for i, col := range process.visibleColumns {
 			width := 0
 			if col == "Comm" {
-				if process.visbleTree == false {
+				if process.visbleTree == false && process.threadPid == 0 {
 					width = 16
 				} else {
 					width = 32
 				}
 
 			}
+			if isThread && col == "Pid" {
+				col = "Tid"
+			}
 			process.processView.SetCell(r+1,
 				i,
-				tview.NewTableCell(process.visbleData[r].
+				tview.NewTableCell(process.rows[r].
 					GetRenderValue(col, model.FieldOpt{FixWidth: true})).
 					SetExpansion(1).
 					SetAlign(tview.AlignLeft).
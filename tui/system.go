@@ -2,6 +2,8 @@ package tui
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
@@ -12,6 +14,11 @@ var (
 	CPUBusy  float64 = 90
 	MemBusy  float64 = 90
 	DiskBusy float64 = 90
+	FSFull   float64 = 90
+	// interrupt is imbalanced if one cpu handle more than IRQImbalance
+	// percent of it, and it is more than IRQBusy per second
+	IRQImbalance float64 = 90
+	IRQBusy      float64 = 100
 )
 
 type System struct {
@@ -27,7 +34,11 @@ type System struct {
 	vm               *tview.Table
 	diskVisbleData   []*model.Disk
 	disk             *tview.Table
+	fs               *tview.Table
 	net              *tview.Table
+	snmp             *tview.Table
+	irq              *tview.Table
+	softirq          *tview.Table
 	source           *model.Model
 }
 
@@ -42,7 +53,11 @@ func NewSystem(status *tview.TextView) *System {
 		mem:     tview.NewTable().SetFixed(1, 1).SetSelectable(true, false),
 		vm:      tview.NewTable().SetFixed(1, 1).SetSelectable(true, false),
 		disk:    tview.NewTable().SetFixed(1, 1).SetSelectable(true, false),
+		fs:      tview.NewTable().SetFixed(1, 1).SetSelectable(true, false),
 		net:     tview.NewTable().SetFixed(1, 1).SetSelectable(true, false),
+		snmp:    tview.NewTable().SetFixed(1, 1).SetSelectable(true, false),
+		irq:     tview.NewTable().SetFixed(1, 1).SetSelectable(true, false),
+		softirq: tview.NewTable().SetFixed(1, 1).SetSelectable(true, false),
 	}
 
 	system.disk.SetSelectionChangedFunc(func(row int, column int) {
@@ -62,26 +77,38 @@ func NewSystem(status *tview.TextView) *System {
 		AddPage("Mem", system.mem, true, false).
 		AddPage("Vm", system.vm, true, false).
 		AddPage("Disk", system.disk, true, false).
-		AddPage("Net", system.net, true, false)
+		AddPage("FS", system.fs, true, false).
+		AddPage("Net", system.net, true, false).
+		AddPage("Proto", system.snmp, true, false).
+		AddPage("Irq", system.irq, true, false).
+		AddPage("SoftIRQ", system.softirq, true, false)
 
 	system.SetDirection(tview.FlexRow).
 		AddItem(system.header, 1, 0, false).
 		AddItem(system.content, 0, 1, true)
 
-	system.regions = []string{"c", "m", "v", "d", "n"}
+	system.regions = []string{"c", "m", "v", "d", "f", "n", "o", "i", "r"}
 	system.regionToPage = map[string]string{
 		"c": "CPU",
 		"m": "Mem",
 		"v": "Vm",
 		"d": "Disk",
+		"f": "FS",
 		"n": "Net",
+		"o": "Proto",
+		"i": "Irq",
+		"r": "SoftIRQ",
 	}
-	fmt.Fprintf(system.header, `["%s"]%s[""]  ["%s"]%s[""]  ["%s"]%s[""]  ["%s"]%s[""]  ["%s"]%s[""]`,
+	fmt.Fprintf(system.header, `["%s"]%s[""]  ["%s"]%s[""]  ["%s"]%s[""]  ["%s"]%s[""]  ["%s"]%s[""]  ["%s"]%s[""]  ["%s"]%s[""]  ["%s"]%s[""]  ["%s"]%s[""]`,
 		"c", "CPU",
 		"m", "Mem",
 		"v", "Vm",
 		"d", "Disk",
-		"n", "Net")
+		"f", "FS",
+		"n", "Net",
+		"o", "Proto",
+		"i", "Irq",
+		"r", "SoftIRQ")
 	system.header.SetRegions(true).Highlight("c")
 
 	return system
@@ -93,7 +120,11 @@ func (system *System) SetSource(source *model.Model) {
 	system.UpdateMEMInfo()
 	system.UpdateVMInfo()
 	system.UpdateDiskInfo()
+	system.UpdateFSInfo()
 	system.UpdateNetInfo()
+	system.UpdateSNMPInfo()
+	system.UpdateIRQInfo(system.irq, system.source.Interrupts, []string{"Name", "PerSec", "MaxCPU", "MaxShare", "Device"})
+	system.UpdateIRQInfo(system.softirq, system.source.Softirqs, model.DefaultSoftirqFields)
 }
 
 func (system *System) UpdateCPUInfo() {
@@ -221,6 +252,35 @@ func (system *System) UpdateDiskInfo() {
 
 }
 
+// UpdateFSInfo show filesystems, the one which is nearly full is red
+func (system *System) UpdateFSInfo() {
+	system.fs.Clear()
+	system.fs.SetOffset(0, 0)
+
+	visbleCols := model.DefaultFilesystemFields
+	f := model.Filesystem{}
+	for i, col := range visbleCols {
+		text := f.DefaultConfig(col).Name
+		system.fs.SetCell(0, i, tview.NewTableCell(text).SetTextColor(tcell.ColorTeal))
+	}
+
+	for r, f := range system.source.Filesystems {
+		color := tcell.ColorWhite
+		if f.UsedPercent >= FSFull || (f.Inodes != 0 && f.InodesPercent >= FSFull) {
+			color = tcell.ColorRed
+		}
+		for i, col := range visbleCols {
+			system.fs.SetCell(r+1,
+				i,
+				tview.NewTableCell(f.GetRenderValue(col, model.FieldOpt{})).
+					SetTextColor(color).
+					SetExpansion(1).
+					SetAlign(tview.AlignLeft))
+		}
+	}
+
+}
+
 func (system *System) UpdateNetInfo() {
 	system.net.Clear()
 	system.net.SetOffset(0, 0)
@@ -247,6 +307,78 @@ func (system *System) UpdateNetInfo() {
 
 }
 
+func (system *System) UpdateSNMPInfo() {
+	system.snmp.Clear()
+	system.snmp.SetOffset(0, 0)
+
+	items := model.DefaultNetSNMPFields
+	for i, v := range []string{"Field", "Value"} {
+		system.snmp.SetCell(0, i, tview.NewTableCell(v).SetTextColor(tcell.ColorTeal))
+	}
+
+	for i, item := range items {
+		color := tcell.ColorWhite
+		// drops and errors should be zero
+		if r := system.source.NetSNMP.Rates[item]; r > 0 {
+			for _, bad := range []string{"Err", "Drop", "Overflow", "Fail"} {
+				if strings.Contains(item, bad) {
+					color = tcell.ColorRed
+				}
+			}
+		}
+		system.snmp.SetCell(i+1,
+			0,
+			tview.NewTableCell(item).
+				SetExpansion(0).
+				SetAlign(tview.AlignLeft))
+		system.snmp.SetCell(i+1,
+			1,
+			tview.NewTableCell(system.source.NetSNMP.GetRenderValue(item, model.FieldOpt{})).
+				SetTextColor(color).
+				SetExpansion(0).
+				SetAlign(tview.AlignRight))
+	}
+
+}
+
+// UpdateIRQInfo show interrupts order by rate, followed by rate of every
+// cpu. imbalanced interrupt is red and its busiest cpu is yellow
+func (system *System) UpdateIRQInfo(table *tview.Table, irqs model.InterruptSlice, fields []string) {
+	table.Clear()
+	table.SetOffset(0, 0)
+
+	visbleCols := slices.Clone(fields)
+	if len(irqs) != 0 {
+		for _, cpu := range irqs[0].CPUs {
+			visbleCols = append(visbleCols, fmt.Sprintf("CPU%d", cpu))
+		}
+	}
+	irq := model.Interrupt{}
+	for i, col := range visbleCols {
+		text := irq.DefaultConfig(col).Name
+		table.SetCell(0, i, tview.NewTableCell(text).SetTextColor(tcell.ColorTeal))
+	}
+
+	for r, irq := range irqs.Iterate() {
+		imbalanced := len(irq.CPUs) > 1 && irq.PerSec >= IRQBusy && irq.MaxShare >= IRQImbalance
+		for i, col := range visbleCols {
+			color := tcell.ColorWhite
+			if imbalanced {
+				color = tcell.ColorRed
+				if col == fmt.Sprintf("CPU%d", irq.MaxCPU) {
+					color = tcell.ColorYellow
+				}
+			}
+			table.SetCell(r+1,
+				i,
+				tview.NewTableCell(irq.GetRenderValue(col, model.FieldOpt{})).
+					SetTextColor(color).
+					SetExpansion(1).
+					SetAlign(tview.AlignLeft))
+		}
+	}
+}
+
 func (system *System) setRegionAndSwitchPage(region string) {
 	for i, r := range system.regions {
 		if r == region {
@@ -260,7 +392,7 @@ func (system *System) setRegionAndSwitchPage(region string) {
 func (system *System) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
 	return system.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
 
-		if k := event.Rune(); k == 'c' || k == 'm' || k == 'v' || k == 'd' || k == 'n' {
+		if k := event.Rune(); k == 'c' || k == 'm' || k == 'v' || k == 'd' || k == 'f' || k == 'n' || k == 'o' || k == 'i' || k == 'r' {
 			s := string(k)
 			system.setRegionAndSwitchPage(s)
 			return
@@ -32,6 +32,20 @@ func GetHumanSize[T int | int64 | uint64 | uint | uint32 | float64](size T) stri
 
 func ConvertToUnixTime(s string) (timeStamp int64, err error) {
 
+	for _, day := range []struct {
+		prefix string
+		offset int
+	}{{"today ", 0}, {"yesterday ", -1}} {
+		if rest, ok := strings.CutPrefix(s, day.prefix); ok {
+			t, err := time.ParseInLocation("15:04", strings.TrimSpace(rest), time.Local)
+			if err != nil {
+				return timeStamp, fmt.Errorf("cannot parse %s: not support format", s)
+			}
+			y, m, d := time.Now().Date()
+			return time.Date(y, m, d+day.offset, t.Hour(), t.Minute(), 0, 0, time.Local).Unix(), nil
+		}
+	}
+
 	if d, err := time.ParseDuration(strings.TrimSpace(s)); err == nil {
 		return time.Now().Add(-d).Unix(), nil
 	}
@@ -52,6 +66,39 @@ func ConvertToUnixTime(s string) (timeStamp int64, err error) {
 	return timeStamp, fmt.Errorf("cannot parse %s: not support format", s)
 }
 
+// ParseTimeWindow parse window like "10:00+15m" or "yesterday 10:00+15m",
+// which is start time (same format with ConvertToUnixTime) and duration
+func ParseTimeWindow(s string) (begin int64, end int64, err error) {
+	i := strings.LastIndex(s, "+")
+	if i == -1 {
+		return 0, 0, fmt.Errorf("cannot parse window %s: should be TIME+DURATION", s)
+	}
+	if begin, err = ConvertToUnixTime(strings.TrimSpace(s[:i])); err != nil {
+		return 0, 0, err
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(s[i+1:]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot parse window %s: %w", s, err)
+	}
+	if d <= 0 {
+		return 0, 0, fmt.Errorf("cannot parse window %s: duration should be positive", s)
+	}
+	return begin, begin + int64(d/time.Second), nil
+}
+
+// ParseDuration is same with time.ParseDuration, but also accept days
+// like "30d"
+func ParseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, fmt.Errorf("cannot parse duration %s: %w", s, err)
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(s)
+}
+
 func ExtractFileFromTar(tarFileName string) (string, error) {
 	f, err := os.Open(tarFileName)
 	if err != nil {
@@ -104,6 +104,21 @@ func TestConvertToTime(t *testing.T) {
 			shouldError: false,
 			expected:    time.Date(time.Now().Year(), 1, 1, 22, 23, 0, 0, time.Local).Unix(),
 		},
+		{
+			intput:      "yesterday 10:00",
+			shouldError: false,
+			expected:    time.Date(y, m, d-1, 10, 0, 0, 0, time.Local).Unix(),
+		},
+		{
+			intput:      "today 10:00",
+			shouldError: false,
+			expected:    time.Date(y, m, d, 10, 0, 0, 0, time.Local).Unix(),
+		},
+		{
+			intput:      "yesterday",
+			shouldError: true,
+			expected:    0,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -123,3 +138,42 @@ func TestConvertToTime(t *testing.T) {
 
 	}
 }
+
+func TestParseTimeWindow(t *testing.T) {
+	y, m, d := time.Now().Date()
+	begin, end, err := ParseTimeWindow("yesterday 10:00+15m")
+	if err != nil {
+		t.Fatalf("parse window: %s", err)
+	}
+	want := time.Date(y, m, d-1, 10, 0, 0, 0, time.Local).Unix()
+	if begin != want || end != want+900 {
+		t.Errorf("got %d-%d, but want %d-%d", begin, end, want, want+900)
+	}
+	for _, s := range []string{"10:00", "10:00+", "10:00+-5m", "abc+5m"} {
+		if _, _, err := ParseTimeWindow(s); err == nil {
+			t.Errorf("input: %q expected error but got no", s)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	testCases := []struct {
+		input string
+		want  time.Duration
+	}{
+		{"30d", 30 * 24 * time.Hour},
+		{"0.5d", 12 * time.Hour},
+		{"10m", 10 * time.Minute},
+	}
+	for _, tc := range testCases {
+		got, err := ParseDuration(tc.input)
+		if err != nil || got != tc.want {
+			t.Errorf("input: %q got %s %v, but want %s", tc.input, got, err, tc.want)
+		}
+	}
+	for _, s := range []string{"d", "xd", "10"} {
+		if _, err := ParseDuration(s); err == nil {
+			t.Errorf("input: %q expected error but got no", s)
+		}
+	}
+}